@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"ai-agent-character-demo/backend/pkg/config"
+	"ai-agent-character-demo/backend/pkg/prompt"
 	"ai-agent-character-demo/backend/pkg/ws"
 	"errors"
 )
@@ -136,6 +138,42 @@ func (c *AI_Layer2Client) GenerateResponse(ctx context.Context, req ResponseRequ
 	return llm2Resp.Response, nil
 }
 
+// Reply generates a character's reply: LLM1 builds the persona context for
+// the message, then LLM2 answers with it and the conversation history
+func (c *AI_Layer2Client) Reply(ctx context.Context, character *ws.Character, userMessage string, history []ws.ChatMessage) (string, error) {
+	// Build character details map for LLM1
+	characterDetails := map[string]interface{}{
+		"id":               character.ID,
+		"name":             character.Name,
+		"description":      character.Description,
+		"personality":      character.Personality,
+		"background":       character.Background,
+		"scenario":         character.Scenario,
+		"traits":           character.Traits,
+		"goals":            character.Goals,
+		"fears":            character.Fears,
+		"relationships":    character.Relationships,
+		"example_dialogue": character.ExampleDialogue,
+		"voice_type":       character.VoiceType,
+		"system_prompt":    prompt.For(character),
+		"prompt_version":   character.PromptVersion,
+	}
+	// TODO: Pass session ID if available
+	contextResp, err := c.GenerateContext(ctx, ContextRequest{
+		UserInput:        userMessage,
+		CharacterDetails: characterDetails,
+	})
+	if err != nil {
+		return "", fmt.Errorf("context gen failed: %w", err)
+	}
+	return c.GenerateResponse(ctx, ResponseRequest{
+		CharacterID: character.ID,
+		Context:     contextResp.Context,
+		Message:     userMessage,
+		History:     history,
+	})
+}
+
 // Stub for TextToSpeech
 func (c *AI_Layer2Client) TextToSpeech(ctx context.Context, text string, voiceType string) ([]byte, error) {
 	log.Printf("[AI_Layer2Client] TextToSpeech called with text: %s, voiceType: %s", text, voiceType)
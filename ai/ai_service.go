@@ -1,6 +1,7 @@
 package ai
 
 import (
+	"ai-agent-character-demo/backend/pkg/prompt"
 	"ai-agent-character-demo/backend/pkg/ws"
 	"bytes"
 	"context"
@@ -83,12 +84,7 @@ type openAIResponse struct {
 }
 
 func (s *AIService) generateResponseOpenAI(character *ws.Character, userMessage string, conversationHistory []ws.ChatMessage) (string, error) {
-	systemPrompt := fmt.Sprintf(
-		"You are %s. %s Your personality traits are: %s. Respond in character, being concise and engaging.",
-		character.Name,
-		character.Description,
-		character.Personality,
-	)
+	systemPrompt := prompt.For(character)
 
 	messages := []message{
 		{Role: "system", Content: systemPrompt},
@@ -97,8 +93,11 @@ func (s *AIService) generateResponseOpenAI(character *ws.Character, userMessage
 	// Add conversation history
 	for _, msg := range conversationHistory {
 		role := "assistant"
-		if msg.Sender == "user" {
+		switch msg.Sender {
+		case "user":
 			role = "user"
+		case ws.SenderSystem:
+			role = "system"
 		}
 		messages = append(messages, message{
 			Role:    role,
@@ -162,12 +161,7 @@ func (s *AIService) generateResponseOpenAI(character *ws.Character, userMessage
 }
 
 func (s *AIService) generateResponseLocal(character *ws.Character, userMessage string, conversationHistory []ws.ChatMessage) (string, error) {
-	systemPrompt := fmt.Sprintf(
-		"You are %s. %s Your personality traits are: %s. Respond in character, being concise and engaging.",
-		character.Name,
-		character.Description,
-		character.Personality,
-	)
+	systemPrompt := prompt.For(character)
 
 	type localModelRequest struct {
 		SystemPrompt string      `json:"system_prompt"`
@@ -179,8 +173,11 @@ func (s *AIService) generateResponseLocal(character *ws.Character, userMessage s
 	history := []message{}
 	for _, msg := range conversationHistory {
 		role := "assistant"
-		if msg.Sender == "user" {
+		switch msg.Sender {
+		case "user":
 			role = "user"
+		case ws.SenderSystem:
+			role = "system"
 		}
 		history = append(history, message{
 			Role:    role,
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"ai-agent-character-demo/backend/internal/service"
+	"ai-agent-character-demo/backend/pkg/config"
+	"ai-agent-character-demo/backend/pkg/fixtures"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Define command line flags
+	setPtr := flag.String("set", "dev", "Fixture set to load (dev, staging or demo)")
+	dirPtr := flag.String("dir", "", "Directory of fixture sets to read instead of the built-in ones")
+	listPtr := flag.Bool("list", false, "List the available fixture sets")
+	flag.Parse()
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	source := fixtures.Source(*dirPtr)
+
+	if *listPtr {
+		sets, err := fixtures.Sets(source)
+		if err != nil {
+			log.Fatalf("Failed to list fixture sets: %v", err)
+		}
+		fmt.Println(strings.Join(sets, "\n"))
+		return
+	}
+
+	// Check the fixtures before touching the database
+	set, err := fixtures.Load(source, *setPtr)
+	if err != nil {
+		log.Fatalf("Failed to read fixtures: %v", err)
+	}
+
+	db, err := config.NewDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	seeder := service.NewSeedService(db)
+	if err := seeder.Migrate(); err != nil {
+		log.Fatalf("Failed to prepare database: %v", err)
+	}
+
+	result, err := seeder.Seed(set)
+	if result != nil {
+		output, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(output))
+	}
+	if err != nil {
+		log.Printf("Seeding stopped: %v", err)
+		os.Exit(1)
+	}
+}
@@ -10,8 +10,11 @@ import (
 	"time"
 
 	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/internal/service"
 	"ai-agent-character-demo/backend/pkg/config"
 	"ai-agent-character-demo/backend/pkg/di"
+	"ai-agent-character-demo/backend/pkg/fixtures"
+	"ai-agent-character-demo/backend/pkg/knowledge"
 	"ai-agent-character-demo/backend/pkg/logger"
 	"ai-agent-character-demo/backend/pkg/router"
 
@@ -45,8 +48,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Feedback is unique per (user, message); drop older duplicates before the index is created
+	if db.Migrator().HasTable(&models.MessageFeedback{}) {
+		if err := db.Exec(`DELETE FROM message_feedbacks a USING message_feedbacks b
+			WHERE a.user_id = b.user_id AND a.message_id = b.message_id AND a.id < b.id`).Error; err != nil {
+			log.LogError(err, "Failed to deduplicate message feedback")
+		}
+	}
+
+	// Inbound audio is unique per (session, content); merge duplicates before the index is created
+	if err := service.PrepareAudioSchema(db); err != nil {
+		log.LogError(err, "Failed to prepare audio tables")
+	}
+
 	// Auto-migrate the schema
-	if err := db.AutoMigrate(&models.Character{}, &models.Message{}, &models.User{}, &models.AudioChunk{}); err != nil {
+	if err := db.AutoMigrate(&models.Character{}, &models.CharacterVersion{}, &models.CharacterCategory{}, &models.User{}, &models.Conversation{}, &models.ConversationParticipant{}, &models.ConversationShare{}, &models.Message{}, &models.MessageFeedback{}, &models.AudioChunk{}, &models.AudioMessageLink{}, &models.AudioUpload{}, &models.KnowledgeDocument{}, &models.KnowledgeChunk{}, &models.PromptTemplate{}, &models.UserPreference{}, &models.Voice{}, &models.SeedRecord{}); err != nil {
 		log.LogError(err, "Failed to migrate database")
 		os.Exit(1)
 	}
@@ -55,13 +71,66 @@ func main() {
 	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_char_session ON messages(character_id, session_id)").Error; err != nil {
 		log.LogError(err, "Failed to create message index", "index", "idx_messages_char_session")
 	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_session_keyset ON messages(session_id, timestamp, id)").Error; err != nil {
+		log.LogError(err, "Failed to create message index", "index", "idx_messages_session_keyset")
+	}
 	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_audio_session ON audio_chunks(session_id)").Error; err != nil {
 		log.LogError(err, "Failed to create audio index", "index", "idx_audio_session")
 	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_audio_session_hash ON audio_chunks(session_id, content_hash)").Error; err != nil {
+		log.LogError(err, "Failed to create audio index", "index", "idx_audio_session_hash")
+	}
 	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_audio_status ON audio_chunks(processing_status)").Error; err != nil {
 		log.LogError(err, "Failed to create audio status index", "index", "idx_audio_status")
 	}
 
+	// Full-text search over message content
+	if err := db.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_tsv tsvector GENERATED ALWAYS AS (to_tsvector('english', coalesce(content, ''))) STORED").Error; err != nil {
+		log.LogError(err, "Failed to add message search column", "column", "content_tsv")
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_content_tsv ON messages USING GIN (content_tsv)").Error; err != nil {
+		log.LogError(err, "Failed to create message search index", "index", "idx_messages_content_tsv")
+	}
+
+	// Character discovery: full-text search, trigram fuzzy matching and trait filters
+	if err := db.Exec("ALTER TABLE characters ADD COLUMN IF NOT EXISTS search_tsv tsvector GENERATED ALWAYS AS (setweight(to_tsvector('english', coalesce(name, '')), 'A') || setweight(to_tsvector('english', coalesce(description, '')), 'B')) STORED").Error; err != nil {
+		log.LogError(err, "Failed to add character search column", "column", "search_tsv")
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_characters_search_tsv ON characters USING GIN (search_tsv)").Error; err != nil {
+		log.LogError(err, "Failed to create character search index", "index", "idx_characters_search_tsv")
+	}
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.LogError(err, "Failed to enable pg_trgm")
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_characters_name_trgm ON characters USING GIN (name gin_trgm_ops)").Error; err != nil {
+		log.LogError(err, "Failed to create character trigram index", "index", "idx_characters_name_trgm")
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_characters_description_trgm ON characters USING GIN (description gin_trgm_ops)").Error; err != nil {
+		log.LogError(err, "Failed to create character trigram index", "index", "idx_characters_description_trgm")
+	}
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_characters_slug ON characters(slug) WHERE slug <> ''").Error; err != nil {
+		log.LogError(err, "Failed to create character slug index", "index", "idx_characters_slug")
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_characters_traits ON characters USING GIN (traits)").Error; err != nil {
+		log.LogError(err, "Failed to create character traits index", "index", "idx_characters_traits")
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_characters_category ON characters(category)").Error; err != nil {
+		log.LogError(err, "Failed to create character category index", "index", "idx_characters_category")
+	}
+
+	// Character knowledge is ranked with pgvector when the extension is
+	// installed; otherwise passages are ranked in memory
+	knowledgePGVector := true
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error; err != nil {
+		log.Warn("pgvector is not available, ranking knowledge passages in memory", "error", err)
+		knowledgePGVector = false
+	} else if err := db.Exec("ALTER TABLE knowledge_chunks ADD COLUMN IF NOT EXISTS embedding_vec vector").Error; err != nil {
+		log.LogError(err, "Failed to add knowledge vector column", "column", "embedding_vec")
+		knowledgePGVector = false
+	} else if err := db.Exec("UPDATE knowledge_chunks SET embedding_vec = embedding::vector WHERE embedding_vec IS NULL AND embedding IS NOT NULL").Error; err != nil {
+		log.LogError(err, "Failed to backfill knowledge vectors", "column", "embedding_vec")
+	}
+
 	// Initialize dependency injection container
 	diConfig := di.DefaultConfig()
 	diConfig.LoggerConfig = logConfig
@@ -71,6 +140,27 @@ func main() {
 			diConfig.JWTExpiryHours = int(val.Hours())
 		}
 	}
+	diConfig.MaxCharactersPerUser = config.Get().Features.MaxCharactersPerUser
+	if dir := os.Getenv("AVATAR_STORE_DIR"); dir != "" {
+		diConfig.AvatarStoreDir = dir
+	}
+	if dir := os.Getenv("AUDIO_STORE_DIR"); dir != "" {
+		diConfig.AudioStoreDir = dir
+	}
+	diConfig.AvatarBaseURL = os.Getenv("PUBLIC_BASE_URL")
+	diConfig.KnowledgePGVector = knowledgePGVector
+	if os.Getenv("EMBEDDING_PROVIDER") == "openai" {
+		embedder, err := knowledge.NewOpenAIEmbedder(os.Getenv("OPENAI_API_KEY"), os.Getenv("EMBEDDING_MODEL"))
+		if err != nil {
+			log.LogError(err, "Failed to configure OpenAI embeddings, using the built-in embedder")
+		} else {
+			diConfig.Embedder = embedder
+		}
+	}
+	if version := os.Getenv("PROMPT_VERSION"); version != "" {
+		diConfig.PromptVersion = version
+	}
+	diConfig.ChatModel = os.Getenv("CHAT_MODEL")
 
 	container, err := di.New(db, diConfig)
 	if err != nil {
@@ -78,6 +168,51 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Link sessions recorded before conversations existed
+	if created, err := container.ConversationService.BackfillConversations(); err != nil {
+		log.LogError(err, "Failed to backfill conversations")
+	} else if created > 0 {
+		log.Info("Backfilled conversations", "count", created)
+	}
+
+	// Record characters created before versioning as their first version
+	if created, err := container.CharacterService.BackfillCharacterVersions(); err != nil {
+		log.LogError(err, "Failed to backfill character versions")
+	} else if created > 0 {
+		log.Info("Backfilled character versions", "count", created)
+	}
+
+	// Add the categories of existing characters to the taxonomy
+	if created, err := container.CharacterService.BackfillCharacterCategories(); err != nil {
+		log.LogError(err, "Failed to backfill character categories")
+	} else if created > 0 {
+		log.Info("Backfilled character categories", "count", created)
+	}
+
+	// Move avatars saved under uploads/ by earlier releases into the avatar store
+	if migrated, err := container.CharacterService.MigrateLegacyAvatars(context.Background()); err != nil {
+		log.LogError(err, "Failed to migrate legacy avatars")
+	} else if migrated > 0 {
+		log.Info("Migrated legacy avatars", "count", migrated)
+	}
+
+	// Load fixtures when SEED_FIXTURES names a set (dev, staging or demo)
+	if set := os.Getenv("SEED_FIXTURES"); set != "" {
+		seeder := service.NewSeedService(db)
+		if err := seeder.Migrate(); err != nil {
+			log.LogError(err, "Failed to prepare seeding", "set", set)
+		} else if result, err := seeder.SeedSet(fixtures.Source(os.Getenv("SEED_FIXTURES_DIR")), set); err != nil {
+			log.LogError(err, "Failed to load fixtures", "set", set)
+		} else {
+			log.Info("Loaded fixtures", "set", set,
+				"characters", result.Characters, "users", result.Users,
+				"voices", result.Voices, "categories", result.Categories)
+			for _, skipped := range result.Skipped {
+				log.Warn("Skipped fixture", "set", set, "record", skipped)
+			}
+		}
+	}
+
 	// Initialize and setup router
 	r := router.New(container)
 	r.SetupRoutes()
@@ -1,11 +1,14 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"ai-agent-character-demo/backend/conversation/models"
 	"ai-agent-character-demo/backend/conversation/service"
+	authjwt "ai-agent-character-demo/backend/pkg/jwt"
+	"ai-agent-character-demo/backend/pkg/pagination"
 
 	// "ai-agent-demo/backend/conversation/service"
 
@@ -67,3 +70,46 @@ func (h *MessageHandler) GetMessagesBySession(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, messages)
 }
+
+// GetMessagesBySessionPage returns a cursor page of a session's messages.
+// Query parameters: before, after, limit. Only the session's owner may page it.
+func (h *MessageHandler) GetMessagesBySessionPage(c *gin.Context) {
+	sessionID := c.Param("session_id")
+
+	claims, ok := c.MustGet("user").(*authjwt.JWTClaims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No user claims in context"})
+		return
+	}
+	if _, err := h.service.AuthorizeSession(sessionID, claims.UserID); err != nil {
+		if errors.Is(err, service.ErrSessionForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+	}
+
+	page, err := pagination.ParsePage(c.Query("before"), c.Query("after"), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.GetMessagesBySessionPage(sessionID, page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
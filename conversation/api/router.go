@@ -17,5 +17,6 @@ func RegisterMessageRoutes(r *gin.Engine, handler *MessageHandler, jwtService *j
 		msgGroup.POST("", JWTAuthMiddleware(jwtService, jwt.RoleUser), handler.CreateMessage)
 		msgGroup.GET("/:id", handler.GetMessageByID)
 		msgGroup.GET("/session/:session_id", handler.GetMessagesBySession)
+		msgGroup.GET("/session/:session_id/page", handler.GetMessagesBySessionPage)
 	}
 }
@@ -1,16 +1,34 @@
 package main
 
 import (
+	"log"
+
+	"ai-agent-character-demo/backend/ai"
 	"ai-agent-character-demo/backend/conversation/api"
 	"ai-agent-character-demo/backend/conversation/repository"
 	"ai-agent-character-demo/backend/conversation/service"
+	"ai-agent-character-demo/backend/conversation/ws"
+	"ai-agent-character-demo/backend/pkg/jwt"
 
 	"gorm.io/gorm"
 )
 
-func NewMessageHandlerWithDI(db *gorm.DB) *api.MessageHandler {
+func NewMessageServiceWithDI(db *gorm.DB) *service.MessageService {
 	repo := repository.NewGormMessageRepository(db)
-	svc := service.NewMessageService(repo)
+	sessions := repository.NewGormSessionRepository(db)
+	return service.NewMessageService(repo, sessions)
+}
+
+func NewMessageHandlerWithDI(svc *service.MessageService) *api.MessageHandler {
 	handler := api.NewMessageHandler(svc)
 	return handler
 }
+
+func NewChatServerWithDI(db *gorm.DB, svc *service.MessageService, jwtService *jwt.Service) *ws.Server {
+	aiClient, err := ai.NewAI_Layer2Client()
+	if err != nil {
+		log.Fatalf("failed to create AI client: %v", err)
+	}
+	characters := repository.NewGormCharacterRepository(db)
+	return ws.NewServer(svc, characters, ws.NonStreaming(aiClient.Reply), jwtService)
+}
@@ -0,0 +1,91 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"ai-agent-character-demo/backend/pkg/jwt"
+)
+
+// publicServices are served without a token so probes and tooling keep working
+var publicServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the caller's JWT claims set by the auth interceptors
+func ClaimsFromContext(ctx context.Context) (*jwt.JWTClaims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*jwt.JWTClaims)
+	return claims, ok
+}
+
+// UnaryAuthInterceptor validates the bearer token of unary calls
+func UnaryAuthInterceptor(jwtService *jwt.Service) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isPublicMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, jwtService)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor validates the bearer token of streaming calls
+func StreamAuthInterceptor(jwtService *jwt.Service) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isPublicMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), jwtService)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate reads "authorization: Bearer <token>" from the metadata and
+// stores the validated claims in the context
+func authenticate(ctx context.Context, jwtService *jwt.Service) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid authorization metadata")
+	}
+
+	claims, err := jwtService.ValidateToken(strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+func isPublicMethod(fullMethod string) bool {
+	for _, prefix := range publicServices {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticatedStream carries the claims context into stream handlers
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -1,25 +1,250 @@
 package grpc
 
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative conversation/grpc/proto/conversation.proto
+
 import (
+	"context"
+	"errors"
 	"log"
 	"net"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	pb "ai-agent-character-demo/backend/conversation/grpc/proto"
+	"ai-agent-character-demo/backend/conversation/models"
+	"ai-agent-character-demo/backend/conversation/service"
+	"ai-agent-character-demo/backend/pkg/jwt"
+	"ai-agent-character-demo/backend/pkg/pagination"
 )
 
-// TODO: Import generated proto and implement service
-// import pb "ai-agent-character-demo/backend/conversation/grpc/proto"
+// ConversationServer implements the ConversationService gRPC API on top of the message service
+type ConversationServer struct {
+	pb.UnimplementedConversationServiceServer
+	service *service.MessageService
+}
+
+// NewConversationServer creates a new gRPC conversation server
+func NewConversationServer(service *service.MessageService) *ConversationServer {
+	return &ConversationServer{service: service}
+}
+
+// NewServer builds a gRPC server with JWT auth, the conversation service,
+// health checks and reflection registered
+func NewServer(messageService *service.MessageService, jwtService *jwt.Service) *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryAuthInterceptor(jwtService)),
+		grpc.ChainStreamInterceptor(StreamAuthInterceptor(jwtService)),
+	)
+
+	pb.RegisterConversationServiceServer(grpcServer, NewConversationServer(messageService))
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(pb.ConversationService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	reflection.Register(grpcServer)
 
-func StartGRPCServer(port string) {
+	return grpcServer
+}
+
+func StartGRPCServer(port string, messageService *service.MessageService, jwtService *jwt.Service) {
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	grpcServer := grpc.NewServer()
-	// TODO: Register your conversation service here
-	// pb.RegisterConversationServiceServer(grpcServer, &ConversationServer{})
+	grpcServer := NewServer(messageService, jwtService)
 	log.Printf("gRPC server listening on %s", port)
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
-} 
\ No newline at end of file
+}
+
+// CreateMessage stores a message and notifies session watchers. Like the REST
+// API it is limited to the user role.
+func (s *ConversationServer) CreateMessage(ctx context.Context, req *pb.CreateMessageRequest) (*pb.Message, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing claims")
+	}
+	if !claims.HasRole(jwt.RoleUser) {
+		return nil, status.Error(codes.PermissionDenied, "insufficient role")
+	}
+
+	if req.GetSessionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id is required")
+	}
+	// Character replies are written by the server, never by callers
+	if req.GetSender() != "user" {
+		return nil, status.Error(codes.InvalidArgument, "sender must be user")
+	}
+	if req.GetContent() == "" {
+		return nil, status.Error(codes.InvalidArgument, "content is required")
+	}
+	if err := s.authorizeSession(ctx, req.GetSessionId()); err != nil {
+		return nil, err
+	}
+
+	message := &models.Message{
+		ExternalID:  req.GetExternalId(),
+		CharacterID: uint(req.GetCharacterId()),
+		SessionID:   req.GetSessionId(),
+		Sender:      req.GetSender(),
+		Content:     req.GetContent(),
+	}
+	if req.GetTimestamp() != nil {
+		message.Timestamp = req.GetTimestamp().AsTime()
+	}
+
+	if err := s.service.CreateMessage(message, claims.UserID); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create message: %v", err)
+	}
+	return toProtoMessage(*message), nil
+}
+
+// GetMessage returns a message by ID from a session the caller owns
+func (s *ConversationServer) GetMessage(ctx context.Context, req *pb.GetMessageRequest) (*pb.Message, error) {
+	message, err := s.service.GetMessageByID(uint(req.GetId()))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, "message not found")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get message: %v", err)
+	}
+	if err := s.authorizeSession(ctx, message.SessionID); err != nil {
+		return nil, err
+	}
+	return toProtoMessage(*message), nil
+}
+
+// ListSessionMessages returns a cursor page of a session the caller owns
+func (s *ConversationServer) ListSessionMessages(ctx context.Context, req *pb.ListSessionMessagesRequest) (*pb.ListSessionMessagesResponse, error) {
+	if req.GetSessionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id is required")
+	}
+	if err := s.authorizeSession(ctx, req.GetSessionId()); err != nil {
+		return nil, err
+	}
+
+	page, err := pagination.ParsePage(req.GetBefore(), req.GetAfter(), int(req.GetLimit()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	result, err := s.service.GetMessagesBySessionPage(req.GetSessionId(), page)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list messages: %v", err)
+	}
+
+	resp := &pb.ListSessionMessagesResponse{
+		Messages:   make([]*pb.Message, len(result.Messages)),
+		PrevCursor: result.PrevCursor,
+		NextCursor: result.NextCursor,
+		HasOlder:   result.HasOlder,
+		HasNewer:   result.HasNewer,
+	}
+	for i, m := range result.Messages {
+		resp.Messages[i] = toProtoMessage(m)
+	}
+	return resp, nil
+}
+
+// WatchSession streams new messages of a session the caller owns until the
+// client goes away. Watchers that fall behind are ended with
+// ResourceExhausted and should reconnect with the cursor of the last message
+// they received.
+func (s *ConversationServer) WatchSession(req *pb.WatchSessionRequest, stream grpc.ServerStreamingServer[pb.Message]) error {
+	if req.GetSessionId() == "" {
+		return status.Error(codes.InvalidArgument, "session_id is required")
+	}
+	if err := s.authorizeSession(stream.Context(), req.GetSessionId()); err != nil {
+		return err
+	}
+
+	var after *pagination.Cursor
+	if req.GetAfter() != "" {
+		cursor, err := pagination.Decode(req.GetAfter())
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		after = cursor
+	}
+
+	// Subscribe before replaying so nothing created in between is missed
+	messages, cancel := s.service.WatchSession(req.GetSessionId())
+	defer cancel()
+
+	sent := make(map[uint]bool)
+	for after != nil {
+		result, err := s.service.GetMessagesBySessionPage(req.GetSessionId(), pagination.Page{After: after, Limit: pagination.MaxLimit})
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to replay messages: %v", err)
+		}
+		for _, m := range result.Messages {
+			if err := stream.Send(toProtoMessage(m)); err != nil {
+				return err
+			}
+			sent[m.ID] = true
+		}
+		if !result.HasNewer || len(result.Messages) == 0 {
+			break
+		}
+		last := result.Messages[len(result.Messages)-1]
+		after = &pagination.Cursor{Time: last.Timestamp, ID: last.ID}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case m, ok := <-messages:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind; reconnect with the last cursor")
+			}
+			if sent[m.ID] {
+				continue
+			}
+			if err := stream.Send(toProtoMessage(m)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// authorizeSession checks that the caller owns the session. Sessions without
+// an owner are refused to everyone.
+func (s *ConversationServer) authorizeSession(ctx context.Context, sessionID string) error {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing claims")
+	}
+	if _, err := s.service.AuthorizeSession(sessionID, claims.UserID); err != nil {
+		if errors.Is(err, service.ErrSessionForbidden) {
+			return status.Error(codes.PermissionDenied, "no access to this session")
+		}
+		return status.Errorf(codes.Internal, "failed to check session access: %v", err)
+	}
+	return nil
+}
+
+// toProtoMessage converts a stored message to its wire form
+func toProtoMessage(m models.Message) *pb.Message {
+	return &pb.Message{
+		Id:          uint64(m.ID),
+		ExternalId:  m.ExternalID,
+		CharacterId: uint64(m.CharacterID),
+		SessionId:   m.SessionID,
+		Sender:      m.Sender,
+		Content:     m.Content,
+		Timestamp:   timestamppb.New(m.Timestamp),
+		CreatedAt:   timestamppb.New(m.CreatedAt),
+		Cursor:      pagination.Cursor{Time: m.Timestamp, ID: m.ID}.Encode(),
+	}
+}
@@ -0,0 +1,347 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	pb "ai-agent-character-demo/backend/conversation/grpc/proto"
+	"ai-agent-character-demo/backend/conversation/models"
+	"ai-agent-character-demo/backend/conversation/service"
+	"ai-agent-character-demo/backend/pkg/jwt"
+	"ai-agent-character-demo/backend/pkg/pagination"
+)
+
+// memoryRepository is an in-memory MessageRepository
+type memoryRepository struct {
+	mu       sync.Mutex
+	messages []models.Message
+}
+
+func (r *memoryRepository) Create(message *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	message.ID = uint(len(r.messages) + 1)
+	message.CreatedAt = time.Now()
+	r.messages = append(r.messages, *message)
+	return nil
+}
+
+func (r *memoryRepository) GetByID(id uint) (*models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.messages {
+		if m.ID == id {
+			return &m, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) GetBySession(sessionID string) ([]models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messages []models.Message
+	for _, m := range r.messages {
+		if m.SessionID == sessionID {
+			messages = append(messages, m)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].Timestamp.Equal(messages[j].Timestamp) {
+			return messages[i].ID < messages[j].ID
+		}
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+	return messages, nil
+}
+
+func (r *memoryRepository) GetBySessionPage(sessionID string, page pagination.Page) ([]models.Message, bool, error) {
+	all, _ := r.GetBySession(sessionID)
+	less := func(m models.Message, c *pagination.Cursor) bool {
+		return m.Timestamp.Before(c.Time) || (m.Timestamp.Equal(c.Time) && m.ID < c.ID)
+	}
+	greater := func(m models.Message, c *pagination.Cursor) bool {
+		return m.Timestamp.After(c.Time) || (m.Timestamp.Equal(c.Time) && m.ID > c.ID)
+	}
+
+	var window []models.Message
+	for _, m := range all {
+		switch {
+		case page.After != nil && !greater(m, page.After):
+		case page.Before != nil && !less(m, page.Before):
+		default:
+			window = append(window, m)
+		}
+	}
+
+	if page.After != nil {
+		if len(window) > page.Limit {
+			return window[:page.Limit], true, nil
+		}
+		return window, false, nil
+	}
+	if len(window) > page.Limit {
+		return window[len(window)-page.Limit:], true, nil
+	}
+	return window, false, nil
+}
+
+// sessionRepository maps session IDs to their owners
+type sessionRepository map[string]uint
+
+func (r sessionRepository) GetBySessionID(sessionID string) (*models.Session, error) {
+	owner, ok := r[sessionID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	session := &models.Session{SessionID: sessionID, CharacterID: 7}
+	if owner != 0 {
+		session.UserID = &owner
+	}
+	return session, nil
+}
+
+// testSessions are owned by the user in authContext, except for the
+// anonymous one
+var testSessions = sessionRepository{"session-1": 42, "session-2": 42, "session-other": 43, "session-anonymous": 0}
+
+const bufSize = 1024 * 1024
+
+func newTestClient(t *testing.T) (pb.ConversationServiceClient, *grpc.ClientConn, *jwt.Service) {
+	t.Helper()
+
+	jwtService := jwt.NewService("test-secret", time.Hour)
+	messageService := service.NewMessageService(&memoryRepository{}, testSessions)
+
+	lis := bufconn.Listen(bufSize)
+	server := NewServer(messageService, jwtService)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewConversationServiceClient(conn), conn, jwtService
+}
+
+func authContext(t *testing.T, jwtService *jwt.Service, role jwt.Role) context.Context {
+	t.Helper()
+	return userContext(t, jwtService, 42, role)
+}
+
+func userContext(t *testing.T, jwtService *jwt.Service, userID uint, role jwt.Role) context.Context {
+	t.Helper()
+	token, err := jwtService.GenerateToken(userID, "user@example.com", role, jwt.GetRolePermissions(role))
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestRequiresToken(t *testing.T) {
+	client, _, _ := newTestClient(t)
+
+	_, err := client.GetMessage(context.Background(), &pb.GetMessageRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token")
+	_, err = client.GetMessage(ctx, &pb.GetMessageRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestHealthWithoutToken(t *testing.T) {
+	_, conn, _ := newTestClient(t)
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: pb.ConversationService_ServiceDesc.ServiceName,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
+
+func TestCreateAndGetMessage(t *testing.T) {
+	client, _, jwtService := newTestClient(t)
+	ctx := authContext(t, jwtService, jwt.RoleUser)
+
+	created, err := client.CreateMessage(ctx, &pb.CreateMessageRequest{
+		ExternalId:  "msg-1",
+		CharacterId: 7,
+		SessionId:   "session-1",
+		Sender:      "user",
+		Content:     "Hello",
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, created.GetId())
+	assert.NotEmpty(t, created.GetCursor())
+	assert.False(t, created.GetTimestamp().AsTime().IsZero())
+
+	fetched, err := client.GetMessage(ctx, &pb.GetMessageRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", fetched.GetContent())
+	assert.Equal(t, uint64(7), fetched.GetCharacterId())
+
+	_, err = client.GetMessage(ctx, &pb.GetMessageRequest{Id: 999})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestCreateMessageValidation(t *testing.T) {
+	client, _, jwtService := newTestClient(t)
+
+	_, err := client.CreateMessage(authContext(t, jwtService, jwt.RoleUser), &pb.CreateMessageRequest{
+		SessionId: "session-1",
+		Sender:    "narrator",
+		Content:   "Hello",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.CreateMessage(authContext(t, jwtService, jwt.RoleUser), &pb.CreateMessageRequest{
+		SessionId: "session-1",
+		Sender:    "character",
+		Content:   "Forged reply",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.CreateMessage(authContext(t, jwtService, jwt.RoleGuest), &pb.CreateMessageRequest{
+		SessionId: "session-1",
+		Sender:    "user",
+		Content:   "Hello",
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestDeniesOtherUsersSessions(t *testing.T) {
+	client, _, jwtService := newTestClient(t)
+	owner := authContext(t, jwtService, jwt.RoleUser)
+	other := userContext(t, jwtService, 43, jwt.RoleUser)
+
+	created, err := client.CreateMessage(owner, &pb.CreateMessageRequest{SessionId: "session-1", Sender: "user", Content: "Hello"})
+	require.NoError(t, err)
+
+	_, err = client.GetMessage(other, &pb.GetMessageRequest{Id: created.GetId()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.ListSessionMessages(other, &pb.ListSessionMessagesRequest{SessionId: "session-1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.CreateMessage(other, &pb.CreateMessageRequest{SessionId: "session-1", Sender: "user", Content: "Hi"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream, err := client.WatchSession(other, &pb.WatchSessionRequest{SessionId: "session-1"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Anonymous and unknown sessions belong to no one
+	for _, sessionID := range []string{"session-anonymous", "session-unknown"} {
+		_, err = client.ListSessionMessages(owner, &pb.ListSessionMessagesRequest{SessionId: sessionID})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), sessionID)
+	}
+}
+
+func TestListSessionMessagesPages(t *testing.T) {
+	client, _, jwtService := newTestClient(t)
+	ctx := authContext(t, jwtService, jwt.RoleUser)
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		_, err := client.CreateMessage(ctx, &pb.CreateMessageRequest{
+			SessionId: "session-1",
+			Sender:    "user",
+			Content:   string(rune('a' + i)),
+			Timestamp: timestampAt(start.Add(time.Duration(i) * time.Minute)),
+		})
+		require.NoError(t, err)
+	}
+
+	latest, err := client.ListSessionMessages(ctx, &pb.ListSessionMessagesRequest{SessionId: "session-1", Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d", "e"}, contents(latest.GetMessages()))
+	assert.True(t, latest.GetHasOlder())
+	assert.False(t, latest.GetHasNewer())
+
+	older, err := client.ListSessionMessages(ctx, &pb.ListSessionMessagesRequest{SessionId: "session-1", Limit: 2, Before: latest.GetPrevCursor()})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, contents(older.GetMessages()))
+	assert.True(t, older.GetHasNewer())
+
+	newer, err := client.ListSessionMessages(ctx, &pb.ListSessionMessagesRequest{SessionId: "session-1", Limit: 2, After: older.GetNextCursor()})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d", "e"}, contents(newer.GetMessages()))
+
+	_, err = client.ListSessionMessages(ctx, &pb.ListSessionMessagesRequest{SessionId: "session-1", Before: "garbage"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWatchSessionReplaysAndStreams(t *testing.T) {
+	client, _, jwtService := newTestClient(t)
+	ctx, cancel := context.WithTimeout(authContext(t, jwtService, jwt.RoleUser), 5*time.Second)
+	defer cancel()
+
+	first, err := client.CreateMessage(ctx, &pb.CreateMessageRequest{SessionId: "session-1", Sender: "user", Content: "first"})
+	require.NoError(t, err)
+	_, err = client.CreateMessage(ctx, &pb.CreateMessageRequest{SessionId: "session-1", Sender: "user", Content: "missed"})
+	require.NoError(t, err)
+
+	stream, err := client.WatchSession(ctx, &pb.WatchSessionRequest{SessionId: "session-1", After: first.GetCursor()})
+	require.NoError(t, err)
+
+	replayed, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "missed", replayed.GetContent())
+
+	// Messages in other sessions are not delivered
+	_, err = client.CreateMessage(ctx, &pb.CreateMessageRequest{SessionId: "session-2", Sender: "user", Content: "elsewhere"})
+	require.NoError(t, err)
+
+	// The watcher subscribed before replaying, so the next message is live
+	received := make(chan *pb.Message, 1)
+	go func() {
+		m, err := stream.Recv()
+		if err == nil {
+			received <- m
+		}
+	}()
+	_, err = client.CreateMessage(ctx, &pb.CreateMessageRequest{SessionId: "session-1", Sender: "user", Content: "live"})
+	require.NoError(t, err)
+
+	select {
+	case m := <-received:
+		assert.Equal(t, "live", m.GetContent())
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for a live message")
+	}
+}
+
+func timestampAt(t time.Time) *timestamppb.Timestamp {
+	return timestamppb.New(t)
+}
+
+func contents(messages []*pb.Message) []string {
+	out := make([]string, len(messages))
+	for i, m := range messages {
+		out[i] = m.GetContent()
+	}
+	return out
+}
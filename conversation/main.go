@@ -42,7 +42,10 @@ func main() {
 	}
 	jwtService := jwt.NewService(jwtSecret, jwtExpiry)
 
-	handler := NewMessageHandlerWithDI(db)
+	// REST, gRPC and WebSocket share one message service so watchers see every write
+	messageService := NewMessageServiceWithDI(db)
+	handler := NewMessageHandlerWithDI(messageService)
+	chatServer := NewChatServerWithDI(db, messageService, jwtService)
 
 	r := gin.Default()
 	api.RegisterMessageRoutes(r, handler, jwtService)
@@ -69,11 +72,11 @@ func main() {
 		wg.Done()
 	}()
 	go func() {
-		grpc.StartGRPCServer(grpcPort)
+		grpc.StartGRPCServer(grpcPort, messageService, jwtService)
 		wg.Done()
 	}()
 	go func() {
-		ws.StartWebSocketServer(wsPort)
+		ws.StartWebSocketServer(wsPort, chatServer)
 		wg.Done()
 	}()
 
@@ -0,0 +1,35 @@
+package models
+
+// Session is the ownership record of a chat session, read from the
+// conversations table of the main backend
+type Session struct {
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	SessionID    string `json:"session_id"`
+	UserID       *uint  `json:"user_id"`
+	CharacterID  uint   `json:"character_id"`
+	CharacterIDs []uint `json:"character_ids" gorm:"-"` // Scene cast, including CharacterID
+}
+
+// OwnedBy reports whether the session belongs to the user. Anonymous
+// sessions belong to no one.
+func (s *Session) OwnedBy(userID uint) bool {
+	return s.UserID != nil && *s.UserID == userID
+}
+
+// HasCharacter reports whether the character takes part in the session
+func (s *Session) HasCharacter(characterID uint) bool {
+	if s.CharacterID == characterID {
+		return true
+	}
+	for _, id := range s.CharacterIDs {
+		if id == characterID {
+			return true
+		}
+	}
+	return false
+}
+
+// TableName overrides the table name
+func (Session) TableName() string {
+	return "conversations"
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"ai-agent-character-demo/backend/pkg/ws"
+
+	"gorm.io/gorm"
+)
+
+// CharacterRepository reads the characters a conversation talks to. The
+// characters table is owned by the character service; this side only reads it.
+type CharacterRepository interface {
+	GetByID(id uint) (*ws.Character, error)
+}
+
+type GormCharacterRepository struct {
+	db *gorm.DB
+}
+
+func NewGormCharacterRepository(db *gorm.DB) *GormCharacterRepository {
+	return &GormCharacterRepository{db: db}
+}
+
+// GetByID returns a character that has not been deleted
+func (r *GormCharacterRepository) GetByID(id uint) (*ws.Character, error) {
+	var character ws.Character
+	err := r.db.Table("characters").
+		Select("id, name, description, personality, background, scenario, greeting, example_dialogue, voice_type").
+		Where("deleted_at IS NULL").
+		First(&character, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &character, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"ai-agent-character-demo/backend/conversation/models"
+	"ai-agent-character-demo/backend/pkg/pagination"
 
 	"gorm.io/gorm"
 )
@@ -10,7 +11,7 @@ type MessageRepository interface {
 	Create(message *models.Message) error
 	GetByID(id uint) (*models.Message, error)
 	GetBySession(sessionID string) ([]models.Message, error)
-	GetBySessionPaginated(sessionID string, limit, offset int) ([]models.Message, error)
+	GetBySessionPage(sessionID string, page pagination.Page) ([]models.Message, bool, error)
 }
 
 type GormMessageRepository struct {
@@ -40,12 +41,43 @@ func (r *GormMessageRepository) GetBySession(sessionID string) ([]models.Message
 	return messages, err
 }
 
-func (r *GormMessageRepository) GetBySessionPaginated(sessionID string, limit, offset int) ([]models.Message, error) {
+// GetBySessionPage returns up to page.Limit messages oldest first, keyed on
+// (timestamp, id), and reports whether more exist past the page in the
+// direction being read
+func (r *GormMessageRepository) GetBySessionPage(sessionID string, page pagination.Page) ([]models.Message, bool, error) {
 	var messages []models.Message
-	err := r.db.Where("session_id = ?", sessionID).
-		Order("timestamp ASC").
-		Limit(limit).
-		Offset(offset).
+	query := r.db.Where("session_id = ?", sessionID)
+
+	if page.After != nil {
+		err := query.Where("(timestamp, id) > (?, ?)", page.After.Time, page.After.ID).
+			Order("timestamp ASC, id ASC").
+			Limit(page.Limit + 1).
+			Find(&messages).Error
+		if err != nil {
+			return nil, false, err
+		}
+		hasMore := len(messages) > page.Limit
+		if hasMore {
+			messages = messages[:page.Limit]
+		}
+		return messages, hasMore, nil
+	}
+
+	if page.Before != nil {
+		query = query.Where("(timestamp, id) < (?, ?)", page.Before.Time, page.Before.ID)
+	}
+	err := query.Order("timestamp DESC, id DESC").
+		Limit(page.Limit + 1).
 		Find(&messages).Error
-	return messages, err
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(messages) > page.Limit
+	if hasMore {
+		messages = messages[:page.Limit]
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, hasMore, nil
 }
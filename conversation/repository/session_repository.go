@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"ai-agent-character-demo/backend/conversation/models"
+
+	"gorm.io/gorm"
+)
+
+// SessionRepository reads who owns a session and which characters take part
+// in it. The conversations table is owned by the main backend; this side only
+// reads it.
+type SessionRepository interface {
+	GetBySessionID(sessionID string) (*models.Session, error)
+}
+
+type GormSessionRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSessionRepository(db *gorm.DB) *GormSessionRepository {
+	return &GormSessionRepository{db: db}
+}
+
+// GetBySessionID returns the session with its scene cast
+func (r *GormSessionRepository) GetBySessionID(sessionID string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Select("id, session_id, user_id, character_id").
+		Where("session_id = ?", sessionID).
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.Table("conversation_participants").
+		Where("conversation_id = ?", session.ID).
+		Order("position ASC").
+		Pluck("character_id", &session.CharacterIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -1,22 +1,78 @@
 package service
 
 import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
 	"ai-agent-character-demo/backend/conversation/models"
 	"ai-agent-character-demo/backend/conversation/repository"
+	"ai-agent-character-demo/backend/pkg/pagination"
 )
 
+// MessagePage is a window of a session's messages, oldest first
+type MessagePage struct {
+	Messages   []models.Message `json:"messages"`
+	PrevCursor string           `json:"prev_cursor,omitempty"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	HasOlder   bool             `json:"has_older"`
+	HasNewer   bool             `json:"has_newer"`
+}
+
+// ErrSessionForbidden is returned when the user does not own a session.
+// Sessions without a conversation, or whose conversation has no owner,
+// belong to no one.
+var ErrSessionForbidden = errors.New("session belongs to another user")
+
 type MessageService struct {
-	repo repository.MessageRepository
+	repo     repository.MessageRepository
+	sessions repository.SessionRepository
+	watchers *sessionWatchers
 }
 
-func NewMessageService(repo repository.MessageRepository) *MessageService {
-	return &MessageService{repo: repo}
+func NewMessageService(repo repository.MessageRepository, sessions repository.SessionRepository) *MessageService {
+	return &MessageService{
+		repo:     repo,
+		sessions: sessions,
+		watchers: newSessionWatchers(),
+	}
+}
+
+// AuthorizeSession returns the session if the user owns it, and
+// ErrSessionForbidden otherwise
+func (s *MessageService) AuthorizeSession(sessionID string, userID uint) (*models.Session, error) {
+	session, err := s.sessions.GetBySessionID(sessionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionForbidden
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !session.OwnedBy(userID) {
+		return nil, ErrSessionForbidden
+	}
+	return session, nil
 }
 
 func (s *MessageService) CreateMessage(message *models.Message, userID uint) error {
 	// Add business logic, validation, etc. here
 	// userID is available for AI orchestration
-	return s.repo.Create(message)
+	if message.Timestamp.IsZero() {
+		message.Timestamp = time.Now()
+	}
+	if err := s.repo.Create(message); err != nil {
+		return err
+	}
+	s.watchers.publish(*message)
+	return nil
+}
+
+// WatchSession returns a channel of messages created in the session from now
+// on. The channel is closed by cancel, or early if the reader falls behind;
+// readers then resume with GetMessagesBySessionPage and their last cursor.
+func (s *MessageService) WatchSession(sessionID string) (<-chan models.Message, func()) {
+	return s.watchers.subscribe(sessionID)
 }
 
 func (s *MessageService) GetMessageByID(id uint) (*models.Message, error) {
@@ -27,11 +83,30 @@ func (s *MessageService) GetMessagesBySession(sessionID string) ([]models.Messag
 	return s.repo.GetBySession(sessionID)
 }
 
-func (s *MessageService) GetMessagesBySessionPaginated(sessionID string, limit, offset int) ([]models.Message, int, error) {
-	messages, err := s.repo.GetBySessionPaginated(sessionID, limit, offset)
-	total := 0
-	if err == nil {
-		total = len(messages)
+// GetMessagesBySessionPage returns a page of a session's messages. Pages
+// move towards older messages with page.Before and newer ones with page.After.
+func (s *MessageService) GetMessagesBySessionPage(sessionID string, page pagination.Page) (*MessagePage, error) {
+	messages, hasMore, err := s.repo.GetBySessionPage(sessionID, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MessagePage{Messages: messages}
+	if result.Messages == nil {
+		result.Messages = []models.Message{}
+	}
+	if page.After != nil {
+		result.HasOlder = true
+		result.HasNewer = hasMore
+	} else {
+		result.HasOlder = hasMore
+		result.HasNewer = page.Before != nil
+	}
+	if len(messages) > 0 {
+		first := messages[0]
+		last := messages[len(messages)-1]
+		result.PrevCursor = pagination.Cursor{Time: first.Timestamp, ID: first.ID}.Encode()
+		result.NextCursor = pagination.Cursor{Time: last.Timestamp, ID: last.ID}.Encode()
 	}
-	return messages, total, err
+	return result, nil
 }
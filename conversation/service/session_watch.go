@@ -0,0 +1,70 @@
+package service
+
+import (
+	"sync"
+
+	"ai-agent-character-demo/backend/conversation/models"
+)
+
+// watchBuffer is how many messages a watcher may fall behind before it is dropped
+const watchBuffer = 64
+
+// sessionWatchers fans newly created messages out to the watchers of their
+// session. Watchers live in this process only.
+type sessionWatchers struct {
+	mu       sync.Mutex
+	sessions map[string]map[chan models.Message]struct{}
+}
+
+func newSessionWatchers() *sessionWatchers {
+	return &sessionWatchers{
+		sessions: make(map[string]map[chan models.Message]struct{}),
+	}
+}
+
+// subscribe registers a watcher for a session. The channel is closed when the
+// watcher is cancelled or falls too far behind.
+func (w *sessionWatchers) subscribe(sessionID string) (<-chan models.Message, func()) {
+	ch := make(chan models.Message, watchBuffer)
+
+	w.mu.Lock()
+	if w.sessions[sessionID] == nil {
+		w.sessions[sessionID] = make(map[chan models.Message]struct{})
+	}
+	w.sessions[sessionID][ch] = struct{}{}
+	w.mu.Unlock()
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.remove(sessionID, ch)
+	}
+}
+
+// publish delivers a message to every watcher of its session without blocking
+func (w *sessionWatchers) publish(message models.Message) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ch := range w.sessions[message.SessionID] {
+		select {
+		case ch <- message:
+		default:
+			// The watcher resumes from its last cursor after reconnecting
+			w.remove(message.SessionID, ch)
+		}
+	}
+}
+
+// remove closes and forgets a watcher; the caller holds mu
+func (w *sessionWatchers) remove(sessionID string, ch chan models.Message) {
+	watchers := w.sessions[sessionID]
+	if _, ok := watchers[ch]; !ok {
+		return
+	}
+	delete(watchers, ch)
+	close(ch)
+	if len(watchers) == 0 {
+		delete(w.sessions, sessionID)
+	}
+}
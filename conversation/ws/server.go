@@ -1,43 +1,504 @@
 package ws
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/conversation/models"
+	"ai-agent-character-demo/backend/conversation/repository"
+	"ai-agent-character-demo/backend/conversation/service"
+	"ai-agent-character-demo/backend/pkg/jwt"
+	"ai-agent-character-demo/backend/pkg/pagination"
+	"ai-agent-character-demo/backend/pkg/ws"
+)
+
+const (
+	// Time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the peer
+	pongWait = 60 * time.Second
+
+	// Send pings to peer with this period. Must be less than pongWait
+	pingPeriod = (pongWait * 9) / 10
+
+	// Maximum message size allowed from peer
+	maxMessageSize = 512 * 1024 // 512KB
+
+	// Messages sent as chat_history on connect; older ones are fetched with load_more
+	historyPageSize = 50
+
+	// Recent messages passed to the AI provider with each user message
+	replyHistorySize = 20
+
+	// How long the AI provider may take to reply
+	replyTimeout = 60 * time.Second
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+// Responder generates a character's reply to a user message. It passes each
+// piece of the reply to delta as it arrives and returns the whole reply.
+type Responder func(ctx context.Context, character *ws.Character, userMessage string, history []ws.ChatMessage, delta func(string)) (string, error)
+
+// NonStreaming adapts a provider that answers whole; its reply arrives as a
+// single delta
+func NonStreaming(reply func(ctx context.Context, character *ws.Character, userMessage string, history []ws.ChatMessage) (string, error)) Responder {
+	return func(ctx context.Context, character *ws.Character, userMessage string, history []ws.ChatMessage, delta func(string)) (string, error) {
+		response, err := reply(ctx, character, userMessage, history)
+		if err != nil {
+			return "", err
+		}
+		delta(response)
+		return response, nil
+	}
+}
+
+// Server is the conversation service's real-time chat endpoint. It speaks the
+// same protocol as the monolith's /ws: user messages are stored, answered by
+// the AI provider, and every message of a session is broadcast to all
+// connections watching it.
+type Server struct {
+	messages   *service.MessageService
+	characters repository.CharacterRepository
+	respond    Responder
+	jwtService *jwt.Service
+	upgrader   websocket.Upgrader
+}
+
+// NewServer creates a new chat server
+func NewServer(messages *service.MessageService, characters repository.CharacterRepository, respond Responder, jwtService *jwt.Service) *Server {
+	return &Server{
+		messages:   messages,
+		characters: characters,
+		respond:    respond,
+		jwtService: jwtService,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Handler returns the HTTP handler serving /ws/chat
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/chat", s.serveChat)
+	return mux
 }
 
-func StartWebSocketServer(port string) {
-	http.HandleFunc("/ws/chat", chatHandler)
+func StartWebSocketServer(port string, server *Server) {
 	log.Printf("WebSocket server listening on %s", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	if err := http.ListenAndServe(":"+port, server.Handler()); err != nil {
 		log.Fatalf("failed to serve websocket: %v", err)
 	}
 }
 
-func chatHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// serveChat authenticates the request and upgrades it to a chat connection.
+// Query parameters match the monolith: characterId and sessionId, plus the
+// token, which may also be sent as an Authorization header. The session must
+// belong to the caller and the character must take part in it.
+func (s *Server) serveChat(w http.ResponseWriter, r *http.Request) {
+	characterID, err := strconv.ParseUint(r.URL.Query().Get("characterId"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "characterId is required")
+		return
+	}
+	sessionID := r.URL.Query().Get("sessionId")
+	if sessionID == "" {
+		writeError(w, http.StatusBadRequest, "sessionId is required")
+		return
+	}
+
+	claims, err := s.jwtService.ValidateToken(requestToken(r))
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	session, err := s.messages.AuthorizeSession(sessionID, claims.UserID)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionForbidden) {
+			writeError(w, http.StatusForbidden, "You do not have access to this session")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to check session access")
+		}
+		return
+	}
+	if !session.HasCharacter(uint(characterID)) {
+		writeError(w, http.StatusForbidden, "Character is not part of this session")
+		return
+	}
+
+	character, err := s.characters.GetByID(uint(characterID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, http.StatusNotFound, "Character not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to load character")
+		}
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
-	defer conn.Close()
-	// TODO: Implement chat streaming logic
+
+	c := &connection{
+		server:    s,
+		conn:      conn,
+		send:      make(chan []byte, 256),
+		done:      make(chan struct{}),
+		claims:    claims,
+		character: character,
+		sessionID: sessionID,
+	}
+	c.serve()
+}
+
+// connection is one client watching and chatting in a session
+type connection struct {
+	server    *Server
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	claims    *jwt.JWTClaims
+	character *ws.Character
+	sessionID string
+	sent      sync.Map // External IDs of user messages sent on this connection, already acked
+}
+
+// serve runs the connection until the client goes away
+func (c *connection) serve() {
+	// Subscribe before loading history so nothing created in between is missed
+	messages, cancel := c.server.messages.WatchSession(c.sessionID)
+
+	seen := make(map[uint]bool)
+	var last *pagination.Cursor
+	history, err := c.server.messages.GetMessagesBySessionPage(c.sessionID, pagination.Page{Limit: historyPageSize})
+	if err != nil {
+		log.Printf("Error loading history for session %s: %v", c.sessionID, err)
+	} else if len(history.Messages) > 0 {
+		for _, m := range history.Messages {
+			seen[m.ID] = true
+		}
+		last = messageCursor(history.Messages[len(history.Messages)-1])
+		c.sendMessage(ws.TypeChatHistory, toHistoryPage(history))
+	}
+	c.sendMessage(ws.TypeConnected, nil)
+
+	go c.writePump()
+	go c.relay(messages, cancel, seen, last)
+	c.readPump()
+}
+
+// close ends the connection; it is safe to call more than once
+func (c *connection) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *connection) readPump() {
+	defer c.close()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		var message ws.Envelope
+		if err := c.conn.ReadJSON(&message); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("WebSocket read error: %v", err)
+			}
+			return
+		}
+		c.handleMessage(message)
+	}
+}
+
+func (c *connection) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.close()
+	}()
+
 	for {
-		_, msg, err := conn.ReadMessage()
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *connection) handleMessage(message ws.Envelope) {
+	switch message.Type {
+	case ws.TypeChat:
+		c.handleChat(message)
+	case ws.TypePing:
+		c.sendMessage(ws.TypePong, nil)
+	case ws.TypeLoadMore:
+		c.handleLoadMore(message)
+	default:
+		c.sendError(fmt.Sprintf("Unknown message type: %s", message.Type))
+	}
+}
+
+// handleChat stores a user message, acknowledges it and has the character
+// answer in the background. The reply reaches this and every other
+// connection on the session through the relay.
+func (c *connection) handleChat(message ws.Envelope) {
+	var request ws.ChatRequest
+	if err := ws.DecodeContent(message.Content, &request); err != nil {
+		c.sendError("Invalid chat message format")
+		return
+	}
+	if request.Sender != "user" {
+		c.sendError("Only user messages can be sent")
+		return
+	}
+	if request.Content == "" {
+		c.sendError("Message content cannot be empty")
+		return
+	}
+	if !c.claims.HasRole(jwt.RoleUser) {
+		c.sendError("Insufficient role to send messages")
+		return
+	}
+
+	if request.ID == "" {
+		request.ID = fmt.Sprintf("msg-%d", time.Now().UnixNano())
+	}
+	userMessage := &models.Message{
+		ExternalID:  request.ID,
+		CharacterID: c.character.ID,
+		SessionID:   c.sessionID,
+		Sender:      "user",
+		Content:     request.Content,
+	}
+
+	// Mark the message before storing it; the relay may see it before CreateMessage returns
+	c.sent.Store(userMessage.ExternalID, true)
+	if err := c.server.messages.CreateMessage(userMessage, c.claims.UserID); err != nil {
+		c.sent.Delete(userMessage.ExternalID)
+		log.Printf("Error saving message for session %s: %v", c.sessionID, err)
+		c.sendError("Failed to save message")
+		return
+	}
+
+	c.sendMessage(ws.TypeAck, ws.Ack{MessageID: userMessage.ExternalID, Status: "received"})
+
+	go c.reply(request.Content)
+}
+
+// reply asks the AI provider for the character's answer, streams it to this
+// connection as it arrives and stores it. The stored reply reaches every
+// connection as chat, and is kept even if this connection closes meanwhile.
+func (c *connection) reply(userMessage string) {
+	c.sendMessage(ws.TypeTyping, ws.Typing{IsTyping: true})
+
+	var history []ws.ChatMessage
+	recent, err := c.server.messages.GetMessagesBySessionPage(c.sessionID, pagination.Page{Limit: replyHistorySize})
+	if err != nil {
+		log.Printf("Error loading history for session %s: %v", c.sessionID, err)
+	} else {
+		history = toChatMessages(recent.Messages)
+	}
+
+	replyID := fmt.Sprintf("resp-%d", time.Now().UnixNano())
+	ctx, cancel := context.WithTimeout(context.Background(), replyTimeout)
+	defer cancel()
+	response, err := c.server.respond(ctx, c.character, userMessage, history, func(delta string) {
+		c.sendMessage(ws.TypeReplyDelta, ws.ReplyDelta{ID: replyID, CharacterID: c.character.ID, Delta: delta})
+	})
+	if err != nil {
+		log.Printf("Error generating AI response for session %s: %v", c.sessionID, err)
+		c.sendError("Failed to generate response from the AI character")
+		return
+	}
+
+	characterMessage := &models.Message{
+		ExternalID:  replyID,
+		CharacterID: c.character.ID,
+		SessionID:   c.sessionID,
+		Sender:      "character",
+		Content:     response,
+	}
+	if err := c.server.messages.CreateMessage(characterMessage, c.claims.UserID); err != nil {
+		log.Printf("Error saving character message for session %s: %v", c.sessionID, err)
+		c.sendError("Failed to save the character's reply")
+	}
+}
+
+// handleLoadMore sends the page of history before the given cursor
+func (c *connection) handleLoadMore(message ws.Envelope) {
+	var request ws.LoadMoreRequest
+	if err := ws.DecodeContent(message.Content, &request); err != nil || request.Before == "" {
+		c.sendError("load_more requires a before cursor")
+		return
+	}
+
+	page, err := pagination.ParsePage(request.Before, "", request.Limit)
+	if err != nil {
+		c.sendError(err.Error())
+		return
+	}
+	result, err := c.server.messages.GetMessagesBySessionPage(c.sessionID, page)
+	if err != nil {
+		log.Printf("Error loading history page for session %s: %v", c.sessionID, err)
+		c.sendError(fmt.Sprintf("Failed to load history: %v", err))
+		return
+	}
+
+	c.sendMessage(ws.TypeHistoryPage, toHistoryPage(result))
+}
+
+// relay forwards the session's new messages to the client. A watcher that
+// falls behind is closed by the message service; relay then subscribes again
+// and catches up from the last message it delivered.
+func (c *connection) relay(messages <-chan models.Message, cancel func(), seen map[uint]bool, last *pagination.Cursor) {
+	defer func() { cancel() }()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case m, ok := <-messages:
+			if !ok {
+				messages, cancel = c.server.messages.WatchSession(c.sessionID)
+				last = c.catchUp(last, seen)
+				continue
+			}
+			if seen[m.ID] {
+				continue
+			}
+			c.deliver(m)
+			last = messageCursor(m)
+		}
+	}
+}
+
+// catchUp delivers the messages created after last and returns the new last
+// cursor. Messages already delivered, such as the history frame's when
+// nothing has been relayed yet, are skipped.
+func (c *connection) catchUp(last *pagination.Cursor, seen map[uint]bool) *pagination.Cursor {
+	for {
+		result, err := c.server.messages.GetMessagesBySessionPage(c.sessionID, pagination.Page{After: last, Limit: pagination.MaxLimit})
 		if err != nil {
-			log.Printf("WebSocket read error: %v", err)
-			break
+			log.Printf("Error catching up session %s: %v", c.sessionID, err)
+			return last
+		}
+		for _, m := range result.Messages {
+			last = messageCursor(m)
+			if seen[m.ID] {
+				continue
+			}
+			seen[m.ID] = true
+			c.deliver(m)
 		}
-		log.Printf("Received message: %s", msg)
-		// Echo back for now
-		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-			log.Printf("WebSocket write error: %v", err)
-			break
+		// Without a cursor the page is the latest one, so there is nothing newer
+		if last == nil || !result.HasNewer || len(result.Messages) == 0 {
+			return last
 		}
 	}
-} 
\ No newline at end of file
+}
+
+// deliver sends a session message as chat, skipping this connection's own
+// user messages, which were acknowledged instead
+func (c *connection) deliver(m models.Message) {
+	if m.Sender == "user" {
+		if _, own := c.sent.LoadAndDelete(m.ExternalID); own {
+			return
+		}
+	}
+	c.sendMessage(ws.TypeChat, toChatMessage(m))
+}
+
+func (c *connection) sendMessage(messageType string, content interface{}) {
+	data, err := json.Marshal(ws.Envelope{Type: messageType, Content: content})
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+	select {
+	case c.send <- data:
+	case <-c.done:
+	}
+}
+
+func (c *connection) sendError(errorText string) {
+	c.sendMessage(ws.TypeError, ws.Error{Message: errorText})
+}
+
+func messageCursor(m models.Message) *pagination.Cursor {
+	return &pagination.Cursor{Time: m.Timestamp, ID: m.ID}
+}
+
+func toChatMessage(m models.Message) ws.ChatMessage {
+	return ws.ChatMessage{
+		ID:          m.ExternalID,
+		Sender:      m.Sender,
+		CharacterID: m.CharacterID,
+		Content:     m.Content,
+		Timestamp:   m.Timestamp,
+	}
+}
+
+func toChatMessages(messages []models.Message) []ws.ChatMessage {
+	out := make([]ws.ChatMessage, len(messages))
+	for i, m := range messages {
+		out[i] = toChatMessage(m)
+	}
+	return out
+}
+
+func toHistoryPage(page *service.MessagePage) *ws.HistoryPage {
+	return &ws.HistoryPage{
+		Messages:   toChatMessages(page.Messages),
+		PrevCursor: page.PrevCursor,
+		HasMore:    page.HasOlder,
+	}
+}
+
+// requestToken reads a bearer token from the query string or Authorization header.
+// Browsers cannot set headers on WebSocket requests, so the query is checked first.
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
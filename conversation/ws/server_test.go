@@ -0,0 +1,320 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/conversation/models"
+	"ai-agent-character-demo/backend/conversation/service"
+	"ai-agent-character-demo/backend/pkg/jwt"
+	"ai-agent-character-demo/backend/pkg/pagination"
+	"ai-agent-character-demo/backend/pkg/ws"
+)
+
+// memoryRepository is an in-memory MessageRepository; messages are kept in
+// creation order, which is also timestamp order in these tests
+type memoryRepository struct {
+	mu       sync.Mutex
+	messages []models.Message
+}
+
+func (r *memoryRepository) Create(message *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	message.ID = uint(len(r.messages) + 1)
+	message.CreatedAt = time.Now()
+	r.messages = append(r.messages, *message)
+	return nil
+}
+
+func (r *memoryRepository) GetByID(id uint) (*models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == 0 || int(id) > len(r.messages) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	m := r.messages[id-1]
+	return &m, nil
+}
+
+func (r *memoryRepository) GetBySession(sessionID string) ([]models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messages []models.Message
+	for _, m := range r.messages {
+		if m.SessionID == sessionID {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (r *memoryRepository) GetBySessionPage(sessionID string, page pagination.Page) ([]models.Message, bool, error) {
+	all, _ := r.GetBySession(sessionID)
+
+	var window []models.Message
+	for _, m := range all {
+		switch {
+		case page.After != nil && m.ID <= page.After.ID:
+		case page.Before != nil && m.ID >= page.Before.ID:
+		default:
+			window = append(window, m)
+		}
+	}
+
+	if page.After != nil {
+		if len(window) > page.Limit {
+			return window[:page.Limit], true, nil
+		}
+		return window, false, nil
+	}
+	if len(window) > page.Limit {
+		return window[len(window)-page.Limit:], true, nil
+	}
+	return window, false, nil
+}
+
+// sessionRepository maps session IDs to their owners
+type sessionRepository map[string]uint
+
+func (r sessionRepository) GetBySessionID(sessionID string) (*models.Session, error) {
+	owner, ok := r[sessionID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	// Character 8 takes part in every session but has been deleted
+	return &models.Session{SessionID: sessionID, UserID: &owner, CharacterID: 7, CharacterIDs: []uint{7, 8}}, nil
+}
+
+type characterRepository struct{}
+
+func (characterRepository) GetByID(id uint) (*ws.Character, error) {
+	if id != 7 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &ws.Character{ID: 7, Name: "Ada"}, nil
+}
+
+// echoResponder streams the reply in two pieces
+func echoResponder(_ context.Context, character *ws.Character, userMessage string, _ []ws.ChatMessage, delta func(string)) (string, error) {
+	delta(character.Name + ": ")
+	delta(userMessage)
+	return character.Name + ": " + userMessage, nil
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *service.MessageService, *jwt.Service) {
+	t.Helper()
+
+	jwtService := jwt.NewService("test-secret", time.Hour)
+	messageService := service.NewMessageService(&memoryRepository{}, sessionRepository{"session-1": 42, "session-other": 43})
+	server := httptest.NewServer(NewServer(messageService, characterRepository{}, echoResponder, jwtService).Handler())
+	t.Cleanup(server.Close)
+
+	return server, messageService, jwtService
+}
+
+func chatURL(server *httptest.Server, params url.Values) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/chat?" + params.Encode()
+}
+
+func dial(t *testing.T, server *httptest.Server, jwtService *jwt.Service, sessionID string) *websocket.Conn {
+	t.Helper()
+
+	token, err := jwtService.GenerateToken(42, "user@example.com", jwt.RoleUser, jwt.GetRolePermissions(jwt.RoleUser))
+	require.NoError(t, err)
+
+	conn, _, err := websocket.DefaultDialer.Dial(chatURL(server, url.Values{
+		"characterId": {"7"},
+		"sessionId":   {sessionID},
+		"token":       {token},
+	}), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// next reads envelopes until one of the given type arrives
+func next(t *testing.T, conn *websocket.Conn, messageType string, v interface{}) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var envelope ws.Envelope
+		require.NoError(t, conn.ReadJSON(&envelope))
+		if envelope.Type == messageType {
+			if v != nil {
+				require.NoError(t, ws.DecodeContent(envelope.Content, v))
+			}
+			return
+		}
+	}
+}
+
+func TestRejectsMissingToken(t *testing.T) {
+	server, _, _ := newTestServer(t)
+
+	_, resp, err := websocket.DefaultDialer.Dial(chatURL(server, url.Values{
+		"characterId": {"7"},
+		"sessionId":   {"session-1"},
+	}), nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestRejectsUnknownCharacter(t *testing.T) {
+	server, _, jwtService := newTestServer(t)
+	token, err := jwtService.GenerateToken(42, "user@example.com", jwt.RoleUser, nil)
+	require.NoError(t, err)
+
+	_, resp, err := websocket.DefaultDialer.Dial(chatURL(server, url.Values{
+		"characterId": {"8"},
+		"sessionId":   {"session-1"},
+		"token":       {token},
+	}), nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRejectsOtherUsersSessions(t *testing.T) {
+	server, _, jwtService := newTestServer(t)
+	token, err := jwtService.GenerateToken(42, "user@example.com", jwt.RoleUser, nil)
+	require.NoError(t, err)
+
+	for _, sessionID := range []string{"session-other", "session-unknown"} {
+		_, resp, err := websocket.DefaultDialer.Dial(chatURL(server, url.Values{
+			"characterId": {"7"},
+			"sessionId":   {sessionID},
+			"token":       {token},
+		}), nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, sessionID)
+	}
+}
+
+func TestRejectsCharacterOutsideSession(t *testing.T) {
+	server, _, jwtService := newTestServer(t)
+	token, err := jwtService.GenerateToken(42, "user@example.com", jwt.RoleUser, nil)
+	require.NoError(t, err)
+
+	_, resp, err := websocket.DefaultDialer.Dial(chatURL(server, url.Values{
+		"characterId": {"9"},
+		"sessionId":   {"session-1"},
+		"token":       {token},
+	}), nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestChatPersistsRepliesAndBroadcasts(t *testing.T) {
+	server, messageService, jwtService := newTestServer(t)
+
+	sender := dial(t, server, jwtService, "session-1")
+	next(t, sender, ws.TypeConnected, nil)
+	watcher := dial(t, server, jwtService, "session-1")
+	next(t, watcher, ws.TypeConnected, nil)
+
+	require.NoError(t, sender.WriteJSON(ws.Envelope{
+		Type:    ws.TypeChat,
+		Content: ws.ChatRequest{ID: "msg-1", Sender: "user", Content: "Hello"},
+	}))
+
+	var ack ws.Ack
+	next(t, sender, ws.TypeAck, &ack)
+	assert.Equal(t, "msg-1", ack.MessageID)
+
+	// The reply streams to the sender, then arrives stored
+	var first, second ws.ReplyDelta
+	next(t, sender, ws.TypeReplyDelta, &first)
+	next(t, sender, ws.TypeReplyDelta, &second)
+	assert.Equal(t, "Ada: Hello", first.Delta+second.Delta)
+	assert.Equal(t, uint(7), first.CharacterID)
+
+	var reply ws.ChatMessage
+	next(t, sender, ws.TypeChat, &reply)
+	assert.Equal(t, "character", reply.Sender)
+	assert.Equal(t, "Ada: Hello", reply.Content)
+	assert.Equal(t, first.ID, reply.ID)
+	assert.Equal(t, uint(7), reply.CharacterID)
+
+	// Other connections on the session see the user message and the reply
+	var relayed ws.ChatMessage
+	next(t, watcher, ws.TypeChat, &relayed)
+	assert.Equal(t, "msg-1", relayed.ID)
+	next(t, watcher, ws.TypeChat, &relayed)
+	assert.Equal(t, "Ada: Hello", relayed.Content)
+
+	stored, err := messageService.GetMessagesBySession("session-1")
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "user", stored[0].Sender)
+	assert.Equal(t, uint(7), stored[0].CharacterID)
+
+	// A new connection receives the stored history
+	late := dial(t, server, jwtService, "session-1")
+	var history ws.HistoryPage
+	next(t, late, ws.TypeChatHistory, &history)
+	assert.Len(t, history.Messages, 2)
+	assert.False(t, history.HasMore)
+}
+
+func TestLoadMore(t *testing.T) {
+	server, messageService, jwtService := newTestServer(t)
+	for i := 0; i < historyPageSize+5; i++ {
+		require.NoError(t, messageService.CreateMessage(&models.Message{SessionID: "session-1", Sender: "user", Content: "hi"}, 42))
+	}
+
+	conn := dial(t, server, jwtService, "session-1")
+	var history ws.HistoryPage
+	next(t, conn, ws.TypeChatHistory, &history)
+	assert.Len(t, history.Messages, historyPageSize)
+	require.True(t, history.HasMore)
+
+	require.NoError(t, conn.WriteJSON(ws.Envelope{
+		Type:    ws.TypeLoadMore,
+		Content: ws.LoadMoreRequest{Before: history.PrevCursor},
+	}))
+	var older ws.HistoryPage
+	next(t, conn, ws.TypeHistoryPage, &older)
+	assert.Len(t, older.Messages, 5)
+	assert.False(t, older.HasMore)
+}
+
+func TestCatchUpSkipsDeliveredMessages(t *testing.T) {
+	_, messageService, _ := newTestServer(t)
+	for _, content := range []string{"a", "b"} {
+		require.NoError(t, messageService.CreateMessage(&models.Message{SessionID: "session-1", Sender: "character", Content: content}, 42))
+	}
+
+	c := &connection{
+		server:    &Server{messages: messageService},
+		send:      make(chan []byte, 8),
+		done:      make(chan struct{}),
+		sessionID: "session-1",
+	}
+	// Both messages went out in the history frame; only the new one is delivered
+	seen := map[uint]bool{1: true, 2: true}
+	require.NoError(t, messageService.CreateMessage(&models.Message{SessionID: "session-1", Sender: "character", Content: "c"}, 42))
+
+	last := c.catchUp(nil, seen)
+	require.NotNil(t, last)
+	assert.Equal(t, uint(3), last.ID)
+	require.Len(t, c.send, 1)
+
+	var envelope ws.Envelope
+	require.NoError(t, json.Unmarshal(<-c.send, &envelope))
+	var delivered ws.ChatMessage
+	require.NoError(t, ws.DecodeContent(envelope.Content, &delivered))
+	assert.Equal(t, "c", delivered.Content)
+}
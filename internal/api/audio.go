@@ -1,35 +1,42 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"ai-agent-character-demo/backend/internal/models"
 	"ai-agent-character-demo/backend/internal/service"
+	"ai-agent-character-demo/backend/pkg/audio"
 	"ai-agent-character-demo/backend/pkg/jwt"
 )
 
 // AudioController handles audio-related API endpoints
 type AudioController struct {
-	audioService *service.AudioService
-	jwtService   *jwt.Service
-	mlApiKey     string
+	audioService        *service.AudioService
+	messageAudioService *service.MessageAudioService
+	waveformService     *service.WaveformService
+	uploadService       *service.AudioUploadService
+	integrityService    *service.AudioIntegrityService
+	conversationService *service.ConversationService
+	jwtService          *jwt.Service
+	mlApiKey            string
 }
 
 // NewAudioController creates a new audio controller
 func NewAudioController(audioService *service.AudioService, jwtService *jwt.Service) *AudioController {
-	// Get ML API key from environment or use a default for development
+	// ML API routes stay disabled unless ML_API_KEY is set
 	mlApiKey := os.Getenv("ML_API_KEY")
-	if mlApiKey == "" {
-		mlApiKey = "ml-api-key-12345" // Default for development only
-	}
 
 	return &AudioController{
 		audioService: audioService,
@@ -38,6 +45,27 @@ func NewAudioController(audioService *service.AudioService, jwtService *jwt.Serv
 	}
 }
 
+// SetMessageAudioService enables replay of message audio, including re-synthesis
+// of character replies whose stored audio has expired
+func (c *AudioController) SetMessageAudioService(messageAudioService *service.MessageAudioService) {
+	c.messageAudioService = messageAudioService
+}
+
+// SetWaveformService enables the waveform peaks endpoint
+func (c *AudioController) SetWaveformService(waveformService *service.WaveformService) {
+	c.waveformService = waveformService
+}
+
+// SetConversationService lets uploads check that the caller owns the session
+func (c *AudioController) SetConversationService(conversationService *service.ConversationService) {
+	c.conversationService = conversationService
+}
+
+// SetIntegrityService enables the ML integrity report endpoints
+func (c *AudioController) SetIntegrityService(integrityService *service.AudioIntegrityService) {
+	c.integrityService = integrityService
+}
+
 // RegisterRoutes registers the routes for the audio controller
 func (c *AudioController) RegisterRoutes(router *gin.Engine) {
 	audioGroup := router.Group("/api/audio")
@@ -71,8 +99,12 @@ func (c *AudioController) RegisterRoutesV1(router *gin.RouterGroup) {
 	{
 		audioGroup.POST("/upload", c.validateUploadRequest(), c.UploadAudio)
 		audioGroup.GET("/:id", c.GetAudio)
+		audioGroup.GET("/:id/raw", c.GetRawAudio)
+		audioGroup.GET("/:id/peaks", c.GetAudioPeaks)
+		audioGroup.GET("/messages/:messageId", c.GetMessageAudio)
 		audioGroup.GET("/session/:sessionId", c.GetSessionAudio)
 		audioGroup.POST("/stream", c.validateStreamRequest(), c.StreamAudio)
+		c.registerUploadRoutes(audioGroup)
 	}
 
 	// ML API routes for audio processing
@@ -83,6 +115,8 @@ func (c *AudioController) RegisterRoutesV1(router *gin.RouterGroup) {
 		mlGroup.GET("/chunk/:id", c.GetAudioChunk)
 		mlGroup.PUT("/chunk/:id/status", c.validateStatusUpdate(), c.UpdateChunkStatus)
 		mlGroup.POST("/process", c.ProcessAudioData)
+		mlGroup.GET("/integrity", c.GetIntegrityReport)
+		mlGroup.POST("/integrity/verify", c.VerifyIntegrity)
 	}
 }
 
@@ -90,6 +124,11 @@ func (c *AudioController) RegisterRoutesV1(router *gin.RouterGroup) {
 func (c *AudioController) authMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		token := ctx.GetHeader("Authorization")
+		if token == "" && ctx.Request.Method == http.MethodGet && strings.HasSuffix(ctx.FullPath(), "/:id/raw") {
+			// Media elements can't set headers, so raw playback URLs may carry
+			// the token; every other route keeps it out of URLs and logs
+			token = ctx.Query("token")
+		}
 		if token == "" {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
 			return
@@ -116,6 +155,11 @@ func (c *AudioController) authMiddleware() gin.HandlerFunc {
 // mlAuthMiddleware provides special authentication for ML API
 func (c *AudioController) mlAuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if c.mlApiKey == "" {
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "ML API is disabled"})
+			return
+		}
+
 		apiKey := ctx.GetHeader("X-ML-API-Key")
 		if apiKey == "" {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key is required"})
@@ -289,6 +333,19 @@ func (c *AudioController) UploadAudio(ctx *gin.Context) {
 		return
 	}
 
+	if c.conversationService == nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Conversation service not available"})
+		return
+	}
+	if err := c.conversationService.CheckSessionAccess(sessionID, userId.(uint)); err != nil {
+		if errors.Is(err, service.ErrConversationForbidden) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this session"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error checking session access: %v", err)})
+		return
+	}
+
 	charIDStr := ctx.PostForm("charId")
 	if charIDStr == "" {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "charId is required"})
@@ -370,8 +427,8 @@ func (c *AudioController) UploadAudio(ctx *gin.Context) {
 		return
 	}
 
-	// Store the audio chunk
-	chunkID, err := c.audioService.StoreAudioChunk(
+	// Store the audio chunk; retried uploads resolve to the existing chunk
+	chunkID, duplicate, err := c.audioService.StoreAudioChunkDedup(
 		fmt.Sprintf("%d", userId.(uint)),
 		sessionID,
 		uint(charID),
@@ -395,6 +452,7 @@ func (c *AudioController) UploadAudio(ctx *gin.Context) {
 		"format":    format,
 		"duration":  duration,
 		"expiresAt": time.Now().Add(ttl),
+		"duplicate": duplicate,
 	})
 }
 
@@ -425,9 +483,8 @@ func (c *AudioController) GetAudioChunk(ctx *gin.Context) {
 	}
 
 	// Return audio data
-	ctx.Header("Content-Type", "audio/"+chunk.Format)
 	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audio-%s.%s", chunkID, chunk.Format))
-	ctx.Data(http.StatusOK, "audio/"+chunk.Format, chunk.AudioData)
+	c.sendAudio(ctx, chunk)
 }
 
 // GetSessionAudioChunks retrieves all audio chunks for a session
@@ -463,7 +520,7 @@ func (c *AudioController) GetSessionAudioChunks(ctx *gin.Context) {
 				"createdAt":        chunk.CreatedAt,
 				"expiresAt":        chunk.ExpiresAt,
 				"processingStatus": chunk.ProcessingStatus,
-				"size":             len(chunk.AudioData),
+				"size":             chunk.ByteSize(),
 			})
 		}
 	}
@@ -524,6 +581,11 @@ func (c *AudioController) GetAudioChunkForML(ctx *gin.Context) {
 		return
 	}
 
+	if err := c.audioService.LoadAudio(ctx.Request.Context(), chunk); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error loading audio chunk: %v", err)})
+		return
+	}
+
 	// Return audio data with metadata
 	ctx.JSON(http.StatusOK, gin.H{
 		"id":               chunk.ID,
@@ -584,7 +646,7 @@ func (c *AudioController) GetPendingAudioChunks(ctx *gin.Context) {
 			"expiresAt":        chunk.ExpiresAt,
 			"metadata":         chunk.Metadata,
 			"processingStatus": chunk.ProcessingStatus,
-			"size":             len(chunk.AudioData),
+			"size":             chunk.ByteSize(),
 		})
 	}
 
@@ -680,7 +742,7 @@ func (c *AudioController) GetAllAudioChunks(ctx *gin.Context) {
 			"expiresAt":        chunk.ExpiresAt,
 			"metadata":         chunk.Metadata,
 			"processingStatus": chunk.ProcessingStatus,
-			"size":             len(chunk.AudioData),
+			"size":             chunk.ByteSize(),
 		}
 	}
 
@@ -728,9 +790,8 @@ func (c *AudioController) GetAudio(ctx *gin.Context) {
 	}
 
 	// Return audio data
-	ctx.Header("Content-Type", "audio/"+chunk.Format)
 	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audio-%s.%s", audioID, chunk.Format))
-	ctx.Data(http.StatusOK, "audio/"+chunk.Format, chunk.AudioData)
+	c.sendAudio(ctx, chunk)
 }
 
 // GetSessionAudio retrieves all audio for a session
@@ -767,7 +828,7 @@ func (c *AudioController) GetSessionAudio(ctx *gin.Context) {
 				"createdAt":        chunk.CreatedAt,
 				"expiresAt":        chunk.ExpiresAt,
 				"processingStatus": chunk.ProcessingStatus,
-				"size":             len(chunk.AudioData),
+				"size":             chunk.ByteSize(),
 			})
 		}
 	}
@@ -821,7 +882,7 @@ func (c *AudioController) GetPendingChunks(ctx *gin.Context) {
 			"expiresAt":        chunk.ExpiresAt,
 			"metadata":         chunk.Metadata,
 			"processingStatus": chunk.ProcessingStatus,
-			"size":             len(chunk.AudioData),
+			"size":             chunk.ByteSize(),
 		})
 	}
 
@@ -903,3 +964,207 @@ func (c *AudioController) ProcessAudioData(ctx *gin.Context) {
 		"estimatedProcessingTime": "5s",
 	})
 }
+
+// GetIntegrityReport returns the latest audio integrity verification result
+func (c *AudioController) GetIntegrityReport(ctx *gin.Context) {
+	if c.integrityService == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": "Integrity verification is not enabled"})
+		return
+	}
+
+	report := c.integrityService.LastReport()
+	if report == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No integrity verification has run yet"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// VerifyIntegrity runs an integrity verification pass immediately
+func (c *AudioController) VerifyIntegrity(ctx *gin.Context) {
+	if c.integrityService == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": "Integrity verification is not enabled"})
+		return
+	}
+
+	report, err := c.integrityService.VerifyIntegrity()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error verifying audio integrity: %v", err)})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// GetMessageAudio returns the voice for a message, re-synthesizing expired character replies
+func (c *AudioController) GetMessageAudio(ctx *gin.Context) {
+	userId, exists := ctx.Get("userId")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	messageID := ctx.Param("messageId")
+	if messageID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Message ID is required"})
+		return
+	}
+
+	if c.messageAudioService == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": "Message audio replay is not enabled"})
+		return
+	}
+
+	chunk, err := c.messageAudioService.GetOrSynthesize(ctx.Request.Context(), messageID, userId.(uint))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMessageNotFound), errors.Is(err, service.ErrAudioNotReplayable):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrConversationForbidden):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this audio"})
+		default:
+			ctx.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Error retrieving message audio: %v", err)})
+		}
+		return
+	}
+
+	serveAudioChunk(ctx, c.audioService, chunk, fmt.Sprintf("message-%s.%s", messageID, chunk.Format))
+}
+
+// GetRawAudio streams the stored bytes of an audio chunk with Range, ETag and
+// caching support so players can seek without downloading the whole file
+func (c *AudioController) GetRawAudio(ctx *gin.Context) {
+	chunk, ok := c.getOwnedChunk(ctx)
+	if !ok {
+		return
+	}
+
+	serveAudioChunk(ctx, c.audioService, chunk, fmt.Sprintf("audio-%d.%s", chunk.ID, chunk.Format))
+}
+
+// GetAudioPeaks returns downsampled waveform min/max data for an audio chunk.
+// The optional samples query parameter sets the number of min/max pairs.
+func (c *AudioController) GetAudioPeaks(ctx *gin.Context) {
+	if c.waveformService == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": "Waveform peaks are not enabled"})
+		return
+	}
+
+	chunk, ok := c.getOwnedChunk(ctx)
+	if !ok {
+		return
+	}
+
+	buckets := service.DefaultPeakBuckets
+	if samplesStr := ctx.Query("samples"); samplesStr != "" {
+		parsed, err := strconv.Atoi(samplesStr)
+		if err != nil || parsed < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "samples must be a positive integer"})
+			return
+		}
+		buckets = parsed
+	}
+
+	etag := fmt.Sprintf(`"%s-peaks-%d"`, audioETag(chunk), buckets)
+	setAudioCacheHeaders(ctx, chunk, etag)
+	if ctx.GetHeader("If-None-Match") == etag {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	peaks, err := c.waveformService.GetPeaks(ctx.Request.Context(), chunk, buckets)
+	if err != nil {
+		if errors.Is(err, audio.ErrTranscoderUnavailable) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Peaks for %s audio require ffmpeg on the server", chunk.Format)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error computing peaks: %v", err)})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, peaks)
+}
+
+// getOwnedChunk loads the chunk named by the id parameter and checks that the
+// caller may read it, writing the error response if not
+func (c *AudioController) getOwnedChunk(ctx *gin.Context) (*models.AudioChunk, bool) {
+	userId, exists := ctx.Get("userId")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return nil, false
+	}
+
+	audioID := ctx.Param("id")
+	if audioID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Audio ID is required"})
+		return nil, false
+	}
+
+	chunk, err := c.audioService.GetAudioChunk(audioID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Error retrieving audio: %v", err)})
+		return nil, false
+	}
+
+	// Audio captured over an unauthenticated socket has no owner and is
+	// served to no one
+	if chunk.UserID != fmt.Sprintf("%d", userId.(uint)) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this audio"})
+		return nil, false
+	}
+
+	return chunk, true
+}
+
+// audioOpener reads the bytes of a stored chunk, wherever they are kept
+type audioOpener interface {
+	OpenAudio(ctx context.Context, chunk *models.AudioChunk) (io.ReadSeekCloser, error)
+}
+
+// serveAudioChunk writes chunk bytes through http.ServeContent, which handles
+// Range, If-Range and conditional requests
+func serveAudioChunk(ctx *gin.Context, opener audioOpener, chunk *models.AudioChunk, filename string) {
+	content, err := opener.OpenAudio(ctx.Request.Context(), chunk)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error reading audio: %v", err)})
+		return
+	}
+	defer content.Close()
+
+	setAudioCacheHeaders(ctx, chunk, fmt.Sprintf(`"%s"`, audioETag(chunk)))
+	ctx.Header("Content-Type", audio.ContentType(chunk.Format))
+	ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=%s", filename))
+	http.ServeContent(ctx.Writer, ctx.Request, filename, chunk.CreatedAt, content)
+}
+
+// sendAudio writes a chunk's bytes as a download, streaming those kept in the
+// blob store
+func (c *AudioController) sendAudio(ctx *gin.Context, chunk *models.AudioChunk) {
+	content, err := c.audioService.OpenAudio(ctx.Request.Context(), chunk)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error reading audio: %v", err)})
+		return
+	}
+	defer content.Close()
+
+	ctx.DataFromReader(http.StatusOK, chunk.ByteSize(), "audio/"+chunk.Format, content, nil)
+}
+
+// audioETag identifies chunk contents; stored chunks are never modified in place
+func audioETag(chunk *models.AudioChunk) string {
+	if chunk.ContentHash != "" {
+		return chunk.ContentHash[:32]
+	}
+	return models.HashAudio(chunk.AudioData)[:32]
+}
+
+// setAudioCacheHeaders lets clients cache a chunk until it expires
+func setAudioCacheHeaders(ctx *gin.Context, chunk *models.AudioChunk, etag string) {
+	maxAge := int(time.Until(chunk.ExpiresAt).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	ctx.Header("ETag", etag)
+	ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d, immutable", maxAge))
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-agent-character-demo/backend/internal/service"
+)
+
+// tusVersion is advertised on resumable upload responses. The protocol follows
+// tus 1.0 core semantics (offset-checked PATCH, HEAD for progress) plus an
+// explicit finalize step that verifies the whole-file checksum.
+const tusVersion = "1.0.0"
+
+// maxUploadPatchSize bounds the body of a single PATCH request
+const maxUploadPatchSize = 32 << 20
+
+// SetUploadService enables resumable audio uploads
+func (c *AudioController) SetUploadService(uploadService *service.AudioUploadService) {
+	c.uploadService = uploadService
+}
+
+// registerUploadRoutes registers the resumable upload endpoints on the audio group
+func (c *AudioController) registerUploadRoutes(audioGroup *gin.RouterGroup) {
+	audioGroup.POST("/uploads", c.CreateUpload)
+	audioGroup.HEAD("/uploads/:uploadId", c.GetUploadOffset)
+	audioGroup.GET("/uploads/:uploadId", c.GetUploadStatus)
+	audioGroup.PATCH("/uploads/:uploadId", c.PatchUpload)
+	audioGroup.POST("/uploads/:uploadId/finalize", c.FinalizeUpload)
+	audioGroup.DELETE("/uploads/:uploadId", c.CancelUpload)
+}
+
+// CreateUpload starts a resumable upload
+func (c *AudioController) CreateUpload(ctx *gin.Context) {
+	userID, ok := c.uploadUser(ctx)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		SessionID  string  `json:"sessionId" binding:"required"`
+		CharID     uint    `json:"charId" binding:"required"`
+		Format     string  `json:"format" binding:"omitempty,oneof=webm mp3 wav ogg"`
+		SampleRate int     `json:"sampleRate"`
+		Channels   int     `json:"channels"`
+		Duration   float64 `json:"duration"`
+		Metadata   string  `json:"metadata"`
+		TTL        string  `json:"ttl"`
+		Length     int64   `json:"length"`
+		Checksum   string  `json:"checksum"` // Hex SHA-256 of the complete file
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request format: %v", err)})
+		return
+	}
+
+	// tus clients send the total size as a header
+	if req.Length == 0 {
+		if header := ctx.GetHeader("Upload-Length"); header != "" {
+			length, err := strconv.ParseInt(header, 10, 64)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Length header"})
+				return
+			}
+			req.Length = length
+		}
+	}
+	if req.Length <= 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "length is required"})
+		return
+	}
+
+	if req.Format == "" {
+		req.Format = "webm"
+	}
+	if req.SampleRate == 0 {
+		req.SampleRate = 48000
+	}
+	if req.Channels == 0 {
+		req.Channels = 1
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+			return
+		}
+		ttl = parsed
+	}
+
+	upload, err := c.uploadService.CreateUpload(userID, service.CreateUploadParams{
+		SessionID:  req.SessionID,
+		CharID:     req.CharID,
+		Format:     req.Format,
+		SampleRate: req.SampleRate,
+		Channels:   req.Channels,
+		Duration:   req.Duration,
+		Metadata:   req.Metadata,
+		TTL:        ttl,
+		Length:     req.Length,
+		Checksum:   req.Checksum,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrUploadTooLarge) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrConversationForbidden) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this session"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error creating upload: %v", err)})
+		return
+	}
+
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Header("Location", ctx.Request.URL.Path+"/"+upload.ID)
+	ctx.Header("Upload-Offset", "0")
+	ctx.JSON(http.StatusCreated, gin.H{
+		"id":        upload.ID,
+		"offset":    upload.Offset,
+		"length":    upload.Length,
+		"expiresAt": upload.ExpiresAt,
+	})
+}
+
+// GetUploadOffset reports upload progress in headers, as tus clients expect
+func (c *AudioController) GetUploadOffset(ctx *gin.Context) {
+	userID, ok := c.uploadUser(ctx)
+	if !ok {
+		return
+	}
+
+	upload, err := c.uploadService.GetUpload(ctx.Param("uploadId"), userID)
+	if err != nil {
+		c.uploadError(ctx, err)
+		return
+	}
+
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	ctx.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	ctx.Status(http.StatusOK)
+}
+
+// GetUploadStatus returns upload progress as JSON
+func (c *AudioController) GetUploadStatus(ctx *gin.Context) {
+	userID, ok := c.uploadUser(ctx)
+	if !ok {
+		return
+	}
+
+	upload, err := c.uploadService.GetUpload(ctx.Param("uploadId"), userID)
+	if err != nil {
+		c.uploadError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, upload)
+}
+
+// PatchUpload appends bytes at the offset given in the Upload-Offset header
+func (c *AudioController) PatchUpload(ctx *gin.Context) {
+	userID, ok := c.uploadUser(ctx)
+	if !ok {
+		return
+	}
+
+	if ctx.ContentType() != "application/offset+octet-stream" {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+
+	offset, err := strconv.ParseInt(ctx.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset header is required"})
+		return
+	}
+
+	checksum, err := parseUploadChecksum(ctx.GetHeader("Upload-Checksum"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxUploadPatchSize)
+	newOffset, err := c.uploadService.AppendChunk(ctx.Param("uploadId"), userID, offset, body, checksum)
+
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	if err != nil {
+		c.uploadError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// FinalizeUpload verifies the assembled file and registers it as an audio chunk
+func (c *AudioController) FinalizeUpload(ctx *gin.Context) {
+	userID, ok := c.uploadUser(ctx)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Checksum string `json:"checksum"`
+	}
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request format: %v", err)})
+			return
+		}
+	}
+
+	chunkID, duplicate, err := c.uploadService.FinalizeUpload(ctx.Param("uploadId"), userID, req.Checksum)
+	if err != nil {
+		c.uploadError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"id":        chunkID,
+		"duplicate": duplicate,
+		"message":   "Audio uploaded successfully",
+	})
+}
+
+// CancelUpload discards an in-progress upload
+func (c *AudioController) CancelUpload(ctx *gin.Context) {
+	userID, ok := c.uploadUser(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.uploadService.CancelUpload(ctx.Param("uploadId"), userID); err != nil {
+		c.uploadError(ctx, err)
+		return
+	}
+
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Status(http.StatusNoContent)
+}
+
+// uploadUser checks that uploads are enabled and returns the caller's ID
+func (c *AudioController) uploadUser(ctx *gin.Context) (string, bool) {
+	if c.uploadService == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": "Resumable uploads are not enabled"})
+		return "", false
+	}
+
+	userId, exists := ctx.Get("userId")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return "", false
+	}
+
+	return fmt.Sprintf("%d", userId.(uint)), true
+}
+
+// uploadError maps upload service errors to HTTP responses
+func (c *AudioController) uploadError(ctx *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, service.ErrUploadNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUploadOffsetMismatch), errors.Is(err, service.ErrUploadIncomplete):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUploadTooLarge), errors.As(err, &maxBytesErr):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUploadChecksumMismatch):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConversationForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this session"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Upload failed: %v", err)})
+	}
+}
+
+// parseUploadChecksum converts a tus "sha256 <base64>" Upload-Checksum header to hex
+func parseUploadChecksum(header string) (string, error) {
+	if header == "" {
+		return "", nil
+	}
+
+	algorithm, value, found := strings.Cut(header, " ")
+	if !found || algorithm != "sha256" {
+		return "", errors.New("Upload-Checksum must use sha256")
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sum) != 32 {
+		return "", errors.New("Upload-Checksum value must be a base64 SHA-256 digest")
+	}
+
+	return hex.EncodeToString(sum), nil
+}
@@ -4,18 +4,20 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
 
 	"ai-agent-character-demo/backend/internal/models"
 	"ai-agent-character-demo/backend/internal/service"
+	"ai-agent-character-demo/backend/pkg/avatar"
+	"ai-agent-character-demo/backend/pkg/charactercard"
+	"ai-agent-character-demo/backend/pkg/jwt"
+	"ai-agent-character-demo/backend/pkg/pagination"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,13 +26,19 @@ import (
 // Do not use 'userID' (uppercase 'D').
 
 type CharacterHandler struct {
-	service *service.CharacterService
+	service   *service.CharacterService
+	knowledge *service.KnowledgeService
 }
 
 func NewCharacterHandler(service *service.CharacterService) *CharacterHandler {
 	return &CharacterHandler{service: service}
 }
 
+// SetKnowledgeService enables the character knowledge endpoints
+func (h *CharacterHandler) SetKnowledgeService(knowledge *service.KnowledgeService) {
+	h.knowledge = knowledge
+}
+
 func (h *CharacterHandler) CreateCharacter(c *gin.Context) {
 	// Log incoming request for debugging
 	log.Printf("[CreateCharacter] Headers: %+v", c.Request.Header)
@@ -62,10 +70,14 @@ func (h *CharacterHandler) CreateCharacter(c *gin.Context) {
 		req.Description = c.PostForm("description")
 		req.Personality = c.PostForm("personality")
 		req.Background = c.PostForm("background")
+		req.Scenario = c.PostForm("scenario")
 		req.Category = c.PostForm("category")
 		req.VoiceType = c.PostForm("voice_type")
 		req.VoiceGender = c.PostForm("voice_gender")
 		req.VoiceStyle = c.PostForm("voice_style")
+		req.Visibility = c.PostForm("visibility")
+		req.Greeting = c.PostForm("greeting")
+		req.ExampleDialogue = c.PostForm("example_dialogue")
 		// Parse arrays from JSON strings
 		traitsStr := c.PostForm("traits")
 		if traitsStr != "" {
@@ -95,6 +107,13 @@ func (h *CharacterHandler) CreateCharacter(c *gin.Context) {
 				req.Relationships = []string{}
 			}
 		}
+		alternateGreetingsStr := c.PostForm("alternate_greetings")
+		if alternateGreetingsStr != "" {
+			if err := json.Unmarshal([]byte(alternateGreetingsStr), &req.AlternateGreetings); err != nil {
+				log.Printf("Error parsing alternate greetings: %v", err)
+				req.AlternateGreetings = []string{}
+			}
+		}
 		// Read is_custom from form (if present)
 		isCustomStr := c.PostForm("is_custom")
 		if isCustomStr == "true" || isCustomStr == "1" {
@@ -103,85 +122,41 @@ func (h *CharacterHandler) CreateCharacter(c *gin.Context) {
 			req.IsCustom = false
 		}
 
-		// First try to process base64 encoded avatar if available
-		base64Image := c.PostForm("avatar_base64")
-		avatarFilename := c.PostForm("avatar_filename")
-
-		if base64Image != "" && avatarFilename != "" {
-			// Create a unique filename with timestamp
-			timestamp := time.Now().UnixNano()
-			filename := fmt.Sprintf("%d_%s", timestamp, filepath.Base(avatarFilename))
-			savePath := filepath.Join("uploads", filename)
-
-			// Extract the base64 data - strip the prefix like "data:image/jpeg;base64,"
-			commaIndex := strings.Index(base64Image, ",")
-			if commaIndex != -1 {
+		// The avatar arrives base64 encoded or as a file; either way it goes
+		// through the avatar pipeline and the URL is generated
+		var avatarData []byte
+		if base64Image := c.PostForm("avatar_base64"); base64Image != "" {
+			// Strip a data URL prefix like "data:image/jpeg;base64,"
+			if commaIndex := strings.Index(base64Image, ","); commaIndex != -1 {
 				base64Image = base64Image[commaIndex+1:]
 			}
-
-			// Decode the base64 image
 			imageData, err := base64.StdEncoding.DecodeString(base64Image)
 			if err != nil {
 				log.Printf("Error decoding base64 image: %v", err)
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid base64 image data"})
 				return
 			}
-
-			// Create the uploads directory if it doesn't exist
-			if err := os.MkdirAll("uploads", 0755); err != nil {
-				log.Printf("Error creating uploads directory: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create uploads directory"})
+			avatarData = imageData
+		} else if file, _, err := c.Request.FormFile("avatar"); err == nil {
+			defer file.Close()
+			imageData, err := io.ReadAll(io.LimitReader(file, maxAvatarSize+1))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read avatar"})
 				return
 			}
-
-			// Save the image to a file
-			if err := os.WriteFile(savePath, imageData, 0644); err != nil {
-				log.Printf("Error saving image file: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+			avatarData = imageData
+		}
+		if avatarData != nil {
+			if len(avatarData) > maxAvatarSize {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "avatar is too large"})
 				return
 			}
-
-			// Set the avatar URL
-			host := c.Request.Host
-			scheme := "http"
-			if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
-				scheme = "https"
-			}
-			req.AvatarURL = fmt.Sprintf("%s://%s/uploads/%s", scheme, host, filename)
-		} else {
-			// Fall back to regular file upload if base64 isn't available
-			file, header, err := c.Request.FormFile("avatar")
-			if err == nil {
-				// File was included
-				defer file.Close()
-
-				// Check if it's an image
-				if !strings.HasPrefix(header.Header.Get("Content-Type"), "image/") {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "uploaded file is not an image"})
-					return
-				}
-
-				// Create a unique filename with timestamp to avoid conflicts
-				timestamp := time.Now().UnixNano()
-				filename := fmt.Sprintf("%d_%s", timestamp, filepath.Base(header.Filename))
-				savePath := filepath.Join("uploads", filename)
-
-				// Save the file
-				if err := c.SaveUploadedFile(header, savePath); err != nil {
-					log.Printf("Error saving file: %v", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
-					return
-				}
-
-				// Set the avatar URL using the server host
-				// In production, you would use your domain name
-				host := c.Request.Host
-				scheme := "http"
-				if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
-					scheme = "https"
-				}
-				req.AvatarURL = fmt.Sprintf("%s://%s/uploads/%s", scheme, host, filename)
+			avatarURL, err := h.service.StoreAvatar(c.Request.Context(), avatarData)
+			if err != nil {
+				characterError(c, err)
+				return
 			}
+			req.AvatarURL = avatarURL
 		}
 		// Log the parsed request
 		log.Printf("[CreateCharacter] Parsed request: %+v", req)
@@ -203,11 +178,17 @@ func (h *CharacterHandler) CreateCharacter(c *gin.Context) {
 		return
 	}
 
+	// The creator owns the character
+	editor, ok := characterEditor(c)
+	if !ok {
+		return
+	}
+
 	log.Printf("Creating character: %+v", req)
-	character, err := h.service.CreateCharacter(&req)
+	character, err := h.service.CreateCharacter(&req, &editor)
 	if err != nil {
 		log.Printf("Error creating character: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		characterError(c, err)
 		return
 	}
 
@@ -215,53 +196,84 @@ func (h *CharacterHandler) CreateCharacter(c *gin.Context) {
 }
 
 func (h *CharacterHandler) GetCharacter(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+	viewer, id, ok := characterParams(c)
+	if !ok {
 		return
 	}
 
-	character, err := h.service.GetCharacter(uint(id))
+	character, err := h.service.GetVisibleCharacter(id, viewer)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		characterError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, character)
 }
 
+// ListCharacters searches the characters listed for the caller, with filters,
+// sorting and cursor pagination
 func (h *CharacterHandler) ListCharacters(c *gin.Context) {
-	// Get user ID from the JWT token context
-	userIdInterface, exists := c.Get("userId")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	viewer, ok := characterEditor(c)
+	if !ok {
 		return
 	}
 
-	// Convert to uint
-	userId, ok := userIdInterface.(uint)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
-		return
+	params := service.SearchCharactersParams{
+		Query:       c.Query("q"),
+		Category:    c.Query("category"),
+		VoiceGender: c.Query("voice_gender"),
+		Type:        c.Query("type"),
+		Sort:        c.Query("sort"),
+		Cursor:      c.Query("cursor"),
+	}
+	// Traits may be repeated or comma-separated
+	for _, value := range c.QueryArray("traits") {
+		for _, trait := range strings.Split(value, ",") {
+			if trait = strings.TrimSpace(trait); trait != "" {
+				params.Traits = append(params.Traits, trait)
+			}
+		}
+	}
+	if chatted := c.Query("chatted"); chatted != "" {
+		b, err := strconv.ParseBool(chatted)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chatted"})
+			return
+		}
+		params.Chatted = b
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		params.Limit = limit
 	}
 
-	// Get characters with conversations involving this user
-	characters, err := h.service.ListCharactersWithConversations(userId)
+	characters, nextCursor, err := h.service.SearchCharacters(viewer, params)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidCharacterSearch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if characters == nil {
-		characters = []models.Character{}
-	}
-
-	c.JSON(http.StatusOK, characters)
+	c.JSON(http.StatusOK, gin.H{
+		"characters":  characters,
+		"next_cursor": nextCursor,
+	})
 }
 
 func (h *CharacterHandler) ListAllCharacters(c *gin.Context) {
-	characters, err := h.service.ListCharacters()
+	viewer, ok := characterEditor(c)
+	if !ok {
+		return
+	}
+
+	characters, err := h.service.ListCharacters(viewer)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -271,3 +283,412 @@ func (h *CharacterHandler) ListAllCharacters(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, characters)
 }
+
+// UpdateCharacter replaces a character's editable fields
+func (h *CharacterHandler) UpdateCharacter(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	var fields models.CharacterFields
+	if err := c.ShouldBindJSON(&fields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	character, err := h.service.UpdateCharacter(id, editor, fields)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, character)
+}
+
+// PatchCharacter applies a JSON merge patch (RFC 7386) to a character
+func (h *CharacterHandler) PatchCharacter(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != "application/merge-patch+json" && contentType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "PATCH expects application/merge-patch+json"})
+		return
+	}
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	character, err := h.service.PatchCharacter(id, editor, patch)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, character)
+}
+
+// DeleteCharacter soft-deletes a character
+func (h *CharacterHandler) DeleteCharacter(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteCharacter(id, editor); err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListCharacterVersions lists a character's versions, newest first
+func (h *CharacterHandler) ListCharacterVersions(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.service.ListVersions(id, editor)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+// GetCharacterVersion returns one version of a character
+func (h *CharacterHandler) GetCharacterVersion(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+	version, ok := characterVersionParam(c)
+	if !ok {
+		return
+	}
+
+	v, err := h.service.GetVersion(id, version, editor)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, v)
+}
+
+// RollbackCharacter restores an earlier version as the character's newest version
+func (h *CharacterHandler) RollbackCharacter(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+	version, ok := characterVersionParam(c)
+	if !ok {
+		return
+	}
+
+	character, err := h.service.RollbackCharacter(id, version, editor)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, character)
+}
+
+// SetCharacterVisibility makes a character private, unlisted or public
+func (h *CharacterHandler) SetCharacterVisibility(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Visibility string `json:"visibility" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	character, err := h.service.SetVisibility(id, editor, req.Visibility)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, character)
+}
+
+// TransferCharacterOwnership gives a character to another user (admin only)
+func (h *CharacterHandler) TransferCharacterOwnership(c *gin.Context) {
+	_, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		OwnerID uint `json:"owner_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	character, err := h.service.TransferOwnership(id, req.OwnerID)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, character)
+}
+
+// maxCharacterCardSize limits imported card documents and images
+const maxCharacterCardSize = 10 << 20
+
+// ImportCharacterCard creates a character from a Character Card V2 JSON
+// document or PNG image, sent as the request body or as the "card" field of
+// a multipart form
+func (h *CharacterHandler) ImportCharacterCard(c *gin.Context) {
+	editor, ok := characterEditor(c)
+	if !ok {
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		file, _, err := c.Request.FormFile("card")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "card file is required"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxCharacterCardSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read character card"})
+		return
+	}
+	if len(data) > maxCharacterCardSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "character card is too large"})
+		return
+	}
+
+	// A PNG card is also the character's avatar
+	var avatarURL string
+	if charactercard.IsPNG(data) {
+		if avatarURL, err = h.service.StoreAvatar(c.Request.Context(), data); err != nil {
+			log.Printf("Imported card image is not usable as an avatar: %v", err)
+			avatarURL = ""
+		}
+	}
+
+	visibility := c.Query("visibility")
+	if visibility == "" {
+		visibility = c.PostForm("visibility")
+	}
+
+	character, err := h.service.ImportCharacterCard(data, editor, visibility, avatarURL)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, character)
+}
+
+// ExportCharacterCard downloads a character as a Character Card V2 JSON
+// document or, with format=png, as a PNG image carrying the card
+func (h *CharacterHandler) ExportCharacterCard(c *gin.Context) {
+	viewer, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "png" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or png"})
+		return
+	}
+
+	character, card, err := h.service.ExportCharacterCard(id, viewer)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", service.CharacterCardFilename(character, format)))
+
+	if format == "png" {
+		data, err := h.service.CharacterCardPNG(c.Request.Context(), character, card)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error rendering card: %v", err)})
+			return
+		}
+		c.Data(http.StatusOK, "image/png", data)
+		return
+	}
+	c.JSON(http.StatusOK, card)
+}
+
+// maxAvatarSize limits uploaded avatar images
+const maxAvatarSize = 10 << 20
+
+// SetCharacterAvatar replaces a character's avatar with the uploaded image,
+// sent as the request body or as the "avatar" field of a multipart form
+func (h *CharacterHandler) SetCharacterAvatar(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		file, _, err := c.Request.FormFile("avatar")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "avatar file is required"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxAvatarSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read avatar"})
+		return
+	}
+	if len(data) > maxAvatarSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "avatar is too large"})
+		return
+	}
+
+	character, err := h.service.SetAvatar(c.Request.Context(), id, editor, data)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, character)
+}
+
+// DeleteCharacterAvatar removes a character's avatar
+func (h *CharacterHandler) DeleteCharacterAvatar(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	character, err := h.service.RemoveAvatar(id, editor)
+	if err != nil {
+		characterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, character)
+}
+
+// GetAvatar serves an avatar thumbnail. Avatars are addressed by content, so
+// responses never change and may be cached indefinitely.
+func (h *CharacterHandler) GetAvatar(c *gin.Context) {
+	size := avatar.DefaultSize
+	if sizeStr := c.Query("size"); sizeStr != "" {
+		s, err := strconv.Atoi(sizeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size"})
+			return
+		}
+		size = s
+	}
+
+	id := c.Param("avatarId")
+	etag := fmt.Sprintf("%q", fmt.Sprintf("%s-%d", id, size))
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	data, err := h.service.GetAvatar(c.Request.Context(), id, size)
+	if err != nil {
+		if errors.Is(err, service.ErrAvatarNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error reading avatar: %v", err)})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("ETag", etag)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, http.DetectContentType(data), data)
+}
+
+// characterEditor returns the authenticated user acting on characters
+func characterEditor(c *gin.Context) (service.CharacterEditor, bool) {
+	userID, ok := conversationUser(c)
+	if !ok {
+		return service.CharacterEditor{}, false
+	}
+
+	role, _ := c.Get("userRole")
+	return service.CharacterEditor{UserID: userID, Admin: role == jwt.RoleAdmin}, true
+}
+
+// characterParams returns the authenticated editor and the character ID parameter
+func characterParams(c *gin.Context) (service.CharacterEditor, uint, bool) {
+	editor, ok := characterEditor(c)
+	if !ok {
+		return service.CharacterEditor{}, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		return service.CharacterEditor{}, 0, false
+	}
+
+	return editor, uint(id), true
+}
+
+// characterVersionParam returns the version number parameter
+func characterVersionParam(c *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return 0, false
+	}
+	return version, true
+}
+
+// characterError maps character service errors to HTTP responses
+func characterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrCharacterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
+	case errors.Is(err, service.ErrCharacterVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Character version not found"})
+	case errors.Is(err, service.ErrCharacterForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this character"})
+	case errors.Is(err, service.ErrInvalidCharacter):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidAvatar):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrCharacterLimitReached):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error updating character: %v", err)})
+	}
+}
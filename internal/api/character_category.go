@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/internal/service"
+)
+
+// ListCategories returns the category taxonomy with character counts
+func (h *CharacterHandler) ListCategories(c *gin.Context) {
+	viewer, ok := characterEditor(c)
+	if !ok {
+		return
+	}
+
+	categories, err := h.service.ListCategories(viewer)
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories": categories,
+		"count":      len(categories),
+	})
+}
+
+// CreateCategory adds a category to the taxonomy (admin only)
+func (h *CharacterHandler) CreateCategory(c *gin.Context) {
+	var req struct {
+		Slug        string `json:"slug" binding:"required"`
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		SortOrder   int    `json:"sort_order"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category := &models.CharacterCategory{
+		Slug:        req.Slug,
+		Name:        req.Name,
+		Description: req.Description,
+		SortOrder:   req.SortOrder,
+	}
+	if err := h.service.CreateCategory(category); err != nil {
+		categoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory changes a category's name, description or sort order (admin only)
+func (h *CharacterHandler) UpdateCategory(c *gin.Context) {
+	var req struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		SortOrder   *int    `json:"sort_order"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.service.UpdateCategory(c.Param("slug"), service.CategoryUpdate{
+		Name:        req.Name,
+		Description: req.Description,
+		SortOrder:   req.SortOrder,
+	})
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory removes an unused category (admin only)
+func (h *CharacterHandler) DeleteCategory(c *gin.Context) {
+	if err := h.service.DeleteCategory(c.Param("slug")); err != nil {
+		categoryError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// categoryError maps category service errors to HTTP responses
+func categoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case errors.Is(err, service.ErrCategoryExists), errors.Is(err, service.ErrCategoryInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error updating category: %v", err)})
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-agent-character-demo/backend/internal/service"
+)
+
+const (
+	// maxKnowledgeUploadSize bounds uploaded knowledge documents
+	maxKnowledgeUploadSize = 20 << 20
+
+	// Passages returned by knowledge search
+	defaultKnowledgeSearchLimit = 5
+	maxKnowledgeSearchLimit     = 20
+)
+
+// AddKnowledgeDocument attaches a document to a character. It accepts a
+// multipart "file" (text, Markdown or PDF) with an optional "title", or a
+// JSON body with title and content.
+func (h *CharacterHandler) AddKnowledgeDocument(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	var title, filename, contentType string
+	var data []byte
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		defer file.Close()
+
+		if data, err = io.ReadAll(io.LimitReader(file, maxKnowledgeUploadSize+1)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read document"})
+			return
+		}
+		title = c.PostForm("title")
+		filename = header.Filename
+		contentType = header.Header.Get("Content-Type")
+	} else {
+		var req struct {
+			Title   string `json:"title" binding:"required"`
+			Content string `json:"content" binding:"required"`
+			Format  string `json:"format"` // "text" or "markdown"
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		title = req.Title
+		data = []byte(req.Content)
+		if req.Format == "markdown" {
+			contentType = "text/markdown"
+		}
+	}
+	if len(data) > maxKnowledgeUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "document is too large"})
+		return
+	}
+
+	document, err := h.knowledge.AddDocument(c.Request.Context(), id, editor, title, filename, contentType, data)
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, document)
+}
+
+// ListKnowledgeDocuments returns the documents attached to a character
+func (h *CharacterHandler) ListKnowledgeDocuments(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	documents, err := h.knowledge.ListDocuments(id, editor)
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"documents": documents,
+		"count":     len(documents),
+	})
+}
+
+// DeleteKnowledgeDocument removes a document and its passages
+func (h *CharacterHandler) DeleteKnowledgeDocument(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	documentID, err := strconv.ParseUint(c.Param("docId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid document id"})
+		return
+	}
+
+	if err := h.knowledge.DeleteDocument(id, uint(documentID), editor); err != nil {
+		knowledgeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SearchKnowledge returns the passages retrieval would pick for a query
+func (h *CharacterHandler) SearchKnowledge(c *gin.Context) {
+	editor, id, ok := characterParams(c)
+	if !ok {
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit := defaultKnowledgeSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxKnowledgeSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxKnowledgeSearchLimit)})
+			return
+		}
+		limit = parsed
+	}
+
+	matches, err := h.knowledge.SearchDocuments(c.Request.Context(), id, editor, query, limit)
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"passages": matches,
+		"count":    len(matches),
+	})
+}
+
+// knowledgeError maps knowledge service errors to HTTP responses
+func knowledgeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrKnowledgeDocumentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+	case errors.Is(err, service.ErrInvalidKnowledgeDocument):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrKnowledgeLimitReached):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		characterError(c, err)
+	}
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/internal/service"
+)
+
+// ConversationHandler exposes a user's conversations
+type ConversationHandler struct {
+	service *service.ConversationService
+}
+
+// NewConversationHandler creates a new conversation handler
+func NewConversationHandler(service *service.ConversationService) *ConversationHandler {
+	return &ConversationHandler{service: service}
+}
+
+// ListConversations returns the caller's conversations, most recently active first.
+// Query parameters: character_id, status=active|archived|all, cursor, limit.
+func (h *ConversationHandler) ListConversations(c *gin.Context) {
+	userID, ok := conversationUser(c)
+	if !ok {
+		return
+	}
+
+	params := service.ListConversationsParams{
+		Status: c.Query("status"),
+		Cursor: c.Query("cursor"),
+	}
+	if charIDStr := c.Query("character_id"); charIDStr != "" {
+		charID, err := strconv.ParseUint(charIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid character ID"})
+			return
+		}
+		params.CharacterID = uint(charID)
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		params.Limit = limit
+	}
+
+	conversations, nextCursor, err := h.service.ListConversations(userID, params)
+	if err != nil {
+		// Bad cursors and status filters are the caller's mistake
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if conversations == nil {
+		conversations = []models.Conversation{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversations": conversations,
+		"next_cursor":   nextCursor,
+	})
+}
+
+// CreateConversation starts a new conversation with a character, or a scene
+// with the characters in character_ids, who speak in that order under
+// round-robin routing
+func (h *ConversationHandler) CreateConversation(c *gin.Context) {
+	userID, ok := conversationUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		CharacterID    uint   `json:"character_id"`
+		CharacterIDs   []uint `json:"character_ids"`
+		SpeakerRouting string `json:"speaker_routing"`
+		Title          string `json:"title" binding:"max=200"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.CharacterID == 0 && len(req.CharacterIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "character_id or character_ids is required"})
+		return
+	}
+
+	var conversation *models.Conversation
+	var err error
+	if len(req.CharacterIDs) > 0 {
+		conversation, err = h.service.CreateScene(userID, req.CharacterIDs, req.SpeakerRouting, req.Title)
+	} else {
+		conversation, err = h.service.CreateConversation(userID, req.CharacterID, req.Title)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, conversation)
+}
+
+// InviteCharacter adds a character to a conversation's cast, turning a
+// one-on-one conversation into a scene
+func (h *ConversationHandler) InviteCharacter(c *gin.Context) {
+	userID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		CharacterID uint `json:"character_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversation, err := h.service.InviteCharacter(id, userID, req.CharacterID)
+	if err != nil {
+		conversationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// ClaimConversation takes ownership of an anonymous session, or of a session
+// whose messages predate conversations, so the caller can read and continue it
+func (h *ConversationHandler) ClaimConversation(c *gin.Context) {
+	userID, ok := conversationUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		SessionID string `json:"session_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversation, err := h.service.ClaimSession(req.SessionID, userID)
+	if err != nil {
+		conversationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// GetConversation returns one of the caller's conversations
+func (h *ConversationHandler) GetConversation(c *gin.Context) {
+	userID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	conversation, err := h.service.GetConversation(id, userID)
+	if err != nil {
+		conversationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// UpdateConversation overrides a conversation's title or summary, or changes
+// a scene's speaker routing. Sending an empty title or summary returns the
+// field to automatic generation.
+func (h *ConversationHandler) UpdateConversation(c *gin.Context) {
+	userID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Title          *string `json:"title" binding:"omitempty,max=200"`
+		Summary        *string `json:"summary" binding:"omitempty,max=4000"`
+		SpeakerRouting *string `json:"speaker_routing"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Title == nil && req.Summary == nil && req.SpeakerRouting == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title, summary or speaker_routing is required"})
+		return
+	}
+
+	conversation, err := h.service.UpdateConversation(id, userID, service.ConversationUpdate{
+		Title:          req.Title,
+		Summary:        req.Summary,
+		SpeakerRouting: req.SpeakerRouting,
+	})
+	if err != nil {
+		conversationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// ArchiveConversation hides a conversation from the default list
+func (h *ConversationHandler) ArchiveConversation(c *gin.Context) {
+	h.setStatus(c, models.ConversationStatusArchived)
+}
+
+// UnarchiveConversation restores an archived conversation
+func (h *ConversationHandler) UnarchiveConversation(c *gin.Context) {
+	h.setStatus(c, models.ConversationStatusActive)
+}
+
+func (h *ConversationHandler) setStatus(c *gin.Context, status string) {
+	userID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	conversation, err := h.service.SetStatus(id, userID, status)
+	if err != nil {
+		conversationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// DeleteConversation removes a conversation with its messages, audio and share links
+func (h *ConversationHandler) DeleteConversation(c *gin.Context) {
+	userID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteConversation(id, userID); err != nil {
+		conversationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// conversationUser returns the authenticated user ID
+func conversationUser(c *gin.Context) (uint, bool) {
+	userIdInterface, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return 0, false
+	}
+
+	userId, ok := userIdInterface.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return 0, false
+	}
+
+	return userId, true
+}
+
+// conversationParams returns the authenticated user and the conversation ID parameter
+func conversationParams(c *gin.Context) (uint, uint, bool) {
+	userID, ok := conversationUser(c)
+	if !ok {
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return 0, 0, false
+	}
+
+	return userID, uint(id), true
+}
+
+// conversationError maps conversation service errors to HTTP responses
+func conversationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrConversationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+	case errors.Is(err, service.ErrConversationForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this conversation"})
+	case errors.Is(err, service.ErrCharacterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
+	case errors.Is(err, service.ErrInvalidScene):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error updating conversation: %v", err)})
+	}
+}
+
+// ExportConversation downloads a conversation as versioned JSON, Markdown or HTML.
+// Markdown and HTML show the active branch only.
+func (h *ConversationHandler) ExportConversation(c *gin.Context) {
+	userID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "md" && format != "html" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, md or html"})
+		return
+	}
+
+	export, err := h.service.ExportConversation(id, userID)
+	if err != nil {
+		conversationError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", service.ExportFilename(id, format)))
+
+	switch format {
+	case "md":
+		data, err := service.FormatConversationMarkdown(export)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error rendering export: %v", err)})
+			return
+		}
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", data)
+	case "html":
+		data, err := service.FormatConversationHTML(export)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error rendering export: %v", err)})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+	default:
+		c.JSON(http.StatusOK, export)
+	}
+}
+
+// ImportConversation recreates a JSON export as a new conversation owned by the caller
+func (h *ConversationHandler) ImportConversation(c *gin.Context) {
+	userID, ok := conversationUser(c)
+	if !ok {
+		return
+	}
+
+	var export service.ConversationExport
+	if err := c.ShouldBindJSON(&export); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid export document: %v", err)})
+		return
+	}
+
+	conversation, err := h.service.ImportConversation(userID, &export)
+	if err != nil {
+		if errors.Is(err, service.ErrUnsupportedExportVersion) || errors.Is(err, service.ErrInvalidExport) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrCharacterLimitReached) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error importing conversation: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, conversation)
+}
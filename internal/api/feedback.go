@@ -0,0 +1,37 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-agent-character-demo/backend/internal/service"
+)
+
+// FeedbackHandler exposes aggregated message feedback
+type FeedbackHandler struct {
+	service *service.FeedbackService
+}
+
+// NewFeedbackHandler creates a new feedback handler
+func NewFeedbackHandler(service *service.FeedbackService) *FeedbackHandler {
+	return &FeedbackHandler{service: service}
+}
+
+// GetFeedbackAnalytics returns feedback totals per character, prompt version and day.
+// Query parameters: character_id, from, to (RFC 3339 or YYYY-MM-DD; to is exclusive).
+func (h *FeedbackHandler) GetFeedbackAnalytics(c *gin.Context) {
+	filter, ok := parseFeedbackFilter(c)
+	if !ok {
+		return
+	}
+
+	analytics, err := h.service.GetAnalytics(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error aggregating feedback: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, analytics)
+}
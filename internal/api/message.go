@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,16 +14,25 @@ import (
 
 	"ai-agent-character-demo/backend/internal/service"
 	"ai-agent-character-demo/backend/pkg/jwt"
+	"ai-agent-character-demo/backend/pkg/pagination"
+	"ai-agent-character-demo/backend/pkg/scene"
 	ws "ai-agent-character-demo/backend/pkg/ws"
 )
 
+// Recent messages the chat model sees when it picks a scene's next speaker
+const sceneDirectorHistorySize = 20
+
 // MessageController handles message-related API endpoints
 type MessageController struct {
-	messageService   *service.MessageService
-	characterService *service.CharacterService
-	aiService        *service.AIServiceAdapter
-	jwtService       *jwt.Service
-	mlApiKey         string
+	messageService      *service.MessageService
+	characterService    *service.CharacterService
+	aiService           *service.AIServiceAdapter
+	conversationService *service.ConversationService
+	feedbackService     *service.FeedbackService
+	knowledgeService    *service.KnowledgeService
+	promptService       *service.PromptTemplateService
+	jwtService          *jwt.Service
+	mlApiKey            string
 }
 
 // NewMessageController creates a new message controller
@@ -31,11 +42,8 @@ func NewMessageController(
 	aiService *service.AIServiceAdapter,
 	jwtService *jwt.Service,
 ) *MessageController {
-	// Get ML API key from environment or use a default for development
+	// ML API routes stay disabled unless ML_API_KEY is set
 	mlApiKey := os.Getenv("ML_API_KEY")
-	if mlApiKey == "" {
-		mlApiKey = "ml-api-secret-key" // Default for development only
-	}
 
 	return &MessageController{
 		messageService:   messageService,
@@ -46,6 +54,153 @@ func NewMessageController(
 	}
 }
 
+// SetConversationService enables per-user access checks on session messages
+func (c *MessageController) SetConversationService(conversationService *service.ConversationService) {
+	c.conversationService = conversationService
+}
+
+// SetFeedbackService enables the fine-tuning dataset export
+func (c *MessageController) SetFeedbackService(feedbackService *service.FeedbackService) {
+	c.feedbackService = feedbackService
+}
+
+// SetKnowledgeService enables retrieval of character knowledge into replies
+func (c *MessageController) SetKnowledgeService(knowledgeService *service.KnowledgeService) {
+	c.knowledgeService = knowledgeService
+}
+
+// SetPromptService enables prompt templates for replies
+func (c *MessageController) SetPromptService(promptService *service.PromptTemplateService) {
+	c.promptService = promptService
+}
+
+// withPrompt renders the character's prompt template for the session
+func (c *MessageController) withPrompt(ctx context.Context, character *ws.Character, sessionID string) {
+	if c.promptService != nil {
+		c.promptService.Apply(ctx, character, sessionID)
+	}
+}
+
+// withKnowledge adds the character's knowledge passages relevant to the user
+// turn, returning the citations to attach to the reply
+func (c *MessageController) withKnowledge(ctx context.Context, characterID uint, query string, history []ws.ChatMessage) ([]ws.ChatMessage, []ws.Citation) {
+	if c.knowledgeService == nil {
+		return history, nil
+	}
+	return c.knowledgeService.Augment(ctx, characterID, query, history)
+}
+
+// withScene prepares the history of a scene session for the character that
+// answers: the scene context leads and the other characters' lines are
+// labelled. Other sessions are returned unchanged.
+func (c *MessageController) withScene(speaker *ws.Character, sessionID string, history []ws.ChatMessage) []ws.ChatMessage {
+	routing, cast, err := c.sceneCast(speaker, sessionID)
+	if err != nil {
+		log.Printf("Error loading scene for session %s: %v", sessionID, err)
+		return history
+	}
+	if routing == "" {
+		return history
+	}
+	return scene.Transcript(speaker, cast, history)
+}
+
+// sceneSpeaker picks the character who answers a scene turn the same way the
+// WebSocket chat does: by mention, in turn or by asking the model. Outside
+// scenes, or when too few of the cast remain, the requested character answers.
+// It returns the speaker and the history prepared for it.
+func (c *MessageController) sceneSpeaker(requested *ws.Character, routing string, cast []*ws.Character, text string, history []ws.ChatMessage) (*ws.Character, []ws.ChatMessage) {
+	if len(cast) < scene.MinCast {
+		return requested, history
+	}
+
+	speaker := scene.Route(routing, cast, history, text, func() (string, error) {
+		recent := history
+		if len(recent) > sceneDirectorHistorySize {
+			recent = recent[len(recent)-sceneDirectorHistorySize:]
+		}
+		return c.aiService.GenerateResponse(scene.Director(cast), text, scene.Label(cast, recent, 0))
+	})
+	return speaker, scene.Transcript(speaker, cast, history)
+}
+
+// sceneCast loads the routing and characters of the session's scene, reusing
+// the requested character. Routing is empty when the session is not a scene.
+// Characters that can no longer be loaded leave the cast.
+func (c *MessageController) sceneCast(requested *ws.Character, sessionID string) (string, []*ws.Character, error) {
+	if c.conversationService == nil {
+		return "", nil, nil
+	}
+	routing, ids, err := c.conversationService.SceneCast(sessionID)
+	if err != nil || routing == "" {
+		return "", nil, err
+	}
+
+	var cast []*ws.Character
+	for _, id := range ids {
+		if id == requested.ID {
+			cast = append(cast, requested)
+			continue
+		}
+		character, err := c.characterService.GetCharacter(id)
+		if err != nil {
+			log.Printf("Leaving character %d out of scene %s: %v", id, sessionID, err)
+			continue
+		}
+		cast = append(cast, service.WebSocketCharacter(character))
+	}
+	return routing, cast, nil
+}
+
+// inCast reports whether the character is a member of the cast
+func inCast(cast []*ws.Character, characterID uint) bool {
+	for _, member := range cast {
+		if member.ID == characterID {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeSession checks that the authenticated user may use a session. When
+// create is set, a new session is started for the user. It writes the error
+// response and returns false when access is denied, including when ownership
+// cannot be checked at all.
+func (c *MessageController) authorizeSession(ctx *gin.Context, sessionID string, characterID uint, create bool) bool {
+	if c.conversationService == nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Conversation service not available"})
+		return false
+	}
+
+	userID, ok := ctx.Get("userId")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return false
+	}
+	uid := userID.(uint)
+
+	var err error
+	if create {
+		_, err = c.conversationService.EnsureConversation(sessionID, characterID, &uid)
+	} else {
+		err = c.conversationService.CheckSessionAccess(sessionID, uid)
+	}
+
+	if errors.Is(err, service.ErrConversationForbidden) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this conversation"})
+		return false
+	}
+	if errors.Is(err, service.ErrCharacterNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
+		return false
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error checking conversation access: %v", err)})
+		return false
+	}
+	return true
+}
+
 // RegisterRoutes registers the routes for the message controller
 func (c *MessageController) RegisterRoutes(router *gin.Engine) {
 	msgGroup := router.Group("/api/messages")
@@ -73,8 +228,10 @@ func (c *MessageController) RegisterRoutesV1(router *gin.RouterGroup) {
 	{
 		messageGroup.GET("", c.validateListMessagesRequest(), c.GetMessages)
 		messageGroup.GET("/session/:sessionId", c.validateSessionRequest(), c.GetSessionMessages)
+		messageGroup.GET("/search", c.SearchMessages)
 		messageGroup.POST("", c.validateCreateMessageRequest(), c.SaveMessage)
 		messageGroup.POST("/feedback", c.SaveFeedback)
+		c.registerBranchRoutes(messageGroup)
 	}
 
 	// ML API endpoints (with separate authentication)
@@ -83,6 +240,7 @@ func (c *MessageController) RegisterRoutesV1(router *gin.RouterGroup) {
 	{
 		mlGroup.GET("", c.validateListMessagesRequest(), c.GetMessagesForML)
 		mlGroup.POST("/process", c.validateProcessMessageRequest(), c.ProcessMessage)
+		mlGroup.GET("/dataset", c.ExportDataset)
 	}
 }
 
@@ -152,12 +310,24 @@ func (c *MessageController) validateListMessagesRequest() gin.HandlerFunc {
 			limit = 50 // Default limit
 		}
 
+		// Optional cursors from a previous page
+		page, err := pagination.ParsePage(ctx.Query("before"), ctx.Query("after"), limit)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+				"code":  "INVALID_CURSOR",
+			})
+			return
+		}
+		page.Limit = limit
+
 		// Store validated parameters in context
 		ctx.Set("characterId", uint(charID))
 		if sessionID != "" {
 			ctx.Set("sessionId", sessionID)
 		}
 		ctx.Set("limit", limit)
+		ctx.Set("page", page)
 
 		log.Printf("[%s] Validated ListMessagesRequest: CharacterID=%d, SessionID=%s, Limit=%d", ctx.FullPath(), uint(charID), sessionID, limit)
 
@@ -264,6 +434,12 @@ func (c *MessageController) validateProcessMessageRequest() gin.HandlerFunc {
 // mlAuthMiddleware ensures ML engineer authentication
 func (c *MessageController) mlAuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if c.mlApiKey == "" {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "ML API is disabled"})
+			ctx.Abort()
+			return
+		}
+
 		token := ctx.GetHeader("X-ML-API-Key")
 		if token == "" {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "API key is required"})
@@ -302,19 +478,30 @@ func (c *MessageController) GetSessionMessages(ctx *gin.Context) {
 		return
 	}
 
+	if !c.authorizeSession(ctx, sessionID, uint(charID), false) {
+		return
+	}
+
 	messages, err := c.messageService.GetSessionMessages(uint(charID), sessionID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error retrieving messages: %v", err)})
 		return
 	}
 
+	audioIDs, err := c.messageService.GetAudioMessageIDs(sessionID)
+	if err != nil {
+		log.Printf("[%s] Error loading audio links for session %s: %v", ctx.FullPath(), sessionID, err)
+	}
+
 	formattedMessages := make([]map[string]interface{}, len(messages))
 	for i, msg := range messages {
 		formattedMessages[i] = map[string]interface{}{
-			"id":        msg.ExternalID,
-			"sender":    msg.Sender,
-			"content":   msg.Content,
-			"timestamp": msg.Timestamp,
+			"id":           msg.ExternalID,
+			"sender":       msg.Sender,
+			"character_id": msg.CharacterID,
+			"content":      msg.Content,
+			"timestamp":    msg.Timestamp,
+			"audio_url":    service.AudioURLFor(msg, audioIDs),
 		}
 	}
 
@@ -339,31 +526,41 @@ func (c *MessageController) SendMessage(ctx *gin.Context) {
 		return
 	}
 
-	userMessage := &ws.ChatMessage{
-		ID:        fmt.Sprintf("msg-%d", time.Now().UnixNano()),
-		Sender:    "user",
-		Content:   request.Content,
-		Timestamp: time.Now(),
+	if !c.authorizeSession(ctx, request.SessionID, request.CharacterID, true) {
+		return
 	}
 
-	err := c.messageService.SaveMessage(request.CharacterID, request.SessionID, userMessage)
+	character, err := c.characterService.GetCharacter(request.CharacterID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error saving message: %v", err)})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error fetching character: %v", err)})
 		return
 	}
 
-	character, err := c.characterService.GetCharacter(request.CharacterID)
+	wsCharacter := service.WebSocketCharacter(character)
+
+	// In a scene the cast decides who answers; the request only names the
+	// character the user is talking through, which must be one of them
+	routing, cast, err := c.sceneCast(wsCharacter, request.SessionID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error fetching character: %v", err)})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error loading scene: %v", err)})
+		return
+	}
+	if routing != "" && !inCast(cast, request.CharacterID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Character is not part of this scene"})
 		return
 	}
 
-	wsCharacter := &ws.Character{
-		ID:          character.ID,
-		Name:        character.Name,
-		Description: character.Description,
-		Personality: character.Personality,
-		VoiceType:   character.VoiceType,
+	userMessage := &ws.ChatMessage{
+		ID:        fmt.Sprintf("msg-%d", time.Now().UnixNano()),
+		Sender:    "user",
+		Content:   request.Content,
+		Timestamp: time.Now(),
+	}
+
+	err = c.messageService.SaveMessage(request.CharacterID, request.SessionID, userMessage)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error saving message: %v", err)})
+		return
 	}
 
 	dbMessages, err := c.messageService.GetSessionMessages(request.CharacterID, request.SessionID)
@@ -375,27 +572,34 @@ func (c *MessageController) SendMessage(ctx *gin.Context) {
 	wsMessages := make([]ws.ChatMessage, len(dbMessages))
 	for i, msg := range dbMessages {
 		wsMessages[i] = ws.ChatMessage{
-			ID:        msg.ExternalID,
-			Sender:    msg.Sender,
-			Content:   msg.Content,
-			Timestamp: msg.Timestamp,
+			ID:          msg.ExternalID,
+			Sender:      msg.Sender,
+			CharacterID: msg.CharacterID,
+			Content:     msg.Content,
+			Timestamp:   msg.Timestamp,
 		}
 	}
 
-	aiResponse, err := c.aiService.GenerateResponse(wsCharacter, request.Content, wsMessages)
+	speaker, wsMessages := c.sceneSpeaker(wsCharacter, routing, cast, request.Content, wsMessages)
+	c.withPrompt(ctx.Request.Context(), speaker, request.SessionID)
+	aiHistory, citations := c.withKnowledge(ctx.Request.Context(), speaker.ID, request.Content, wsMessages)
+	aiResponse, err := c.aiService.GenerateResponse(speaker, request.Content, aiHistory)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error generating AI response: %v", err)})
 		return
 	}
 
 	characterMessage := &ws.ChatMessage{
-		ID:        fmt.Sprintf("resp-%d", time.Now().UnixNano()),
-		Sender:    "character",
-		Content:   aiResponse,
-		Timestamp: time.Now(),
+		ID:            fmt.Sprintf("resp-%d", time.Now().UnixNano()),
+		Sender:        "character",
+		CharacterID:   speaker.ID,
+		Content:       aiResponse,
+		Timestamp:     time.Now(),
+		Citations:     citations,
+		PromptVersion: speaker.PromptVersion,
 	}
 
-	err = c.messageService.SaveMessage(request.CharacterID, request.SessionID, characterMessage)
+	err = c.messageService.SaveMessage(speaker.ID, request.SessionID, characterMessage)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error saving character response: %v", err)})
 		return
@@ -409,10 +613,12 @@ func (c *MessageController) SendMessage(ctx *gin.Context) {
 			"timestamp": userMessage.Timestamp,
 		},
 		"characterMessage": map[string]interface{}{
-			"id":        characterMessage.ID,
-			"sender":    characterMessage.Sender,
-			"content":   characterMessage.Content,
-			"timestamp": characterMessage.Timestamp,
+			"id":           characterMessage.ID,
+			"sender":       characterMessage.Sender,
+			"character_id": characterMessage.CharacterID,
+			"content":      characterMessage.Content,
+			"timestamp":    characterMessage.Timestamp,
+			"citations":    characterMessage.Citations,
 		},
 	})
 }
@@ -487,7 +693,9 @@ func (c *MessageController) GetRecentMessages(ctx *gin.Context) {
 	ctx.JSON(http.StatusNotImplemented, gin.H{"error": "This endpoint is not yet implemented"})
 }
 
-// GetMessages retrieves a list of messages for a character or session
+// GetMessages retrieves a page of a session's messages, oldest first.
+// Pass prev_cursor as before to load older messages and next_cursor as after
+// to load newer ones.
 func (c *MessageController) GetMessages(ctx *gin.Context) {
 	charID, exists := ctx.Get("characterId")
 	if !exists {
@@ -497,8 +705,7 @@ func (c *MessageController) GetMessages(ctx *gin.Context) {
 	}
 
 	sessionID, hasSession := ctx.Get("sessionId")
-	queryLimit, limitExists := ctx.Get("limit")
-	queryOffset, offsetExists := ctx.Get("offset")
+	page := ctx.MustGet("page").(pagination.Page)
 
 	charIDUint := charID.(uint)
 	var sessionIDStr string
@@ -506,50 +713,59 @@ func (c *MessageController) GetMessages(ctx *gin.Context) {
 		sessionIDStr = sessionID.(string)
 	}
 
-	limitInt := 30 // Default
-	if limitExists {
-		limitInt = queryLimit.(int)
-	}
-	offsetInt := 0 // Default
-	if offsetExists {
-		offsetInt = queryOffset.(int)
+	log.Printf("[%s] GetMessages Handler: CharacterID=%d, SessionID=%s (Exists: %t), Limit=%d", ctx.FullPath(), charIDUint, sessionIDStr, hasSession, page.Limit)
+
+	if !hasSession {
+		ctx.JSON(http.StatusNotImplemented, gin.H{
+			"error": "Retrieving all messages for a character without session ID is not implemented",
+		})
+		return
 	}
 
-	log.Printf("[%s] GetMessages Handler: CharacterID=%d, SessionID=%s (Exists: %t), Limit=%d, Offset=%d", ctx.FullPath(), charIDUint, sessionIDStr, hasSession, limitInt, offsetInt)
+	if !c.authorizeSession(ctx, sessionIDStr, charIDUint, false) {
+		return
+	}
 
-	if hasSession {
-		sessionMessages, totalCount, err := c.messageService.GetSessionMessagesPaginated(charID.(uint), sessionID.(string), queryLimit.(int), queryOffset.(int))
-		if err != nil {
-			log.Printf("[%s] GetMessages Error: Error retrieving session messages for CharacterID=%d, SessionID=%s, Limit=%d, Offset=%d - %v", ctx.FullPath(), charIDUint, sessionIDStr, limitInt, offsetInt, err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Error retrieving session messages: %v", err),
-			})
+	result, err := c.messageService.GetSessionMessagesPage(charIDUint, sessionIDStr, page)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) || errors.Is(err, service.ErrCursorNotOnBranch) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_CURSOR"})
 			return
 		}
-		log.Printf("[%s] Successfully retrieved %d messages (Total: %d) for CharacterID=%d, SessionID=%s", ctx.FullPath(), len(sessionMessages), totalCount, charIDUint, sessionIDStr)
-		formattedMessages := make([]map[string]interface{}, len(sessionMessages))
-		for i, msg := range sessionMessages {
-			formattedMessages[i] = map[string]interface{}{
-				"id":        msg.ExternalID,
-				"sender":    msg.Sender,
-				"content":   msg.Content,
-				"timestamp": msg.Timestamp,
-			}
-		}
-		ctx.JSON(http.StatusOK, gin.H{
-			"characterId": charID,
-			"sessionId":   sessionID,
-			"messages":    formattedMessages,
-			"count":       totalCount,
-			"limit":       queryLimit,
-			"offset":      queryOffset,
-		})
-	} else {
-		ctx.JSON(http.StatusNotImplemented, gin.H{
-			"error": "Retrieving all messages for a character without session ID is not implemented",
+		log.Printf("[%s] GetMessages Error: Error retrieving session messages for CharacterID=%d, SessionID=%s - %v", ctx.FullPath(), charIDUint, sessionIDStr, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Error retrieving session messages: %v", err),
 		})
 		return
 	}
+	log.Printf("[%s] Successfully retrieved %d messages for CharacterID=%d, SessionID=%s", ctx.FullPath(), len(result.Messages), charIDUint, sessionIDStr)
+
+	audioIDs, err := c.messageService.GetAudioMessageIDs(sessionIDStr)
+	if err != nil {
+		log.Printf("[%s] Error loading audio links for session %s: %v", ctx.FullPath(), sessionIDStr, err)
+	}
+	formattedMessages := make([]map[string]interface{}, len(result.Messages))
+	for i, msg := range result.Messages {
+		formattedMessages[i] = map[string]interface{}{
+			"id":           msg.ExternalID,
+			"sender":       msg.Sender,
+			"character_id": msg.CharacterID,
+			"content":      msg.Content,
+			"timestamp":    msg.Timestamp,
+			"audio_url":    service.AudioURLFor(msg, audioIDs),
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"characterId": charID,
+		"sessionId":   sessionID,
+		"messages":    formattedMessages,
+		"count":       len(formattedMessages),
+		"limit":       page.Limit,
+		"prev_cursor": result.PrevCursor,
+		"next_cursor": result.NextCursor,
+		"has_older":   result.HasOlder,
+		"has_newer":   result.HasNewer,
+	})
 }
 
 // SaveMessage saves a new message to the database
@@ -567,6 +783,10 @@ func (c *MessageController) SaveMessage(ctx *gin.Context) {
 		Sender      string `json:"sender" binding:"required,oneof=user character system"`
 	})
 
+	if !c.authorizeSession(ctx, req.SessionID, req.CharacterID, true) {
+		return
+	}
+
 	chatMessage := &ws.ChatMessage{
 		ID:        fmt.Sprintf("msg-%d", time.Now().UnixNano()),
 		Sender:    req.Sender,
@@ -661,13 +881,7 @@ func (c *MessageController) ProcessMessage(ctx *gin.Context) {
 		return
 	}
 
-	wsCharacter := &ws.Character{
-		ID:          character.ID,
-		Name:        character.Name,
-		Description: character.Description,
-		Personality: character.Personality,
-		VoiceType:   character.VoiceType,
-	}
+	wsCharacter := service.WebSocketCharacter(character)
 
 	dbMessages, err := c.messageService.GetSessionMessages(req.CharacterID, req.SessionID)
 	if err != nil {
@@ -680,14 +894,18 @@ func (c *MessageController) ProcessMessage(ctx *gin.Context) {
 	wsMessages := make([]ws.ChatMessage, len(dbMessages))
 	for i, msg := range dbMessages {
 		wsMessages[i] = ws.ChatMessage{
-			ID:        msg.ExternalID,
-			Sender:    msg.Sender,
-			Content:   msg.Content,
-			Timestamp: msg.Timestamp,
+			ID:          msg.ExternalID,
+			Sender:      msg.Sender,
+			CharacterID: msg.CharacterID,
+			Content:     msg.Content,
+			Timestamp:   msg.Timestamp,
 		}
 	}
 
-	response, err := c.aiService.GenerateResponse(wsCharacter, req.Message, wsMessages)
+	c.withPrompt(ctx.Request.Context(), wsCharacter, req.SessionID)
+	wsMessages = c.withScene(wsCharacter, req.SessionID, wsMessages)
+	aiHistory, citations := c.withKnowledge(ctx.Request.Context(), req.CharacterID, req.Message, wsMessages)
+	response, err := c.aiService.GenerateResponse(wsCharacter, req.Message, aiHistory)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Error generating AI response: %v", err),
@@ -697,30 +915,171 @@ func (c *MessageController) ProcessMessage(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"response":    response,
+		"citations":   citations,
 		"characterId": req.CharacterID,
 		"sessionId":   req.SessionID,
 	})
 }
 
-// Feedback model
+// Feedback model. The rating user is always the authenticated caller.
 type FeedbackRequest struct {
 	MessageID    string `json:"messageId" binding:"required"`
-	UserID       uint   `json:"userId" binding:"required"`
 	FeedbackType string `json:"feedbackType" binding:"required,oneof=up down flag"`
 	Timestamp    int64  `json:"timestamp"`
 }
 
-// SaveFeedback handler
+// SaveFeedback records the caller's rating of a message, replacing any earlier rating
 func (c *MessageController) SaveFeedback(ctx *gin.Context) {
 	var req FeedbackRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feedback request"})
 		return
 	}
-	err := c.messageService.SaveFeedback(req.MessageID, req.UserID, req.FeedbackType, req.Timestamp)
-	if err != nil {
+
+	userIDValue, exists := ctx.Get("userId")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userID := userIDValue.(uint)
+
+	if _, err := c.messageService.GetOwnedMessage(req.MessageID, userID); err != nil {
+		branchError(ctx, err)
+		return
+	}
+
+	if err := c.messageService.SaveFeedback(req.MessageID, userID, req.FeedbackType, req.Timestamp); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusCreated, gin.H{"status": "ok"})
 }
+
+// ExportDataset streams conversations as JSONL for chat fine-tuning (ML access).
+// Query parameters: type=sft|preference, character_id, from, to.
+func (c *MessageController) ExportDataset(ctx *gin.Context) {
+	if c.feedbackService == nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Dataset export is not available"})
+		return
+	}
+
+	datasetType := ctx.DefaultQuery("type", service.DatasetSFT)
+	if datasetType != service.DatasetSFT && datasetType != service.DatasetPreference {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "type must be sft or preference"})
+		return
+	}
+
+	filter, ok := parseFeedbackFilter(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.Header("Content-Type", "application/jsonl; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "dataset-"+datasetType+".jsonl"))
+	ctx.Status(http.StatusOK)
+
+	written, err := c.feedbackService.ExportDataset(ctx.Request.Context(), ctx.Writer, datasetType, filter)
+	if err != nil {
+		// Headers are already sent, so the stream just ends early
+		log.Printf("[%s] Dataset export stopped after %d lines: %v", ctx.FullPath(), written, err)
+		return
+	}
+	log.Printf("[%s] Exported %d %s dataset lines", ctx.FullPath(), written, datasetType)
+}
+
+// parseFeedbackFilter reads character_id, from and to query parameters
+func parseFeedbackFilter(ctx *gin.Context) (service.FeedbackFilter, bool) {
+	var filter service.FeedbackFilter
+	var err error
+	if filter.CharacterID, err = parseOptionalID(ctx.Query("character_id")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid character ID"})
+		return filter, false
+	}
+	if filter.From, err = parseSearchTime(ctx.Query("from")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return filter, false
+	}
+	if filter.To, err = parseSearchTime(ctx.Query("to")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return filter, false
+	}
+	return filter, true
+}
+
+// SearchMessages runs a full-text search over the caller's conversations.
+// Query parameters: q, character_id, conversation_id, from, to (RFC 3339 or
+// YYYY-MM-DD; to is exclusive), limit, offset.
+func (c *MessageController) SearchMessages(ctx *gin.Context) {
+	userIDValue, exists := ctx.Get("userId")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userID := userIDValue.(uint)
+
+	params := service.SearchMessagesParams{Query: ctx.Query("q")}
+	if params.Query == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+
+	var err error
+	if params.CharacterID, err = parseOptionalID(ctx.Query("character_id")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid character ID"})
+		return
+	}
+	if params.ConversationID, err = parseOptionalID(ctx.Query("conversation_id")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+	if params.From, err = parseSearchTime(ctx.Query("from")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	if params.To, err = parseSearchTime(ctx.Query("to")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+	params.Limit, _ = strconv.Atoi(ctx.Query("limit"))
+	params.Offset, _ = strconv.Atoi(ctx.Query("offset"))
+
+	results, err := c.messageService.SearchMessages(userID, params)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error searching messages: %v", err)})
+		return
+	}
+	if results == nil {
+		results = []service.MessageSearchResult{}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"query":   params.Query,
+		"results": results,
+		"count":   len(results),
+		"offset":  params.Offset,
+	})
+}
+
+// parseOptionalID parses an ID query parameter, returning 0 when it is absent
+func parseOptionalID(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	return uint(id), err
+}
+
+// parseSearchTime parses an RFC 3339 timestamp or a plain date
+func parseSearchTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/internal/service"
+	ws "ai-agent-character-demo/backend/pkg/ws"
+)
+
+// registerBranchRoutes registers edit, regenerate and alternate-branch routes
+func (c *MessageController) registerBranchRoutes(group *gin.RouterGroup) {
+	group.POST("/:id/edit", c.EditMessage)
+	group.POST("/:id/regenerate", c.RegenerateMessage)
+	group.GET("/:id/alternates", c.GetMessageAlternates)
+	group.POST("/:id/activate", c.ActivateMessage)
+}
+
+// EditMessage stores new content for a past user message as a new branch
+// and generates a fresh character reply to it
+func (c *MessageController) EditMessage(ctx *gin.Context) {
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Content is required"})
+		return
+	}
+
+	original, ok := c.getBranchMessage(ctx)
+	if !ok {
+		return
+	}
+
+	// In a scene the character that answered the original answers the edit
+	speakerID, err := c.messageService.GetReplySpeaker(original)
+	if err != nil {
+		branchError(ctx, err)
+		return
+	}
+
+	edited, err := c.messageService.EditMessage(original, req.Content)
+	if err != nil {
+		branchError(ctx, err)
+		return
+	}
+
+	reply, err := c.generateReply(ctx.Request.Context(), edited, speakerID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error generating AI response: %v", err)})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"userMessage":      branchMessageJSON(*edited),
+		"characterMessage": branchMessageJSON(*reply),
+	})
+}
+
+// RegenerateMessage generates an alternate character reply as a sibling of the given one
+func (c *MessageController) RegenerateMessage(ctx *gin.Context) {
+	original, ok := c.getBranchMessage(ctx)
+	if !ok {
+		return
+	}
+
+	parent, err := c.messageService.GetRegenerationParent(original)
+	if err != nil {
+		branchError(ctx, err)
+		return
+	}
+
+	reply, err := c.generateReply(ctx.Request.Context(), parent, original.CharacterID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error generating AI response: %v", err)})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"characterMessage": branchMessageJSON(*reply),
+	})
+}
+
+// GetMessageAlternates lists a message and its sibling branches
+func (c *MessageController) GetMessageAlternates(ctx *gin.Context) {
+	message, ok := c.getBranchMessage(ctx)
+	if !ok {
+		return
+	}
+
+	siblings, activeIndex, err := c.messageService.GetAlternates(message)
+	if err != nil {
+		branchError(ctx, err)
+		return
+	}
+
+	formatted := make([]map[string]interface{}, len(siblings))
+	for i, sibling := range siblings {
+		formatted[i] = branchMessageJSON(sibling)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"messageId":   message.ExternalID,
+		"alternates":  formatted,
+		"activeIndex": activeIndex,
+		"count":       len(formatted),
+	})
+}
+
+// ActivateMessage switches the session to the branch containing the message
+// and returns the new active history
+func (c *MessageController) ActivateMessage(ctx *gin.Context) {
+	message, ok := c.getBranchMessage(ctx)
+	if !ok {
+		return
+	}
+
+	path, err := c.messageService.ActivateMessage(message)
+	if err != nil {
+		branchError(ctx, err)
+		return
+	}
+
+	formatted := make([]map[string]interface{}, len(path))
+	for i, msg := range path {
+		formatted[i] = branchMessageJSON(msg)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"sessionId": message.SessionID,
+		"messages":  formatted,
+		"count":     len(formatted),
+	})
+}
+
+// getBranchMessage loads the message named in the path from one of the
+// caller's conversations
+func (c *MessageController) getBranchMessage(ctx *gin.Context) (*models.Message, bool) {
+	userID, ok := ctx.Get("userId")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	message, err := c.messageService.GetOwnedMessage(ctx.Param("id"), userID.(uint))
+	if err != nil {
+		branchError(ctx, err)
+		return nil, false
+	}
+	return message, true
+}
+
+// generateReply asks a character to answer a user message using the branch
+// that leads to it, and stores the answer as its child
+func (c *MessageController) generateReply(ctx context.Context, userMessage *models.Message, characterID uint) (*models.Message, error) {
+	history, err := c.messageService.GetHistory(userMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	character, err := c.characterService.GetCharacter(characterID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching character: %w", err)
+	}
+
+	wsCharacter := service.WebSocketCharacter(character)
+
+	wsMessages := make([]ws.ChatMessage, len(history))
+	for i, msg := range history {
+		wsMessages[i] = ws.ChatMessage{
+			ID:          msg.ExternalID,
+			Sender:      msg.Sender,
+			CharacterID: msg.CharacterID,
+			Content:     msg.Content,
+			Timestamp:   msg.Timestamp,
+		}
+	}
+
+	c.withPrompt(ctx, wsCharacter, userMessage.SessionID)
+	wsMessages = c.withScene(wsCharacter, userMessage.SessionID, wsMessages)
+	aiHistory, citations := c.withKnowledge(ctx, characterID, userMessage.Content, wsMessages)
+	response, err := c.aiService.GenerateResponse(wsCharacter, userMessage.Content, aiHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.messageService.SaveReply(userMessage, characterID, response, wsCharacter.PromptVersion, citations)
+}
+
+// branchMessageJSON formats a message for branch responses
+func branchMessageJSON(msg models.Message) map[string]interface{} {
+	body := map[string]interface{}{
+		"id":           msg.ExternalID,
+		"sender":       msg.Sender,
+		"character_id": msg.CharacterID,
+		"content":      msg.Content,
+		"timestamp":    msg.Timestamp,
+	}
+	if len(msg.Citations) > 0 {
+		body["citations"] = msg.Citations
+	}
+	return body
+}
+
+// branchError maps branching errors to HTTP responses
+func branchError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrMessageNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+	case errors.Is(err, service.ErrConversationForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this conversation"})
+	case errors.Is(err, service.ErrMessageNotEditable), errors.Is(err, service.ErrMessageNotRegenerable):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConversationNotFound):
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Session has no conversation to branch"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error updating conversation branch: %v", err)})
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-agent-character-demo/backend/internal/service"
+)
+
+// PromptTemplateHandler exposes prompt template administration (admin only)
+type PromptTemplateHandler struct {
+	service *service.PromptTemplateService
+}
+
+// NewPromptTemplateHandler creates a new prompt template handler
+func NewPromptTemplateHandler(service *service.PromptTemplateService) *PromptTemplateHandler {
+	return &PromptTemplateHandler{service: service}
+}
+
+// ListPromptTemplates returns every template version. Query parameter: name.
+func (h *PromptTemplateHandler) ListPromptTemplates(c *gin.Context) {
+	templates, err := h.service.ListTemplates(c.Query("name"))
+	if err != nil {
+		promptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+// CreatePromptTemplate saves a new version of a template
+func (h *PromptTemplateHandler) CreatePromptTemplate(c *gin.Context) {
+	userID, ok := conversationUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Body        string `json:"body" binding:"required"`
+		CharacterID *uint  `json:"character_id"`
+		Model       string `json:"model"`
+		Draft       bool   `json:"draft"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tmpl, err := h.service.CreateTemplate(service.PromptTemplateInput{
+		Name:        req.Name,
+		Description: req.Description,
+		Body:        req.Body,
+		CharacterID: req.CharacterID,
+		Model:       req.Model,
+		Draft:       req.Draft,
+	}, &userID)
+	if err != nil {
+		promptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tmpl)
+}
+
+// GetPromptTemplate returns the active version of a template
+func (h *PromptTemplateHandler) GetPromptTemplate(c *gin.Context) {
+	tmpl, err := h.service.GetTemplate(c.Param("name"), 0)
+	if err != nil {
+		promptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// GetPromptTemplateVersion returns one version of a template
+func (h *PromptTemplateHandler) GetPromptTemplateVersion(c *gin.Context) {
+	version, ok := characterVersionParam(c)
+	if !ok {
+		return
+	}
+
+	tmpl, err := h.service.GetTemplate(c.Param("name"), version)
+	if err != nil {
+		promptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// ActivatePromptTemplateVersion makes a version the one replies use
+func (h *PromptTemplateHandler) ActivatePromptTemplateVersion(c *gin.Context) {
+	version, ok := characterVersionParam(c)
+	if !ok {
+		return
+	}
+
+	tmpl, err := h.service.ActivateTemplate(c.Param("name"), version)
+	if err != nil {
+		promptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// DeactivatePromptTemplate stops a template from applying to replies
+func (h *PromptTemplateHandler) DeactivatePromptTemplate(c *gin.Context) {
+	if err := h.service.DeactivateTemplate(c.Param("name")); err != nil {
+		promptTemplateError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PreviewPromptTemplate renders a template, saved or not, for a character,
+// user and session without generating a reply
+func (h *PromptTemplateHandler) PreviewPromptTemplate(c *gin.Context) {
+	var req struct {
+		Body        string `json:"body"`
+		Name        string `json:"name"`
+		Version     int    `json:"version"`
+		CharacterID uint   `json:"character_id"`
+		UserID      uint   `json:"user_id"`
+		SessionID   string `json:"session_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := h.service.Preview(c.Request.Context(), service.PromptPreviewRequest{
+		Body:        req.Body,
+		Name:        req.Name,
+		Version:     req.Version,
+		CharacterID: req.CharacterID,
+		UserID:      req.UserID,
+		SessionID:   req.SessionID,
+	})
+	if err != nil {
+		promptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// promptTemplateError maps prompt template service errors to HTTP responses
+func promptTemplateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPromptTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template not found"})
+	case errors.Is(err, service.ErrCharacterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Character not found"})
+	case errors.Is(err, service.ErrInvalidPromptTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error processing prompt template: %v", err)})
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-agent-character-demo/backend/internal/service"
+	"ai-agent-character-demo/backend/pkg/audio"
+)
+
+// RecordingHandler exports whole-session voice recordings and their transcripts
+type RecordingHandler struct {
+	service *service.RecordingService
+}
+
+// NewRecordingHandler creates a new recording handler
+func NewRecordingHandler(service *service.RecordingService) *RecordingHandler {
+	return &RecordingHandler{service: service}
+}
+
+// GetRecording returns the session's audio stitched into a single file.
+// Query parameters: format=wav|ogg, gap=<duration>, normalize=true|false.
+func (h *RecordingHandler) GetRecording(c *gin.Context) {
+	format := c.DefaultQuery("format", "wav")
+	if format != "wav" && format != "ogg" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be wav or ogg"})
+		return
+	}
+
+	recording, ok := h.buildRecording(c)
+	if !ok {
+		return
+	}
+
+	contentType, err := h.service.ContentType(format)
+	if err != nil {
+		if errors.Is(err, audio.ErrTranscoderUnavailable) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "ogg export requires ffmpeg on the server; use format=wav"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error encoding recording: %v", err)})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="session-%s.%s"`, recording.SessionID, format))
+	c.Header("X-Recording-Duration", strconv.FormatFloat(recording.Duration, 'f', 3, 64))
+	c.Header("X-Recording-Skipped-Segments", strconv.Itoa(recording.Skipped))
+	c.Status(http.StatusOK)
+
+	// The status is sent with the first bytes, so a failure part way can only cut the download short
+	if err := h.service.WriteRecording(c.Request.Context(), c.Writer, recording, format); err != nil {
+		log.Printf("Error streaming recording for session %s: %v", recording.SessionID, err)
+		c.Abort()
+	}
+}
+
+// GetRecordingTranscript returns the sidecar transcript aligned to GetRecording.
+// It accepts the same gap parameter so cue times match the audio file.
+func (h *RecordingHandler) GetRecordingTranscript(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "vtt" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or vtt"})
+		return
+	}
+
+	recording, ok := h.buildRecording(c)
+	if !ok {
+		return
+	}
+
+	if format == "vtt" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="session-%s.vtt"`, recording.SessionID))
+		c.Data(http.StatusOK, "text/vtt; charset=utf-8", []byte(service.FormatWebVTT(recording.Cues)))
+		return
+	}
+
+	c.JSON(http.StatusOK, recording)
+}
+
+// buildRecording parses the shared options and writes the error response on failure
+func (h *RecordingHandler) buildRecording(c *gin.Context) (*service.Recording, bool) {
+	userId, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return nil, false
+	}
+
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		return nil, false
+	}
+
+	var opts service.RecordingOptions
+	if gapStr := c.Query("gap"); gapStr != "" {
+		gap, err := time.ParseDuration(gapStr)
+		if err != nil || gap < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "gap must be a non-negative duration such as 500ms"})
+			return nil, false
+		}
+		opts.Gap = gap
+	}
+	if normalizeStr := c.Query("normalize"); normalizeStr != "" {
+		normalize, err := strconv.ParseBool(normalizeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "normalize must be true or false"})
+			return nil, false
+		}
+		opts.Normalize = normalize
+	}
+
+	recording, err := h.service.BuildRecording(c.Request.Context(), sessionID, fmt.Sprintf("%d", userId.(uint)), opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRecordingEmpty):
+			c.JSON(http.StatusNotFound, gin.H{"error": "No recordable audio found for this session"})
+		case errors.Is(err, service.ErrRecordingForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this session"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error building recording: %v", err)})
+		}
+		return nil, false
+	}
+
+	return recording, true
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/internal/service"
+)
+
+// maxShareExpiryHours bounds how long a share link may live when an expiry is set
+const maxShareExpiryHours = 24 * 365
+
+// ShareHandler manages conversation share links and serves shared conversations
+type ShareHandler struct {
+	shareService        *service.ShareService
+	messageAudioService *service.MessageAudioService
+}
+
+// NewShareHandler creates a new share handler
+func NewShareHandler(shareService *service.ShareService, messageAudioService *service.MessageAudioService) *ShareHandler {
+	return &ShareHandler{
+		shareService:        shareService,
+		messageAudioService: messageAudioService,
+	}
+}
+
+// CreateShare creates a share link for the conversation's current active branch
+func (h *ShareHandler) CreateShare(c *gin.Context) {
+	userID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ExpiresInHours int  `json:"expires_in_hours" binding:"min=0"`
+		IncludeAudio   bool `json:"include_audio"`
+	}
+	// The body is optional; an empty one creates a permanent link without audio
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresInHours > maxShareExpiryHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expires_in_hours must be at most %d", maxShareExpiryHours)})
+		return
+	}
+
+	share, err := h.shareService.CreateShare(id, userID, service.CreateShareParams{
+		ExpiresIn:    time.Duration(req.ExpiresInHours) * time.Hour,
+		IncludeAudio: req.IncludeAudio,
+	})
+	if err != nil {
+		shareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, shareJSON(share))
+}
+
+// ListShares lists the share links of a conversation with their access counts
+func (h *ShareHandler) ListShares(c *gin.Context) {
+	userID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	shares, err := h.shareService.ListShares(id, userID)
+	if err != nil {
+		shareError(c, err)
+		return
+	}
+
+	formatted := make([]gin.H, len(shares))
+	for i := range shares {
+		formatted[i] = shareJSON(&shares[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shares": formatted,
+		"count":  len(formatted),
+	})
+}
+
+// RevokeShare disables a share link
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	userID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	shareID, err := strconv.ParseUint(c.Param("shareId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share ID"})
+		return
+	}
+
+	if err := h.shareService.RevokeShare(id, uint(shareID), userID); err != nil {
+		shareError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetSharedConversation serves the read-only view behind a share token. No authentication is required.
+func (h *ShareHandler) GetSharedConversation(c *gin.Context) {
+	view, err := h.shareService.GetSharedConversation(c.Param("token"))
+	if err != nil {
+		shareError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, view)
+}
+
+// GetSharedAudio plays a message of a shared conversation when the link includes audio
+func (h *ShareHandler) GetSharedAudio(c *gin.Context) {
+	if h.messageAudioService == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Message audio replay is not enabled"})
+		return
+	}
+
+	message, ownerID, err := h.shareService.GetSharedAudioMessage(c.Param("token"), c.Param("messageId"))
+	if err != nil {
+		shareError(c, err)
+		return
+	}
+
+	chunk, err := h.messageAudioService.Replay(c.Request.Context(), message, ownerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMessageNotFound), errors.Is(err, service.ErrAudioNotReplayable):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Error retrieving message audio: %v", err)})
+		}
+		return
+	}
+
+	serveAudioChunk(c, h.messageAudioService, chunk, fmt.Sprintf("message-%s.%s", message.ExternalID, chunk.Format))
+}
+
+// shareJSON formats a share link for its owner, including the public path
+func shareJSON(share *models.ConversationShare) gin.H {
+	return gin.H{
+		"id":               share.ID,
+		"token":            share.Token,
+		"path":             "/api/v1/shared/" + share.Token,
+		"title":            share.Title,
+		"include_audio":    share.IncludeAudio,
+		"expires_at":       share.ExpiresAt,
+		"revoked_at":       share.RevokedAt,
+		"access_count":     share.AccessCount,
+		"last_accessed_at": share.LastAccessedAt,
+		"created_at":       share.CreatedAt,
+	}
+}
+
+// shareError maps share service errors to HTTP responses
+func shareError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrShareNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found or no longer available"})
+	case errors.Is(err, service.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+	case errors.Is(err, service.ErrNothingToShare):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		conversationError(c, err)
+	}
+}
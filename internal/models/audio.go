@@ -1,16 +1,24 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-// AudioChunk represents a temporary stored audio fragment from a user
+// Audio directions distinguish what the user said from what a character replied
+const (
+	AudioDirectionInbound  = "inbound"  // Captured from the user
+	AudioDirectionOutbound = "outbound" // Synthesized for a character reply
+)
+
+// AudioChunk represents a temporary stored audio fragment from a user or a character
 type AudioChunk struct {
 	ID               uint      `json:"id" gorm:"primaryKey"`
-	UserID           string    `json:"user_id"`
-	SessionID        string    `json:"session_id" gorm:"index"`
+	UserID           string    `json:"user_id" gorm:"uniqueIndex:idx_audio_inbound_user_content,priority:1,where:direction = 'inbound' AND content_hash <> ''"`
+	SessionID        string    `json:"session_id" gorm:"index;uniqueIndex:idx_audio_inbound_user_content,priority:2"`
 	CharID           uint      `json:"char_id"`
 	AudioData        []byte    `json:"audio_data"`
 	Format           string    `json:"format" gorm:"default:webm"`
@@ -21,6 +29,15 @@ type AudioChunk struct {
 	ExpiresAt        time.Time `json:"expires_at" gorm:"index"`
 	Metadata         string    `json:"metadata"` // JSON string for additional context
 	ProcessingStatus string    `json:"processing_status" gorm:"default:pending"`
+	Direction        string    `json:"direction" gorm:"default:inbound;index"`
+	VoiceType        string    `json:"voice_type"`                                                                      // Voice used for synthesized audio
+	ContentHash      string    `json:"content_hash" gorm:"index;uniqueIndex:idx_audio_inbound_user_content,priority:3"` // SHA-256 of AudioData, hex encoded
+	Transcript       string    `json:"transcript"`                                                                      // Cached speech-to-text result
+	BlobKey          string    `json:"-"`                                                                               // Key in the audio blob store; AudioData is empty when set
+	Size             int64     `json:"size"`                                                                            // Length of the audio in bytes
+
+	ConversationID *uint         `json:"conversation_id,omitempty" gorm:"index"`
+	Conversation   *Conversation `json:"-" gorm:"constraint:OnDelete:CASCADE"`
 }
 
 // BeforeCreate sets default values and expiration time
@@ -29,9 +46,33 @@ func (a *AudioChunk) BeforeCreate(tx *gorm.DB) error {
 		// Default TTL of 24 hours if not specified
 		a.ExpiresAt = time.Now().Add(24 * time.Hour)
 	}
+	if a.Direction == "" {
+		a.Direction = AudioDirectionInbound
+	}
+	if a.ContentHash == "" && len(a.AudioData) > 0 {
+		a.ContentHash = HashAudio(a.AudioData)
+	}
+	if a.Size == 0 {
+		a.Size = int64(len(a.AudioData))
+	}
 	return nil
 }
 
+// HashAudio returns the hex SHA-256 used to deduplicate and verify audio
+func HashAudio(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ByteSize returns the length of the audio, whether it is in the row or in
+// the blob store
+func (a *AudioChunk) ByteSize() int64 {
+	if a.Size > 0 {
+		return a.Size
+	}
+	return int64(len(a.AudioData))
+}
+
 // Expired checks if the audio chunk has expired
 func (a *AudioChunk) Expired() bool {
 	return time.Now().After(a.ExpiresAt)
@@ -41,3 +82,61 @@ func (a *AudioChunk) Expired() bool {
 func (AudioChunk) TableName() string {
 	return "audio_chunks"
 }
+
+// AudioMessageLink attaches a stored chunk to a message it produced. Inbound
+// audio is deduplicated per session, so one chunk may be linked to every
+// message that sent the same recording.
+type AudioMessageLink struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ChunkID   uint      `json:"chunk_id" gorm:"uniqueIndex:idx_audio_links_chunk_message;not null"`
+	MessageID string    `json:"message_id" gorm:"uniqueIndex:idx_audio_links_chunk_message;index:idx_audio_links_session_message,priority:2;not null"` // ExternalID of the message
+	SessionID string    `json:"session_id" gorm:"index:idx_audio_links_session_message,priority:1"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Chunk *AudioChunk `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// TableName overrides the table name
+func (AudioMessageLink) TableName() string {
+	return "audio_message_links"
+}
+
+// Audio upload states
+const (
+	AudioUploadStatusUploading = "uploading"
+	AudioUploadStatusCompleted = "completed"
+)
+
+// AudioUpload tracks a resumable upload whose bytes are staged on disk until
+// it is finalized into an AudioChunk
+type AudioUpload struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	UserID     string    `json:"user_id" gorm:"index"`
+	SessionID  string    `json:"session_id"`
+	CharID     uint      `json:"char_id"`
+	Format     string    `json:"format"`
+	SampleRate int       `json:"sample_rate"`
+	Channels   int       `json:"channels"`
+	Duration   float64   `json:"duration"`
+	Metadata   string    `json:"metadata"`
+	ChunkTTL   int64     `json:"chunk_ttl"`                          // TTL in seconds for the resulting chunk
+	Length     int64     `json:"length"`                             // Declared total size in bytes
+	Offset     int64     `json:"offset" gorm:"column:upload_offset"` // Bytes received so far
+	Checksum   string    `json:"checksum"`                           // Expected SHA-256 of the whole file, hex encoded
+	TempPath   string    `json:"-"`                                  // Staging file on local disk
+	Status     string    `json:"status" gorm:"default:uploading;index"`
+	ChunkID    string    `json:"chunk_id"` // Set once finalized
+	ExpiresAt  time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Expired checks if an unfinished upload has been abandoned
+func (u *AudioUpload) Expired() bool {
+	return u.Status != AudioUploadStatusCompleted && time.Now().After(u.ExpiresAt)
+}
+
+// TableName overrides the table name
+func (AudioUpload) TableName() string {
+	return "audio_uploads"
+}
@@ -1,45 +1,212 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Character struct {
-	gorm.Model    `json:"-"`
-	ID            uint      `json:"id" gorm:"primarykey"`
-	Name          string    `json:"name" gorm:"not null"`
-	Description   string    `json:"description" gorm:"not null"`
-	Personality   string    `json:"personality" gorm:"not null"`
-	Background    string    `json:"background"`
-	Category      string    `json:"category"`
-	Traits        []string  `json:"traits" gorm:"type:text[]"`
-	Goals         []string  `json:"goals" gorm:"type:text[]"`
-	Fears         []string  `json:"fears" gorm:"type:text[]"`
-	Relationships []string  `json:"relationships" gorm:"type:text[]"`
-	VoiceType     string    `json:"voice_type" gorm:"not null"`
-	VoiceGender   string    `json:"voice_gender"`
-	VoiceStyle    string    `json:"voice_style"`
-	AvatarURL     string    `json:"avatar_url"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	IsCustom      bool      `json:"is_custom" gorm:"default:false"` // Only true for custom characters
+	gorm.Model         `json:"-"`
+	ID                 uint            `json:"id" gorm:"primarykey"`
+	Slug               string          `json:"slug,omitempty"` // Stable key of seeded characters; empty for others
+	Name               string          `json:"name" gorm:"not null"`
+	Description        string          `json:"description" gorm:"not null"`
+	Personality        string          `json:"personality" gorm:"not null"`
+	Background         string          `json:"background"`
+	Scenario           string          `json:"scenario"` // Setting conversations take place in
+	Category           string          `json:"category"`
+	Traits             []string        `json:"traits" gorm:"type:text[]"`
+	Goals              []string        `json:"goals" gorm:"type:text[]"`
+	Fears              []string        `json:"fears" gorm:"type:text[]"`
+	Relationships      []string        `json:"relationships" gorm:"type:text[]"`
+	VoiceType          string          `json:"voice_type" gorm:"not null"`
+	VoiceGender        string          `json:"voice_gender"`
+	VoiceStyle         string          `json:"voice_style"`
+	AvatarURL          string          `json:"avatar_url"`
+	Greeting           string          `json:"greeting"`                               // First message of a conversation
+	AlternateGreetings []string        `json:"alternate_greetings" gorm:"type:text[]"` // Picked at random instead of Greeting
+	ExampleDialogue    string          `json:"example_dialogue"`                       // Sample exchanges showing the character's voice
+	CardExtensions     json.RawMessage `json:"-" gorm:"type:jsonb"`                    // Unmapped members of an imported character card
+	CreatedAt          time.Time       `json:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at"`
+	IsCustom           bool            `json:"is_custom" gorm:"default:false"`  // Only true for custom characters
+	OwnerID            *uint           `json:"owner_id,omitempty" gorm:"index"` // Nil for system characters
+	Visibility         string          `json:"visibility" gorm:"not null;default:public;index"`
+	Version            int             `json:"version" gorm:"not null;default:1"` // Current entry in character_versions
+}
+
+// Character visibility. System characters are visible to everyone whatever
+// their visibility.
+const (
+	CharacterVisibilityPrivate  = "private"  // Owner and admins only
+	CharacterVisibilityUnlisted = "unlisted" // Anyone with the ID, but not listed
+	CharacterVisibilityPublic   = "public"   // Listed for everyone
+)
+
+// ValidCharacterVisibility reports whether v is a known visibility
+func ValidCharacterVisibility(v string) bool {
+	switch v {
+	case CharacterVisibilityPrivate, CharacterVisibilityUnlisted, CharacterVisibilityPublic:
+		return true
+	}
+	return false
+}
+
+// CharacterFields are the editable persona fields of a character. Each
+// character version stores a full copy of them.
+type CharacterFields struct {
+	Name               string   `json:"name" gorm:"not null"`
+	Description        string   `json:"description" gorm:"not null"`
+	Personality        string   `json:"personality" gorm:"not null"`
+	Background         string   `json:"background"`
+	Scenario           string   `json:"scenario"`
+	Category           string   `json:"category"`
+	Traits             []string `json:"traits" gorm:"type:text[]"`
+	Goals              []string `json:"goals" gorm:"type:text[]"`
+	Fears              []string `json:"fears" gorm:"type:text[]"`
+	Relationships      []string `json:"relationships" gorm:"type:text[]"`
+	VoiceType          string   `json:"voice_type" gorm:"not null"`
+	VoiceGender        string   `json:"voice_gender"`
+	VoiceStyle         string   `json:"voice_style"`
+	AvatarURL          string   `json:"avatar_url"`
+	Greeting           string   `json:"greeting"`
+	AlternateGreetings []string `json:"alternate_greetings" gorm:"type:text[]"`
+	ExampleDialogue    string   `json:"example_dialogue"`
+}
+
+// Fields returns the character's editable fields
+func (c *Character) Fields() CharacterFields {
+	return CharacterFields{
+		Name:               c.Name,
+		Description:        c.Description,
+		Personality:        c.Personality,
+		Background:         c.Background,
+		Scenario:           c.Scenario,
+		Category:           c.Category,
+		Traits:             c.Traits,
+		Goals:              c.Goals,
+		Fears:              c.Fears,
+		Relationships:      c.Relationships,
+		VoiceType:          c.VoiceType,
+		VoiceGender:        c.VoiceGender,
+		VoiceStyle:         c.VoiceStyle,
+		AvatarURL:          c.AvatarURL,
+		Greeting:           c.Greeting,
+		AlternateGreetings: c.AlternateGreetings,
+		ExampleDialogue:    c.ExampleDialogue,
+	}
+}
+
+// SetFields replaces the character's editable fields
+func (c *Character) SetFields(f CharacterFields) {
+	c.Name = f.Name
+	c.Description = f.Description
+	c.Personality = f.Personality
+	c.Background = f.Background
+	c.Scenario = f.Scenario
+	c.Category = f.Category
+	c.Traits = f.Traits
+	c.Goals = f.Goals
+	c.Fears = f.Fears
+	c.Relationships = f.Relationships
+	c.VoiceType = f.VoiceType
+	c.VoiceGender = f.VoiceGender
+	c.VoiceStyle = f.VoiceStyle
+	c.AvatarURL = f.AvatarURL
+	c.Greeting = f.Greeting
+	c.AlternateGreetings = f.AlternateGreetings
+	c.ExampleDialogue = f.ExampleDialogue
+}
+
+// OwnedBy reports whether the user owns the character. System characters
+// have no owner.
+func (c *Character) OwnedBy(userID uint) bool {
+	return c.OwnerID != nil && *c.OwnerID == userID
+}
+
+// IsSystem reports whether the character is a global system character
+func (c *Character) IsSystem() bool {
+	return c.OwnerID == nil
+}
+
+// VisibleTo reports whether the user may open the character by ID
+func (c *Character) VisibleTo(userID uint) bool {
+	return c.IsSystem() || c.OwnedBy(userID) || c.Visibility != CharacterVisibilityPrivate
+}
+
+// CharacterCategory is an entry in the admin-managed category taxonomy.
+// Characters refer to it by slug.
+type CharacterCategory struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Slug        string    `json:"slug" gorm:"uniqueIndex;not null"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description"`
+	SortOrder   int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName overrides the table name
+func (CharacterCategory) TableName() string {
+	return "character_categories"
+}
+
+// Character version changes
+const (
+	CharacterChangeCreate   = "create"
+	CharacterChangeUpdate   = "update"
+	CharacterChangeRollback = "rollback"
+	CharacterChangeBackfill = "backfill" // Snapshot of a character that predates versioning
+	CharacterChangeSeed     = "seed"     // Loaded from fixtures
+)
+
+// ErrCharacterVersionImmutable is returned when saving over an existing character version
+var ErrCharacterVersionImmutable = errors.New("character versions are immutable")
+
+// CharacterVersion is a snapshot of a character written on every edit.
+// Versions are never changed; a rollback writes a new version.
+type CharacterVersion struct {
+	ID              uint `json:"id" gorm:"primaryKey"`
+	CharacterID     uint `json:"character_id" gorm:"not null;uniqueIndex:idx_character_versions_version"`
+	Version         int  `json:"version" gorm:"not null;uniqueIndex:idx_character_versions_version"`
+	CharacterFields `gorm:"embedded"`
+	Change          string    `json:"change"`
+	RestoredFrom    *int      `json:"restored_from,omitempty"` // Version copied by a rollback
+	EditedBy        *uint     `json:"edited_by,omitempty"`     // Nil for system edits
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// BeforeUpdate keeps versions immutable
+func (v *CharacterVersion) BeforeUpdate(tx *gorm.DB) error {
+	return ErrCharacterVersionImmutable
+}
+
+// TableName overrides the table name
+func (CharacterVersion) TableName() string {
+	return "character_versions"
 }
 
 type CreateCharacterRequest struct {
-	Name          string   `json:"name" binding:"required"`
-	Description   string   `json:"description" binding:"required"`
-	Personality   string   `json:"personality" binding:"required"`
-	Background    string   `json:"background"`
-	Category      string   `json:"category"`
-	Traits        []string `json:"traits"`
-	Goals         []string `json:"goals"`
-	Fears         []string `json:"fears"`
-	Relationships []string `json:"relationships"`
-	VoiceType     string   `json:"voice_type" binding:"required"`
-	VoiceGender   string   `json:"voice_gender"`
-	VoiceStyle    string   `json:"voice_style"`
-	AvatarURL     string   `json:"avatar_url"`
-	IsCustom      bool     `json:"is_custom"`
+	Name               string   `json:"name" binding:"required"`
+	Description        string   `json:"description" binding:"required"`
+	Personality        string   `json:"personality" binding:"required"`
+	Background         string   `json:"background"`
+	Scenario           string   `json:"scenario"`
+	Category           string   `json:"category"`
+	Traits             []string `json:"traits"`
+	Goals              []string `json:"goals"`
+	Fears              []string `json:"fears"`
+	Relationships      []string `json:"relationships"`
+	VoiceType          string   `json:"voice_type" binding:"required"`
+	VoiceGender        string   `json:"voice_gender"`
+	VoiceStyle         string   `json:"voice_style"`
+	AvatarURL          string   `json:"-"` // Set from an uploaded avatar, never by clients
+	Greeting           string   `json:"greeting"`
+	AlternateGreetings []string `json:"alternate_greetings"`
+	ExampleDialogue    string   `json:"example_dialogue"`
+	IsCustom           bool     `json:"is_custom"`
+	Visibility         string   `json:"visibility"` // Defaults to private
 }
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+)
+
+// Conversation states
+const (
+	ConversationStatusActive   = "active"
+	ConversationStatusArchived = "archived"
+)
+
+// Conversation is a chat between a user and a character. SessionID is the
+// public key used by the WebSocket and message APIs.
+type Conversation struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	SessionID    string    `json:"session_id" gorm:"uniqueIndex;not null"`
+	UserID       *uint     `json:"user_id" gorm:"index"` // Nil for anonymous WebSocket sessions
+	CharacterID  uint      `json:"character_id" gorm:"index"`
+	Title        string    `json:"title"`
+	Summary      string    `json:"summary"`
+	Status       string    `json:"status" gorm:"default:active;index"`
+	MessageCount int       `json:"message_count" gorm:"default:0"`
+	ActiveLeafID *uint     `json:"active_leaf_id,omitempty"` // Last message of the active branch
+	LastActiveAt time.Time `json:"last_active_at" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Character version the conversation was started with; 0 when unknown
+	CharacterVersion int `json:"character_version" gorm:"default:0"`
+
+	// Titles and summaries are generated in the background unless the user set them
+	TitleOverridden   bool       `json:"title_overridden" gorm:"default:false"`
+	SummaryOverridden bool       `json:"summary_overridden" gorm:"default:false"`
+	SummarizedCount   int        `json:"-" gorm:"default:0"` // MessageCount when last summarized
+	SummarizedAt      *time.Time `json:"summarized_at,omitempty"`
+
+	// Scenes have several characters; CharacterID is the first of them. One
+	// of the pkg/scene routing modes picks who answers each turn.
+	SpeakerRouting string                    `json:"speaker_routing,omitempty"`
+	Participants   []ConversationParticipant `json:"participants,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// IsScene reports whether several characters take part in the conversation
+func (c *Conversation) IsScene() bool {
+	return c.SpeakerRouting != ""
+}
+
+// OwnedBy reports whether the conversation belongs to the user. Anonymous
+// conversations belong to no one until they are claimed.
+func (c *Conversation) OwnedBy(userID uint) bool {
+	return c.UserID != nil && *c.UserID == userID
+}
+
+// TableName overrides the table name
+func (Conversation) TableName() string {
+	return "conversations"
+}
+
+// ConversationParticipant is a character taking part in a scene. Position
+// orders the cast for round-robin turns.
+type ConversationParticipant struct {
+	ID             uint      `json:"-" gorm:"primaryKey"`
+	ConversationID uint      `json:"-" gorm:"uniqueIndex:idx_participants_character;not null"`
+	CharacterID    uint      `json:"character_id" gorm:"uniqueIndex:idx_participants_character;not null"`
+	Position       int       `json:"position"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+// TableName overrides the table name
+func (ConversationParticipant) TableName() string {
+	return "conversation_participants"
+}
+
+// ConversationShare is a public read-only link to a snapshot of a
+// conversation. The snapshot ends at LeafID, so later messages stay private.
+type ConversationShare struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	Token          string     `json:"token" gorm:"uniqueIndex;not null"`
+	ConversationID uint       `json:"conversation_id" gorm:"index;not null"`
+	UserID         uint       `json:"user_id" gorm:"index;not null"`
+	LeafID         uint       `json:"-" gorm:"not null"`
+	Title          string     `json:"title"`
+	IncludeAudio   bool       `json:"include_audio" gorm:"default:false"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	AccessCount    int64      `json:"access_count" gorm:"default:0"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// TableName overrides the table name
+func (ConversationShare) TableName() string {
+	return "conversation_shares"
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"ai-agent-character-demo/backend/pkg/knowledge"
+)
+
+// KnowledgeDocument is a document an author attached to a character. Its
+// text is split into chunks that are retrieved into replies.
+type KnowledgeDocument struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CharacterID uint      `json:"character_id" gorm:"not null;index"`
+	Title       string    `json:"title" gorm:"not null"`
+	Filename    string    `json:"filename"`
+	Format      string    `json:"format" gorm:"not null"` // text, markdown or pdf
+	Content     string    `json:"-"`                      // Extracted text
+	Size        int       `json:"size"`                   // Characters of extracted text
+	ChunkCount  int       `json:"chunk_count"`
+	Embedder    string    `json:"embedder"`
+	UploadedBy  *uint     `json:"uploaded_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName overrides the table name
+func (KnowledgeDocument) TableName() string {
+	return "knowledge_documents"
+}
+
+// KnowledgeChunk is an embedded passage of a knowledge document. Chunks
+// carry the character ID so retrieval needs no join.
+type KnowledgeChunk struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	DocumentID  uint             `json:"document_id" gorm:"not null;index"`
+	CharacterID uint             `json:"character_id" gorm:"not null;index:idx_knowledge_chunks_character_embedder"`
+	Embedder    string           `json:"embedder" gorm:"not null;index:idx_knowledge_chunks_character_embedder"`
+	Position    int              `json:"position"` // Order within the document
+	Content     string           `json:"content" gorm:"not null"`
+	Embedding   knowledge.Vector `json:"-" gorm:"type:real[]"`
+	CreatedAt   time.Time        `json:"created_at"`
+
+	Document *KnowledgeDocument `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// TableName overrides the table name
+func (KnowledgeChunk) TableName() string {
+	return "knowledge_chunks"
+}
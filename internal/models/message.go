@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -14,14 +15,28 @@ type Message struct {
 	Content     string    `json:"content"`
 	Timestamp   time.Time `json:"timestamp"`
 	CreatedAt   time.Time `json:"created_at"`
+
+	ConversationID *uint           `json:"conversation_id,omitempty" gorm:"index"`
+	Conversation   *Conversation   `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	ParentID       *uint           `json:"parent_id,omitempty" gorm:"index"`      // Previous turn; siblings are alternate branches
+	PromptVersion  string          `json:"prompt_version,omitempty" gorm:"index"` // Prompt that produced a character reply
+	Citations      json.RawMessage `json:"citations,omitempty" gorm:"type:jsonb"` // Knowledge passages a character reply drew on
 }
 
 // MessageFeedback represents feedback on a message
 type MessageFeedback struct {
 	ID           uint      `gorm:"primaryKey" json:"id"`
-	MessageID    string    `gorm:"index" json:"messageId"`
-	UserID       uint      `gorm:"index" json:"userId"`
-	FeedbackType string    `json:"feedbackType"`
+	MessageID    string    `gorm:"uniqueIndex:idx_feedback_user_message" json:"messageId"`
+	UserID       uint      `gorm:"uniqueIndex:idx_feedback_user_message;index" json:"userId"`
+	FeedbackType string    `gorm:"index" json:"feedbackType"` // "up", "down" or "flag"
 	Timestamp    int64     `json:"timestamp"`
 	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
 }
+
+// Feedback types
+const (
+	FeedbackUp   = "up"
+	FeedbackDown = "down"
+	FeedbackFlag = "flag"
+)
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrPromptTemplateImmutable is returned when saving over an existing prompt template version
+var ErrPromptTemplateImmutable = errors.New("prompt template versions are immutable")
+
+// PromptTemplate is one version of a named text/template system prompt.
+// A template is bound to a character, to a chat model, to both, or to
+// neither (the global default). Versions are never changed; edits are saved
+// as a new version and one version per name is active.
+type PromptTemplate struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"not null;uniqueIndex:idx_prompt_templates_version"`
+	Version     int       `json:"version" gorm:"not null;uniqueIndex:idx_prompt_templates_version"`
+	Description string    `json:"description"`
+	Body        string    `json:"body" gorm:"type:text;not null"`
+	CharacterID *uint     `json:"character_id,omitempty" gorm:"index"` // Nil for templates not bound to a character
+	Model       string    `json:"model,omitempty" gorm:"index"`        // Empty for templates not bound to a model
+	Active      bool      `json:"active" gorm:"default:false;index"`
+	CreatedBy   *uint     `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Label identifies the version on the messages it produced, e.g. "tutor@v3"
+func (t *PromptTemplate) Label() string {
+	return fmt.Sprintf("%s@v%d", t.Name, t.Version)
+}
+
+// BeforeUpdate keeps versions immutable; only the active flag may change
+func (t *PromptTemplate) BeforeUpdate(tx *gorm.DB) error {
+	if tx.Statement.Changed("Name", "Version", "Description", "Body", "CharacterID", "Model") {
+		return ErrPromptTemplateImmutable
+	}
+	return nil
+}
+
+// TableName overrides the table name
+func (PromptTemplate) TableName() string {
+	return "prompt_templates"
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Kinds of seeded records
+const (
+	SeedKindCategory  = "category"
+	SeedKindVoice     = "voice"
+	SeedKindUser      = "user"
+	SeedKindCharacter = "character"
+)
+
+// SeedRecord remembers which revision of a fixture record was loaded, so
+// seeding again only applies records whose revision went up
+type SeedRecord struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Kind       string    `json:"kind" gorm:"not null;uniqueIndex:idx_seed_records_key"`
+	Key        string    `json:"key" gorm:"not null;uniqueIndex:idx_seed_records_key"` // Slug, or email for users
+	Revision   int       `json:"revision" gorm:"not null"`
+	FixtureSet string    `json:"fixture_set"`
+	TargetID   uint      `json:"target_id"` // ID of the seeded row
+	AppliedAt  time.Time `json:"applied_at"`
+}
+
+// TableName overrides the table name
+func (SeedRecord) TableName() string {
+	return "seed_records"
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Voice is a text-to-speech voice characters can speak with. Characters
+// refer to it by slug in VoiceType.
+type Voice struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	Slug            string    `json:"slug" gorm:"uniqueIndex;not null"`
+	Name            string    `json:"name" gorm:"not null"`
+	Provider        string    `json:"provider"`          // e.g. elevenlabs
+	ProviderVoiceID string    `json:"provider_voice_id"` // The provider's ID for the voice
+	Gender          string    `json:"gender"`
+	Style           string    `json:"style"`
+	Language        string    `json:"language"`
+	Description     string    `json:"description"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// TableName overrides the table name
+func (Voice) TableName() string {
+	return "voices"
+}
@@ -2,16 +2,21 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"ai-agent-character-demo/backend/ai"
 	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/cache"
+	"ai-agent-character-demo/backend/pkg/pagination"
 	ws "ai-agent-character-demo/backend/pkg/ws"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CharacterServiceAdapter adapts the CharacterService to the ws.CharacterService interface
@@ -26,20 +31,29 @@ func NewCharacterServiceAdapter(service *CharacterService) *CharacterServiceAdap
 	}
 }
 
-// GetCharacter implements the ws.CharacterService interface
+// GetCharacter implements the ws.CharacterService interface. Only characters
+// the connected user may see are returned; anonymous users see the ones that
+// are not private.
 func (a *CharacterServiceAdapter) GetCharacter(id uint, userID string) (*ws.Character, error) {
-	character, err := a.service.GetCharacter(id)
+	var viewer CharacterEditor
+	if uid, err := strconv.ParseUint(userID, 10, 64); err == nil {
+		viewer.UserID = uint(uid)
+	}
+	character, err := a.service.GetVisibleCharacter(id, viewer)
 	if err != nil {
 		return nil, err
 	}
 
-	return &ws.Character{
-		ID:          character.ID,
-		Name:        character.Name,
-		Description: character.Description,
-		Personality: character.Personality,
-		VoiceType:   character.VoiceType,
-	}, nil
+	return WebSocketCharacter(character), nil
+}
+
+// CharacterIDBySlug implements the ws.CharacterResolver interface
+func (a *CharacterServiceAdapter) CharacterIDBySlug(slug string) (uint, error) {
+	character, err := a.service.GetCharacterBySlug(slug)
+	if err != nil {
+		return 0, err
+	}
+	return character.ID, nil
 }
 
 // AIServiceAdapter adapts an external AI service to the ws.AIService interface
@@ -79,61 +93,139 @@ func (a *AIServiceAdapter) SpeechToText(ctx context.Context, sessionID string, a
 
 // MessageService handles message persistence
 type MessageService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	promptVersion string
+	branches      *cache.Cache // Leaf message ID -> IDs on its branch; a message's ancestors never change
 }
 
 // NewMessageService creates a new message service
 func NewMessageService(db *gorm.DB) *MessageService {
 	return &MessageService{
-		db: db,
+		db:       db,
+		branches: cache.NewCache(),
 	}
 }
 
-// SaveMessage persists a message to the database
+// SetPromptVersion sets the prompt version recorded on character replies
+func (s *MessageService) SetPromptVersion(version string) {
+	s.promptVersion = version
+}
+
+// SaveMessage appends a message to the session's active branch and updates its conversation's activity
 func (s *MessageService) SaveMessage(characterID uint, sessionID string, wsMessage *ws.ChatMessage) error {
-	message := &models.Message{
-		ExternalID:  wsMessage.ID,
-		CharacterID: characterID,
-		SessionID:   sessionID,
-		Sender:      wsMessage.Sender,
-		Content:     wsMessage.Content,
-		Timestamp:   wsMessage.Timestamp,
-		CreatedAt:   time.Now(),
-	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		message := &models.Message{
+			ExternalID:  wsMessage.ID,
+			CharacterID: characterID,
+			SessionID:   sessionID,
+			Sender:      wsMessage.Sender,
+			Content:     wsMessage.Content,
+			Timestamp:   wsMessage.Timestamp,
+		}
+		if message.Sender == "character" {
+			message.PromptVersion = s.promptVersion
+			if wsMessage.PromptVersion != "" {
+				message.PromptVersion = wsMessage.PromptVersion
+			}
+		}
+		citations, err := encodeCitations(wsMessage.Citations)
+		if err != nil {
+			return err
+		}
+		message.Citations = citations
 
-	return s.db.Create(message).Error
+		conversation, err := lockConversation(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		if conversation != nil {
+			message.ParentID = conversation.ActiveLeafID
+		}
+
+		return appendMessage(tx, conversation, message)
+	})
 }
 
-// GetSessionMessages retrieves messages for a specific session
+// GetSessionMessages retrieves the active branch of a session, oldest first.
+// Branches span every character that spoke in the session, so scenes keep
+// each speaker's lines; sessions without a conversation are read per character.
 func (s *MessageService) GetSessionMessages(characterID uint, sessionID string) ([]models.Message, error) {
+	var leafIDs []*uint
+	if err := s.db.Model(&models.Conversation{}).Where("session_id = ?", sessionID).Limit(1).Pluck("active_leaf_id", &leafIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(leafIDs) > 0 && leafIDs[0] != nil {
+		messages, err := s.sessionMessages(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		return branchTo(messages, *leafIDs[0]), nil
+	}
+
 	var messages []models.Message
 	result := s.db.Where("character_id = ? AND session_id = ?", characterID, sessionID).
-		Order("timestamp ASC").
+		Order("timestamp ASC, id ASC").
 		Find(&messages)
+	if result.Error != nil {
+		return nil, result.Error
+	}
 
-	return messages, result.Error
+	return messages, nil
 }
 
-// Add paginated message fetching
-func (s *MessageService) GetSessionMessagesPaginated(characterID uint, sessionID string, limit, offset int) ([]models.Message, int, error) {
-	var messages []models.Message
-	var total int64
-	db := s.db.Model(&models.Message{}).Where("character_id = ? AND session_id = ?", characterID, sessionID)
-	db.Count(&total)
-	err := db.Order("timestamp ASC").Limit(limit).Offset(offset).Find(&messages).Error
-	return messages, int(total), err
+// GetAudioMessageIDs returns the external IDs of session messages that have
+// unexpired audio linked to them
+func (s *MessageService) GetAudioMessageIDs(sessionID string) (map[string]bool, error) {
+	var messageIDs []string
+	err := s.db.Model(&models.AudioMessageLink{}).
+		Joins("JOIN audio_chunks ON audio_chunks.id = audio_message_links.chunk_id").
+		Where("audio_message_links.session_id = ? AND audio_chunks.expires_at > ?", sessionID, time.Now()).
+		Distinct("audio_message_links.message_id").
+		Pluck("audio_message_links.message_id", &messageIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		ids[id] = true
+	}
+	return ids, nil
 }
 
-// Add feedback saving
+// AudioURLFor returns the audio URL to expose for a message, or an empty string
+// when the message has no replayable voice
+func AudioURLFor(msg models.Message, audioIDs map[string]bool) string {
+	if msg.ExternalID == "" {
+		return ""
+	}
+	// Character replies can always be replayed because expired audio is re-synthesized
+	if msg.Sender == "character" || audioIDs[msg.ExternalID] {
+		return ws.MessageAudioURL(msg.ExternalID)
+	}
+	return ""
+}
+
+// SaveFeedback records a user's rating of a message. A user has one rating
+// per message; rating again replaces it.
 func (s *MessageService) SaveFeedback(messageID string, userID uint, feedbackType string, timestamp int64) error {
+	now := time.Now()
+	if timestamp == 0 {
+		timestamp = now.UnixMilli()
+	}
+
 	feedback := &models.MessageFeedback{
 		MessageID:    messageID,
 		UserID:       userID,
 		FeedbackType: feedbackType,
 		Timestamp:    timestamp,
-		CreatedAt:    time.Now(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
 	}
-	return s.db.Create(feedback).Error
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"feedback_type", "timestamp", "updated_at"}),
+	}).Create(feedback).Error
 }
 
 // MessageServiceAdapter adapts MessageService to be used with the WebSocket hub
@@ -160,36 +252,70 @@ func (a *MessageServiceAdapter) GetSessionMessages(characterID uint, sessionID s
 		return nil, err
 	}
 
-	// Convert from DB model to WS model
+	return a.toChatMessages(sessionID, dbMessages), nil
+}
+
+// toChatMessages converts DB messages to the WS model, attaching audio URLs
+func (a *MessageServiceAdapter) toChatMessages(sessionID string, dbMessages []models.Message) []ws.ChatMessage {
+	audioIDs, err := a.messageService.GetAudioMessageIDs(sessionID)
+	if err != nil {
+		log.Printf("Error loading audio links for session %s: %v", sessionID, err)
+	}
+
 	wsMessages := make([]ws.ChatMessage, len(dbMessages))
 	for i, msg := range dbMessages {
 		wsMessages[i] = ws.ChatMessage{
-			ID:        msg.ExternalID,
-			Sender:    msg.Sender,
-			Content:   msg.Content,
-			Timestamp: msg.Timestamp,
+			ID:          msg.ExternalID,
+			Sender:      msg.Sender,
+			CharacterID: msg.CharacterID,
+			Content:     msg.Content,
+			Timestamp:   msg.Timestamp,
+			AudioURL:    AudioURLFor(msg, audioIDs),
+			Citations:   messageCitations(msg),
 		}
 	}
+	return wsMessages
+}
+
+// encodeCitations encodes the knowledge citations of a reply for storage
+func encodeCitations(citations []ws.Citation) (json.RawMessage, error) {
+	if len(citations) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(citations)
+}
 
-	return wsMessages, nil
+// messageCitations decodes the knowledge citations stored on a message
+func messageCitations(msg models.Message) []ws.Citation {
+	if len(msg.Citations) == 0 {
+		return nil
+	}
+	var citations []ws.Citation
+	if err := json.Unmarshal(msg.Citations, &citations); err != nil {
+		log.Printf("Error decoding citations of message %s: %v", msg.ExternalID, err)
+		return nil
+	}
+	return citations
 }
 
-// Add paginated message fetching
-func (a *MessageServiceAdapter) GetSessionMessagesPaginated(characterID uint, sessionID string, limit, offset int) ([]ws.ChatMessage, int, error) {
-	dbMessages, total, err := a.messageService.GetSessionMessagesPaginated(characterID, sessionID, limit, offset)
+// GetHistoryPage returns the page of the session's history just before the
+// before cursor, or the latest page when before is empty
+func (a *MessageServiceAdapter) GetHistoryPage(characterID uint, sessionID string, before string, limit int) (*ws.HistoryPage, error) {
+	page, err := pagination.ParsePage(before, "", limit)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	wsMessages := make([]ws.ChatMessage, len(dbMessages))
-	for i, msg := range dbMessages {
-		wsMessages[i] = ws.ChatMessage{
-			ID:        msg.ExternalID,
-			Sender:    msg.Sender,
-			Content:   msg.Content,
-			Timestamp: msg.Timestamp,
-		}
+
+	result, err := a.messageService.GetSessionMessagesPage(characterID, sessionID, page)
+	if err != nil {
+		return nil, err
 	}
-	return wsMessages, total, nil
+
+	return &ws.HistoryPage{
+		Messages:   a.toChatMessages(sessionID, result.Messages),
+		PrevCursor: result.PrevCursor,
+		HasMore:    result.HasOlder,
+	}, nil
 }
 
 // Add feedback saving
@@ -197,6 +323,47 @@ func (a *MessageServiceAdapter) SaveFeedback(messageID string, userID uint, feed
 	return a.messageService.SaveFeedback(messageID, userID, feedbackType, timestamp)
 }
 
+// ResumeGap is how long a session must be idle before its summary is used as
+// context on reconnect
+const ResumeGap = 30 * time.Minute
+
+// ConversationServiceAdapter adapts ConversationService to be used with the WebSocket hub
+type ConversationServiceAdapter struct {
+	conversationService *ConversationService
+}
+
+// NewConversationServiceAdapter creates a new conversation service adapter
+func NewConversationServiceAdapter(conversationService *ConversationService) *ConversationServiceAdapter {
+	return &ConversationServiceAdapter{
+		conversationService: conversationService,
+	}
+}
+
+// ResumeSummary returns the "previously on" summary for a session resumed
+// after a break, or an empty string
+func (a *ConversationServiceAdapter) ResumeSummary(sessionID string) (string, error) {
+	return a.conversationService.ResumeSummary(sessionID, ResumeGap)
+}
+
+// AuthorizeSession creates the session's conversation on first use and
+// reports whether the connecting user may join it
+func (a *ConversationServiceAdapter) AuthorizeSession(sessionID string, characterID uint, userID *uint) (bool, error) {
+	_, err := a.conversationService.EnsureConversation(sessionID, characterID, userID)
+	if errors.Is(err, ErrConversationForbidden) || errors.Is(err, ErrCharacterNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SceneCast returns the speaker routing and cast of the scene behind a
+// session; routing is empty when the session is not a scene
+func (a *ConversationServiceAdapter) SceneCast(sessionID string) (string, []uint, error) {
+	return a.conversationService.SceneCast(sessionID)
+}
+
 // AdapterService handles the connection between the audio service and AI layer
 type AdapterService struct {
 	audioService *AudioService
@@ -307,6 +474,10 @@ func (s *AdapterService) ProcessAudioChunk(ctx context.Context, chunkID string)
 	sessionInfo.LastActive = time.Now()
 
 	// 1. Speech-to-text
+	if err := s.audioService.LoadAudio(ctx, chunk); err != nil {
+		s.audioService.UpdateProcessingStatus(chunkID, "failed")
+		return "", nil, err
+	}
 	transcript, _, err := s.aiBridge.SpeechToText(ctx, chunk.SessionID, chunk.AudioData)
 	if err != nil {
 		s.audioService.UpdateProcessingStatus(chunkID, "failed")
@@ -319,13 +490,7 @@ func (s *AdapterService) ProcessAudioChunk(ctx context.Context, chunkID string)
 		s.audioService.UpdateProcessingStatus(chunkID, "failed")
 		return "", nil, fmt.Errorf("failed to get character: %v", err)
 	}
-	wsChar := &ws.Character{
-		ID:          character.ID,
-		Name:        character.Name,
-		Description: character.Description,
-		Personality: character.Personality,
-		VoiceType:   character.VoiceType,
-	}
+	wsChar := WebSocketCharacter(character)
 	// Optionally, fetch conversation history if needed
 	var history []ws.ChatMessage
 	textResponse, err := s.aiBridge.GenerateTextResponse(wsChar, transcript, history)
@@ -421,13 +586,7 @@ func (s *AdapterService) ProcessAudioData(ctx context.Context, sessionID string,
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to get character: %v", err)
 	}
-	wsChar := &ws.Character{
-		ID:          character.ID,
-		Name:        character.Name,
-		Description: character.Description,
-		Personality: character.Personality,
-		VoiceType:   character.VoiceType,
-	}
+	wsChar := WebSocketCharacter(character)
 	var history []ws.ChatMessage
 	textResponse, err := s.aiBridge.GenerateTextResponse(wsChar, transcript, history)
 	if err != nil {
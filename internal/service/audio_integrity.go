@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/blob"
+)
+
+// integrityBatchSize bounds how many blobs are loaded at once during verification
+const integrityBatchSize = 100
+
+// IntegrityReport summarizes one verification pass over stored audio
+type IntegrityReport struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Checked    int       `json:"checked"`
+	Backfilled int       `json:"backfilled"` // Chunks stored before hashing that received a hash
+	Corrupted  []uint    `json:"corrupted"`  // Chunks whose bytes no longer match their hash
+	Missing    []uint    `json:"missing"`    // Chunks whose bytes are empty or gone from the blob store
+}
+
+// AudioIntegrityService periodically verifies stored audio against its content hash
+type AudioIntegrityService struct {
+	audioService *AudioService
+	interval     time.Duration
+
+	mu         sync.Mutex
+	lastReport *IntegrityReport
+}
+
+// NewAudioIntegrityService creates a verifier and starts its periodic job.
+// An interval of zero disables the schedule; VerifyIntegrity can still be run on demand.
+func NewAudioIntegrityService(audioService *AudioService, interval time.Duration) *AudioIntegrityService {
+	service := &AudioIntegrityService{
+		audioService: audioService,
+		interval:     interval,
+	}
+
+	if interval > 0 {
+		go service.startVerificationRoutine()
+	}
+
+	return service
+}
+
+// VerifyIntegrity rehashes every unexpired chunk and records the result as the latest report
+func (s *AudioIntegrityService) VerifyIntegrity() (*IntegrityReport, error) {
+	report := &IntegrityReport{
+		StartedAt: time.Now(),
+		Corrupted: []uint{},
+		Missing:   []uint{},
+	}
+
+	db := s.audioService.db
+	var lastID uint
+	for {
+		var chunks []models.AudioChunk
+		if err := db.Select("id", "audio_data", "content_hash", "blob_key").
+			Where("id > ? AND expires_at > ?", lastID, time.Now()).
+			Order("id ASC").
+			Limit(integrityBatchSize).
+			Find(&chunks).Error; err != nil {
+			return nil, fmt.Errorf("error loading audio chunks for verification: %w", err)
+		}
+		if len(chunks) == 0 {
+			break
+		}
+
+		for _, chunk := range chunks {
+			lastID = chunk.ID
+			report.Checked++
+
+			actual, err := s.hashChunk(&chunk)
+			if errors.Is(err, blob.ErrNotFound) || (err == nil && actual == "") {
+				report.Missing = append(report.Missing, chunk.ID)
+				continue
+			}
+			if err != nil {
+				log.Printf("Error reading audio chunk %d for verification: %v", chunk.ID, err)
+				continue
+			}
+
+			if chunk.ContentHash == "" {
+				if err := db.Model(&models.AudioChunk{}).Where("id = ?", chunk.ID).Update("content_hash", actual).Error; err != nil {
+					log.Printf("Error backfilling content hash for audio chunk %d: %v", chunk.ID, err)
+					continue
+				}
+				report.Backfilled++
+				continue
+			}
+
+			if actual != chunk.ContentHash {
+				report.Corrupted = append(report.Corrupted, chunk.ID)
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+
+	s.mu.Lock()
+	s.lastReport = report
+	s.mu.Unlock()
+
+	return report, nil
+}
+
+// hashChunk returns the SHA-256 of a chunk's bytes, streaming those kept in
+// the blob store, or "" when the chunk has no bytes
+func (s *AudioIntegrityService) hashChunk(chunk *models.AudioChunk) (string, error) {
+	if chunk.BlobKey == "" {
+		if len(chunk.AudioData) == 0 {
+			return "", nil
+		}
+		return models.HashAudio(chunk.AudioData), nil
+	}
+
+	content, err := s.audioService.OpenAudio(context.Background(), chunk)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// LastReport returns the most recent verification result, or nil if none has run
+func (s *AudioIntegrityService) LastReport() *IntegrityReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastReport
+}
+
+// startVerificationRoutine runs periodic integrity verification
+func (s *AudioIntegrityService) startVerificationRoutine() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := s.VerifyIntegrity()
+		if err != nil {
+			log.Printf("Error verifying audio integrity: %v", err)
+			continue
+		}
+		if len(report.Corrupted) > 0 || len(report.Missing) > 0 {
+			log.Printf("Audio integrity check found %d corrupted and %d missing chunks (corrupted: %v, missing: %v)",
+				len(report.Corrupted), len(report.Missing), report.Corrupted, report.Missing)
+		}
+	}
+}
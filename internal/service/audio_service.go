@@ -1,17 +1,27 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/blob"
 )
 
+// ErrAudioNotFound is returned when no stored audio matches a lookup
+var ErrAudioNotFound = errors.New("audio not found")
+
 // AudioServiceConfig defines configuration for the audio service
 type AudioServiceConfig struct {
 	MaxChunksPerSession int // Maximum number of audio chunks allowed per session
@@ -30,6 +40,7 @@ func DefaultAudioServiceConfig() AudioServiceConfig {
 type AudioService struct {
 	db     *gorm.DB
 	config AudioServiceConfig
+	blobs  blob.Store // Holds uploaded files; nil keeps all audio in database rows
 }
 
 // NewAudioService creates a new audio service with default config
@@ -50,7 +61,69 @@ func NewAudioServiceWithConfig(db *gorm.DB, config AudioServiceConfig) *AudioSer
 	return service
 }
 
-// StoreAudioChunk saves an audio chunk to the database with TTL
+// SetBlobStore keeps uploaded audio files in store, with only their key in the
+// database
+func (s *AudioService) SetBlobStore(store blob.Store) {
+	s.blobs = store
+}
+
+// PrepareAudioSchema readies existing audio tables for AutoMigrate. It moves
+// message links out of the legacy audio_chunks.message_id column into
+// audio_message_links, then merges inbound chunks of a session with the same
+// content, which the unique deduplication index no longer allows.
+func PrepareAudioSchema(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.AudioChunk{}) {
+		return nil
+	}
+	if err := db.AutoMigrate(&models.AudioMessageLink{}); err != nil {
+		return fmt.Errorf("failed to create audio message links: %w", err)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&models.AudioChunk{}, "message_id") {
+			if err := tx.Exec(`INSERT INTO audio_message_links (chunk_id, message_id, session_id, created_at)
+				SELECT id, message_id, session_id, created_at FROM audio_chunks WHERE message_id <> ''
+				ON CONFLICT DO NOTHING`).Error; err != nil {
+				return fmt.Errorf("failed to move audio message links: %w", err)
+			}
+			if err := tx.Migrator().DropColumn(&models.AudioChunk{}, "message_id"); err != nil {
+				return fmt.Errorf("failed to drop audio_chunks.message_id: %w", err)
+			}
+		}
+
+		// Duplicates are now only merged within one user's uploads; the index
+		// over (session_id, content_hash) alone is replaced
+		if err := tx.Exec("DROP INDEX IF EXISTS idx_audio_inbound_content").Error; err != nil {
+			return fmt.Errorf("failed to drop idx_audio_inbound_content: %w", err)
+		}
+
+		// Keep the oldest of each group of duplicates, with every link and the
+		// latest expiry of the group
+		duplicates := `SELECT id, MIN(id) OVER (PARTITION BY user_id, session_id, content_hash) AS keep,
+			MAX(expires_at) OVER (PARTITION BY user_id, session_id, content_hash) AS expires_at
+			FROM audio_chunks WHERE direction = 'inbound' AND content_hash <> ''`
+		if err := tx.Exec(`INSERT INTO audio_message_links (chunk_id, message_id, session_id, created_at)
+			SELECT d.keep, l.message_id, l.session_id, l.created_at
+			FROM audio_message_links l JOIN (` + duplicates + `) d ON d.id = l.chunk_id
+			WHERE d.id <> d.keep
+			ON CONFLICT DO NOTHING`).Error; err != nil {
+			return fmt.Errorf("failed to merge duplicate audio links: %w", err)
+		}
+		if err := tx.Exec(`UPDATE audio_chunks a SET expires_at = d.expires_at
+			FROM (` + duplicates + `) d
+			WHERE a.id = d.id AND d.id = d.keep AND a.expires_at < d.expires_at`).Error; err != nil {
+			return fmt.Errorf("failed to merge duplicate audio expiry: %w", err)
+		}
+		if err := tx.Exec(`DELETE FROM audio_chunks a USING (` + duplicates + `) d
+			WHERE a.id = d.id AND d.id <> d.keep`).Error; err != nil {
+			return fmt.Errorf("failed to delete duplicate audio: %w", err)
+		}
+		return nil
+	})
+}
+
+// StoreAudioChunk saves an audio chunk to the database with TTL. Retries of
+// identical audio within a session return the existing chunk's ID.
 func (s *AudioService) StoreAudioChunk(
 	userID string,
 	sessionID string,
@@ -63,45 +136,377 @@ func (s *AudioService) StoreAudioChunk(
 	metadata string,
 	ttl time.Duration,
 ) (string, error) {
+	id, _, err := s.StoreAudioChunkDedup(userID, sessionID, charID, audioData, format, duration, sampleRate, channels, metadata, ttl)
+	return id, err
+}
+
+// StoreAudioChunkDedup saves an audio chunk unless the user already stored a
+// chunk with the same content in the session, in which case it returns that
+// chunk's ID and duplicate=true
+func (s *AudioService) StoreAudioChunkDedup(
+	userID string,
+	sessionID string,
+	charID uint,
+	audioData []byte,
+	format string,
+	duration float64,
+	sampleRate int,
+	channels int,
+	metadata string,
+	ttl time.Duration,
+) (string, bool, error) {
 	if len(audioData) == 0 {
-		return "", errors.New("audio data cannot be empty")
+		return "", false, errors.New("audio data cannot be empty")
 	}
 
-	// Check if this session has reached the maximum number of chunks
-	if s.config.MaxChunksPerSession > 0 {
-		var count int64
-		if err := s.db.Model(&models.AudioChunk{}).Where("session_id = ?", sessionID).Count(&count).Error; err != nil {
-			log.Printf("Error counting audio chunks: %v", err)
-		} else if count >= int64(s.config.MaxChunksPerSession) {
-			return "", fmt.Errorf("session has reached the maximum limit of %d audio chunks", s.config.MaxChunksPerSession)
+	contentHash := models.HashAudio(audioData)
+	if id, ok := s.findDuplicate(userID, sessionID, contentHash); ok {
+		return id, true, nil
+	}
+	if err := s.checkSessionLimit(sessionID); err != nil {
+		return "", false, err
+	}
+
+	chunk := &models.AudioChunk{
+		UserID:      userID,
+		SessionID:   sessionID,
+		CharID:      charID,
+		AudioData:   audioData,
+		Format:      format,
+		Duration:    duration,
+		SampleRate:  sampleRate,
+		Channels:    channels,
+		ExpiresAt:   time.Now().Add(ttl),
+		Metadata:    metadata,
+		ContentHash: contentHash,
+	}
+	return s.createInbound(chunk)
+}
+
+// StoreAudioFile saves a staged file whose SHA-256 is contentHash as an audio
+// chunk, deduplicating like StoreAudioChunkDedup. With a blob store the file
+// is streamed into it and the row keeps only the key; without one the file is
+// read into the row.
+func (s *AudioService) StoreAudioFile(
+	ctx context.Context,
+	userID string,
+	sessionID string,
+	charID uint,
+	path string,
+	contentHash string,
+	format string,
+	duration float64,
+	sampleRate int,
+	channels int,
+	metadata string,
+	ttl time.Duration,
+) (string, bool, error) {
+	if id, ok := s.findDuplicate(userID, sessionID, contentHash); ok {
+		return id, true, nil
+	}
+	if err := s.checkSessionLimit(sessionID); err != nil {
+		return "", false, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read audio file: %w", err)
+	}
+	if info.Size() == 0 {
+		return "", false, errors.New("audio data cannot be empty")
+	}
+
+	chunk := &models.AudioChunk{
+		UserID:      userID,
+		SessionID:   sessionID,
+		CharID:      charID,
+		Format:      format,
+		Duration:    duration,
+		SampleRate:  sampleRate,
+		Channels:    channels,
+		ExpiresAt:   time.Now().Add(ttl),
+		Metadata:    metadata,
+		ContentHash: contentHash,
+		Size:        info.Size(),
+	}
+
+	if s.blobs == nil {
+		if chunk.AudioData, err = os.ReadFile(path); err != nil {
+			return "", false, fmt.Errorf("failed to read audio file: %w", err)
+		}
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read audio file: %w", err)
+		}
+		chunk.BlobKey = "audio/" + uuid.New().String()
+		err = s.blobs.PutReader(ctx, chunk.BlobKey, file)
+		file.Close()
+		if err != nil {
+			return "", false, fmt.Errorf("failed to store audio file: %w", err)
 		}
 	}
 
-	// Generate a unique ID for this chunk
-	chunkID := uuid.New().String()
+	id, duplicate, err := s.createInbound(chunk)
+	if err != nil || duplicate {
+		s.deleteBlob(chunk.BlobKey)
+	}
+	return id, duplicate, err
+}
+
+// findDuplicate returns the ID of an unexpired inbound chunk the user stored
+// in the session with the given content. Other users' identical audio is
+// never shared.
+func (s *AudioService) findDuplicate(userID string, sessionID string, contentHash string) (string, bool) {
+	var existing models.AudioChunk
+	err := s.db.Select("id").
+		Where("user_id = ? AND session_id = ? AND content_hash = ? AND direction = ? AND expires_at > ?",
+			userID, sessionID, contentHash, models.AudioDirectionInbound, time.Now()).
+		Order("id ASC").
+		First(&existing).Error
+	if err == nil {
+		return strconv.FormatUint(uint64(existing.ID), 10), true
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Error checking for duplicate audio chunk: %v", err)
+	}
+	return "", false
+}
+
+// checkSessionLimit fails once a session holds the maximum number of chunks
+func (s *AudioService) checkSessionLimit(sessionID string) error {
+	if s.config.MaxChunksPerSession <= 0 {
+		return nil
+	}
+	var count int64
+	if err := s.db.Model(&models.AudioChunk{}).Where("session_id = ?", sessionID).Count(&count).Error; err != nil {
+		log.Printf("Error counting audio chunks: %v", err)
+	} else if count >= int64(s.config.MaxChunksPerSession) {
+		return fmt.Errorf("session has reached the maximum limit of %d audio chunks", s.config.MaxChunksPerSession)
+	}
+	return nil
+}
+
+// createInbound stores a chunk captured from the user. The unique index on
+// (user_id, session_id, content_hash) for inbound audio settles concurrent
+// uploads of the same recording: the loser gets the stored chunk's ID and
+// duplicate=true.
+func (s *AudioService) createInbound(chunk *models.AudioChunk) (string, bool, error) {
+	chunk.CreatedAt = time.Now()
+	chunk.ProcessingStatus = "pending"
+	chunk.Direction = models.AudioDirectionInbound
+	chunk.ConversationID = conversationIDForSession(s.db, chunk.SessionID)
+
+	// Reuse the transcript of identical audio so it doesn't go through STT again
+	if transcript, ok := s.FindTranscript(chunk.ContentHash); ok {
+		chunk.Transcript = transcript
+	}
+
+	result := s.db.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "user_id"}, {Name: "session_id"}, {Name: "content_hash"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "direction = 'inbound' AND content_hash <> ''"}}},
+		DoNothing:   true,
+	}).Create(chunk)
+	if result.Error != nil {
+		return "", false, fmt.Errorf("failed to store audio chunk: %w", result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return strconv.FormatUint(uint64(chunk.ID), 10), false, nil
+	}
+
+	// The user already stored this audio in the session, possibly expired but
+	// not yet cleaned up; keep it for at least as long as the new chunk would have lived
+	var existing models.AudioChunk
+	if err := s.db.Select("id").
+		Where("user_id = ? AND session_id = ? AND content_hash = ? AND direction = ?", chunk.UserID, chunk.SessionID, chunk.ContentHash, models.AudioDirectionInbound).
+		First(&existing).Error; err != nil {
+		return "", false, fmt.Errorf("failed to find duplicate audio chunk: %w", err)
+	}
+	if err := s.db.Model(&models.AudioChunk{}).
+		Where("id = ? AND expires_at < ?", existing.ID, chunk.ExpiresAt).
+		Update("expires_at", chunk.ExpiresAt).Error; err != nil {
+		log.Printf("Error extending duplicate audio chunk %d: %v", existing.ID, err)
+	}
+	return strconv.FormatUint(uint64(existing.ID), 10), true, nil
+}
+
+// LoadAudio fills in the bytes of a chunk kept in the blob store
+func (s *AudioService) LoadAudio(ctx context.Context, chunk *models.AudioChunk) error {
+	if chunk.BlobKey == "" || len(chunk.AudioData) > 0 {
+		return nil
+	}
+	if s.blobs == nil {
+		return fmt.Errorf("audio chunk %d is in a blob store that is not configured", chunk.ID)
+	}
+	data, err := s.blobs.Get(ctx, chunk.BlobKey)
+	if err != nil {
+		return fmt.Errorf("failed to load audio: %w", err)
+	}
+	chunk.AudioData = data
+	return nil
+}
+
+// OpenAudio returns a seekable reader over a chunk's bytes, streaming those
+// kept in the blob store
+func (s *AudioService) OpenAudio(ctx context.Context, chunk *models.AudioChunk) (io.ReadSeekCloser, error) {
+	if chunk.BlobKey == "" || len(chunk.AudioData) > 0 {
+		return nopReadSeekCloser{bytes.NewReader(chunk.AudioData)}, nil
+	}
+	if s.blobs == nil {
+		return nil, fmt.Errorf("audio chunk %d is in a blob store that is not configured", chunk.ID)
+	}
+	reader, err := s.blobs.Open(ctx, chunk.BlobKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio: %w", err)
+	}
+	return reader, nil
+}
+
+// nopReadSeekCloser serves in-row audio through OpenAudio
+type nopReadSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopReadSeekCloser) Close() error { return nil }
+
+// deleteBlob removes stored audio bytes, logging failures; orphaned objects
+// are harmless
+func (s *AudioService) deleteBlob(key string) {
+	if key == "" || s.blobs == nil {
+		return
+	}
+	if err := s.blobs.Delete(context.Background(), key); err != nil {
+		log.Printf("Error deleting audio blob %s: %v", key, err)
+	}
+}
+
+// FindTranscript returns a cached transcript for audio with the given content hash
+func (s *AudioService) FindTranscript(contentHash string) (string, bool) {
+	var chunk models.AudioChunk
+	err := s.db.Select("transcript").
+		Where("content_hash = ? AND transcript <> ''", contentHash).
+		Order("id DESC").
+		First(&chunk).Error
+	if err != nil {
+		return "", false
+	}
+	return chunk.Transcript, true
+}
+
+// GetTranscript returns the cached transcript of a stored chunk, if any
+func (s *AudioService) GetTranscript(chunkID string) (string, bool) {
+	var chunk models.AudioChunk
+	if err := s.db.Select("transcript").Where("id = ?", chunkID).First(&chunk).Error; err != nil {
+		return "", false
+	}
+	return chunk.Transcript, chunk.Transcript != ""
+}
+
+// SaveTranscript caches a speech-to-text result on a chunk
+func (s *AudioService) SaveTranscript(chunkID string, transcript string) error {
+	result := s.db.Model(&models.AudioChunk{}).
+		Where("id = ?", chunkID).
+		Update("transcript", transcript)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to save transcript: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("audio chunk not found")
+	}
+
+	return nil
+}
+
+// StoreCharacterAudio saves synthesized speech for a character reply and links it
+// to the message it was generated for, so the reply can be replayed later
+func (s *AudioService) StoreCharacterAudio(
+	userID string,
+	sessionID string,
+	charID uint,
+	messageID string,
+	audioData []byte,
+	format string,
+	voiceType string,
+	ttl time.Duration,
+) (string, error) {
+	if len(audioData) == 0 {
+		return "", errors.New("audio data cannot be empty")
+	}
+	if messageID == "" {
+		return "", errors.New("message ID is required for character audio")
+	}
+	if ttl <= 0 {
+		ttl = s.config.DefaultTTL
+	}
 
-	// Create the audio chunk record
 	chunk := &models.AudioChunk{
 		UserID:           userID,
 		SessionID:        sessionID,
 		CharID:           charID,
 		AudioData:        audioData,
 		Format:           format,
-		Duration:         duration,
-		SampleRate:       sampleRate,
-		Channels:         channels,
+		SampleRate:       0, // Unknown for provider-encoded audio
+		Channels:         1,
 		CreatedAt:        time.Now(),
 		ExpiresAt:        time.Now().Add(ttl),
-		Metadata:         metadata,
-		ProcessingStatus: "pending",
+		ProcessingStatus: "completed",
+		Direction:        models.AudioDirectionOutbound,
+		VoiceType:        voiceType,
+		ConversationID:   conversationIDForSession(s.db, sessionID),
 	}
 
-	// Store in database
-	if err := s.db.Create(chunk).Error; err != nil {
-		return "", fmt.Errorf("failed to store audio chunk: %w", err)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(chunk).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AudioMessageLink{ChunkID: chunk.ID, MessageID: messageID, SessionID: sessionID}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store character audio: %w", err)
 	}
 
-	return chunkID, nil
+	return strconv.FormatUint(uint64(chunk.ID), 10), nil
+}
+
+// LinkAudioChunkToMessage associates a stored chunk with the message it
+// produced. Links add up, so a deduplicated chunk keeps the messages it was
+// already linked to.
+func (s *AudioService) LinkAudioChunkToMessage(chunkID string, messageID string) error {
+	var chunk models.AudioChunk
+	if err := s.db.Select("id", "session_id").Where("id = ?", chunkID).First(&chunk).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("audio chunk not found")
+		}
+		return fmt.Errorf("failed to link audio chunk: %w", err)
+	}
+
+	link := &models.AudioMessageLink{ChunkID: chunk.ID, MessageID: messageID, SessionID: chunk.SessionID}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(link).Error; err != nil {
+		return fmt.Errorf("failed to link audio chunk: %w", err)
+	}
+
+	return nil
+}
+
+// GetMessageAudio returns the most recent unexpired audio linked to a message
+// of the session
+func (s *AudioService) GetMessageAudio(sessionID string, messageID string) (*models.AudioChunk, error) {
+	var chunk models.AudioChunk
+
+	err := s.db.Joins("JOIN audio_message_links ON audio_message_links.chunk_id = audio_chunks.id").
+		Where("audio_message_links.session_id = ? AND audio_message_links.message_id = ? AND audio_chunks.expires_at > ?", sessionID, messageID, time.Now()).
+		Order("audio_chunks.created_at DESC").
+		First(&chunk).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAudioNotFound
+		}
+		return nil, fmt.Errorf("error retrieving message audio: %w", err)
+	}
+
+	return &chunk, nil
 }
 
 // GetAudioChunk retrieves an audio chunk by ID
@@ -117,7 +522,9 @@ func (s *AudioService) GetAudioChunk(id string) (*models.AudioChunk, error) {
 
 	// Check if the chunk has expired
 	if chunk.Expired() {
-		s.db.Delete(&chunk) // Delete expired chunk
+		if s.db.Delete(&chunk).Error == nil { // Delete expired chunk
+			s.deleteBlob(chunk.BlobKey)
+		}
 		return nil, errors.New("audio chunk has expired")
 	}
 
@@ -154,6 +561,9 @@ func (s *AudioService) UpdateProcessingStatus(id string, status string) error {
 
 // DeleteAudioChunk deletes an audio chunk by ID
 func (s *AudioService) DeleteAudioChunk(id string) error {
+	var keys []string
+	s.db.Model(&models.AudioChunk{}).Where("id = ? AND blob_key <> ''", id).Pluck("blob_key", &keys)
+
 	result := s.db.Delete(&models.AudioChunk{}, "id = ?", id)
 
 	if result.Error != nil {
@@ -164,17 +574,34 @@ func (s *AudioService) DeleteAudioChunk(id string) error {
 		return errors.New("audio chunk not found")
 	}
 
+	for _, key := range keys {
+		s.deleteBlob(key)
+	}
+
 	return nil
 }
 
-// CleanupExpiredChunks removes all expired audio chunks
+// CleanupExpiredChunks removes all expired audio chunks and their stored files
 func (s *AudioService) CleanupExpiredChunks() (int64, error) {
-	result := s.db.Where("expires_at < ?", time.Now()).Delete(&models.AudioChunk{})
+	now := time.Now()
+
+	var keys []string
+	if err := s.db.Model(&models.AudioChunk{}).
+		Where("expires_at < ? AND blob_key <> ''", now).
+		Pluck("blob_key", &keys).Error; err != nil {
+		return 0, fmt.Errorf("failed to find expired audio files: %w", err)
+	}
+
+	result := s.db.Where("expires_at < ?", now).Delete(&models.AudioChunk{})
 
 	if result.Error != nil {
 		return 0, fmt.Errorf("failed to cleanup expired chunks: %w", result.Error)
 	}
 
+	for _, key := range keys {
+		s.deleteBlob(key)
+	}
+
 	return result.RowsAffected, nil
 }
 
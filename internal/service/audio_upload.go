@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+)
+
+var (
+	// ErrUploadNotFound is returned for unknown, foreign or expired uploads
+	ErrUploadNotFound = errors.New("upload not found")
+
+	// ErrUploadOffsetMismatch is returned when a PATCH does not start at the current offset
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match")
+
+	// ErrUploadTooLarge is returned when data would exceed the declared or allowed size
+	ErrUploadTooLarge = errors.New("upload exceeds its declared length")
+
+	// ErrUploadIncomplete is returned when finalizing before all bytes have arrived
+	ErrUploadIncomplete = errors.New("upload is incomplete")
+
+	// ErrUploadChecksumMismatch is returned when the assembled file fails verification
+	ErrUploadChecksumMismatch = errors.New("upload checksum mismatch")
+)
+
+// AudioUploadConfig defines configuration for resumable uploads
+type AudioUploadConfig struct {
+	Dir     string        // Directory where in-progress uploads are staged
+	MaxSize int64         // Maximum total size of a single upload in bytes
+	Expiry  time.Duration // How long an upload may sit idle before it is discarded
+}
+
+// DefaultAudioUploadConfig returns default configuration
+func DefaultAudioUploadConfig() AudioUploadConfig {
+	dir := os.Getenv("AUDIO_UPLOAD_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "audio-uploads")
+	}
+
+	return AudioUploadConfig{
+		Dir:     dir,
+		MaxSize: 200 << 20, // 200MB
+		Expiry:  24 * time.Hour,
+	}
+}
+
+// CreateUploadParams describes an upload and the chunk it will become
+type CreateUploadParams struct {
+	SessionID  string
+	CharID     uint
+	Format     string
+	SampleRate int
+	Channels   int
+	Duration   float64
+	Metadata   string
+	TTL        time.Duration
+	Length     int64
+	Checksum   string
+}
+
+// AudioUploadService implements tus-style resumable uploads into the audio store
+type AudioUploadService struct {
+	db            *gorm.DB
+	audioService  *AudioService
+	conversations *ConversationService // Checks that uploads go into the caller's own sessions
+	config        AudioUploadConfig
+	locks         sync.Map // Upload ID -> *sync.Mutex, serializes writes per upload
+}
+
+// NewAudioUploadService creates a new upload service and starts its expiry routine
+func NewAudioUploadService(db *gorm.DB, audioService *AudioService, config AudioUploadConfig) (*AudioUploadService, error) {
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	service := &AudioUploadService{
+		db:           db,
+		audioService: audioService,
+		config:       config,
+	}
+
+	go service.startCleanupRoutine()
+
+	return service, nil
+}
+
+// SetConversationService lets the service check who owns a session
+func (s *AudioUploadService) SetConversationService(conversations *ConversationService) {
+	s.conversations = conversations
+}
+
+// CreateUpload registers a new upload into a session the user owns and
+// creates its empty staging file
+func (s *AudioUploadService) CreateUpload(userID string, params CreateUploadParams) (*models.AudioUpload, error) {
+	if params.SessionID == "" {
+		return nil, errors.New("session ID is required")
+	}
+	if err := s.checkSession(params.SessionID, userID); err != nil {
+		return nil, err
+	}
+	if params.Length <= 0 {
+		return nil, errors.New("upload length must be positive")
+	}
+	if params.Length > s.config.MaxSize {
+		return nil, fmt.Errorf("%w: maximum is %d bytes", ErrUploadTooLarge, s.config.MaxSize)
+	}
+	if params.TTL <= 0 {
+		params.TTL = s.audioService.GetConfig().DefaultTTL
+	}
+
+	id := uuid.New().String()
+	tempPath := filepath.Join(s.config.Dir, id+".part")
+
+	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	file.Close()
+
+	upload := &models.AudioUpload{
+		ID:         id,
+		UserID:     userID,
+		SessionID:  params.SessionID,
+		CharID:     params.CharID,
+		Format:     params.Format,
+		SampleRate: params.SampleRate,
+		Channels:   params.Channels,
+		Duration:   params.Duration,
+		Metadata:   params.Metadata,
+		ChunkTTL:   int64(params.TTL.Seconds()),
+		Length:     params.Length,
+		Checksum:   strings.ToLower(params.Checksum),
+		TempPath:   tempPath,
+		Status:     models.AudioUploadStatusUploading,
+		ExpiresAt:  time.Now().Add(s.config.Expiry),
+	}
+
+	if err := s.db.Create(upload).Error; err != nil {
+		os.Remove(tempPath)
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+// GetUpload returns an upload owned by the user
+func (s *AudioUploadService) GetUpload(id string, userID string) (*models.AudioUpload, error) {
+	var upload models.AudioUpload
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&upload).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("error retrieving upload: %w", err)
+	}
+
+	if upload.Expired() {
+		return nil, ErrUploadNotFound
+	}
+
+	return &upload, nil
+}
+
+// AppendChunk writes data at offset, which must equal the bytes received so
+// far. It returns the new offset. If checksum is set it must be the SHA-256 of
+// this part; a mismatching part is discarded without advancing the offset.
+func (s *AudioUploadService) AppendChunk(id string, userID string, offset int64, data io.Reader, checksum string) (int64, error) {
+	unlock := s.lock(id)
+	defer unlock()
+
+	upload, err := s.GetUpload(id, userID)
+	if err != nil {
+		return 0, err
+	}
+	if upload.Status != models.AudioUploadStatusUploading {
+		return upload.Offset, ErrUploadOffsetMismatch
+	}
+	if offset != upload.Offset {
+		return upload.Offset, ErrUploadOffsetMismatch
+	}
+
+	file, err := os.OpenFile(upload.TempPath, os.O_WRONLY, 0o600)
+	if err != nil {
+		return upload.Offset, fmt.Errorf("failed to open upload file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return upload.Offset, fmt.Errorf("failed to seek upload file: %w", err)
+	}
+
+	// Read one byte past the remaining length to detect oversize parts
+	remaining := upload.Length - upload.Offset
+	hash := sha256.New()
+	written, copyErr := io.Copy(io.MultiWriter(file, hash), io.LimitReader(data, remaining+1))
+
+	if written > remaining {
+		file.Truncate(offset)
+		return upload.Offset, ErrUploadTooLarge
+	}
+	if checksum != "" && !strings.EqualFold(checksum, hex.EncodeToString(hash.Sum(nil))) {
+		file.Truncate(offset)
+		return upload.Offset, ErrUploadChecksumMismatch
+	}
+
+	// A dropped connection still keeps whatever arrived, so the client can resume
+	newOffset := offset + written
+	if err := s.db.Model(&models.AudioUpload{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"upload_offset": newOffset,
+			"expires_at":    time.Now().Add(s.config.Expiry),
+		}).Error; err != nil {
+		file.Truncate(offset)
+		return upload.Offset, fmt.Errorf("failed to update upload offset: %w", err)
+	}
+
+	if copyErr != nil {
+		return newOffset, fmt.Errorf("upload interrupted: %w", copyErr)
+	}
+
+	return newOffset, nil
+}
+
+// FinalizeUpload verifies the assembled file and stores it as an audio chunk.
+// Finalizing an already completed upload returns the same chunk ID. duplicate
+// reports that the session already held identical audio.
+func (s *AudioUploadService) FinalizeUpload(id string, userID string, checksum string) (chunkID string, duplicate bool, err error) {
+	unlock := s.lock(id)
+	defer unlock()
+
+	upload, err := s.GetUpload(id, userID)
+	if err != nil {
+		return "", false, err
+	}
+	if upload.Status == models.AudioUploadStatusCompleted {
+		return upload.ChunkID, false, nil
+	}
+	if upload.Offset != upload.Length {
+		return "", false, ErrUploadIncomplete
+	}
+	// The session may have changed hands since the upload started
+	if err := s.checkSession(upload.SessionID, userID); err != nil {
+		return "", false, err
+	}
+
+	expected := strings.ToLower(checksum)
+	if expected == "" {
+		expected = upload.Checksum
+	}
+	if expected == "" {
+		return "", false, errors.New("a SHA-256 checksum is required to finalize an upload")
+	}
+
+	// Hash the staged file as a stream; it may be far larger than memory allows
+	contentHash, size, err := hashFile(upload.TempPath)
+	if err != nil {
+		return "", false, err
+	}
+	if size != upload.Length {
+		return "", false, ErrUploadIncomplete
+	}
+	if contentHash != expected {
+		return "", false, ErrUploadChecksumMismatch
+	}
+
+	chunkID, duplicate, err = s.audioService.StoreAudioFile(
+		context.Background(),
+		upload.UserID,
+		upload.SessionID,
+		upload.CharID,
+		upload.TempPath,
+		contentHash,
+		upload.Format,
+		upload.Duration,
+		upload.SampleRate,
+		upload.Channels,
+		upload.Metadata,
+		time.Duration(upload.ChunkTTL)*time.Second,
+	)
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := s.db.Model(&models.AudioUpload{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     models.AudioUploadStatusCompleted,
+			"chunk_id":   chunkID,
+			"expires_at": time.Now().Add(time.Duration(upload.ChunkTTL) * time.Second),
+		}).Error; err != nil {
+		return "", false, fmt.Errorf("failed to complete upload: %w", err)
+	}
+
+	if err := os.Remove(upload.TempPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove staged upload %s: %v", upload.TempPath, err)
+	}
+
+	return chunkID, duplicate, nil
+}
+
+// checkSession returns ErrConversationForbidden unless the user owns the session
+func (s *AudioUploadService) checkSession(sessionID string, userID string) error {
+	if s.conversations == nil {
+		return errors.New("conversation service not configured")
+	}
+	parsed, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return ErrConversationForbidden
+	}
+	return s.conversations.CheckSessionAccess(sessionID, uint(parsed))
+}
+
+// hashFile returns the hex SHA-256 and size of a file, read as a stream
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read upload file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read upload file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// CancelUpload discards an upload and its staged bytes
+func (s *AudioUploadService) CancelUpload(id string, userID string) error {
+	unlock := s.lock(id)
+	defer unlock()
+
+	upload, err := s.GetUpload(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.deleteUpload(upload)
+}
+
+// CleanupExpiredUploads removes abandoned uploads and their staging files
+func (s *AudioUploadService) CleanupExpiredUploads() (int, error) {
+	var uploads []models.AudioUpload
+	if err := s.db.Where("expires_at < ?", time.Now()).Find(&uploads).Error; err != nil {
+		return 0, fmt.Errorf("failed to find expired uploads: %w", err)
+	}
+
+	removed := 0
+	for i := range uploads {
+		if err := s.deleteUpload(&uploads[i]); err != nil {
+			log.Printf("Error removing expired upload %s: %v", uploads[i].ID, err)
+			continue
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (s *AudioUploadService) deleteUpload(upload *models.AudioUpload) error {
+	if err := os.Remove(upload.TempPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove upload file: %w", err)
+	}
+	if err := s.db.Delete(&models.AudioUpload{}, "id = ?", upload.ID).Error; err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	s.locks.Delete(upload.ID)
+	return nil
+}
+
+// lock serializes PATCH and finalize calls for one upload
+func (s *AudioUploadService) lock(id string) func() {
+	value, _ := s.locks.LoadOrStore(id, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// startCleanupRoutine runs periodic cleanup of abandoned uploads
+func (s *AudioUploadService) startCleanupRoutine() {
+	ticker := time.NewTicker(15 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := s.CleanupExpiredUploads()
+		if err != nil {
+			log.Printf("Error cleaning up expired uploads: %v", err)
+		} else if count > 0 {
+			log.Printf("Cleaned up %d expired audio uploads", count)
+		}
+	}
+}
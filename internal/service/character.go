@@ -1,19 +1,27 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
 	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/blob"
+	"ai-agent-character-demo/backend/pkg/mergepatch"
 	ws "ai-agent-character-demo/backend/pkg/ws"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CharacterService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	maxPerUser    int        // Characters a user may own; 0 means unlimited
+	avatars       blob.Store // Avatar thumbnails
+	avatarBaseURL string     // Prefix of generated avatar URLs
 }
 
 func NewCharacterService(db *gorm.DB) *CharacterService {
@@ -22,44 +30,331 @@ func NewCharacterService(db *gorm.DB) *CharacterService {
 	}
 }
 
-func (s *CharacterService) CreateCharacter(req *models.CreateCharacterRequest) (*models.Character, error) {
-	if req.Name == "" {
-		return nil, errors.New("character name is required")
+// SetMaxCharactersPerUser limits how many characters a user may own. Admins
+// and system characters are not limited.
+func (s *CharacterService) SetMaxCharactersPerUser(limit int) {
+	s.maxPerUser = limit
+}
+
+var (
+	// ErrCharacterNotFound is returned for unknown, deleted or hidden characters
+	ErrCharacterNotFound = errors.New("character not found")
+
+	// ErrCharacterForbidden is returned when a character belongs to another user
+	ErrCharacterForbidden = errors.New("character belongs to another user")
+
+	// ErrInvalidCharacter is returned when character fields fail validation
+	ErrInvalidCharacter = errors.New("invalid character")
+
+	// ErrCharacterLimitReached is returned when a user already owns the maximum number of characters
+	ErrCharacterLimitReached = errors.New("character limit reached")
+)
+
+// CharacterEditor identifies who is acting on a character. Admins may see and
+// edit any character; other users edit only the ones they own and see system
+// characters, their own and those that are not private.
+type CharacterEditor struct {
+	UserID uint
+	Admin  bool
+}
+
+// CreateCharacter creates a character owned by the editor and records it as
+// version 1. A nil editor creates a system character.
+func (s *CharacterService) CreateCharacter(req *models.CreateCharacterRequest, editor *CharacterEditor) (*models.Character, error) {
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = models.CharacterVisibilityPrivate
 	}
-	if req.Description == "" {
-		return nil, errors.New("character description is required")
+	if !models.ValidCharacterVisibility(visibility) {
+		return nil, fmt.Errorf("%w: visibility must be private, unlisted or public", ErrInvalidCharacter)
 	}
-	if req.Personality == "" {
-		return nil, errors.New("character personality is required")
+
+	character := &models.Character{
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		IsCustom:   req.IsCustom,
+		Visibility: visibility,
+		Version:    1,
 	}
-	if req.VoiceType == "" {
-		return nil, errors.New("character voice type is required")
+	if editor != nil {
+		character.OwnerID = &editor.UserID
 	}
+	character.SetFields(models.CharacterFields{
+		Name:               req.Name,
+		Description:        req.Description,
+		Personality:        req.Personality,
+		Background:         req.Background,
+		Scenario:           req.Scenario,
+		Category:           req.Category,
+		Traits:             req.Traits,
+		Goals:              req.Goals,
+		Fears:              req.Fears,
+		Relationships:      req.Relationships,
+		VoiceType:          req.VoiceType,
+		VoiceGender:        req.VoiceGender,
+		VoiceStyle:         req.VoiceStyle,
+		AvatarURL:          req.AvatarURL,
+		Greeting:           req.Greeting,
+		AlternateGreetings: req.AlternateGreetings,
+		ExampleDialogue:    req.ExampleDialogue,
+	})
+	return s.createCharacter(character, editor)
+}
 
-	character := &models.Character{
-		Name:        req.Name,
-		Description: req.Description,
-		Personality: req.Personality,
-		VoiceType:   req.VoiceType,
-		AvatarURL:   req.AvatarURL,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-		IsCustom:    req.IsCustom,
+// createCharacter validates and stores a new character as version 1
+func (s *CharacterService) createCharacter(character *models.Character, editor *CharacterEditor) (*models.Character, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.createCharacterTx(tx, character, editor)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	result := s.db.Create(character)
-	if result.Error != nil {
-		return nil, result.Error
+	return character, nil
+}
+
+// createCharacterTx is createCharacter inside a caller's transaction
+func (s *CharacterService) createCharacterTx(tx *gorm.DB, character *models.Character, editor *CharacterEditor) error {
+	if err := validateCharacterFields(character.Fields()); err != nil {
+		return err
+	}
+
+	var editedBy *uint
+	if editor != nil {
+		editedBy = &editor.UserID
+	}
+	if editor != nil && !editor.Admin {
+		if err := s.checkCharacterLimit(tx, editor.UserID); err != nil {
+			return err
+		}
+	}
+	if err := checkCategory(tx, character.Category); err != nil {
+		return err
+	}
+	if err := tx.Create(character).Error; err != nil {
+		return err
+	}
+	return createCharacterVersion(tx, character, models.CharacterChangeCreate, nil, editedBy)
+}
+
+// UpdateCharacter replaces a character's editable fields and records a new version
+func (s *CharacterService) UpdateCharacter(id uint, editor CharacterEditor, fields models.CharacterFields) (*models.Character, error) {
+	if err := validateCharacterFields(fields); err != nil {
+		return nil, err
+	}
+	return s.editCharacter(id, editor, func(character *models.Character) (models.CharacterFields, string, *int, error) {
+		// The avatar only changes through SetAvatar
+		fields.AvatarURL = character.AvatarURL
+		return fields, models.CharacterChangeUpdate, nil, nil
+	})
+}
+
+// PatchCharacter applies a JSON merge patch (RFC 7386) to a character's
+// editable fields and records a new version
+func (s *CharacterService) PatchCharacter(id uint, editor CharacterEditor, patch []byte) (*models.Character, error) {
+	return s.editCharacter(id, editor, func(character *models.Character) (models.CharacterFields, string, *int, error) {
+		current, err := json.Marshal(character.Fields())
+		if err != nil {
+			return models.CharacterFields{}, "", nil, err
+		}
+		merged, err := mergepatch.Apply(current, patch)
+		if err != nil {
+			return models.CharacterFields{}, "", nil, fmt.Errorf("%w: %v", ErrInvalidCharacter, err)
+		}
+
+		var fields models.CharacterFields
+		decoder := json.NewDecoder(bytes.NewReader(merged))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&fields); err != nil {
+			return models.CharacterFields{}, "", nil, fmt.Errorf("%w: %v", ErrInvalidCharacter, err)
+		}
+		if err := validateCharacterFields(fields); err != nil {
+			return models.CharacterFields{}, "", nil, err
+		}
+		fields.AvatarURL = character.AvatarURL
+		return fields, models.CharacterChangeUpdate, nil, nil
+	})
+}
+
+// DeleteCharacter soft-deletes a character. Its conversations and versions
+// are kept, but it can no longer be chatted with or shared.
+func (s *CharacterService) DeleteCharacter(id uint, editor CharacterEditor) error {
+	character, err := s.editableCharacter(s.db, id, editor)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(character).Error; err != nil {
+		return fmt.Errorf("failed to delete character: %w", err)
+	}
+	return nil
+}
+
+// SetVisibility changes who can see a character. Visibility is not part of
+// the character's versions.
+func (s *CharacterService) SetVisibility(id uint, editor CharacterEditor, visibility string) (*models.Character, error) {
+	if !models.ValidCharacterVisibility(visibility) {
+		return nil, fmt.Errorf("%w: visibility must be private, unlisted or public", ErrInvalidCharacter)
+	}
+
+	character, err := s.editableCharacter(s.db, id, editor)
+	if err != nil {
+		return nil, err
+	}
+	if character.IsSystem() {
+		return nil, fmt.Errorf("%w: system characters are always visible", ErrInvalidCharacter)
+	}
+
+	character.Visibility = visibility
+	if err := s.db.Model(character).Update("visibility", visibility).Error; err != nil {
+		return nil, fmt.Errorf("failed to update character visibility: %w", err)
+	}
+	return character, nil
+}
+
+// TransferOwnership gives a character to another user. It is meant for
+// admins and ignores the new owner's character limit.
+func (s *CharacterService) TransferOwnership(id uint, newOwnerID uint) (*models.Character, error) {
+	var character *models.Character
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		character, err = s.editableCharacter(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id, CharacterEditor{Admin: true})
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("id = ?", newOwnerID).Count(&count).Error; err != nil {
+			return fmt.Errorf("error checking user: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: new owner does not exist", ErrInvalidCharacter)
+		}
+
+		character.OwnerID = &newOwnerID
+		if err := tx.Model(character).Update("owner_id", newOwnerID).Error; err != nil {
+			return fmt.Errorf("failed to transfer character: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return character, nil
+}
+
+// checkCharacterLimit fails when the user already owns the maximum number of characters
+func (s *CharacterService) checkCharacterLimit(tx *gorm.DB, userID uint) error {
+	if s.maxPerUser <= 0 {
+		return nil
+	}
+	// Locking the owner serializes concurrent creations, so each one counts
+	// the characters committed before it
+	var locked []uint
+	if err := tx.Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", userID).Pluck("id", &locked).Error; err != nil {
+		return fmt.Errorf("error locking user: %w", err)
+	}
+	var count int64
+	if err := tx.Model(&models.Character{}).Where("owner_id = ?", userID).Count(&count).Error; err != nil {
+		return fmt.Errorf("error counting characters: %w", err)
+	}
+	if count >= int64(s.maxPerUser) {
+		return fmt.Errorf("%w: you can own at most %d characters", ErrCharacterLimitReached, s.maxPerUser)
 	}
+	return nil
+}
+
+// editCharacter locks a character the editor may change, applies the fields
+// returned by edit and writes them as the next version
+func (s *CharacterService) editCharacter(id uint, editor CharacterEditor, edit func(*models.Character) (models.CharacterFields, string, *int, error)) (*models.Character, error) {
+	var character *models.Character
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		character, err = s.editableCharacter(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id, editor)
+		if err != nil {
+			return err
+		}
+
+		fields, change, restoredFrom, err := edit(character)
+		if err != nil {
+			return err
+		}
+
+		// Characters keep categories that predate the taxonomy until they change
+		if fields.Category != character.Category {
+			if err := checkCategory(tx, fields.Category); err != nil {
+				return err
+			}
+		}
 
+		character.SetFields(fields)
+		character.Version++
+		character.UpdatedAt = time.Now()
+		if err := tx.Save(character).Error; err != nil {
+			return fmt.Errorf("failed to update character: %w", err)
+		}
+		return createCharacterVersion(tx, character, change, restoredFrom, &editor.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
 	return character, nil
 }
 
-// Helper to determine if a character is custom
+// editableCharacter loads a character and checks that the editor may change it
+func (s *CharacterService) editableCharacter(db *gorm.DB, id uint, editor CharacterEditor) (*models.Character, error) {
+	var character models.Character
+	if err := db.First(&character, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCharacterNotFound
+		}
+		return nil, fmt.Errorf("error retrieving character: %w", err)
+	}
+	if !editor.Admin && !character.OwnedBy(editor.UserID) {
+		return nil, ErrCharacterForbidden
+	}
+	return &character, nil
+}
+
+// validateCharacterFields checks the fields every character needs
+func validateCharacterFields(fields models.CharacterFields) error {
+	switch {
+	case strings.TrimSpace(fields.Name) == "":
+		return fmt.Errorf("%w: character name is required", ErrInvalidCharacter)
+	case strings.TrimSpace(fields.Description) == "":
+		return fmt.Errorf("%w: character description is required", ErrInvalidCharacter)
+	case strings.TrimSpace(fields.VoiceType) == "":
+		return fmt.Errorf("%w: character voice type is required", ErrInvalidCharacter)
+	}
+	return nil
+}
+
+// isCustomCharacter reports whether a character was made by a user rather
+// than shipped with the system. Characters created before ownership existed
+// keep their stored flag.
 func isCustomCharacter(c *models.Character) bool {
-	// TODO: Replace this logic with your actual custom character criteria
-	// For example, if you have a DB field or a naming convention
-	return c.IsCustom // or: strings.HasPrefix(c.Name, "Custom")
+	return !c.IsSystem() || c.IsCustom
+}
+
+// visibleCharacters limits a query to the characters the viewer may open by ID
+func visibleCharacters(viewer CharacterEditor) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewer.Admin {
+			return db
+		}
+		return db.Where("(owner_id IS NULL OR owner_id = ? OR visibility <> ?)", viewer.UserID, models.CharacterVisibilityPrivate)
+	}
+}
+
+// listedCharacters limits a query to the characters listed for the viewer;
+// unlisted characters only appear for their owner
+func listedCharacters(viewer CharacterEditor) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewer.Admin {
+			return db
+		}
+		return db.Where("(owner_id IS NULL OR owner_id = ? OR visibility = ?)", viewer.UserID, models.CharacterVisibilityPublic)
+	}
 }
 
 func (s *CharacterService) GetCharacter(id uint) (*models.Character, error) {
@@ -72,6 +367,33 @@ func (s *CharacterService) GetCharacter(id uint) (*models.Character, error) {
 	return &character, nil
 }
 
+// GetCharacterBySlug returns the character seeded under a slug
+func (s *CharacterService) GetCharacterBySlug(slug string) (*models.Character, error) {
+	var character models.Character
+	if err := s.db.Where("slug = ? AND slug <> ''", slug).First(&character).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCharacterNotFound
+		}
+		return nil, fmt.Errorf("error retrieving character: %w", err)
+	}
+	character.IsCustom = isCustomCharacter(&character)
+	return &character, nil
+}
+
+// GetVisibleCharacter returns a character the viewer may see. Hidden
+// characters are reported as not found.
+func (s *CharacterService) GetVisibleCharacter(id uint, viewer CharacterEditor) (*models.Character, error) {
+	var character models.Character
+	if err := s.db.Scopes(visibleCharacters(viewer)).First(&character, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCharacterNotFound
+		}
+		return nil, fmt.Errorf("error retrieving character: %w", err)
+	}
+	character.IsCustom = isCustomCharacter(&character)
+	return &character, nil
+}
+
 // GetWebSocketCharacter converts a models.Character to a ws.Character for WebSocket use
 func (s *CharacterService) GetWebSocketCharacter(id uint) (*ws.Character, error) {
 	character, err := s.GetCharacter(id)
@@ -79,61 +401,34 @@ func (s *CharacterService) GetWebSocketCharacter(id uint) (*ws.Character, error)
 		return nil, err
 	}
 
-	return &ws.Character{
-		ID:          character.ID,
-		Name:        character.Name,
-		Description: character.Description,
-		Personality: character.Personality,
-		VoiceType:   character.VoiceType,
-	}, nil
+	return WebSocketCharacter(character), nil
 }
 
-func (s *CharacterService) ListCharacters() ([]models.Character, error) {
-	var characters []models.Character
-	result := s.db.Find(&characters)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	if characters == nil {
-		characters = []models.Character{}
-	}
-	for i := range characters {
-		characters[i].IsCustom = isCustomCharacter(&characters[i])
+// WebSocketCharacter returns the persona the AI plays for a character
+func WebSocketCharacter(character *models.Character) *ws.Character {
+	return &ws.Character{
+		ID:                 character.ID,
+		Name:               character.Name,
+		Description:        character.Description,
+		Personality:        character.Personality,
+		Background:         character.Background,
+		Scenario:           character.Scenario,
+		Traits:             character.Traits,
+		Goals:              character.Goals,
+		Fears:              character.Fears,
+		Relationships:      character.Relationships,
+		Greeting:           character.Greeting,
+		AlternateGreetings: character.AlternateGreetings,
+		ExampleDialogue:    character.ExampleDialogue,
+		VoiceType:          character.VoiceType,
 	}
-	return characters, nil
 }
 
-// ListCharactersWithConversations returns characters that have conversations with a specific user
-func (s *CharacterService) ListCharactersWithConversations(userID uint) ([]models.Character, error) {
+// ListCharacters returns the characters listed for the viewer: system
+// characters, their own and public ones
+func (s *CharacterService) ListCharacters(viewer CharacterEditor) ([]models.Character, error) {
 	var characters []models.Character
-
-	// Find all unique character IDs from the messages table where the session involves the user
-	// Query to find all sessionIDs that contain userID in their name
-	// This approach depends on how you're creating session IDs - we need to adapt based on the exact format
-
-	// First, let's get all unique character IDs from messages where sessionID contains userID
-	var characterIDs []uint
-
-	// This query attempts to find all characters that have messages in sessions involving the user
-	// The query looks for sessions where the session ID contains the user ID as a string
-	userIDStr := fmt.Sprintf("%d", userID)
-	query := s.db.Table("messages").
-		Select("DISTINCT character_id").
-		Where("session_id LIKE ?", "%"+userIDStr+"%").
-		Pluck("character_id", &characterIDs)
-
-	if query.Error != nil {
-		return nil, query.Error
-	}
-
-	// If no characters found with the current session pattern, return all characters as a fallback
-	if len(characterIDs) == 0 {
-		log.Printf("No character conversations found for user %d, returning all characters", userID)
-		return s.ListCharacters()
-	}
-
-	// Now get the character details
-	result := s.db.Where("id IN ?", characterIDs).Find(&characters)
+	result := s.db.Scopes(listedCharacters(viewer)).Find(&characters)
 	if result.Error != nil {
 		return nil, result.Error
 	}
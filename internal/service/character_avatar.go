@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/avatar"
+	"ai-agent-character-demo/backend/pkg/blob"
+)
+
+// AvatarPath is the route prefix avatars are served from
+const AvatarPath = "/api/v1/avatars/"
+
+var (
+	// ErrAvatarNotFound is returned for unknown avatars and sizes
+	ErrAvatarNotFound = errors.New("avatar not found")
+
+	// ErrInvalidAvatar is returned for uploads that are not usable images
+	ErrInvalidAvatar = errors.New("invalid avatar")
+)
+
+// avatarIDPattern matches the content hashes avatars are stored under
+var avatarIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// SetAvatarStore sets where avatar thumbnails are kept. baseURL is put in
+// front of AvatarPath in generated avatar URLs; empty gives relative URLs.
+func (s *CharacterService) SetAvatarStore(store blob.Store, baseURL string) {
+	s.avatars = store
+	s.avatarBaseURL = strings.TrimRight(baseURL, "/")
+}
+
+// StoreAvatar validates an uploaded image, stores its thumbnails and returns
+// the avatar URL. Avatars are keyed by content, so uploading the same image
+// twice reuses it.
+func (s *CharacterService) StoreAvatar(ctx context.Context, data []byte) (string, error) {
+	if s.avatars == nil {
+		return "", errors.New("avatar store is not configured")
+	}
+
+	thumbnails, err := avatar.Process(data)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidAvatar, err)
+	}
+
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:16])
+	for _, thumb := range thumbnails {
+		if err := s.avatars.Put(ctx, avatarKey(id, thumb.Size), thumb.Data); err != nil {
+			return "", fmt.Errorf("failed to store avatar: %w", err)
+		}
+	}
+	return s.avatarBaseURL + AvatarPath + id, nil
+}
+
+// SetAvatar replaces a character's avatar with an uploaded image and records
+// a new version
+func (s *CharacterService) SetAvatar(ctx context.Context, id uint, editor CharacterEditor, data []byte) (*models.Character, error) {
+	if _, err := s.editableCharacter(s.db, id, editor); err != nil {
+		return nil, err
+	}
+	avatarURL, err := s.StoreAvatar(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return s.setAvatarURL(id, editor, avatarURL)
+}
+
+// RemoveAvatar clears a character's avatar and records a new version
+func (s *CharacterService) RemoveAvatar(id uint, editor CharacterEditor) (*models.Character, error) {
+	return s.setAvatarURL(id, editor, "")
+}
+
+// setAvatarURL writes a new version with only the avatar changed
+func (s *CharacterService) setAvatarURL(id uint, editor CharacterEditor, avatarURL string) (*models.Character, error) {
+	return s.editCharacter(id, editor, func(character *models.Character) (models.CharacterFields, string, *int, error) {
+		fields := character.Fields()
+		fields.AvatarURL = avatarURL
+		return fields, models.CharacterChangeUpdate, nil, nil
+	})
+}
+
+// GetAvatar returns one thumbnail of a stored avatar
+func (s *CharacterService) GetAvatar(ctx context.Context, id string, size int) ([]byte, error) {
+	if s.avatars == nil || !avatarIDPattern.MatchString(id) || !validAvatarSize(size) {
+		return nil, ErrAvatarNotFound
+	}
+	data, err := s.avatars.Get(ctx, avatarKey(id, size))
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, ErrAvatarNotFound
+	}
+	return data, err
+}
+
+// avatarPNG returns the largest thumbnail of a character's avatar as a PNG
+func (s *CharacterService) avatarPNG(ctx context.Context, avatarURL string) ([]byte, error) {
+	id, ok := avatarIDFromURL(avatarURL)
+	if !ok {
+		return nil, ErrAvatarNotFound
+	}
+	data, err := s.GetAvatar(ctx, id, avatar.Sizes[0])
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte("\x89PNG")) {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MigrateLegacyAvatars moves avatars saved under uploads/ by earlier releases
+// into the avatar store and points the characters at them. It returns the
+// number of characters updated; avatars that cannot be read are left alone.
+func (s *CharacterService) MigrateLegacyAvatars(ctx context.Context) (int, error) {
+	var characters []models.Character
+	if err := s.db.Select("id", "avatar_url").Where("avatar_url LIKE ?", "%/uploads/%").Find(&characters).Error; err != nil {
+		return 0, fmt.Errorf("error finding legacy avatars: %w", err)
+	}
+
+	migrated := 0
+	for _, character := range characters {
+		data, err := os.ReadFile(filepath.Join("uploads", path.Base(character.AvatarURL)))
+		if err != nil {
+			log.Printf("Skipping legacy avatar of character %d: %v", character.ID, err)
+			continue
+		}
+		avatarURL, err := s.StoreAvatar(ctx, data)
+		if err != nil {
+			log.Printf("Skipping legacy avatar of character %d: %v", character.ID, err)
+			continue
+		}
+		if err := s.db.Model(&models.Character{}).Where("id = ?", character.ID).UpdateColumn("avatar_url", avatarURL).Error; err != nil {
+			return migrated, fmt.Errorf("failed to update avatar of character %d: %w", character.ID, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// avatarKey is the blob key of one avatar thumbnail
+func avatarKey(id string, size int) string {
+	return fmt.Sprintf("avatars/%s/%d", id, size)
+}
+
+// avatarIDFromURL extracts the avatar ID from a generated avatar URL
+func avatarIDFromURL(avatarURL string) (string, bool) {
+	i := strings.Index(avatarURL, AvatarPath)
+	if i < 0 {
+		return "", false
+	}
+	id := strings.SplitN(avatarURL[i+len(AvatarPath):], "?", 2)[0]
+	return id, avatarIDPattern.MatchString(id)
+}
+
+// validAvatarSize reports whether size is one of the stored thumbnail sizes
+func validAvatarSize(size int) bool {
+	for _, s := range avatar.Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"time"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/charactercard"
+)
+
+// cardExtension is the key under data.extensions where exports keep the
+// character fields that Character Card V2 has no place for
+const cardExtension = "ai_agent_character"
+
+// cardCharacterExtension holds the fields kept under cardExtension
+type cardCharacterExtension struct {
+	Background    string   `json:"background,omitempty"`
+	Category      string   `json:"category,omitempty"`
+	Goals         []string `json:"goals,omitempty"`
+	Fears         []string `json:"fears,omitempty"`
+	Relationships []string `json:"relationships,omitempty"`
+	VoiceType     string   `json:"voice_type,omitempty"`
+	VoiceGender   string   `json:"voice_gender,omitempty"`
+	VoiceStyle    string   `json:"voice_style,omitempty"`
+}
+
+// ImportCharacterCard creates a character owned by the editor from a
+// Character Card V2 (or V1) JSON document or PNG image. Card members that
+// do not map onto the character are kept so an export returns them.
+func (s *CharacterService) ImportCharacterCard(data []byte, editor CharacterEditor, visibility string, avatarURL string) (*models.Character, error) {
+	card, err := charactercard.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCharacter, err)
+	}
+
+	if visibility == "" {
+		visibility = models.CharacterVisibilityPrivate
+	}
+	if !models.ValidCharacterVisibility(visibility) {
+		return nil, fmt.Errorf("%w: visibility must be private, unlisted or public", ErrInvalidCharacter)
+	}
+
+	extra, own, err := splitCardExtension(card.Data.Extra)
+	if err != nil {
+		return nil, err
+	}
+
+	character := &models.Character{
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		IsCustom:   true,
+		OwnerID:    &editor.UserID,
+		Visibility: visibility,
+		Version:    1,
+	}
+	if len(extra) > 0 {
+		if character.CardExtensions, err = json.Marshal(extra); err != nil {
+			return nil, err
+		}
+	}
+
+	// Categories from another deployment may not be in this taxonomy
+	if own.Category != "" {
+		known, err := categoryExists(s.db, own.Category)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			own.Category = ""
+		}
+	}
+
+	voiceType := own.VoiceType
+	if voiceType == "" {
+		voiceType = "default"
+	}
+	character.SetFields(models.CharacterFields{
+		Name:               card.Data.Name,
+		Description:        card.Data.Description,
+		Personality:        card.Data.Personality,
+		Background:         own.Background,
+		Scenario:           card.Data.Scenario,
+		Category:           own.Category,
+		Traits:             card.Data.Tags,
+		Goals:              own.Goals,
+		Fears:              own.Fears,
+		Relationships:      own.Relationships,
+		VoiceType:          voiceType,
+		VoiceGender:        own.VoiceGender,
+		VoiceStyle:         own.VoiceStyle,
+		AvatarURL:          avatarURL,
+		Greeting:           card.Data.FirstMes,
+		AlternateGreetings: card.Data.AlternateGreetings,
+		ExampleDialogue:    card.Data.MesExample,
+	})
+
+	return s.createCharacter(character, &editor)
+}
+
+// ExportCharacterCard returns a character visible to the viewer as a
+// Character Card V2 document
+func (s *CharacterService) ExportCharacterCard(id uint, viewer CharacterEditor) (*models.Character, *charactercard.Card, error) {
+	character, err := s.GetVisibleCharacter(id, viewer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var extra map[string]json.RawMessage
+	if len(character.CardExtensions) > 0 {
+		if err := json.Unmarshal(character.CardExtensions, &extra); err != nil {
+			return nil, nil, fmt.Errorf("invalid stored card extensions: %w", err)
+		}
+	}
+	extra, err = joinCardExtension(extra, cardCharacterExtension{
+		Background:    character.Background,
+		Category:      character.Category,
+		Goals:         character.Goals,
+		Fears:         character.Fears,
+		Relationships: character.Relationships,
+		VoiceType:     character.VoiceType,
+		VoiceGender:   character.VoiceGender,
+		VoiceStyle:    character.VoiceStyle,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	card := charactercard.New(charactercard.Data{
+		Name:               character.Name,
+		Description:        character.Description,
+		Personality:        character.Personality,
+		Scenario:           character.Scenario,
+		FirstMes:           character.Greeting,
+		AlternateGreetings: character.AlternateGreetings,
+		MesExample:         character.ExampleDialogue,
+		Tags:               character.Traits,
+		Extra:              extra,
+	})
+	return character, card, nil
+}
+
+// CharacterCardPNG embeds a card in the character's avatar, or in a plain
+// placeholder image when the character has no stored avatar
+func (s *CharacterService) CharacterCardPNG(ctx context.Context, character *models.Character, card *charactercard.Card) ([]byte, error) {
+	data, err := card.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := s.avatarPNG(ctx, character.AvatarURL)
+	if err != nil {
+		if img, err = placeholderPNG(); err != nil {
+			return nil, err
+		}
+	}
+	return charactercard.EmbedPNG(img, data)
+}
+
+// CharacterCardFilename returns the download filename for a card export
+func CharacterCardFilename(character *models.Character, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == '"' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, character.Name)
+	return fmt.Sprintf("%s.%s", name, ext)
+}
+
+// splitCardExtension removes this application's fields from a card's
+// extensions, returning the remaining members and the removed fields
+func splitCardExtension(extra map[string]json.RawMessage) (map[string]json.RawMessage, cardCharacterExtension, error) {
+	var own cardCharacterExtension
+	raw, ok := extra["extensions"]
+	if !ok {
+		return extra, own, nil
+	}
+
+	var extensions map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &extensions); err != nil {
+		return nil, own, fmt.Errorf("%w: extensions must be an object", ErrInvalidCharacter)
+	}
+	ownRaw, ok := extensions[cardExtension]
+	if !ok {
+		return extra, own, nil
+	}
+	if err := json.Unmarshal(ownRaw, &own); err != nil {
+		return nil, own, fmt.Errorf("%w: extensions.%s: %v", ErrInvalidCharacter, cardExtension, err)
+	}
+
+	delete(extensions, cardExtension)
+	rest, err := json.Marshal(extensions)
+	if err != nil {
+		return nil, own, err
+	}
+	extra["extensions"] = rest
+	return extra, own, nil
+}
+
+// joinCardExtension adds this application's fields to a card's extensions
+func joinCardExtension(extra map[string]json.RawMessage, own cardCharacterExtension) (map[string]json.RawMessage, error) {
+	if extra == nil {
+		extra = map[string]json.RawMessage{}
+	}
+	extensions := map[string]json.RawMessage{}
+	if raw, ok := extra["extensions"]; ok {
+		if err := json.Unmarshal(raw, &extensions); err != nil {
+			return nil, fmt.Errorf("invalid stored card extensions: %w", err)
+		}
+	}
+
+	ownRaw, err := json.Marshal(own)
+	if err != nil {
+		return nil, err
+	}
+	extensions[cardExtension] = ownRaw
+	if extra["extensions"], err = json.Marshal(extensions); err != nil {
+		return nil, err
+	}
+	return extra, nil
+}
+
+// placeholderPNG returns a plain square image for characters without an avatar
+func placeholderPNG() ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 0x4a, G: 0x55, B: 0x68, A: 0xff}}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+)
+
+var (
+	// ErrCategoryNotFound is returned for unknown category slugs
+	ErrCategoryNotFound = errors.New("category not found")
+
+	// ErrCategoryExists is returned when creating a category whose slug is taken
+	ErrCategoryExists = errors.New("category already exists")
+
+	// ErrCategoryInUse is returned when deleting a category characters still use
+	ErrCategoryInUse = errors.New("category is in use")
+
+	// ErrInvalidCategory is returned for categories with a bad slug or no name
+	ErrInvalidCategory = errors.New("invalid category")
+)
+
+// categorySlugPattern is the form of new category slugs
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// CategoryCount is a category with the number of characters listed in it
+type CategoryCount struct {
+	models.CharacterCategory
+	CharacterCount int64 `json:"character_count"`
+}
+
+// CategoryUpdate holds admin edits to a category. Nil fields are left alone;
+// the slug never changes.
+type CategoryUpdate struct {
+	Name        *string
+	Description *string
+	SortOrder   *int
+}
+
+// ListCategories returns the taxonomy in display order, counting the
+// characters listed for the viewer in each category
+func (s *CharacterService) ListCategories(viewer CharacterEditor) ([]CategoryCount, error) {
+	var categories []models.CharacterCategory
+	if err := s.db.Order("sort_order ASC, name ASC").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("error listing categories: %w", err)
+	}
+
+	var counts []struct {
+		Category string
+		Count    int64
+	}
+	if err := s.db.Model(&models.Character{}).Scopes(listedCharacters(viewer)).
+		Select("category, COUNT(*) AS count").Where("category <> ''").Group("category").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("error counting characters by category: %w", err)
+	}
+	bySlug := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		bySlug[c.Category] = c.Count
+	}
+
+	result := make([]CategoryCount, len(categories))
+	for i, category := range categories {
+		result[i] = CategoryCount{CharacterCategory: category, CharacterCount: bySlug[category.Slug]}
+	}
+	return result, nil
+}
+
+// CreateCategory adds a category to the taxonomy
+func (s *CharacterService) CreateCategory(category *models.CharacterCategory) error {
+	category.Slug = strings.TrimSpace(category.Slug)
+	category.Name = strings.TrimSpace(category.Name)
+	if !categorySlugPattern.MatchString(category.Slug) {
+		return fmt.Errorf("%w: slug must be lowercase letters, digits and hyphens", ErrInvalidCategory)
+	}
+	if category.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCategory)
+	}
+
+	exists, err := categoryExists(s.db, category.Slug)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrCategoryExists, category.Slug)
+	}
+
+	category.ID = 0
+	category.CreatedAt = time.Now()
+	category.UpdatedAt = time.Now()
+	if err := s.db.Create(category).Error; err != nil {
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+	return nil
+}
+
+// UpdateCategory changes a category's name, description or sort order
+func (s *CharacterService) UpdateCategory(slug string, update CategoryUpdate) (*models.CharacterCategory, error) {
+	category, err := findCategory(s.db, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		if strings.TrimSpace(*update.Name) == "" {
+			return nil, fmt.Errorf("%w: name is required", ErrInvalidCategory)
+		}
+		category.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Description != nil {
+		category.Description = *update.Description
+	}
+	if update.SortOrder != nil {
+		category.SortOrder = *update.SortOrder
+	}
+	category.UpdatedAt = time.Now()
+	if err := s.db.Save(category).Error; err != nil {
+		return nil, fmt.Errorf("failed to update category: %w", err)
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a category no character uses
+func (s *CharacterService) DeleteCategory(slug string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		category, err := findCategory(tx, slug)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Character{}).Where("category = ?", slug).Count(&count).Error; err != nil {
+			return fmt.Errorf("error counting characters: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %d characters use %s", ErrCategoryInUse, count, slug)
+		}
+
+		return tx.Delete(category).Error
+	})
+}
+
+// BackfillCharacterCategories adds the categories of existing characters to
+// the taxonomy so they stay valid. It returns the number of categories added.
+func (s *CharacterService) BackfillCharacterCategories() (int64, error) {
+	result := s.db.Exec(`INSERT INTO character_categories (slug, name, created_at, updated_at)
+		SELECT DISTINCT category, category, NOW(), NOW() FROM characters
+		WHERE category <> '' AND deleted_at IS NULL
+		ON CONFLICT (slug) DO NOTHING`)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to backfill categories: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// findCategory looks up a category by slug
+func findCategory(db *gorm.DB, slug string) (*models.CharacterCategory, error) {
+	var category models.CharacterCategory
+	if err := db.Where("slug = ?", slug).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("error retrieving category: %w", err)
+	}
+	return &category, nil
+}
+
+// categoryExists reports whether a slug is in the category taxonomy
+func categoryExists(db *gorm.DB, slug string) (bool, error) {
+	var count int64
+	if err := db.Model(&models.CharacterCategory{}).Where("slug = ?", slug).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("error checking category: %w", err)
+	}
+	return count > 0, nil
+}
+
+// checkCategory fails unless the category is empty or in the taxonomy
+func checkCategory(db *gorm.DB, slug string) error {
+	if slug == "" {
+		return nil
+	}
+	exists, err := categoryExists(db, slug)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: unknown category %q", ErrInvalidCharacter, slug)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/pagination"
+)
+
+// Character list sorts
+const (
+	CharacterSortRelevance  = "relevance"  // Best search matches first; needs a query
+	CharacterSortPopularity = "popularity" // Most conversations first
+	CharacterSortRecent     = "recent"     // Newest first
+	CharacterSortRating     = "rating"     // Best rated first
+)
+
+// Character types a search can be limited to
+const (
+	CharacterTypeCustom = "custom"
+	CharacterTypeSystem = "system"
+)
+
+// ErrInvalidCharacterSearch is returned for unknown sorts, types and other bad search parameters
+var ErrInvalidCharacterSearch = errors.New("invalid character search")
+
+// SearchCharactersParams filters, sorts and pages a character list
+type SearchCharactersParams struct {
+	Query       string   // Full-text and fuzzy match on name and description
+	Category    string   // Category slug
+	Traits      []string // Characters must have every trait
+	VoiceGender string
+	Type        string // CharacterTypeCustom, CharacterTypeSystem or "" for both
+	Chatted     bool   // Only characters the viewer has conversations with
+	Sort        string // Defaults to relevance with a query, popularity without
+	Cursor      string
+	Limit       int
+}
+
+// CharacterListing is a character in a search result with its popularity and rating
+type CharacterListing struct {
+	models.Character
+	Popularity  int64   `json:"popularity"`   // Conversations started with the character
+	Rating      float64 `json:"rating"`       // Smoothed share of positive feedback; 0.5 when unrated
+	RatingCount int64   `json:"rating_count"` // Up and down votes on the character's messages
+	Relevance   float64 `json:"relevance,omitempty"`
+}
+
+// characterPopularityJoin adds pop.conversations, the number of
+// conversations with each character
+const characterPopularityJoin = `LEFT JOIN (SELECT character_id, COUNT(*) AS conversations
+	FROM conversations GROUP BY character_id) pop ON pop.character_id = characters.id`
+
+// characterRatingJoin adds fb.up and fb.down, the votes on each character's messages
+const characterRatingJoin = `LEFT JOIN (SELECT m.character_id,
+		COUNT(*) FILTER (WHERE f.feedback_type = ?) AS up,
+		COUNT(*) FILTER (WHERE f.feedback_type = ?) AS down
+	FROM message_feedbacks f JOIN messages m ON m.external_id = f.message_id
+	GROUP BY m.character_id) fb ON fb.character_id = characters.id`
+
+// characterListingColumns selects a character with its popularity and a
+// rating smoothed towards 0.5 so a single vote does not top the list
+const characterListingColumns = `characters.*,
+	COALESCE(pop.conversations, 0) AS popularity,
+	(COALESCE(fb.up, 0) + 1)::float8 / (COALESCE(fb.up, 0) + COALESCE(fb.down, 0) + 2) AS rating,
+	COALESCE(fb.up, 0) + COALESCE(fb.down, 0) AS rating_count`
+
+// characterRelevanceColumn ranks full-text matches and adds the fuzzy
+// similarity of the query to the name and description
+const characterRelevanceColumn = `(ts_rank_cd(characters.search_tsv, websearch_to_tsquery('` + searchConfig + `', ?))
+	+ word_similarity(?, characters.name)
+	+ 0.5 * word_similarity(?, characters.description))::float8 AS relevance`
+
+// characterMatch matches a query against the full-text index or, for typos
+// and partial words, by trigram word similarity
+const characterMatch = `(characters.search_tsv @@ websearch_to_tsquery('` + searchConfig + `', ?)
+	OR ? <% characters.name OR ? <% characters.description)`
+
+// SearchCharacters lists the characters listed for the viewer that match the
+// search, and a cursor for the next page ("" on the last page)
+func (s *CharacterService) SearchCharacters(viewer CharacterEditor, params SearchCharactersParams) ([]CharacterListing, string, error) {
+	cursor, err := pagination.Decode(params.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	limit := pagination.ClampLimit(params.Limit)
+	text := strings.TrimSpace(params.Query)
+
+	sort := params.Sort
+	if sort == "" {
+		sort = CharacterSortPopularity
+		if text != "" {
+			sort = CharacterSortRelevance
+		}
+	}
+	var sortColumn string
+	switch sort {
+	case CharacterSortRelevance:
+		if text == "" {
+			return nil, "", fmt.Errorf("%w: relevance sort needs a query", ErrInvalidCharacterSearch)
+		}
+		sortColumn = "relevance"
+	case CharacterSortPopularity:
+		sortColumn = "popularity"
+	case CharacterSortRecent:
+		sortColumn = "created_at"
+	case CharacterSortRating:
+		sortColumn = "rating"
+	default:
+		return nil, "", fmt.Errorf("%w: unknown sort %q", ErrInvalidCharacterSearch, sort)
+	}
+
+	query := s.db.Model(&models.Character{}).
+		Joins(characterPopularityJoin).
+		Joins(characterRatingJoin, models.FeedbackUp, models.FeedbackDown)
+	if text != "" {
+		query = query.Select(characterListingColumns+", "+characterRelevanceColumn, text, text, text).
+			Where(characterMatch, text, text, text)
+	} else {
+		query = query.Select(characterListingColumns)
+	}
+
+	if params.Chatted {
+		// Characters the viewer already talks to stay reachable when unlisted
+		query = query.Scopes(visibleCharacters(viewer)).
+			Where("characters.id IN (SELECT character_id FROM conversations WHERE user_id = ?)", viewer.UserID)
+	} else {
+		query = query.Scopes(listedCharacters(viewer))
+	}
+	if params.Category != "" {
+		query = query.Where("characters.category = ?", params.Category)
+	}
+	if len(params.Traits) > 0 {
+		query = query.Scopes(withTraits(params.Traits))
+	}
+	if params.VoiceGender != "" {
+		query = query.Where("characters.voice_gender = ?", params.VoiceGender)
+	}
+	switch params.Type {
+	case "":
+	case CharacterTypeCustom:
+		query = query.Where("(characters.owner_id IS NOT NULL OR characters.is_custom)")
+	case CharacterTypeSystem:
+		query = query.Where("characters.owner_id IS NULL AND NOT characters.is_custom")
+	default:
+		return nil, "", fmt.Errorf("%w: type must be custom or system", ErrInvalidCharacterSearch)
+	}
+
+	// Sort columns are computed, so the keyset applies to the wrapped query
+	page := s.db.Table("(?) AS listing", query)
+	if cursor != nil {
+		if sort == CharacterSortRecent {
+			page = page.Where("(listing.created_at, listing.id) < (?, ?)", cursor.Time, cursor.ID)
+		} else {
+			page = page.Where(fmt.Sprintf("(listing.%s, listing.id) < (?, ?)", sortColumn), cursor.Score, cursor.ID)
+		}
+	}
+
+	var listings []CharacterListing
+	if err := page.Order(fmt.Sprintf("listing.%s DESC, listing.id DESC", sortColumn)).Limit(limit + 1).Scan(&listings).Error; err != nil {
+		return nil, "", fmt.Errorf("error searching characters: %w", err)
+	}
+	if listings == nil {
+		listings = []CharacterListing{}
+	}
+	for i := range listings {
+		listings[i].IsCustom = isCustomCharacter(&listings[i].Character)
+	}
+
+	nextCursor := ""
+	if len(listings) > limit {
+		listings = listings[:limit]
+		nextCursor = listingCursor(listings[limit-1], sort).Encode()
+	}
+	return listings, nextCursor, nil
+}
+
+// listingCursor returns the keyset position of a listing in the given sort
+func listingCursor(l CharacterListing, sort string) pagination.Cursor {
+	switch sort {
+	case CharacterSortRecent:
+		return pagination.Cursor{Time: l.CreatedAt, ID: l.ID}
+	case CharacterSortRelevance:
+		return pagination.Cursor{Score: l.Relevance, ID: l.ID}
+	case CharacterSortRating:
+		return pagination.Cursor{Score: l.Rating, ID: l.ID}
+	default:
+		return pagination.Cursor{Score: float64(l.Popularity), ID: l.ID}
+	}
+}
+
+// withTraits limits a query to characters that have every trait. The traits
+// are bound as one array literal: a slice bound to a placeholder is expanded
+// into a parenthesized list, which Postgres reads as a record.
+func withTraits(traits []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("characters.traits @> ?::text[]", textArray(traits))
+	}
+}
+
+// textArray encodes values as a Postgres text[] literal
+func textArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		v = strings.ReplaceAll(v, `\`, `\\`)
+		v = strings.ReplaceAll(v, `"`, `\"`)
+		quoted[i] = `"` + v + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}"
+}
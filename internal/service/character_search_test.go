@@ -0,0 +1,26 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+)
+
+func TestWithTraitsBindsOneArray(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, err)
+
+	var characters []models.Character
+	stmt := db.Model(&models.Character{}).Scopes(withTraits([]string{"brave", `say "hi"`, `back\slash`})).Find(&characters).Statement
+
+	assert.Contains(t, stmt.SQL.String(), "characters.traits @> $1::text[]")
+	assert.Equal(t, []interface{}{`{"brave","say \"hi\"","back\\slash"}`}, stmt.Vars)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+)
+
+// ErrCharacterVersionNotFound is returned for unknown character versions
+var ErrCharacterVersionNotFound = errors.New("character version not found")
+
+// ListVersions returns a character's versions, newest first
+func (s *CharacterService) ListVersions(id uint, editor CharacterEditor) ([]models.CharacterVersion, error) {
+	if _, err := s.editableCharacter(s.db, id, editor); err != nil {
+		return nil, err
+	}
+
+	var versions []models.CharacterVersion
+	if err := s.db.Where("character_id = ?", id).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving character versions: %w", err)
+	}
+	if versions == nil {
+		versions = []models.CharacterVersion{}
+	}
+	return versions, nil
+}
+
+// GetVersion returns one version of a character
+func (s *CharacterService) GetVersion(id uint, version int, editor CharacterEditor) (*models.CharacterVersion, error) {
+	if _, err := s.editableCharacter(s.db, id, editor); err != nil {
+		return nil, err
+	}
+	return findCharacterVersion(s.db, id, version)
+}
+
+// RollbackCharacter restores the fields of an earlier version. History is
+// kept: the restored fields are written as a new version.
+func (s *CharacterService) RollbackCharacter(id uint, version int, editor CharacterEditor) (*models.Character, error) {
+	return s.editCharacter(id, editor, func(*models.Character) (models.CharacterFields, string, *int, error) {
+		target, err := findCharacterVersion(s.db, id, version)
+		if err != nil {
+			return models.CharacterFields{}, "", nil, err
+		}
+		return target.CharacterFields, models.CharacterChangeRollback, &target.Version, nil
+	})
+}
+
+// BackfillCharacterVersions records the current state of characters created
+// before versioning as their current version
+func (s *CharacterService) BackfillCharacterVersions() (int, error) {
+	var characters []models.Character
+	if err := s.db.Unscoped().
+		Where("NOT EXISTS (SELECT 1 FROM character_versions v WHERE v.character_id = characters.id)").
+		Find(&characters).Error; err != nil {
+		return 0, fmt.Errorf("error finding unversioned characters: %w", err)
+	}
+
+	created := 0
+	for i := range characters {
+		if characters[i].Version < 1 {
+			characters[i].Version = 1
+		}
+		if err := createCharacterVersion(s.db, &characters[i], models.CharacterChangeBackfill, nil, nil); err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
+
+// createCharacterVersion snapshots a character as its current version
+func createCharacterVersion(tx *gorm.DB, character *models.Character, change string, restoredFrom *int, editedBy *uint) error {
+	version := &models.CharacterVersion{
+		CharacterID:     character.ID,
+		Version:         character.Version,
+		CharacterFields: character.Fields(),
+		Change:          change,
+		RestoredFrom:    restoredFrom,
+		EditedBy:        editedBy,
+		CreatedAt:       time.Now(),
+	}
+	if err := tx.Create(version).Error; err != nil {
+		return fmt.Errorf("failed to record character version: %w", err)
+	}
+	return nil
+}
+
+// findCharacterVersion looks up one version of a character
+func findCharacterVersion(db *gorm.DB, characterID uint, version int) (*models.CharacterVersion, error) {
+	var v models.CharacterVersion
+	if err := db.Where("character_id = ? AND version = ?", characterID, version).First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCharacterVersionNotFound
+		}
+		return nil, fmt.Errorf("error retrieving character version: %w", err)
+	}
+	return &v, nil
+}
+
+// currentCharacterVersion returns the version a new conversation with the
+// character starts on. Characters hidden from the viewer are not found.
+func currentCharacterVersion(db *gorm.DB, characterID uint, viewer CharacterEditor) (int, error) {
+	var versions []int
+	if err := db.Model(&models.Character{}).Scopes(visibleCharacters(viewer)).Where("id = ?", characterID).Limit(1).Pluck("version", &versions).Error; err != nil {
+		return 0, fmt.Errorf("error checking character: %w", err)
+	}
+	if len(versions) == 0 {
+		return 0, ErrCharacterNotFound
+	}
+	return versions[0], nil
+}
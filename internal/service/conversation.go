@@ -0,0 +1,542 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/pagination"
+)
+
+var (
+	// ErrConversationNotFound is returned for unknown conversations
+	ErrConversationNotFound = errors.New("conversation not found")
+
+	// ErrConversationForbidden is returned when a conversation belongs to another user
+	ErrConversationForbidden = errors.New("conversation belongs to another user")
+)
+
+// ListConversationsParams filters and pages a user's conversations
+type ListConversationsParams struct {
+	CharacterID uint
+	Status      string // "active", "archived" or "all"; defaults to active
+	Cursor      string
+	Limit       int
+}
+
+// ConversationService manages conversation ownership and lifecycle
+type ConversationService struct {
+	db         *gorm.DB
+	characters *CharacterService
+}
+
+// NewConversationService creates a new conversation service
+func NewConversationService(db *gorm.DB) *ConversationService {
+	return &ConversationService{
+		db: db,
+	}
+}
+
+// SetCharacterService sets the service that creates characters for imports
+func (s *ConversationService) SetCharacterService(characters *CharacterService) {
+	s.characters = characters
+}
+
+// EnsureConversation returns the conversation for a session, creating it on
+// first use. A nil userID creates an anonymous conversation. Connecting to a
+// conversation owned by someone else returns ErrConversationForbidden, and so
+// does a user connecting to an anonymous conversation. A session that already
+// has messages but no conversation is refused to everyone, anonymous callers
+// included; it must be claimed first. The character must be the
+// conversation's own or part of its scene.
+func (s *ConversationService) EnsureConversation(sessionID string, characterID uint, userID *uint) (*models.Conversation, error) {
+	if sessionID == "" {
+		return nil, errors.New("session ID is required")
+	}
+
+	conversation, err := s.GetBySessionID(sessionID)
+	if err == nil {
+		if userID != nil && !conversation.OwnedBy(*userID) {
+			return nil, ErrConversationForbidden
+		}
+		if userID == nil && conversation.UserID != nil {
+			return nil, ErrConversationForbidden
+		}
+		if err := s.checkConversationCharacter(conversation, characterID); err != nil {
+			return nil, err
+		}
+		return conversation, nil
+	}
+	if !errors.Is(err, ErrConversationNotFound) {
+		return nil, err
+	}
+
+	var messageCount int64
+	if err := s.db.Model(&models.Message{}).Where("session_id = ?", sessionID).Limit(1).Count(&messageCount).Error; err != nil {
+		return nil, fmt.Errorf("error checking session messages: %w", err)
+	}
+	if messageCount > 0 {
+		return nil, ErrConversationForbidden
+	}
+
+	// Only characters the user can see may be started with
+	var viewer CharacterEditor
+	if userID != nil {
+		viewer.UserID = *userID
+	}
+	characterVersion, err := currentCharacterVersion(s.db, characterID, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	conversation = &models.Conversation{
+		SessionID:        sessionID,
+		UserID:           userID,
+		CharacterID:      characterID,
+		CharacterVersion: characterVersion,
+		Status:           models.ConversationStatusActive,
+		LastActiveAt:     now,
+	}
+
+	// Two connections racing on the same new session both land on one row
+	if err := s.db.Where("session_id = ?", sessionID).FirstOrCreate(conversation).Error; err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+	if userID != nil && !conversation.OwnedBy(*userID) {
+		return nil, ErrConversationForbidden
+	}
+	if userID == nil && conversation.UserID != nil {
+		return nil, ErrConversationForbidden
+	}
+	if err := s.checkConversationCharacter(conversation, characterID); err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// checkConversationCharacter rejects a character that is neither the
+// conversation's own nor part of its scene
+func (s *ConversationService) checkConversationCharacter(conversation *models.Conversation, characterID uint) error {
+	if characterID == conversation.CharacterID {
+		return nil
+	}
+	var count int64
+	if err := s.db.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND character_id = ?", conversation.ID, characterID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("error checking conversation cast: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: character is not part of the conversation", ErrConversationForbidden)
+	}
+	return nil
+}
+
+// CreateConversation starts a new conversation owned by the user
+func (s *ConversationService) CreateConversation(userID uint, characterID uint, title string) (*models.Conversation, error) {
+	characterVersion, err := currentCharacterVersion(s.db, characterID, CharacterEditor{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	conversation := &models.Conversation{
+		SessionID:        fmt.Sprintf("conv-%s", uuid.New().String()),
+		UserID:           &userID,
+		CharacterID:      characterID,
+		CharacterVersion: characterVersion,
+		Title:            title,
+		TitleOverridden:  title != "",
+		Status:           models.ConversationStatusActive,
+		LastActiveAt:     time.Now(),
+	}
+
+	if err := s.db.Create(conversation).Error; err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+
+	return conversation, nil
+}
+
+// GetBySessionID looks up a conversation by its session ID
+func (s *ConversationService) GetBySessionID(sessionID string) (*models.Conversation, error) {
+	var conversation models.Conversation
+	if err := s.db.Where("session_id = ?", sessionID).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, fmt.Errorf("error retrieving conversation: %w", err)
+	}
+	return &conversation, nil
+}
+
+// GetConversation returns a conversation the user owns
+func (s *ConversationService) GetConversation(id uint, userID uint) (*models.Conversation, error) {
+	var conversation models.Conversation
+	if err := s.db.Scopes(withParticipants).Where("id = ? AND user_id = ?", id, userID).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, fmt.Errorf("error retrieving conversation: %w", err)
+	}
+	return &conversation, nil
+}
+
+// CheckSessionAccess verifies that the user may read a session. Anonymous
+// sessions and sessions with no conversation row are refused until the user
+// claims them.
+func (s *ConversationService) CheckSessionAccess(sessionID string, userID uint) error {
+	conversation, err := s.GetBySessionID(sessionID)
+	if errors.Is(err, ErrConversationNotFound) {
+		return ErrConversationForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if !conversation.OwnedBy(userID) {
+		return ErrConversationForbidden
+	}
+	return nil
+}
+
+// ClaimSession gives the user an anonymous session, or a session whose
+// messages have no conversation row. Sessions owned by someone else return
+// ErrConversationForbidden; claiming one's own session is a no-op.
+func (s *ConversationService) ClaimSession(sessionID string, userID uint) (*models.Conversation, error) {
+	conversation, err := s.GetBySessionID(sessionID)
+	if errors.Is(err, ErrConversationNotFound) {
+		var first models.Message
+		if err := s.db.Where("session_id = ?", sessionID).Order("id").First(&first).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrConversationNotFound
+			}
+			return nil, fmt.Errorf("error retrieving session messages: %w", err)
+		}
+
+		conversation = &models.Conversation{
+			SessionID:    sessionID,
+			UserID:       &userID,
+			CharacterID:  first.CharacterID,
+			Status:       models.ConversationStatusActive,
+			LastActiveAt: time.Now(),
+		}
+		if err := s.db.Where("session_id = ?", sessionID).FirstOrCreate(conversation).Error; err != nil {
+			return nil, fmt.Errorf("failed to create conversation: %w", err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if conversation.UserID == nil {
+		// Only one of two racing claims takes the session
+		result := s.db.Model(&models.Conversation{}).
+			Where("id = ? AND user_id IS NULL", conversation.ID).
+			Update("user_id", userID)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to claim conversation: %w", result.Error)
+		}
+		if conversation, err = s.GetBySessionID(sessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	if !conversation.OwnedBy(userID) {
+		return nil, ErrConversationForbidden
+	}
+	return conversation, nil
+}
+
+// ListConversations returns the user's conversations, most recently active
+// first, and a cursor for the next page ("" on the last page)
+func (s *ConversationService) ListConversations(userID uint, params ListConversationsParams) ([]models.Conversation, string, error) {
+	cursor, err := pagination.Decode(params.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	limit := pagination.ClampLimit(params.Limit)
+
+	query := s.db.Model(&models.Conversation{}).Where("user_id = ?", userID)
+
+	switch params.Status {
+	case "", models.ConversationStatusActive:
+		query = query.Where("status = ?", models.ConversationStatusActive)
+	case models.ConversationStatusArchived:
+		query = query.Where("status = ?", models.ConversationStatusArchived)
+	case "all":
+	default:
+		return nil, "", fmt.Errorf("invalid status filter: %s", params.Status)
+	}
+
+	if params.CharacterID != 0 {
+		query = query.Where("character_id = ? OR id IN (SELECT conversation_id FROM conversation_participants WHERE character_id = ?)",
+			params.CharacterID, params.CharacterID)
+	}
+	if cursor != nil {
+		query = query.Where("(last_active_at, id) < (?, ?)", cursor.Time, cursor.ID)
+	}
+
+	var conversations []models.Conversation
+	if err := query.Scopes(withParticipants).Order("last_active_at DESC, id DESC").Limit(limit + 1).Find(&conversations).Error; err != nil {
+		return nil, "", fmt.Errorf("error listing conversations: %w", err)
+	}
+
+	nextCursor := ""
+	if len(conversations) > limit {
+		conversations = conversations[:limit]
+		last := conversations[limit-1]
+		nextCursor = pagination.Cursor{Time: last.LastActiveAt, ID: last.ID}.Encode()
+	}
+
+	return conversations, nextCursor, nil
+}
+
+// ConversationUpdate holds user edits to a conversation. Nil fields are left
+// alone; an empty string hands the field back to automatic generation.
+type ConversationUpdate struct {
+	Title          *string
+	Summary        *string
+	SpeakerRouting *string // Scenes only
+}
+
+// UpdateConversation applies a user's title and summary overrides and a
+// scene's speaker routing
+func (s *ConversationService) UpdateConversation(id uint, userID uint, update ConversationUpdate) (*models.Conversation, error) {
+	conversation, err := s.GetConversation(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	regenerate := false
+	if update.Title != nil {
+		updates["title"] = *update.Title
+		updates["title_overridden"] = *update.Title != ""
+		regenerate = regenerate || *update.Title == ""
+	}
+	if update.Summary != nil {
+		updates["summary"] = *update.Summary
+		updates["summary_overridden"] = *update.Summary != ""
+		regenerate = regenerate || *update.Summary == ""
+	}
+	if update.SpeakerRouting != nil {
+		if err := checkSpeakerRouting(conversation, *update.SpeakerRouting); err != nil {
+			return nil, err
+		}
+		updates["speaker_routing"] = *update.SpeakerRouting
+	}
+	if len(updates) == 0 {
+		return conversation, nil
+	}
+	if regenerate {
+		// Picked up again by the next summary pass
+		updates["summarized_count"] = 0
+	}
+
+	if err := s.db.Model(conversation).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update conversation: %w", err)
+	}
+
+	return conversation, nil
+}
+
+// ResumeSummary returns the conversation's summary when the session has been
+// idle for at least gap, so a returning user's character can pick up the
+// thread. It returns an empty string otherwise.
+func (s *ConversationService) ResumeSummary(sessionID string, gap time.Duration) (string, error) {
+	conversation, err := s.GetBySessionID(sessionID)
+	if err != nil {
+		if errors.Is(err, ErrConversationNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if conversation.Summary == "" || time.Since(conversation.LastActiveAt) < gap {
+		return "", nil
+	}
+	return conversation.Summary, nil
+}
+
+// SetStatus archives or restores a conversation
+func (s *ConversationService) SetStatus(id uint, userID uint, status string) (*models.Conversation, error) {
+	if status != models.ConversationStatusActive && status != models.ConversationStatusArchived {
+		return nil, fmt.Errorf("invalid conversation status: %s", status)
+	}
+
+	conversation, err := s.GetConversation(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(conversation).Update("status", status).Error; err != nil {
+		return nil, fmt.Errorf("failed to update conversation status: %w", err)
+	}
+
+	return conversation, nil
+}
+
+// DeleteConversation removes a conversation with its messages, audio and share links
+func (s *ConversationService) DeleteConversation(id uint, userID uint) error {
+	conversation, err := s.GetConversation(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Rows written before the foreign key existed are matched by session ID
+		if err := tx.Where("conversation_id = ? OR session_id = ?", conversation.ID, conversation.SessionID).
+			Delete(&models.AudioChunk{}).Error; err != nil {
+			return fmt.Errorf("failed to delete conversation audio: %w", err)
+		}
+		if err := tx.Where("conversation_id = ? OR session_id = ?", conversation.ID, conversation.SessionID).
+			Delete(&models.Message{}).Error; err != nil {
+			return fmt.Errorf("failed to delete conversation messages: %w", err)
+		}
+		if err := tx.Where("conversation_id = ?", conversation.ID).Delete(&models.ConversationShare{}).Error; err != nil {
+			return fmt.Errorf("failed to delete conversation share links: %w", err)
+		}
+		if err := tx.Where("conversation_id = ?", conversation.ID).Delete(&models.ConversationParticipant{}).Error; err != nil {
+			return fmt.Errorf("failed to delete conversation participants: %w", err)
+		}
+		if err := tx.Delete(conversation).Error; err != nil {
+			return fmt.Errorf("failed to delete conversation: %w", err)
+		}
+		return nil
+	})
+}
+
+// BackfillConversations creates conversations for sessions that have messages
+// but no conversation row, and links their messages and audio. Owners are taken
+// from the session's uploaded audio when available.
+func (s *ConversationService) BackfillConversations() (int, error) {
+	var sessions []struct {
+		SessionID    string
+		CharacterID  uint
+		FirstAt      time.Time
+		LastAt       time.Time
+		MessageCount int
+	}
+	if err := s.db.Model(&models.Message{}).
+		Select("session_id, MIN(character_id) AS character_id, MIN(timestamp) AS first_at, MAX(timestamp) AS last_at, COUNT(*) AS message_count").
+		Where("conversation_id IS NULL AND session_id <> ''").
+		Group("session_id").
+		Scan(&sessions).Error; err != nil {
+		return 0, fmt.Errorf("error finding unlinked sessions: %w", err)
+	}
+
+	created := 0
+	for _, session := range sessions {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			conversation := models.Conversation{
+				SessionID:    session.SessionID,
+				CharacterID:  session.CharacterID,
+				Status:       models.ConversationStatusActive,
+				MessageCount: session.MessageCount,
+				LastActiveAt: session.LastAt,
+				CreatedAt:    session.FirstAt,
+			}
+
+			var owner string
+			tx.Model(&models.AudioChunk{}).
+				Where("session_id = ? AND user_id <> ''", session.SessionID).
+				Limit(1).
+				Pluck("user_id", &owner)
+			if ownerID, err := strconv.ParseUint(owner, 10, 64); err == nil {
+				id := uint(ownerID)
+				conversation.UserID = &id
+			}
+
+			if err := tx.Where("session_id = ?", session.SessionID).FirstOrCreate(&conversation).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Message{}).Where("session_id = ?", session.SessionID).
+				Update("conversation_id", conversation.ID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.AudioChunk{}).Where("session_id = ?", session.SessionID).
+				Update("conversation_id", conversation.ID).Error
+		})
+		if err != nil {
+			log.Printf("Error backfilling conversation for session %s: %v", session.SessionID, err)
+			continue
+		}
+		created++
+	}
+
+	if err := s.backfillMessageTree(); err != nil {
+		return created, err
+	}
+
+	return created, nil
+}
+
+// backfillMessageTree links messages of conversations that have no active
+// leaf yet into a single branch ordered by timestamp, and points the leaf at
+// the newest message
+func (s *ConversationService) backfillMessageTree() error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE messages m SET parent_id = p.prev_id
+			FROM (
+				SELECT id, LAG(id) OVER (PARTITION BY conversation_id ORDER BY timestamp, id) AS prev_id
+				FROM messages
+				WHERE conversation_id IN (SELECT id FROM conversations WHERE active_leaf_id IS NULL)
+			) p
+			WHERE m.id = p.id AND m.parent_id IS NULL AND p.prev_id IS NOT NULL`).Error; err != nil {
+			return fmt.Errorf("error linking message history: %w", err)
+		}
+		if err := tx.Exec(`UPDATE conversations c SET active_leaf_id = (
+				SELECT id FROM messages WHERE conversation_id = c.id ORDER BY timestamp DESC, id DESC LIMIT 1
+			) WHERE active_leaf_id IS NULL`).Error; err != nil {
+			return fmt.Errorf("error setting active branch: %w", err)
+		}
+		return nil
+	})
+}
+
+// conversationIDForSession returns the conversation ID for a session, or nil
+// when the session has no conversation row
+func conversationIDForSession(db *gorm.DB, sessionID string) *uint {
+	var ids []uint
+	if err := db.Model(&models.Conversation{}).Where("session_id = ?", sessionID).Limit(1).Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return nil
+	}
+	return &ids[0]
+}
+
+// ownedMessage looks up a message by external ID among the conversations the
+// user owns. External IDs are chosen by clients and not unique, so a message
+// in another user's session is never a match; it only turns a miss into
+// ErrConversationForbidden.
+func ownedMessage(db *gorm.DB, externalID string, userID uint) (*models.Message, *models.Conversation, error) {
+	var message models.Message
+	err := db.Joins("JOIN conversations ON conversations.session_id = messages.session_id").
+		Where("messages.external_id = ? AND conversations.user_id = ?", externalID, userID).
+		Order("messages.id DESC").
+		First(&message).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var count int64
+		if err := db.Model(&models.Message{}).Where("external_id = ?", externalID).Count(&count).Error; err != nil {
+			return nil, nil, fmt.Errorf("error retrieving message: %w", err)
+		}
+		if count > 0 {
+			return nil, nil, ErrConversationForbidden
+		}
+		return nil, nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving message: %w", err)
+	}
+
+	var conversation models.Conversation
+	if err := db.Where("session_id = ?", message.SessionID).First(&conversation).Error; err != nil {
+		return nil, nil, fmt.Errorf("error retrieving conversation: %w", err)
+	}
+	return &message, &conversation, nil
+}
@@ -0,0 +1,641 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/scene"
+)
+
+// ConversationExportVersion is the version of the JSON export format written
+// by ExportConversation. Imports of other versions are rejected.
+const ConversationExportVersion = 1
+
+var (
+	// ErrUnsupportedExportVersion is returned when importing an unknown format version
+	ErrUnsupportedExportVersion = errors.New("unsupported conversation export version")
+
+	// ErrInvalidExport is returned when an import document is malformed
+	ErrInvalidExport = errors.New("invalid conversation export")
+)
+
+// ConversationExport is the portable form of a conversation
+type ConversationExport struct {
+	Version      int                  `json:"version"`
+	ExportedAt   time.Time            `json:"exported_at"`
+	Conversation ExportedConversation `json:"conversation"`
+	Character    ExportedCharacter    `json:"character"`
+	Participants []ExportedCharacter  `json:"participants,omitempty"` // Scene cast in speaking order
+	Messages     []ExportedMessage    `json:"messages"`               // Every branch, parents before children
+}
+
+// ExportedConversation holds the conversation's own fields
+type ExportedConversation struct {
+	Title        string    `json:"title"`
+	Summary      string    `json:"summary,omitempty"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+
+	SpeakerRouting string `json:"speaker_routing,omitempty"` // Scenes only
+}
+
+// ExportedCharacter is a snapshot of the character at export time
+type ExportedCharacter struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Personality string `json:"personality"`
+	Background  string `json:"background,omitempty"`
+	Category    string `json:"category,omitempty"`
+	VoiceType   string `json:"voice_type"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+}
+
+// ExportedMessage is one turn of the conversation
+type ExportedMessage struct {
+	ID        string             `json:"id"`
+	ParentID  string             `json:"parent_id,omitempty"` // ID of the previous turn; empty for the first
+	Sender    string             `json:"sender"`              // "user", "character" or "system"
+	Content   string             `json:"content"`
+	Timestamp time.Time          `json:"timestamp"`
+	Active    bool               `json:"active"` // On the branch shown to the user
+	Feedback  []ExportedFeedback `json:"feedback,omitempty"`
+	Audio     []ExportedAudio    `json:"audio,omitempty"`
+
+	// Character replies name their speaker, which tells scene voices apart
+	CharacterID uint   `json:"character_id,omitempty"`
+	Speaker     string `json:"speaker,omitempty"`
+}
+
+// ExportedFeedback is a rating left on a message
+type ExportedFeedback struct {
+	Type      string    `json:"type"` // "up", "down" or "flag"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportedAudio references stored audio for a message. Audio bytes are not
+// exported and references are dropped on import.
+type ExportedAudio struct {
+	ID        string  `json:"id"`
+	Format    string  `json:"format"`
+	Duration  float64 `json:"duration"`
+	Direction string  `json:"direction"`
+	URL       string  `json:"url"`
+}
+
+// ExportConversation builds the portable form of a conversation the user owns
+func (s *ConversationService) ExportConversation(id uint, userID uint) (*ConversationExport, error) {
+	conversation, err := s.GetConversation(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &ConversationExport{
+		Version:    ConversationExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Conversation: ExportedConversation{
+			Title:        conversation.Title,
+			Summary:      conversation.Summary,
+			Status:       conversation.Status,
+			CreatedAt:    conversation.CreatedAt,
+			LastActiveAt: conversation.LastActiveAt,
+
+			SpeakerRouting: conversation.SpeakerRouting,
+		},
+		Character: ExportedCharacter{ID: conversation.CharacterID},
+		Messages:  []ExportedMessage{},
+	}
+
+	var messages []models.Message
+	if err := s.db.Where("conversation_id = ? OR session_id = ?", conversation.ID, conversation.SessionID).
+		Order("timestamp ASC, id ASC").
+		Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving conversation messages: %w", err)
+	}
+
+	characterIDs := []uint{conversation.CharacterID}
+	for _, p := range conversation.Participants {
+		characterIDs = append(characterIDs, p.CharacterID)
+	}
+	for _, m := range messages {
+		if m.Sender == "character" {
+			characterIDs = append(characterIDs, m.CharacterID)
+		}
+	}
+	var characters []models.Character
+	if err := s.db.Where("id IN ?", characterIDs).Find(&characters).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving conversation characters: %w", err)
+	}
+	snapshots := make(map[uint]ExportedCharacter, len(characters))
+	for _, character := range characters {
+		snapshots[character.ID] = exportedCharacter(character)
+	}
+
+	if snapshot, ok := snapshots[conversation.CharacterID]; ok {
+		export.Character = snapshot
+	}
+	if conversation.IsScene() {
+		for _, p := range conversation.Participants {
+			snapshot, ok := snapshots[p.CharacterID]
+			if !ok {
+				snapshot = ExportedCharacter{ID: p.CharacterID}
+			}
+			export.Participants = append(export.Participants, snapshot)
+		}
+	}
+
+	if len(messages) == 0 {
+		return export, nil
+	}
+
+	active := make(map[uint]bool, len(messages))
+	if conversation.ActiveLeafID != nil {
+		for _, m := range branchTo(messages, *conversation.ActiveLeafID) {
+			active[m.ID] = true
+		}
+	} else {
+		for _, m := range messages {
+			active[m.ID] = true
+		}
+	}
+
+	externalIDs := make([]string, len(messages))
+	byID := make(map[uint]string, len(messages))
+	for i, m := range messages {
+		externalIDs[i] = m.ExternalID
+		byID[m.ID] = m.ExternalID
+	}
+
+	// External IDs are chosen by clients and repeat across sessions, so only
+	// the owner's own ratings are exported
+	var feedback []models.MessageFeedback
+	if err := s.db.Where("user_id = ? AND message_id IN ?", userID, externalIDs).Order("created_at ASC").Find(&feedback).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving message feedback: %w", err)
+	}
+	feedbackByMessage := make(map[string][]ExportedFeedback)
+	for _, f := range feedback {
+		feedbackByMessage[f.MessageID] = append(feedbackByMessage[f.MessageID], ExportedFeedback{
+			Type:      f.FeedbackType,
+			CreatedAt: f.CreatedAt,
+		})
+	}
+
+	var chunks []struct {
+		models.AudioChunk
+		LinkedMessageID string
+	}
+	if err := s.db.Model(&models.AudioChunk{}).
+		Select("audio_chunks.id, audio_chunks.format, audio_chunks.duration, audio_chunks.direction, audio_message_links.message_id AS linked_message_id").
+		Joins("JOIN audio_message_links ON audio_message_links.chunk_id = audio_chunks.id").
+		Where("audio_message_links.session_id = ? AND audio_chunks.expires_at > ?", conversation.SessionID, time.Now()).
+		Order("audio_chunks.created_at ASC").
+		Scan(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving conversation audio: %w", err)
+	}
+	audioByMessage := make(map[string][]ExportedAudio)
+	for _, chunk := range chunks {
+		audioByMessage[chunk.LinkedMessageID] = append(audioByMessage[chunk.LinkedMessageID], ExportedAudio{
+			ID:        fmt.Sprintf("%d", chunk.ID),
+			Format:    chunk.Format,
+			Duration:  chunk.Duration,
+			Direction: chunk.Direction,
+			URL:       fmt.Sprintf("/api/v1/audio/%d/raw", chunk.ID),
+		})
+	}
+
+	for _, m := range messages {
+		exported := ExportedMessage{
+			ID:        m.ExternalID,
+			Sender:    m.Sender,
+			Content:   m.Content,
+			Timestamp: m.Timestamp,
+			Active:    active[m.ID],
+			Feedback:  feedbackByMessage[m.ExternalID],
+			Audio:     audioByMessage[m.ExternalID],
+		}
+		if m.Sender == "character" {
+			exported.CharacterID = m.CharacterID
+			exported.Speaker = snapshots[m.CharacterID].Name
+		}
+		if m.ParentID != nil {
+			exported.ParentID = byID[*m.ParentID]
+		}
+		export.Messages = append(export.Messages, exported)
+	}
+
+	return export, nil
+}
+
+// exportedCharacter snapshots a character for an export
+func exportedCharacter(character models.Character) ExportedCharacter {
+	return ExportedCharacter{
+		ID:          character.ID,
+		Name:        character.Name,
+		Description: character.Description,
+		Personality: character.Personality,
+		Background:  character.Background,
+		Category:    character.Category,
+		VoiceType:   character.VoiceType,
+		AvatarURL:   character.AvatarURL,
+	}
+}
+
+// ImportConversation recreates an exported conversation under the user. Each
+// character is matched by ID and name, then by name, and otherwise created
+// from the snapshot. A scene gets its cast back and each reply its speaker.
+// Messages receive new IDs. When no message names a parent the messages are
+// treated as one branch in order.
+func (s *ConversationService) ImportConversation(userID uint, export *ConversationExport) (*models.Conversation, error) {
+	if export.Version != ConversationExportVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedExportVersion, export.Version)
+	}
+	if err := validateExport(export); err != nil {
+		return nil, err
+	}
+
+	linear := true
+	for _, m := range export.Messages {
+		if m.ParentID != "" {
+			linear = false
+			break
+		}
+	}
+
+	var conversation *models.Conversation
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		character, err := s.resolveImportedCharacter(tx, export.Character, userID)
+		if err != nil {
+			return err
+		}
+
+		// Speakers are looked up by their exported IDs
+		speakers := map[uint]*models.Character{export.Character.ID: character}
+		var cast []models.ConversationParticipant
+		for _, snapshot := range export.Participants {
+			speaker, ok := speakers[snapshot.ID]
+			if !ok || speaker.Name != snapshot.Name {
+				if speaker, err = s.resolveImportedCharacter(tx, snapshot, userID); err != nil {
+					return err
+				}
+				speakers[snapshot.ID] = speaker
+			}
+			if !castIncludes(cast, speaker.ID) {
+				cast = append(cast, models.ConversationParticipant{CharacterID: speaker.ID, Position: len(cast), JoinedAt: time.Now()})
+			}
+		}
+		routing := ""
+		if len(cast) >= scene.MinCast {
+			routing = export.Conversation.SpeakerRouting
+			if routing == "" {
+				routing = scene.RoutingRoundRobin
+			}
+		} else {
+			cast = nil
+		}
+
+		status := export.Conversation.Status
+		if status != models.ConversationStatusArchived {
+			status = models.ConversationStatusActive
+		}
+		lastActive := export.Conversation.LastActiveAt
+		if lastActive.IsZero() {
+			lastActive = time.Now()
+		}
+
+		conversation = &models.Conversation{
+			SessionID:        fmt.Sprintf("conv-%s", uuid.New().String()),
+			UserID:           &userID,
+			CharacterID:      character.ID,
+			CharacterVersion: character.Version,
+			Title:            export.Conversation.Title,
+			Summary:          export.Conversation.Summary,
+			Status:           status,
+			MessageCount:     len(export.Messages),
+			LastActiveAt:     lastActive,
+			CreatedAt:        export.Conversation.CreatedAt,
+			SpeakerRouting:   routing,
+			Participants:     cast,
+		}
+		if conversation.Summary != "" {
+			// The imported summary already covers these messages
+			conversation.SummarizedCount = conversation.MessageCount
+		}
+		if err := tx.Create(conversation).Error; err != nil {
+			return fmt.Errorf("failed to create conversation: %w", err)
+		}
+
+		newIDs := make(map[string]uint, len(export.Messages))
+		var previous, leaf *uint
+		for _, m := range export.Messages {
+			prefix := "msg"
+			if m.Sender == "character" {
+				prefix = "resp"
+			}
+			speaker := character
+			if m.Sender == "character" && m.CharacterID != 0 {
+				speaker = speakers[m.CharacterID]
+			}
+			message := models.Message{
+				ExternalID:     fmt.Sprintf("%s-%s", prefix, uuid.New().String()),
+				CharacterID:    speaker.ID,
+				SessionID:      conversation.SessionID,
+				ConversationID: &conversation.ID,
+				Sender:         m.Sender,
+				Content:        m.Content,
+				Timestamp:      m.Timestamp,
+				CreatedAt:      time.Now(),
+			}
+			if linear {
+				message.ParentID = previous
+			} else if m.ParentID != "" {
+				parentID := newIDs[m.ParentID]
+				message.ParentID = &parentID
+			}
+
+			if err := tx.Create(&message).Error; err != nil {
+				return fmt.Errorf("failed to import message %s: %w", m.ID, err)
+			}
+			newIDs[m.ID] = message.ID
+			previous = &message.ID
+			if m.Active || linear {
+				leaf = &message.ID
+			}
+
+			for _, f := range m.Feedback {
+				if err := tx.Create(&models.MessageFeedback{
+					MessageID:    message.ExternalID,
+					UserID:       userID,
+					FeedbackType: f.Type,
+					Timestamp:    f.CreatedAt.UnixMilli(),
+					CreatedAt:    f.CreatedAt,
+				}).Error; err != nil {
+					return fmt.Errorf("failed to import feedback for message %s: %w", m.ID, err)
+				}
+			}
+		}
+
+		if leaf == nil {
+			leaf = previous
+		}
+		if leaf != nil {
+			conversation.ActiveLeafID = leaf
+			if err := tx.Model(conversation).Update("active_leaf_id", *leaf).Error; err != nil {
+				return fmt.Errorf("failed to set active branch: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// castIncludes reports whether a character is already in the cast
+func castIncludes(cast []models.ConversationParticipant, characterID uint) bool {
+	for _, p := range cast {
+		if p.CharacterID == characterID {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExport checks the scene, message IDs, senders and speakers, and
+// that parents precede their children
+func validateExport(export *ConversationExport) error {
+	if routing := export.Conversation.SpeakerRouting; routing != "" && !scene.ValidRouting(routing) {
+		return fmt.Errorf("%w: %v", ErrInvalidExport, scene.ErrInvalidRouting)
+	}
+	speakers := map[uint]bool{export.Character.ID: true}
+	for _, p := range export.Participants {
+		speakers[p.ID] = true
+	}
+
+	seen := make(map[string]bool, len(export.Messages))
+	for i, m := range export.Messages {
+		if m.ID == "" {
+			return fmt.Errorf("%w: message %d has no id", ErrInvalidExport, i)
+		}
+		if seen[m.ID] {
+			return fmt.Errorf("%w: duplicate message id %s", ErrInvalidExport, m.ID)
+		}
+		switch m.Sender {
+		case "user", "character", "system":
+		default:
+			return fmt.Errorf("%w: message %s has invalid sender %q", ErrInvalidExport, m.ID, m.Sender)
+		}
+		if m.Sender == "character" && m.CharacterID != 0 && !speakers[m.CharacterID] {
+			return fmt.Errorf("%w: message %s is spoken by character %d, which is not in the export", ErrInvalidExport, m.ID, m.CharacterID)
+		}
+		if m.ParentID != "" && !seen[m.ParentID] {
+			return fmt.Errorf("%w: message %s appears before its parent %s", ErrInvalidExport, m.ID, m.ParentID)
+		}
+		seen[m.ID] = true
+	}
+	return nil
+}
+
+// resolveImportedCharacter finds a character the user can see that an import
+// refers to, creating a private custom character from the snapshot when none
+// matches. The snapshot's avatar URL is client-supplied and is not kept;
+// avatars only come from the avatar store.
+func (s *ConversationService) resolveImportedCharacter(tx *gorm.DB, snapshot ExportedCharacter, userID uint) (*models.Character, error) {
+	if snapshot.Name == "" {
+		return nil, fmt.Errorf("%w: character name is required", ErrInvalidExport)
+	}
+
+	viewer := CharacterEditor{UserID: userID}
+	var character models.Character
+	if snapshot.ID != 0 {
+		if err := tx.Scopes(visibleCharacters(viewer)).Where("id = ? AND name = ?", snapshot.ID, snapshot.Name).First(&character).Error; err == nil {
+			return &character, nil
+		}
+	}
+	if err := tx.Scopes(visibleCharacters(viewer)).Where("name = ?", snapshot.Name).Order("id ASC").First(&character).Error; err == nil {
+		return &character, nil
+	}
+
+	if s.characters == nil {
+		return nil, errors.New("character service not configured")
+	}
+	character = models.Character{
+		IsCustom:   true,
+		OwnerID:    &userID,
+		Visibility: models.CharacterVisibilityPrivate,
+		Version:    1,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	character.SetFields(models.CharacterFields{
+		Name:        snapshot.Name,
+		Description: snapshot.Description,
+		Personality: snapshot.Personality,
+		Background:  snapshot.Background,
+		Category:    snapshot.Category,
+		VoiceType:   snapshot.VoiceType,
+	})
+	if character.VoiceType == "" {
+		character.VoiceType = "default"
+	}
+	// Categories unknown here are dropped rather than failing the import
+	if character.Category != "" {
+		known, err := categoryExists(tx, character.Category)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			character.Category = ""
+		}
+	}
+	if err := s.characters.createCharacterTx(tx, &character, &viewer); err != nil {
+		if errors.Is(err, ErrInvalidCharacter) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		return nil, err
+	}
+	return &character, nil
+}
+
+// activeMessages returns the messages on the active branch
+func (e *ConversationExport) activeMessages() []ExportedMessage {
+	var messages []ExportedMessage
+	for _, m := range e.Messages {
+		if m.Active {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// exportTitle returns the conversation title, falling back to the character name
+func (e *ConversationExport) exportTitle() string {
+	if e.Conversation.Title != "" {
+		return e.Conversation.Title
+	}
+	return fmt.Sprintf("Conversation with %s", e.Character.Name)
+}
+
+// exportSpeaker returns the display name for a message's sender
+func (e *ConversationExport) exportSpeaker(m ExportedMessage) string {
+	switch m.Sender {
+	case "character":
+		if m.Speaker != "" {
+			return m.Speaker
+		}
+		return e.Character.Name
+	case "system":
+		return "System"
+	default:
+		return "You"
+	}
+}
+
+var exportTemplateFuncs = map[string]interface{}{
+	"time": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+}
+
+var markdownExportTemplate = template.Must(template.New("md").Funcs(exportTemplateFuncs).Parse(
+	`# {{.Title}}
+
+- **Character:** {{.Export.Character.Name}}{{with .Export.Character.Description}} — {{.}}{{end}}
+- **Started:** {{time .Export.Conversation.CreatedAt}}
+{{- with .Export.Conversation.Summary}}
+- **Summary:** {{.}}{{end}}
+- **Exported:** {{time .Export.ExportedAt}}
+
+---
+{{range .Messages}}
+**{{.Speaker}}** · {{time .Timestamp}}
+
+{{.Content}}
+{{end}}`))
+
+var htmlExportTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(exportTemplateFuncs).Parse(
+	`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 720px; margin: 2rem auto; padding: 0 1rem; color: #222; }
+header p { color: #666; margin: 0.2rem 0; }
+.message { margin: 1rem 0; padding: 0.75rem 1rem; border-radius: 8px; }
+.content { white-space: pre-wrap; }
+.user { background: #e8f0fe; }
+.character { background: #f1f3f4; }
+.system { background: #fff8e1; }
+.meta { font-size: 0.8rem; color: #666; margin-bottom: 0.3rem; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p>Character: {{.Export.Character.Name}}{{with .Export.Character.Description}} — {{.}}{{end}}</p>
+<p>Started {{time .Export.Conversation.CreatedAt}} · Exported {{time .Export.ExportedAt}}</p>
+{{with .Export.Conversation.Summary}}<p>{{.}}</p>
+{{end}}</header>
+<main>
+{{range .Messages}}<div class="message {{.Sender}}">
+<div class="meta"><strong>{{.Speaker}}</strong> · {{time .Timestamp}}</div>
+<div class="content">{{.Content}}</div>
+</div>
+{{end}}</main>
+</body>
+</html>
+`))
+
+// renderedMessage is a message prepared for the Markdown and HTML templates
+type renderedMessage struct {
+	ExportedMessage
+	Speaker string
+}
+
+// renderExport runs an export template over the active branch
+func renderExport(export *ConversationExport, execute func(*bytes.Buffer, interface{}) error) ([]byte, error) {
+	active := export.activeMessages()
+	messages := make([]renderedMessage, len(active))
+	for i, m := range active {
+		messages[i] = renderedMessage{ExportedMessage: m, Speaker: export.exportSpeaker(m)}
+	}
+
+	var buf bytes.Buffer
+	err := execute(&buf, map[string]interface{}{
+		"Title":    export.exportTitle(),
+		"Export":   export,
+		"Messages": messages,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FormatConversationMarkdown renders the active branch of an export as Markdown
+func FormatConversationMarkdown(export *ConversationExport) ([]byte, error) {
+	return renderExport(export, func(buf *bytes.Buffer, data interface{}) error {
+		return markdownExportTemplate.Execute(buf, data)
+	})
+}
+
+// FormatConversationHTML renders the active branch of an export as a standalone HTML page
+func FormatConversationHTML(export *ConversationExport) ([]byte, error) {
+	return renderExport(export, func(buf *bytes.Buffer, data interface{}) error {
+		return htmlExportTemplate.Execute(buf, data)
+	})
+}
+
+// ExportFilename returns a download filename for a conversation export
+func ExportFilename(conversationID uint, format string) string {
+	return fmt.Sprintf("conversation-%d.%s", conversationID, format)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/scene"
+)
+
+// ErrInvalidScene is returned for scenes without enough characters, with
+// repeated characters or with an unknown speaker routing
+var ErrInvalidScene = errors.New("invalid scene")
+
+// CreateScene starts a conversation owned by the user in which several
+// characters take part. The cast speaks in the given order under round-robin
+// routing; routing defaults to round-robin.
+func (s *ConversationService) CreateScene(userID uint, characterIDs []uint, routing string, title string) (*models.Conversation, error) {
+	if routing == "" {
+		routing = scene.RoutingRoundRobin
+	}
+	if !scene.ValidRouting(routing) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScene, scene.ErrInvalidRouting)
+	}
+	if len(characterIDs) < scene.MinCast {
+		return nil, fmt.Errorf("%w: a scene needs at least %d characters", ErrInvalidScene, scene.MinCast)
+	}
+
+	viewer := CharacterEditor{UserID: userID}
+	seen := make(map[uint]bool, len(characterIDs))
+	var characterVersion int
+	for i, characterID := range characterIDs {
+		if seen[characterID] {
+			return nil, fmt.Errorf("%w: character %d is in the cast twice", ErrInvalidScene, characterID)
+		}
+		seen[characterID] = true
+
+		version, err := currentCharacterVersion(s.db, characterID, viewer)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			characterVersion = version
+		}
+	}
+
+	now := time.Now()
+	conversation := &models.Conversation{
+		SessionID:        fmt.Sprintf("conv-%s", uuid.New().String()),
+		UserID:           &userID,
+		CharacterID:      characterIDs[0],
+		CharacterVersion: characterVersion,
+		Title:            title,
+		TitleOverridden:  title != "",
+		Status:           models.ConversationStatusActive,
+		LastActiveAt:     now,
+		SpeakerRouting:   routing,
+	}
+	for i, characterID := range characterIDs {
+		conversation.Participants = append(conversation.Participants, models.ConversationParticipant{
+			CharacterID: characterID,
+			Position:    i,
+			JoinedAt:    now,
+		})
+	}
+
+	if err := s.db.Create(conversation).Error; err != nil {
+		return nil, fmt.Errorf("failed to create scene: %w", err)
+	}
+
+	return conversation, nil
+}
+
+// InviteCharacter adds a character to the end of a conversation's cast. A
+// one-on-one conversation becomes a round-robin scene with its character first.
+func (s *ConversationService) InviteCharacter(id uint, userID uint, characterID uint) (*models.Conversation, error) {
+	conversation, err := s.GetConversation(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := currentCharacterVersion(s.db, characterID, CharacterEditor{UserID: userID}); err != nil {
+		return nil, err
+	}
+
+	cast := conversation.Participants
+	if !conversation.IsScene() {
+		cast = []models.ConversationParticipant{{CharacterID: conversation.CharacterID, Position: 0, JoinedAt: conversation.CreatedAt}}
+	}
+	for _, p := range cast {
+		if p.CharacterID == characterID {
+			return nil, fmt.Errorf("%w: character %d is already in the scene", ErrInvalidScene, characterID)
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if !conversation.IsScene() {
+			conversation.SpeakerRouting = scene.RoutingRoundRobin
+			if err := tx.Model(conversation).Update("speaker_routing", conversation.SpeakerRouting).Error; err != nil {
+				return err
+			}
+		}
+
+		invited := models.ConversationParticipant{
+			CharacterID: characterID,
+			Position:    cast[len(cast)-1].Position + 1,
+			JoinedAt:    time.Now(),
+		}
+		cast = append(cast, invited)
+		for i := range cast {
+			if cast[i].ID != 0 {
+				continue
+			}
+			cast[i].ConversationID = conversation.ID
+			if err := tx.Create(&cast[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to invite character: %w", err)
+	}
+
+	conversation.Participants = cast
+	return conversation, nil
+}
+
+// SceneCast returns the speaker routing and the cast, in speaking order, of
+// the scene behind a session. Routing is empty when the session is not a scene.
+func (s *ConversationService) SceneCast(sessionID string) (string, []uint, error) {
+	var conversation models.Conversation
+	err := s.db.Scopes(withParticipants).Where("session_id = ?", sessionID).First(&conversation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("error retrieving conversation: %w", err)
+	}
+	if !conversation.IsScene() {
+		return "", nil, nil
+	}
+
+	cast := make([]uint, len(conversation.Participants))
+	for i, p := range conversation.Participants {
+		cast[i] = p.CharacterID
+	}
+	return conversation.SpeakerRouting, cast, nil
+}
+
+// checkSpeakerRouting validates a routing change for a conversation
+func checkSpeakerRouting(conversation *models.Conversation, routing string) error {
+	if !conversation.IsScene() {
+		return fmt.Errorf("%w: speaker routing applies to scenes only", ErrInvalidScene)
+	}
+	if !scene.ValidRouting(routing) {
+		return fmt.Errorf("%w: %v", ErrInvalidScene, scene.ErrInvalidRouting)
+	}
+	return nil
+}
+
+// withParticipants loads a conversation's cast in speaking order
+func withParticipants(db *gorm.DB) *gorm.DB {
+	return db.Preload("Participants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	})
+}
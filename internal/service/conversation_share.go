@@ -0,0 +1,319 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+)
+
+var (
+	// ErrShareNotFound is returned for unknown, revoked or expired share links,
+	// and for links whose conversation or character is no longer available
+	ErrShareNotFound = errors.New("share link not found")
+
+	// ErrNothingToShare is returned when a conversation has no messages yet
+	ErrNothingToShare = errors.New("conversation has no messages to share")
+)
+
+// shareTokenBytes is the amount of randomness in a share token
+const shareTokenBytes = 24
+
+// CreateShareParams configures a new share link
+type CreateShareParams struct {
+	ExpiresIn    time.Duration // Zero for a link that never expires
+	IncludeAudio bool
+}
+
+// SharedConversation is the public view of a share link. It leaves out
+// session IDs, owners and anything else that identifies the account.
+type SharedConversation struct {
+	Title     string          `json:"title"`
+	Character SharedCharacter `json:"character"`
+	SharedAt  time.Time       `json:"shared_at"`
+	Messages  []SharedMessage `json:"messages"`
+}
+
+// SharedCharacter is the character shown on a shared conversation
+type SharedCharacter struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+}
+
+// SharedMessage is one message of a shared conversation. Character replies
+// name their speaker, which tells scene voices apart.
+type SharedMessage struct {
+	ID          string    `json:"id"`
+	Sender      string    `json:"sender"`
+	CharacterID uint      `json:"character_id,omitempty"`
+	Speaker     string    `json:"speaker,omitempty"`
+	Content     string    `json:"content"`
+	Timestamp   time.Time `json:"timestamp"`
+	AudioURL    string    `json:"audio_url,omitempty"`
+}
+
+// ShareService manages public share links for conversations
+type ShareService struct {
+	db             *gorm.DB
+	messageService *MessageService
+}
+
+// NewShareService creates a new share service
+func NewShareService(db *gorm.DB, messageService *MessageService) *ShareService {
+	return &ShareService{
+		db:             db,
+		messageService: messageService,
+	}
+}
+
+// CreateShare snapshots the conversation's active branch behind a new random token
+func (s *ShareService) CreateShare(conversationID uint, userID uint, params CreateShareParams) (*models.ConversationShare, error) {
+	conversation, err := s.ownedConversation(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.ActiveLeafID == nil {
+		return nil, ErrNothingToShare
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	share := &models.ConversationShare{
+		Token:          token,
+		ConversationID: conversation.ID,
+		UserID:         userID,
+		LeafID:         *conversation.ActiveLeafID,
+		Title:          conversation.Title,
+		IncludeAudio:   params.IncludeAudio,
+	}
+	if params.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(params.ExpiresIn)
+		share.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Create(share).Error; err != nil {
+		return nil, fmt.Errorf("failed to create share link: %w", err)
+	}
+	return share, nil
+}
+
+// ListShares returns the share links of a conversation the user owns, newest first
+func (s *ShareService) ListShares(conversationID uint, userID uint) ([]models.ConversationShare, error) {
+	if _, err := s.ownedConversation(conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	var shares []models.ConversationShare
+	if err := s.db.Where("conversation_id = ?", conversationID).Order("created_at DESC").Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving share links: %w", err)
+	}
+	return shares, nil
+}
+
+// RevokeShare disables a share link. Revoking twice is not an error.
+func (s *ShareService) RevokeShare(conversationID uint, shareID uint, userID uint) error {
+	if _, err := s.ownedConversation(conversationID, userID); err != nil {
+		return err
+	}
+
+	result := s.db.Model(&models.ConversationShare{}).
+		Where("id = ? AND conversation_id = ?", shareID, conversationID).
+		Update("revoked_at", gorm.Expr("COALESCE(revoked_at, ?)", time.Now()))
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke share link: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// GetSharedConversation returns the messages behind a token and counts the
+// visit. The link fixes which messages are shown, not their content: they are
+// read live, so later edits and redactions show through it.
+func (s *ShareService) GetSharedConversation(token string) (*SharedConversation, error) {
+	share, conversation, character, err := s.resolveShare(token)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.messageService.sessionMessages(conversation.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := branchTo(messages, share.LeafID)
+
+	var audioIDs map[string]bool
+	if share.IncludeAudio {
+		if audioIDs, err = s.messageService.GetAudioMessageIDs(conversation.SessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	speakers, err := s.speakerNames(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &SharedConversation{
+		Title: share.Title,
+		Character: SharedCharacter{
+			Name:        character.Name,
+			Description: character.Description,
+			AvatarURL:   character.AvatarURL,
+		},
+		SharedAt: share.CreatedAt,
+		Messages: make([]SharedMessage, len(snapshot)),
+	}
+	for i, msg := range snapshot {
+		view.Messages[i] = SharedMessage{
+			ID:        msg.ExternalID,
+			Sender:    msg.Sender,
+			Content:   msg.Content,
+			Timestamp: msg.Timestamp,
+		}
+		if msg.Sender == "character" {
+			view.Messages[i].CharacterID = msg.CharacterID
+			view.Messages[i].Speaker = speakers[msg.CharacterID]
+		}
+		if share.IncludeAudio && AudioURLFor(msg, audioIDs) != "" {
+			view.Messages[i].AudioURL = SharedAudioURL(token, msg.ExternalID)
+		}
+	}
+
+	if err := s.db.Model(&models.ConversationShare{}).Where("id = ?", share.ID).Updates(map[string]interface{}{
+		"access_count":     gorm.Expr("access_count + 1"),
+		"last_accessed_at": time.Now(),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to record share access: %w", err)
+	}
+
+	return view, nil
+}
+
+// GetSharedAudioMessage checks that a share link plays audio and that the
+// message is part of its snapshot. It returns the message with the owner's
+// user ID, which audio replay needs to store re-synthesized audio.
+func (s *ShareService) GetSharedAudioMessage(token string, messageID string) (*models.Message, uint, error) {
+	share, conversation, _, err := s.resolveShare(token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !share.IncludeAudio {
+		return nil, 0, ErrMessageNotFound
+	}
+
+	messages, err := s.messageService.sessionMessages(conversation.SessionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, msg := range branchTo(messages, share.LeafID) {
+		if msg.ExternalID == messageID {
+			return &msg, share.UserID, nil
+		}
+	}
+	return nil, 0, ErrMessageNotFound
+}
+
+// resolveShare loads a usable share link with its conversation and character
+func (s *ShareService) resolveShare(token string) (*models.ConversationShare, *models.Conversation, *models.Character, error) {
+	var share models.ConversationShare
+	err := s.db.Where("token = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", token, time.Now()).
+		First(&share).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, ErrShareNotFound
+		}
+		return nil, nil, nil, fmt.Errorf("error retrieving share link: %w", err)
+	}
+
+	var conversation models.Conversation
+	if err := s.db.First(&conversation, share.ConversationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, ErrShareNotFound
+		}
+		return nil, nil, nil, fmt.Errorf("error retrieving conversation: %w", err)
+	}
+
+	// Deleted characters are filtered out by their soft delete
+	var character models.Character
+	if err := s.db.First(&character, conversation.CharacterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, ErrShareNotFound
+		}
+		return nil, nil, nil, fmt.Errorf("error retrieving character: %w", err)
+	}
+	// A character made private, even by the link's owner, stops the link working
+	if !sharable(&character) {
+		return nil, nil, nil, ErrShareNotFound
+	}
+
+	return &share, &conversation, &character, nil
+}
+
+// speakerNames returns the names of the characters that replied in the
+// messages. Private characters are left unnamed.
+func (s *ShareService) speakerNames(messages []models.Message) (map[uint]string, error) {
+	var ids []uint
+	for _, msg := range messages {
+		if msg.Sender == "character" {
+			ids = append(ids, msg.CharacterID)
+		}
+	}
+	names := make(map[uint]string)
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	var characters []models.Character
+	if err := s.db.Where("id IN ?", ids).Find(&characters).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving speakers: %w", err)
+	}
+	for _, character := range characters {
+		if sharable(&character) {
+			names[character.ID] = character.Name
+		}
+	}
+	return names, nil
+}
+
+// sharable reports whether a character may be shown through share links
+func sharable(character *models.Character) bool {
+	return character.IsSystem() || character.Visibility != models.CharacterVisibilityPrivate
+}
+
+// ownedConversation loads a conversation that belongs to the user
+func (s *ShareService) ownedConversation(conversationID uint, userID uint) (*models.Conversation, error) {
+	var conversation models.Conversation
+	if err := s.db.Where("id = ? AND user_id = ?", conversationID, userID).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, fmt.Errorf("error retrieving conversation: %w", err)
+	}
+	return &conversation, nil
+}
+
+// SharedAudioURL returns the public replay URL for a message of a shared conversation
+func SharedAudioURL(token string, messageID string) string {
+	return "/api/v1/shared/" + url.PathEscape(token) + "/audio/" + url.PathEscape(messageID)
+}
+
+// newShareToken returns a random URL-safe token
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+)
+
+// summaryInstructions tells the chat provider what to produce
+const summaryInstructions = `You summarize role-play chats between a user and a character.
+Reply with exactly two parts:
+Title: a title of at most eight words, without quotes
+Summary: one paragraph, written in the past tense, covering what happened, what the user shared and any open threads
+If a previous summary is given, fold it into the new one.`
+
+const (
+	// summaryTranscriptSize is how many recent messages are sent with each request
+	summaryTranscriptSize = 40
+
+	// summaryMessageChars bounds each message in the transcript
+	summaryMessageChars = 500
+
+	// maxGeneratedTitle bounds generated titles
+	maxGeneratedTitle = 80
+)
+
+// SummarizeFunc sends instructions and a transcript to the chat provider and
+// returns its reply
+type SummarizeFunc func(ctx context.Context, instructions string, transcript string) (string, error)
+
+// ConversationSummaryConfig controls when conversations are summarized
+type ConversationSummaryConfig struct {
+	Interval   time.Duration // How often pending conversations are checked; zero disables the job
+	FirstAfter int           // Messages before the first title and summary
+	Every      int           // New messages before the summary is refreshed
+	BatchSize  int           // Conversations summarized per pass
+}
+
+// DefaultConversationSummaryConfig returns the summary job defaults
+func DefaultConversationSummaryConfig() ConversationSummaryConfig {
+	return ConversationSummaryConfig{
+		Interval:   5 * time.Minute,
+		FirstAfter: 6,
+		Every:      20,
+		BatchSize:  20,
+	}
+}
+
+// ConversationSummaryService generates conversation titles and summaries in the background
+type ConversationSummaryService struct {
+	db             *gorm.DB
+	messageService *MessageService
+	summarize      SummarizeFunc
+	config         ConversationSummaryConfig
+}
+
+// NewConversationSummaryService creates the summarizer and starts its periodic job
+func NewConversationSummaryService(db *gorm.DB, messageService *MessageService, summarize SummarizeFunc, config ConversationSummaryConfig) *ConversationSummaryService {
+	service := &ConversationSummaryService{
+		db:             db,
+		messageService: messageService,
+		summarize:      summarize,
+		config:         config,
+	}
+
+	if config.Interval > 0 {
+		go service.startSummaryRoutine()
+	}
+
+	return service
+}
+
+// SummarizePending summarizes conversations that reached their first few
+// turns or have grown since their last summary, and returns how many were updated
+func (s *ConversationSummaryService) SummarizePending() (int, error) {
+	var conversations []models.Conversation
+	err := s.db.Where("NOT (title_overridden AND summary_overridden)").
+		Where("(summarized_count = 0 AND message_count >= ?) OR (summarized_count > 0 AND message_count - summarized_count >= ?)",
+			s.config.FirstAfter, s.config.Every).
+		Order("last_active_at DESC").
+		Limit(s.config.BatchSize).
+		Find(&conversations).Error
+	if err != nil {
+		return 0, fmt.Errorf("error finding conversations to summarize: %w", err)
+	}
+
+	updated := 0
+	for i := range conversations {
+		if err := s.SummarizeConversation(&conversations[i]); err != nil {
+			log.Printf("Error summarizing conversation %d: %v", conversations[i].ID, err)
+			continue
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// SummarizeConversation generates a title and summary from the active branch.
+// Fields the user has overridden are left untouched.
+func (s *ConversationSummaryService) SummarizeConversation(conversation *models.Conversation) error {
+	messages, err := s.messageService.GetSessionMessages(conversation.CharacterID, conversation.SessionID)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	// Scenes have several speakers, so each reply is labelled with its own
+	characterIDs := []uint{conversation.CharacterID}
+	for _, msg := range messages {
+		characterIDs = append(characterIDs, msg.CharacterID)
+	}
+	var characters []models.Character
+	if err := s.db.Select("id", "name").Where("id IN ?", characterIDs).Find(&characters).Error; err != nil {
+		return fmt.Errorf("error retrieving characters: %w", err)
+	}
+	names := make(map[uint]string, len(characters))
+	for _, character := range characters {
+		names[character.ID] = character.Name
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	reply, err := s.summarize(ctx, summaryInstructions, summaryTranscript(conversation.Summary, names, conversation.CharacterID, messages))
+	if err != nil {
+		return fmt.Errorf("error generating summary: %w", err)
+	}
+	title, summary := parseSummaryReply(reply)
+
+	// The override checks run in SQL in case the user edited while the provider was working
+	updates := map[string]interface{}{
+		"summarized_count": conversation.MessageCount,
+		"summarized_at":    time.Now(),
+	}
+	if title != "" {
+		updates["title"] = gorm.Expr("CASE WHEN title_overridden THEN title ELSE ? END", title)
+	}
+	if summary != "" {
+		updates["summary"] = gorm.Expr("CASE WHEN summary_overridden THEN summary ELSE ? END", summary)
+	}
+	if err := s.db.Model(&models.Conversation{}).Where("id = ?", conversation.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to save summary: %w", err)
+	}
+	return nil
+}
+
+// summaryTranscript formats the previous summary and the latest messages for
+// the provider. Replies are labelled with their speaker's name, falling back
+// to the conversation's character.
+func summaryTranscript(previous string, names map[uint]string, characterID uint, messages []models.Message) string {
+	if len(messages) > summaryTranscriptSize {
+		messages = messages[len(messages)-summaryTranscriptSize:]
+	}
+
+	var b strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&b, "Previous summary: %s\n\n", previous)
+	}
+	for _, msg := range messages {
+		speaker := names[msg.CharacterID]
+		if speaker == "" {
+			speaker = names[characterID]
+		}
+		if speaker == "" {
+			speaker = "Character"
+		}
+		if msg.Sender == "user" {
+			speaker = "User"
+		}
+		content := msg.Content
+		if runes := []rune(content); len(runes) > summaryMessageChars {
+			content = string(runes[:summaryMessageChars]) + "…"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", speaker, content)
+	}
+	return b.String()
+}
+
+// summaryReplyPattern matches the labelled parts of a summary reply
+var summaryReplyPattern = regexp.MustCompile(`(?is)^(?:.*?title:(.*?))?summary:(.*)$`)
+
+// parseSummaryReply splits a "Title: ... Summary: ..." reply. Replies without
+// the labels use the first line as the title and the rest as the summary.
+func parseSummaryReply(reply string) (title string, summary string) {
+	reply = strings.TrimSpace(reply)
+	if match := summaryReplyPattern.FindStringSubmatch(reply); match != nil {
+		title, summary = match[1], match[2]
+	} else {
+		title, summary, _ = strings.Cut(reply, "\n")
+	}
+
+	// Strip quotes and markdown emphasis around the parts
+	title = strings.TrimSpace(strings.Trim(strings.TrimSpace(title), `"'*`))
+	summary = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(summary), "*"))
+	if runes := []rune(title); len(runes) > maxGeneratedTitle {
+		title = strings.TrimSpace(string(runes[:maxGeneratedTitle]))
+	}
+	return title, summary
+}
+
+// startSummaryRoutine periodically summarizes pending conversations
+func (s *ConversationSummaryService) startSummaryRoutine() {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := s.SummarizePending()
+		if err != nil {
+			log.Printf("Error summarizing conversations: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("Summarized %d conversations", count)
+		}
+	}
+}
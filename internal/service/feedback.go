@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/prompt"
+	"ai-agent-character-demo/backend/pkg/redact"
+)
+
+// exportBatchSize bounds how many conversations are loaded at once while streaming a dataset
+const exportBatchSize = 50
+
+// Fine-tuning dataset types
+const (
+	DatasetSFT        = "sft"        // One chat example per conversation's active branch
+	DatasetPreference = "preference" // Preferred and rejected replies to the same user turn
+)
+
+// FeedbackCounts tallies ratings
+type FeedbackCounts struct {
+	Up           int64   `json:"up"`
+	Down         int64   `json:"down"`
+	Flag         int64   `json:"flag"`
+	Total        int64   `json:"total"`
+	ApprovalRate float64 `json:"approval_rate"` // up / (up + down), 0 when there are no votes
+}
+
+// CharacterFeedback is the feedback on one character's replies
+type CharacterFeedback struct {
+	CharacterID uint   `json:"character_id"`
+	Name        string `json:"name"`
+	FeedbackCounts
+}
+
+// PromptVersionFeedback is the feedback on replies produced by one prompt version
+type PromptVersionFeedback struct {
+	PromptVersion string `json:"prompt_version"`
+	FeedbackCounts
+}
+
+// DailyFeedback is the feedback given on one day (UTC)
+type DailyFeedback struct {
+	Day string `json:"day"`
+	FeedbackCounts
+}
+
+// FeedbackAnalytics aggregates message feedback
+type FeedbackAnalytics struct {
+	Totals          FeedbackCounts          `json:"totals"`
+	ByCharacter     []CharacterFeedback     `json:"by_character"`
+	ByPromptVersion []PromptVersionFeedback `json:"by_prompt_version"`
+	ByDay           []DailyFeedback         `json:"by_day"`
+}
+
+// FeedbackFilter narrows analytics and dataset exports
+type FeedbackFilter struct {
+	CharacterID uint
+	From        *time.Time
+	To          *time.Time // Exclusive
+}
+
+// FeedbackService reads message feedback for analytics and training data
+type FeedbackService struct {
+	db *gorm.DB
+}
+
+// NewFeedbackService creates a new feedback service
+func NewFeedbackService(db *gorm.DB) *FeedbackService {
+	return &FeedbackService{
+		db: db,
+	}
+}
+
+// feedbackTallies is the SELECT list shared by the analytics queries
+const feedbackTallies = `COALESCE(SUM(CASE WHEN f.feedback_type = 'up' THEN 1 ELSE 0 END), 0) AS up,
+	COALESCE(SUM(CASE WHEN f.feedback_type = 'down' THEN 1 ELSE 0 END), 0) AS down,
+	COALESCE(SUM(CASE WHEN f.feedback_type = 'flag' THEN 1 ELSE 0 END), 0) AS flag,
+	COUNT(*) AS total`
+
+// GetAnalytics aggregates feedback per character, per prompt version and per day
+func (s *FeedbackService) GetAnalytics(filter FeedbackFilter) (*FeedbackAnalytics, error) {
+	analytics := &FeedbackAnalytics{
+		ByCharacter:     []CharacterFeedback{},
+		ByPromptVersion: []PromptVersionFeedback{},
+		ByDay:           []DailyFeedback{},
+	}
+
+	if err := s.feedbackQuery(filter).Select(feedbackTallies).Scan(&analytics.Totals).Error; err != nil {
+		return nil, fmt.Errorf("error aggregating feedback: %w", err)
+	}
+	analytics.Totals.ApprovalRate = approvalRate(analytics.Totals)
+
+	if err := s.feedbackQuery(filter).
+		Select("m.character_id, COALESCE(MAX(c.name), '') AS name, " + feedbackTallies).
+		Joins("LEFT JOIN characters c ON c.id = m.character_id").
+		Group("m.character_id").
+		Order("total DESC").
+		Scan(&analytics.ByCharacter).Error; err != nil {
+		return nil, fmt.Errorf("error aggregating feedback by character: %w", err)
+	}
+	for i := range analytics.ByCharacter {
+		analytics.ByCharacter[i].ApprovalRate = approvalRate(analytics.ByCharacter[i].FeedbackCounts)
+	}
+
+	if err := s.feedbackQuery(filter).
+		Select("m.prompt_version, " + feedbackTallies).
+		Group("m.prompt_version").
+		Order("m.prompt_version").
+		Scan(&analytics.ByPromptVersion).Error; err != nil {
+		return nil, fmt.Errorf("error aggregating feedback by prompt version: %w", err)
+	}
+	for i := range analytics.ByPromptVersion {
+		analytics.ByPromptVersion[i].ApprovalRate = approvalRate(analytics.ByPromptVersion[i].FeedbackCounts)
+	}
+
+	if err := s.feedbackQuery(filter).
+		Select("TO_CHAR(DATE_TRUNC('day', f.created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD') AS day, " + feedbackTallies).
+		Group("day").
+		Order("day").
+		Scan(&analytics.ByDay).Error; err != nil {
+		return nil, fmt.Errorf("error aggregating feedback by day: %w", err)
+	}
+	for i := range analytics.ByDay {
+		analytics.ByDay[i].ApprovalRate = approvalRate(analytics.ByDay[i].FeedbackCounts)
+	}
+
+	return analytics, nil
+}
+
+// feedbackQuery joins feedback to the rated messages and applies the filter
+func (s *FeedbackService) feedbackQuery(filter FeedbackFilter) *gorm.DB {
+	query := s.db.Table("message_feedbacks f").Joins("JOIN messages m ON m.external_id = f.message_id")
+	if filter.CharacterID != 0 {
+		query = query.Where("m.character_id = ?", filter.CharacterID)
+	}
+	if filter.From != nil {
+		query = query.Where("f.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("f.created_at < ?", *filter.To)
+	}
+	return query
+}
+
+// approvalRate returns the share of up votes among up and down votes
+func approvalRate(c FeedbackCounts) float64 {
+	if c.Up+c.Down == 0 {
+		return 0
+	}
+	return float64(c.Up) / float64(c.Up+c.Down)
+}
+
+// chatTurn is a message in the chat fine-tuning format
+type chatTurn struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// sftExample is one supervised fine-tuning line
+type sftExample struct {
+	Messages       []chatTurn `json:"messages"`
+	PromptVersions []string   `json:"prompt_versions,omitempty"` // Prompts that produced the replies
+}
+
+// preferenceExample is one preference-tuning line
+type preferenceExample struct {
+	Input struct {
+		Messages []chatTurn `json:"messages"`
+	} `json:"input"`
+	PreferredOutput    []chatTurn `json:"preferred_output"`
+	NonPreferredOutput []chatTurn `json:"non_preferred_output"`
+	PromptVersions     []string   `json:"prompt_versions,omitempty"` // Prompts that produced both outputs
+}
+
+// messageVotes tallies the feedback on a single message
+type messageVotes struct {
+	up, down, flag int
+}
+
+// score ranks a reply; flagged replies are never preferred
+func (v messageVotes) score() int {
+	return v.up - v.down
+}
+
+// ExportDataset streams conversations as JSONL in chat fine-tuning format and
+// returns the number of lines written. Message content is PII-redacted.
+//
+// DatasetSFT writes each conversation's active branch, cut before the first
+// reply rated down or flagged. DatasetPreference writes one line per user turn
+// with both an upvoted and a downvoted reply among its regenerated alternatives.
+func (s *FeedbackService) ExportDataset(ctx context.Context, w io.Writer, datasetType string, filter FeedbackFilter) (int, error) {
+	if datasetType != DatasetSFT && datasetType != DatasetPreference {
+		return 0, fmt.Errorf("unknown dataset type: %s", datasetType)
+	}
+
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(interface{ Flush() })
+	characters := make(map[uint]string)
+	written := 0
+
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		query := s.db.Where("id > ?", lastID)
+		if filter.CharacterID != 0 {
+			query = query.Where("character_id = ?", filter.CharacterID)
+		}
+		if filter.From != nil {
+			query = query.Where("last_active_at >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			query = query.Where("created_at < ?", *filter.To)
+		}
+
+		var conversations []models.Conversation
+		if err := query.Order("id ASC").Limit(exportBatchSize).Find(&conversations).Error; err != nil {
+			return written, fmt.Errorf("error loading conversations: %w", err)
+		}
+		if len(conversations) == 0 {
+			break
+		}
+
+		for _, conversation := range conversations {
+			lastID = conversation.ID
+
+			system, err := s.systemPrompt(characters, conversation.CharacterID)
+			if err != nil {
+				return written, err
+			}
+
+			messages, votes, err := s.ratedMessages(conversation)
+			if err != nil {
+				return written, err
+			}
+
+			var lines []interface{}
+			if datasetType == DatasetSFT {
+				if example, ok := sftFromConversation(conversation, messages, votes, system); ok {
+					lines = append(lines, example)
+				}
+			} else {
+				for _, example := range preferencesFromConversation(messages, votes, system) {
+					lines = append(lines, example)
+				}
+			}
+
+			for _, line := range lines {
+				if err := encoder.Encode(line); err != nil {
+					return written, err
+				}
+				written++
+			}
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	return written, nil
+}
+
+// systemPrompt builds the system turn for a character, caching by ID
+func (s *FeedbackService) systemPrompt(cache map[uint]string, characterID uint) (string, error) {
+	if system, ok := cache[characterID]; ok {
+		return system, nil
+	}
+
+	var character models.Character
+	if err := s.db.First(&character, characterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			cache[characterID] = ""
+			return "", nil
+		}
+		return "", fmt.Errorf("error loading character %d: %w", characterID, err)
+	}
+
+	// This is the built-in persona prompt. Replies rendered from a prompt
+	// template saw a different one, so examples carry the versions of their
+	// replies' prompts.
+	system := prompt.SystemPrompt(WebSocketCharacter(&character))
+	cache[characterID] = system
+	return system, nil
+}
+
+// ratedMessages loads a conversation's messages, oldest first, with their feedback tallies
+func (s *FeedbackService) ratedMessages(conversation models.Conversation) ([]models.Message, map[string]messageVotes, error) {
+	var messages []models.Message
+	if err := s.db.Where("conversation_id = ?", conversation.ID).
+		Order("timestamp ASC, id ASC").
+		Find(&messages).Error; err != nil {
+		return nil, nil, fmt.Errorf("error loading messages for conversation %d: %w", conversation.ID, err)
+	}
+	if len(messages) == 0 {
+		return nil, nil, nil
+	}
+
+	ids := make([]string, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ExternalID
+	}
+
+	var feedback []models.MessageFeedback
+	if err := s.db.Select("message_id", "feedback_type").Where("message_id IN ?", ids).Find(&feedback).Error; err != nil {
+		return nil, nil, fmt.Errorf("error loading feedback for conversation %d: %w", conversation.ID, err)
+	}
+
+	votes := make(map[string]messageVotes)
+	for _, f := range feedback {
+		v := votes[f.MessageID]
+		switch f.FeedbackType {
+		case models.FeedbackUp:
+			v.up++
+		case models.FeedbackDown:
+			v.down++
+		case models.FeedbackFlag:
+			v.flag++
+		}
+		votes[f.MessageID] = v
+	}
+
+	return messages, votes, nil
+}
+
+// sftFromConversation turns the active branch into a training example
+func sftFromConversation(conversation models.Conversation, messages []models.Message, votes map[string]messageVotes, system string) (sftExample, bool) {
+	path := messages
+	if conversation.ActiveLeafID != nil {
+		path = branchTo(messages, *conversation.ActiveLeafID)
+	}
+
+	// Stop before the first reply users rejected
+	for i, m := range path {
+		v := votes[m.ExternalID]
+		if m.Sender == "character" && (v.flag > 0 || v.down > v.up) {
+			path = path[:i]
+			break
+		}
+	}
+
+	// Examples must end on a character reply
+	for len(path) > 0 && path[len(path)-1].Sender != "character" {
+		path = path[:len(path)-1]
+	}
+	if len(path) == 0 {
+		return sftExample{}, false
+	}
+
+	return sftExample{Messages: chatTurns(system, path), PromptVersions: promptVersions(path)}, true
+}
+
+// preferencesFromConversation pairs the best and worst rated alternatives
+// given to the same user turn
+func preferencesFromConversation(messages []models.Message, votes map[string]messageVotes, system string) []preferenceExample {
+	replies := make(map[uint][]models.Message)
+	for _, m := range messages {
+		if m.Sender == "character" && m.ParentID != nil {
+			replies[*m.ParentID] = append(replies[*m.ParentID], m)
+		}
+	}
+
+	var examples []preferenceExample
+	for _, m := range messages {
+		alternatives := replies[m.ID]
+		if len(alternatives) < 2 {
+			continue
+		}
+
+		var preferred, rejected *models.Message
+		for i := range alternatives {
+			alt := &alternatives[i]
+			v := votes[alt.ExternalID]
+			if v.up > 0 && v.flag == 0 && (preferred == nil || v.score() > votes[preferred.ExternalID].score()) {
+				preferred = alt
+			}
+			if v.down > 0 && (rejected == nil || v.score() < votes[rejected.ExternalID].score()) {
+				rejected = alt
+			}
+		}
+		if preferred == nil || rejected == nil || preferred.ID == rejected.ID {
+			continue
+		}
+
+		var example preferenceExample
+		example.Input.Messages = chatTurns(system, branchTo(messages, m.ID))
+		example.PreferredOutput = []chatTurn{{Role: "assistant", Content: redact.String(preferred.Content)}}
+		example.NonPreferredOutput = []chatTurn{{Role: "assistant", Content: redact.String(rejected.Content)}}
+		example.PromptVersions = promptVersions([]models.Message{*preferred, *rejected})
+		examples = append(examples, example)
+	}
+
+	return examples
+}
+
+// promptVersions lists the distinct prompt versions recorded on character
+// replies, in order of first use
+func promptVersions(messages []models.Message) []string {
+	var versions []string
+	seen := make(map[string]bool)
+	for _, m := range messages {
+		if m.Sender != "character" || m.PromptVersion == "" || seen[m.PromptVersion] {
+			continue
+		}
+		seen[m.PromptVersion] = true
+		versions = append(versions, m.PromptVersion)
+	}
+	return versions
+}
+
+// chatTurns converts messages to redacted chat turns behind an optional system prompt
+func chatTurns(system string, messages []models.Message) []chatTurn {
+	turns := make([]chatTurn, 0, len(messages)+1)
+	if system != "" {
+		turns = append(turns, chatTurn{Role: "system", Content: system})
+	}
+	for _, m := range messages {
+		role := "user"
+		switch m.Sender {
+		case "character":
+			role = "assistant"
+		case "system":
+			role = "system"
+		}
+		turns = append(turns, chatTurn{Role: role, Content: redact.String(m.Content)})
+	}
+	return turns
+}
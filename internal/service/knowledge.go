@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/knowledge"
+	ws "ai-agent-character-demo/backend/pkg/ws"
+)
+
+var (
+	// ErrKnowledgeDocumentNotFound is returned for unknown documents and
+	// documents of another character
+	ErrKnowledgeDocumentNotFound = errors.New("knowledge document not found")
+
+	// ErrInvalidKnowledgeDocument is returned for uploads that cannot be
+	// turned into passages
+	ErrInvalidKnowledgeDocument = errors.New("invalid knowledge document")
+
+	// ErrKnowledgeLimitReached is returned when a character already has the
+	// maximum number of documents
+	ErrKnowledgeLimitReached = errors.New("knowledge document limit reached")
+)
+
+const (
+	// maxKnowledgeTitle bounds document titles
+	maxKnowledgeTitle = 200
+
+	// knowledgeSnippetChars bounds the passage text kept in citations
+	knowledgeSnippetChars = 240
+)
+
+// knowledgeInstructions introduces retrieved passages to the model
+const knowledgeInstructions = "Reference material about you and your world follows. " +
+	"Use it when it is relevant to the conversation, stay in character and do not mention that you were given it."
+
+// KnowledgeConfig controls how documents are chunked and retrieved
+type KnowledgeConfig struct {
+	ChunkSize    int     // Target passage length in characters
+	ChunkOverlap int     // Characters a passage repeats from the previous one
+	TopK         int     // Passages added to each reply
+	MinScore     float64 // Passages less similar to the user turn are left out
+	MaxDocuments int     // Documents per character; 0 means unlimited
+	MaxChars     int     // Extracted characters per document; 0 means unlimited
+}
+
+// DefaultKnowledgeConfig returns the knowledge base defaults
+func DefaultKnowledgeConfig() KnowledgeConfig {
+	return KnowledgeConfig{
+		ChunkSize:    knowledge.DefaultChunkSize,
+		ChunkOverlap: knowledge.DefaultChunkOverlap,
+		TopK:         4,
+		MinScore:     0.15,
+		MaxDocuments: 50,
+		MaxChars:     1 << 20,
+	}
+}
+
+// KnowledgeMatch is a passage retrieved for a query
+type KnowledgeMatch struct {
+	ChunkID    uint    `json:"chunk_id"`
+	DocumentID uint    `json:"document_id"`
+	Title      string  `json:"title"`
+	Position   int     `json:"position"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score"`
+}
+
+// KnowledgeService stores the documents attached to characters and retrieves
+// the passages relevant to a user turn
+type KnowledgeService struct {
+	db         *gorm.DB
+	characters *CharacterService
+	embedder   knowledge.Embedder
+	config     KnowledgeConfig
+	pgvector   bool // Chunks are mirrored into a pgvector column and ranked in the database
+}
+
+// NewKnowledgeService creates a knowledge service that embeds passages with
+// the given embedder
+func NewKnowledgeService(db *gorm.DB, characters *CharacterService, embedder knowledge.Embedder, config KnowledgeConfig) *KnowledgeService {
+	return &KnowledgeService{
+		db:         db,
+		characters: characters,
+		embedder:   embedder,
+		config:     config,
+	}
+}
+
+// SetPGVector ranks passages with the pgvector extension. Without it all of
+// a character's passages are loaded and ranked in memory.
+func (s *KnowledgeService) SetPGVector(enabled bool) {
+	s.pgvector = enabled
+}
+
+// AddDocument extracts the text of an uploaded document, splits it into
+// passages and stores their embeddings. Only the character's owner and
+// admins may add documents.
+func (s *KnowledgeService) AddDocument(ctx context.Context, characterID uint, editor CharacterEditor, title, filename, contentType string, data []byte) (*models.KnowledgeDocument, error) {
+	if _, err := s.characters.editableCharacter(s.db, characterID, editor); err != nil {
+		return nil, err
+	}
+
+	text, format, err := knowledge.ExtractText(filename, contentType, data, s.config.MaxChars)
+	if errors.Is(err, knowledge.ErrTooMuchText) {
+		return nil, fmt.Errorf("%w: document has more than %d characters of text", ErrInvalidKnowledgeDocument, s.config.MaxChars)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKnowledgeDocument, err)
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = strings.TrimSpace(filename)
+	}
+	if title == "" {
+		return nil, fmt.Errorf("%w: title is required", ErrInvalidKnowledgeDocument)
+	}
+	if utf8.RuneCountInString(title) > maxKnowledgeTitle {
+		return nil, fmt.Errorf("%w: title must be at most %d characters", ErrInvalidKnowledgeDocument, maxKnowledgeTitle)
+	}
+
+	passages := knowledge.Chunk(text, s.config.ChunkSize, s.config.ChunkOverlap)
+	if len(passages) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKnowledgeDocument, knowledge.ErrNoText)
+	}
+	embeddings, err := s.embedder.Embed(ctx, passages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed document: %w", err)
+	}
+
+	document := &models.KnowledgeDocument{
+		CharacterID: characterID,
+		Title:       title,
+		Filename:    filename,
+		Format:      format,
+		Content:     text,
+		Size:        utf8.RuneCountInString(text),
+		ChunkCount:  len(passages),
+		Embedder:    s.embedder.Name(),
+	}
+	if editor.UserID != 0 {
+		uploadedBy := editor.UserID
+		document.UploadedBy = &uploadedBy
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if s.config.MaxDocuments > 0 {
+			var count int64
+			if err := tx.Model(&models.KnowledgeDocument{}).Where("character_id = ?", characterID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= int64(s.config.MaxDocuments) {
+				return fmt.Errorf("%w: a character may have at most %d documents", ErrKnowledgeLimitReached, s.config.MaxDocuments)
+			}
+		}
+
+		if err := tx.Create(document).Error; err != nil {
+			return err
+		}
+		chunks := make([]models.KnowledgeChunk, len(passages))
+		for i, passage := range passages {
+			chunks[i] = models.KnowledgeChunk{
+				DocumentID:  document.ID,
+				CharacterID: characterID,
+				Embedder:    document.Embedder,
+				Position:    i,
+				Content:     passage,
+				Embedding:   embeddings[i],
+			}
+		}
+		if err := tx.CreateInBatches(chunks, 100).Error; err != nil {
+			return err
+		}
+		if s.pgvector {
+			return tx.Exec("UPDATE knowledge_chunks SET embedding_vec = embedding::vector WHERE document_id = ?", document.ID).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// ListDocuments returns the documents attached to a character, newest first
+func (s *KnowledgeService) ListDocuments(characterID uint, editor CharacterEditor) ([]models.KnowledgeDocument, error) {
+	if _, err := s.characters.editableCharacter(s.db, characterID, editor); err != nil {
+		return nil, err
+	}
+
+	var documents []models.KnowledgeDocument
+	err := s.db.Omit("content").
+		Where("character_id = ?", characterID).
+		Order("created_at DESC, id DESC").
+		Find(&documents).Error
+	if err != nil {
+		return nil, fmt.Errorf("error listing knowledge documents: %w", err)
+	}
+	return documents, nil
+}
+
+// DeleteDocument removes a document and its passages
+func (s *KnowledgeService) DeleteDocument(characterID, documentID uint, editor CharacterEditor) error {
+	if _, err := s.characters.editableCharacter(s.db, characterID, editor); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", documentID).Delete(&models.KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND character_id = ?", documentID, characterID).Delete(&models.KnowledgeDocument{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrKnowledgeDocumentNotFound
+		}
+		return nil
+	})
+}
+
+// SearchDocuments returns the passages of a character closest to a query,
+// for authors checking what retrieval will find
+func (s *KnowledgeService) SearchDocuments(ctx context.Context, characterID uint, editor CharacterEditor, query string, limit int) ([]KnowledgeMatch, error) {
+	if _, err := s.characters.editableCharacter(s.db, characterID, editor); err != nil {
+		return nil, err
+	}
+	return s.Search(ctx, characterID, query, limit)
+}
+
+// Search returns up to limit passages of a character ranked by similarity
+// to the query. Passages embedded by a different embedder are skipped.
+func (s *KnowledgeService) Search(ctx context.Context, characterID uint, query string, limit int) ([]KnowledgeMatch, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || limit <= 0 {
+		return nil, nil
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	vector := knowledge.Vector(vectors[0])
+
+	var matches []KnowledgeMatch
+	if s.pgvector {
+		matches, err = s.searchPGVector(characterID, vector, limit)
+		if err != nil {
+			log.Printf("pgvector search failed for character %d, ranking in memory: %v", characterID, err)
+		}
+	}
+	if !s.pgvector || err != nil {
+		if matches, err = s.searchInMemory(characterID, vector, limit); err != nil {
+			return nil, err
+		}
+	}
+	return s.withTitles(matches)
+}
+
+// searchPGVector ranks passages by cosine distance in the database
+func (s *KnowledgeService) searchPGVector(characterID uint, vector knowledge.Vector, limit int) ([]KnowledgeMatch, error) {
+	var matches []KnowledgeMatch
+	err := s.db.Raw(`SELECT id AS chunk_id, document_id, position, content, 1 - (embedding_vec <=> ?::vector) AS score
+		FROM knowledge_chunks
+		WHERE character_id = ? AND embedder = ? AND embedding_vec IS NOT NULL
+		ORDER BY embedding_vec <=> ?::vector
+		LIMIT ?`, vector.Literal(), characterID, s.embedder.Name(), vector.Literal(), limit).
+		Scan(&matches).Error
+	return matches, err
+}
+
+// searchInMemory loads every passage of the character and ranks them here
+func (s *KnowledgeService) searchInMemory(characterID uint, vector knowledge.Vector, limit int) ([]KnowledgeMatch, error) {
+	var chunks []models.KnowledgeChunk
+	err := s.db.Select("id", "document_id", "position", "content", "embedding").
+		Where("character_id = ? AND embedder = ?", characterID, s.embedder.Name()).
+		Find(&chunks).Error
+	if err != nil {
+		return nil, fmt.Errorf("error loading knowledge passages: %w", err)
+	}
+
+	candidates := make([][]float32, len(chunks))
+	for i, chunk := range chunks {
+		candidates[i] = chunk.Embedding
+	}
+	ranked := knowledge.TopK(vector, candidates, limit)
+
+	matches := make([]KnowledgeMatch, len(ranked))
+	for i, match := range ranked {
+		chunk := chunks[match.Index]
+		matches[i] = KnowledgeMatch{
+			ChunkID:    chunk.ID,
+			DocumentID: chunk.DocumentID,
+			Position:   chunk.Position,
+			Content:    chunk.Content,
+			Score:      match.Score,
+		}
+	}
+	return matches, nil
+}
+
+// withTitles fills in the title of each match's document
+func (s *KnowledgeService) withTitles(matches []KnowledgeMatch) ([]KnowledgeMatch, error) {
+	if len(matches) == 0 {
+		return matches, nil
+	}
+	ids := make([]uint, len(matches))
+	for i, match := range matches {
+		ids[i] = match.DocumentID
+	}
+
+	var documents []models.KnowledgeDocument
+	if err := s.db.Select("id", "title").Where("id IN ?", ids).Find(&documents).Error; err != nil {
+		return nil, fmt.Errorf("error loading knowledge documents: %w", err)
+	}
+	titles := make(map[uint]string, len(documents))
+	for _, document := range documents {
+		titles[document.ID] = document.Title
+	}
+	for i := range matches {
+		matches[i].Title = titles[matches[i].DocumentID]
+	}
+	return matches, nil
+}
+
+// Augment retrieves the passages relevant to a user turn and prepends them
+// to the history as a system message. It returns the history unchanged when
+// nothing relevant is found; retrieval errors are logged rather than failing
+// the reply.
+func (s *KnowledgeService) Augment(ctx context.Context, characterID uint, query string, history []ws.ChatMessage) ([]ws.ChatMessage, []ws.Citation) {
+	matches, err := s.Search(ctx, characterID, query, s.config.TopK)
+	if err != nil {
+		log.Printf("Error retrieving knowledge for character %d: %v", characterID, err)
+		return history, nil
+	}
+
+	var passages strings.Builder
+	passages.WriteString(knowledgeInstructions)
+	var citations []ws.Citation
+	for _, match := range matches {
+		if match.Score < s.config.MinScore {
+			continue
+		}
+		citation := ws.Citation{
+			Index:      len(citations) + 1,
+			DocumentID: match.DocumentID,
+			Title:      match.Title,
+			ChunkID:    match.ChunkID,
+			Snippet:    snippet(match.Content, knowledgeSnippetChars),
+			Score:      match.Score,
+		}
+		citations = append(citations, citation)
+		fmt.Fprintf(&passages, "\n\n[%d] %s\n%s", citation.Index, match.Title, match.Content)
+	}
+	if len(citations) == 0 {
+		return history, nil
+	}
+
+	augmented := make([]ws.ChatMessage, 0, len(history)+1)
+	augmented = append(augmented, ws.ChatMessage{
+		Sender:  ws.SenderSystem,
+		Content: passages.String(),
+	})
+	return append(augmented, history...), citations
+}
+
+// snippet shortens text to at most n characters, cutting at a word boundary
+func snippet(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	cut := string([]rune(text)[:n])
+	if i := strings.LastIndexByte(cut, ' '); i > n/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
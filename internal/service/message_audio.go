@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+)
+
+// ErrMessageNotFound is returned when a message lookup by external ID fails
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrAudioNotReplayable is returned for messages whose voice cannot be recreated
+var ErrAudioNotReplayable = errors.New("message audio has expired and cannot be re-synthesized")
+
+// MessageAudioService serves the stored voice for a message and re-synthesizes
+// character replies whose audio has expired
+type MessageAudioService struct {
+	db               *gorm.DB
+	audioService     *AudioService
+	characterService *CharacterService
+	textToSpeech     func(ctx context.Context, text string, voiceType string) ([]byte, error)
+	group            singleflight.Group
+}
+
+// NewMessageAudioService creates a new message audio service
+func NewMessageAudioService(
+	db *gorm.DB,
+	audioService *AudioService,
+	characterService *CharacterService,
+	textToSpeech func(ctx context.Context, text string, voiceType string) ([]byte, error),
+) *MessageAudioService {
+	return &MessageAudioService{
+		db:               db,
+		audioService:     audioService,
+		characterService: characterService,
+		textToSpeech:     textToSpeech,
+	}
+}
+
+// GetOrSynthesize returns the audio linked to a message in one of the user's
+// conversations. When a character reply's audio has expired it is synthesized
+// again and stored, so repeated requests are served from the audio store
+// rather than the TTS provider.
+func (s *MessageAudioService) GetOrSynthesize(ctx context.Context, messageID string, userID uint) (*models.AudioChunk, error) {
+	message, _, err := ownedMessage(s.db, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Replay(ctx, message, userID)
+}
+
+// Replay returns the audio of a message the caller has already authorized,
+// synthesizing it again for ownerID, the conversation's owner, if it expired
+func (s *MessageAudioService) Replay(ctx context.Context, message *models.Message, ownerID uint) (*models.AudioChunk, error) {
+	chunk, err := s.audioService.GetMessageAudio(message.SessionID, message.ExternalID)
+	if err == nil {
+		return chunk, nil
+	}
+	if !errors.Is(err, ErrAudioNotFound) {
+		return nil, err
+	}
+	if message.Sender != "character" || message.Content == "" {
+		return nil, ErrAudioNotReplayable
+	}
+
+	// Concurrent replays of the same message share one synthesis call
+	result, err, _ := s.group.Do(fmt.Sprintf("%d", message.ID), func() (interface{}, error) {
+		if chunk, err := s.audioService.GetMessageAudio(message.SessionID, message.ExternalID); err == nil {
+			return chunk, nil
+		}
+		return s.synthesize(ctx, message, fmt.Sprintf("%d", ownerID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.AudioChunk), nil
+}
+
+// OpenAudio reads the bytes of a chunk returned by Replay
+func (s *MessageAudioService) OpenAudio(ctx context.Context, chunk *models.AudioChunk) (io.ReadSeekCloser, error) {
+	return s.audioService.OpenAudio(ctx, chunk)
+}
+
+// synthesize generates and stores speech for a character message
+func (s *MessageAudioService) synthesize(ctx context.Context, message *models.Message, userID string) (*models.AudioChunk, error) {
+	if s.textToSpeech == nil {
+		return nil, ErrAudioNotReplayable
+	}
+
+	voiceType := "default"
+	if character, err := s.characterService.GetCharacter(message.CharacterID); err == nil && character.VoiceType != "" {
+		voiceType = character.VoiceType
+	}
+
+	ttsCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	audioData, err := s.textToSpeech(ttsCtx, message.Content, voiceType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-synthesize message audio: %w", err)
+	}
+	if len(audioData) == 0 {
+		return nil, ErrAudioNotReplayable
+	}
+
+	chunkID, err := s.audioService.StoreCharacterAudio(
+		userID,
+		message.SessionID,
+		message.CharacterID,
+		message.ExternalID,
+		audioData,
+		"mp3",
+		voiceType,
+		s.audioService.GetConfig().DefaultTTL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Re-synthesized audio %s for message %s", chunkID, message.ExternalID)
+	return s.audioService.GetAudioChunk(chunkID)
+}
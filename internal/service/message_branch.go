@@ -0,0 +1,298 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"ai-agent-character-demo/backend/internal/models"
+	ws "ai-agent-character-demo/backend/pkg/ws"
+)
+
+var (
+	// ErrMessageNotEditable is returned when editing anything but a user message
+	ErrMessageNotEditable = errors.New("only user messages can be edited")
+
+	// ErrMessageNotRegenerable is returned when regenerating anything but a character reply
+	ErrMessageNotRegenerable = errors.New("only character replies can be regenerated")
+)
+
+// GetOwnedMessage returns a message by its external ID from a conversation the
+// user owns. A message of anyone else's session, of an anonymous session or of
+// a session with no conversation returns ErrConversationForbidden.
+func (s *MessageService) GetOwnedMessage(externalID string, userID uint) (*models.Message, error) {
+	message, _, err := ownedMessage(s.db, externalID, userID)
+	return message, err
+}
+
+// GetHistory returns the branch that ends at the given message, oldest first
+func (s *MessageService) GetHistory(message *models.Message) ([]models.Message, error) {
+	messages, err := s.sessionMessages(message.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return branchTo(messages, message.ID), nil
+}
+
+// EditMessage stores new content for a user message as a sibling branch and
+// makes it the active leaf. The original message and its replies are kept.
+func (s *MessageService) EditMessage(original *models.Message, content string) (*models.Message, error) {
+	if original.Sender != "user" {
+		return nil, ErrMessageNotEditable
+	}
+
+	edited := &models.Message{
+		ExternalID:  fmt.Sprintf("msg-%d", time.Now().UnixNano()),
+		CharacterID: original.CharacterID,
+		SessionID:   original.SessionID,
+		ParentID:    original.ParentID,
+		Sender:      "user",
+		Content:     content,
+		Timestamp:   time.Now(),
+	}
+
+	if err := s.saveBranch(edited); err != nil {
+		return nil, err
+	}
+	return edited, nil
+}
+
+// GetRegenerationParent returns the user message a character reply answered.
+// A regenerated reply is stored as another child of it.
+func (s *MessageService) GetRegenerationParent(reply *models.Message) (*models.Message, error) {
+	if reply.Sender != "character" || reply.ParentID == nil {
+		return nil, ErrMessageNotRegenerable
+	}
+
+	var parent models.Message
+	if err := s.db.Where("session_id = ?", reply.SessionID).First(&parent, *reply.ParentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("error retrieving parent message: %w", err)
+	}
+	return &parent, nil
+}
+
+// GetReplySpeaker returns the character that first answered a user message,
+// or the message's own character when it has no reply. In a scene this is
+// who answers an edit of the message.
+func (s *MessageService) GetReplySpeaker(message *models.Message) (uint, error) {
+	var speakers []uint
+	if err := s.db.Model(&models.Message{}).
+		Where("parent_id = ? AND sender = ?", message.ID, "character").
+		Order("id ASC").Limit(1).
+		Pluck("character_id", &speakers).Error; err != nil {
+		return 0, fmt.Errorf("error retrieving reply: %w", err)
+	}
+	if len(speakers) == 0 || speakers[0] == 0 {
+		return message.CharacterID, nil
+	}
+	return speakers[0], nil
+}
+
+// SaveReply stores a character's reply as a child of parent and makes it the
+// active leaf. An empty promptVersion records the default version.
+func (s *MessageService) SaveReply(parent *models.Message, characterID uint, content string, promptVersion string, citations []ws.Citation) (*models.Message, error) {
+	if promptVersion == "" {
+		promptVersion = s.promptVersion
+	}
+	encoded, err := encodeCitations(citations)
+	if err != nil {
+		return nil, err
+	}
+	reply := &models.Message{
+		ExternalID:    fmt.Sprintf("resp-%d", time.Now().UnixNano()),
+		CharacterID:   characterID,
+		SessionID:     parent.SessionID,
+		ParentID:      &parent.ID,
+		Sender:        "character",
+		Content:       content,
+		Timestamp:     time.Now(),
+		PromptVersion: promptVersion,
+		Citations:     encoded,
+	}
+
+	if err := s.saveBranch(reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// GetAlternates returns a message and its siblings, oldest first, with the
+// index of the sibling on the active branch (-1 if none is)
+func (s *MessageService) GetAlternates(message *models.Message) ([]models.Message, int, error) {
+	query := s.db.Where("session_id = ? AND sender = ?", message.SessionID, message.Sender)
+	if message.ParentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *message.ParentID)
+	}
+
+	var siblings []models.Message
+	if err := query.Order("timestamp ASC, id ASC").Find(&siblings).Error; err != nil {
+		return nil, -1, fmt.Errorf("error retrieving alternates: %w", err)
+	}
+
+	active, err := s.sessionMessages(message.SessionID)
+	if err != nil {
+		return nil, -1, err
+	}
+	onBranch := make(map[uint]bool)
+	for _, m := range s.activeBranch(message.SessionID, active) {
+		onBranch[m.ID] = true
+	}
+
+	activeIndex := -1
+	for i, sibling := range siblings {
+		if onBranch[sibling.ID] {
+			activeIndex = i
+			break
+		}
+	}
+	return siblings, activeIndex, nil
+}
+
+// ActivateMessage switches the session to the branch containing the message,
+// following the most recent reply at each turn below it, and returns the new
+// active branch
+func (s *MessageService) ActivateMessage(message *models.Message) ([]models.Message, error) {
+	messages, err := s.sessionMessages(message.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Messages are ordered oldest first, so the last child seen is the latest
+	latestChild := make(map[uint]uint)
+	for _, m := range messages {
+		if m.ParentID != nil {
+			latestChild[*m.ParentID] = m.ID
+		}
+	}
+	leafID := message.ID
+	for {
+		child, ok := latestChild[leafID]
+		if !ok {
+			break
+		}
+		leafID = child
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		conversation, err := lockConversation(tx, message.SessionID)
+		if err != nil {
+			return err
+		}
+		if conversation == nil {
+			return ErrConversationNotFound
+		}
+		return tx.Model(conversation).Update("active_leaf_id", leafID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return branchTo(messages, leafID), nil
+}
+
+// saveBranch stores a message whose parent is already set and moves the
+// conversation's active leaf to it
+func (s *MessageService) saveBranch(message *models.Message) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		conversation, err := lockConversation(tx, message.SessionID)
+		if err != nil {
+			return err
+		}
+		if conversation == nil {
+			return ErrConversationNotFound
+		}
+		return appendMessage(tx, conversation, message)
+	})
+}
+
+// sessionMessages loads every message of a session across all branches, oldest first
+func (s *MessageService) sessionMessages(sessionID string) ([]models.Message, error) {
+	var messages []models.Message
+	if err := s.db.Where("session_id = ?", sessionID).Order("timestamp ASC, id ASC").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving session messages: %w", err)
+	}
+	return messages, nil
+}
+
+// activeBranch narrows a session's messages to its active branch. Sessions
+// without a conversation predate branching and are returned unchanged.
+func (s *MessageService) activeBranch(sessionID string, messages []models.Message) []models.Message {
+	var leafIDs []*uint
+	s.db.Model(&models.Conversation{}).Where("session_id = ?", sessionID).Limit(1).Pluck("active_leaf_id", &leafIDs)
+	if len(leafIDs) == 0 || leafIDs[0] == nil {
+		return messages
+	}
+	return branchTo(messages, *leafIDs[0])
+}
+
+// branchTo follows parent links from leafID back to the root and returns the
+// path oldest first. Links to messages outside the slice end the path.
+func branchTo(messages []models.Message, leafID uint) []models.Message {
+	byID := make(map[uint]int, len(messages))
+	for i, m := range messages {
+		byID[m.ID] = i
+	}
+
+	var path []models.Message
+	next, ok := byID[leafID]
+	for ok && len(path) <= len(messages) {
+		m := messages[next]
+		path = append(path, m)
+		if m.ParentID == nil {
+			break
+		}
+		next, ok = byID[*m.ParentID]
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// lockConversation loads a session's conversation for update, or nil when the session has none
+func lockConversation(tx *gorm.DB, sessionID string) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("session_id = ?", sessionID).
+		First(&conversation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving conversation: %w", err)
+	}
+	return &conversation, nil
+}
+
+// appendMessage creates a message and, when it belongs to a conversation,
+// makes it the active leaf and records the activity
+func appendMessage(tx *gorm.DB, conversation *models.Conversation, message *models.Message) error {
+	message.CreatedAt = time.Now()
+	if conversation != nil {
+		message.ConversationID = &conversation.ID
+	}
+
+	if err := tx.Create(message).Error; err != nil {
+		return err
+	}
+
+	if conversation == nil {
+		return nil
+	}
+	return tx.Model(&models.Conversation{}).
+		Where("id = ?", conversation.ID).
+		Updates(map[string]interface{}{
+			"active_leaf_id": message.ID,
+			"message_count":  gorm.Expr("message_count + 1"),
+			"last_active_at": time.Now(),
+		}).Error
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/pagination"
+)
+
+// ErrCursorNotOnBranch is returned when an after cursor names a message that
+// is no longer on the active branch, for example after switching alternates
+var ErrCursorNotOnBranch = errors.New("cursor is not on the active branch")
+
+// MessagePage is a window of a session's history, oldest first
+type MessagePage struct {
+	Messages   []models.Message
+	PrevCursor string // Pass as before to load older messages
+	NextCursor string // Pass as after to load newer messages
+	HasOlder   bool
+	HasNewer   bool
+}
+
+// GetSessionMessagesPage returns a window of the session's active branch.
+// Sessions with a conversation page along parent links so only the requested
+// window is read; older sessions page on (timestamp, id).
+func (s *MessageService) GetSessionMessagesPage(characterID uint, sessionID string, page pagination.Page) (*MessagePage, error) {
+	var leafIDs []*uint
+	if err := s.db.Model(&models.Conversation{}).Where("session_id = ?", sessionID).Limit(1).Pluck("active_leaf_id", &leafIDs).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving conversation: %w", err)
+	}
+	if len(leafIDs) == 0 || leafIDs[0] == nil {
+		return s.flatPage(characterID, sessionID, page)
+	}
+	return s.branchPage(sessionID, *leafIDs[0], page)
+}
+
+// branchPage pages the session's branch that ends at leafID
+func (s *MessageService) branchPage(sessionID string, leafID uint, page pagination.Page) (*MessagePage, error) {
+	result := &MessagePage{}
+
+	switch {
+	case page.After != nil:
+		// Walk up from the leaf until the cursor; the walk is as long as the
+		// number of newer messages, which is short when catching up
+		ids, found, err := s.ancestorIDs(leafID, 0, page.After.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, ErrCursorNotOnBranch
+		}
+		result.HasOlder = true
+		if len(ids) > page.Limit {
+			ids = ids[:page.Limit]
+			result.HasNewer = true
+		}
+		return s.fillPage(result, ids)
+
+	case page.Before != nil:
+		var parentIDs []*uint
+		if err := s.db.Model(&models.Message{}).Where("id = ? AND session_id = ?", page.Before.ID, sessionID).Pluck("parent_id", &parentIDs).Error; err != nil {
+			return nil, fmt.Errorf("error retrieving cursor message: %w", err)
+		}
+		if len(parentIDs) == 0 {
+			return nil, pagination.ErrInvalidCursor
+		}
+		// A cursor off the active branch would page through another branch,
+		// or through another session's messages via its parent links
+		found, err := s.onBranch(leafID, page.Before.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, pagination.ErrInvalidCursor
+		}
+		result.HasNewer = true
+		if parentIDs[0] == nil {
+			result.Messages = []models.Message{}
+			return result, nil
+		}
+		leafID = *parentIDs[0]
+	}
+
+	ids, _, err := s.ancestorIDs(leafID, page.Limit+1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > page.Limit {
+		ids = ids[1:]
+		result.HasOlder = true
+	}
+	return s.fillPage(result, ids)
+}
+
+// onBranch reports whether id is on the branch that ends at leafID. The branch
+// is walked once per leaf and cached, so paging back through a long history
+// doesn't rewalk it from the leaf for every page.
+func (s *MessageService) onBranch(leafID, id uint) (bool, error) {
+	key := fmt.Sprintf("branch:%d", leafID)
+	if cached, ok := s.branches.Get(key); ok {
+		_, found := cached.(map[uint]struct{})[id]
+		return found, nil
+	}
+
+	ids, _, err := s.ancestorIDs(leafID, 0, 0)
+	if err != nil {
+		return false, err
+	}
+	branch := make(map[uint]struct{}, len(ids))
+	for _, branchID := range ids {
+		branch[branchID] = struct{}{}
+	}
+	s.branches.Set(key, branch)
+
+	_, found := branch[id]
+	return found, nil
+}
+
+// ancestorIDs walks parent links up from startID and returns the visited IDs
+// oldest first. The walk stops after max messages (0 for no limit) or just
+// below stopID, in which case found reports whether stopID was reached.
+func (s *MessageService) ancestorIDs(startID uint, max int, stopID uint) ([]uint, bool, error) {
+	var rows []struct {
+		ID       uint
+		ParentID *uint
+	}
+	err := s.db.Raw(`WITH RECURSIVE branch AS (
+			SELECT id, parent_id, 1 AS depth FROM messages WHERE id = @start AND id <> @stop
+			UNION ALL
+			SELECT m.id, m.parent_id, b.depth + 1 FROM messages m
+			JOIN branch b ON m.id = b.parent_id
+			WHERE m.id <> @stop AND (@max = 0 OR b.depth < @max)
+		)
+		SELECT id, parent_id FROM branch ORDER BY depth DESC`,
+		map[string]interface{}{"start": startID, "stop": stopID, "max": max},
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, false, fmt.Errorf("error walking message branch: %w", err)
+	}
+
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+
+	found := false
+	if stopID != 0 {
+		if len(rows) == 0 {
+			found = startID == stopID
+		} else {
+			found = rows[0].ParentID != nil && *rows[0].ParentID == stopID
+		}
+	}
+	return ids, found, nil
+}
+
+// fillPage loads the messages for ids, keeping their order, and sets the cursors
+func (s *MessageService) fillPage(result *MessagePage, ids []uint) (*MessagePage, error) {
+	result.Messages = []models.Message{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var messages []models.Message
+	if err := s.db.Where("id IN ?", ids).Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving messages: %w", err)
+	}
+	byID := make(map[uint]models.Message, len(messages))
+	for _, m := range messages {
+		byID[m.ID] = m
+	}
+	for _, id := range ids {
+		if m, ok := byID[id]; ok {
+			result.Messages = append(result.Messages, m)
+		}
+	}
+
+	setPageCursors(result)
+	return result, nil
+}
+
+// flatPage pages a session without branches on (timestamp, id)
+func (s *MessageService) flatPage(characterID uint, sessionID string, page pagination.Page) (*MessagePage, error) {
+	result := &MessagePage{}
+	query := s.db.Where("character_id = ? AND session_id = ?", characterID, sessionID)
+
+	var messages []models.Message
+	var err error
+	if page.After != nil {
+		err = query.Where("(timestamp, id) > (?, ?)", page.After.Time, page.After.ID).
+			Order("timestamp ASC, id ASC").
+			Limit(page.Limit + 1).
+			Find(&messages).Error
+		result.HasOlder = true
+		if len(messages) > page.Limit {
+			messages = messages[:page.Limit]
+			result.HasNewer = true
+		}
+	} else {
+		if page.Before != nil {
+			query = query.Where("(timestamp, id) < (?, ?)", page.Before.Time, page.Before.ID)
+			result.HasNewer = true
+		}
+		err = query.Order("timestamp DESC, id DESC").
+			Limit(page.Limit + 1).
+			Find(&messages).Error
+		if len(messages) > page.Limit {
+			messages = messages[:page.Limit]
+			result.HasOlder = true
+		}
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error retrieving messages: %w", err)
+	}
+
+	result.Messages = messages
+	if result.Messages == nil {
+		result.Messages = []models.Message{}
+	}
+	setPageCursors(result)
+	return result, nil
+}
+
+// setPageCursors points the cursors at the first and last message of the page
+func setPageCursors(result *MessagePage) {
+	if len(result.Messages) == 0 {
+		return
+	}
+	first := result.Messages[0]
+	last := result.Messages[len(result.Messages)-1]
+	result.PrevCursor = pagination.Cursor{Time: first.Timestamp, ID: first.ID}.Encode()
+	result.NextCursor = pagination.Cursor{Time: last.Timestamp, ID: last.ID}.Encode()
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ai-agent-character-demo/backend/pkg/pagination"
+)
+
+// searchConfig is the text search configuration used for the content_tsv column and queries
+const searchConfig = "english"
+
+// searchHeadlineOptions controls the snippets returned with search results
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=24, MinWords=8, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// escapedContent is message content with HTML special characters escaped, so
+// the only markup in a snippet is the <mark> tags added by ts_headline
+const escapedContent = `replace(replace(replace(replace(replace(m.content,
+		'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+// ErrEmptySearchQuery is returned when a search has no terms
+var ErrEmptySearchQuery = errors.New("search query is required")
+
+// SearchMessagesParams filters a message search
+type SearchMessagesParams struct {
+	Query          string
+	CharacterID    uint
+	ConversationID uint
+	From           *time.Time
+	To             *time.Time
+	Limit          int
+	Offset         int
+}
+
+// MessageSearchResult is a message matching a search, with its rank and a highlighted snippet
+type MessageSearchResult struct {
+	ID             string    `json:"id"`
+	ConversationID uint      `json:"conversation_id"`
+	SessionID      string    `json:"session_id"`
+	CharacterID    uint      `json:"character_id"`
+	Sender         string    `json:"sender"`
+	Content        string    `json:"content"`
+	Snippet        string    `json:"snippet"` // HTML-escaped and safe to render, matches wrapped in <mark></mark>
+	Rank           float64   `json:"rank"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// SearchMessages runs a full-text search over messages in conversations the
+// user owns, best matches first. Queries use web search syntax: quoted
+// phrases, "or" and -excluded terms.
+func (s *MessageService) SearchMessages(userID uint, params SearchMessagesParams) ([]MessageSearchResult, error) {
+	query := strings.TrimSpace(params.Query)
+	if query == "" {
+		return nil, ErrEmptySearchQuery
+	}
+
+	var sql strings.Builder
+	args := []interface{}{query}
+
+	sql.WriteString(`SELECT m.external_id AS id, m.conversation_id, m.session_id, m.character_id,
+		m.sender, m.content, m.timestamp,
+		ts_rank_cd(m.content_tsv, q) AS rank,
+		ts_headline('` + searchConfig + `', ` + escapedContent + `, q, '` + searchHeadlineOptions + `') AS snippet
+	FROM messages m
+	JOIN conversations c ON c.id = m.conversation_id,
+		websearch_to_tsquery('` + searchConfig + `', ?) q
+	WHERE m.content_tsv @@ q AND c.user_id = ?`)
+	args = append(args, userID)
+
+	if params.CharacterID != 0 {
+		sql.WriteString(" AND m.character_id = ?")
+		args = append(args, params.CharacterID)
+	}
+	if params.ConversationID != 0 {
+		sql.WriteString(" AND m.conversation_id = ?")
+		args = append(args, params.ConversationID)
+	}
+	if params.From != nil {
+		sql.WriteString(" AND m.timestamp >= ?")
+		args = append(args, *params.From)
+	}
+	if params.To != nil {
+		sql.WriteString(" AND m.timestamp < ?")
+		args = append(args, *params.To)
+	}
+
+	offset := params.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	sql.WriteString(" ORDER BY rank DESC, m.timestamp DESC, m.id DESC LIMIT ? OFFSET ?")
+	args = append(args, pagination.ClampLimit(params.Limit), offset)
+
+	var results []MessageSearchResult
+	if err := s.db.Raw(sql.String(), args...).Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("error searching messages: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"text/template"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/prompt"
+	"ai-agent-character-demo/backend/pkg/ws"
+)
+
+var (
+	// ErrPromptTemplateNotFound is returned for unknown prompt templates or versions
+	ErrPromptTemplateNotFound = errors.New("prompt template not found")
+
+	// ErrInvalidPromptTemplate is returned for templates with a bad name or a body that does not render
+	ErrInvalidPromptTemplate = prompt.ErrTemplate
+)
+
+// PromptTemplateInput is a new version of a prompt template
+type PromptTemplateInput struct {
+	Name        string
+	Description string
+	Body        string
+	CharacterID *uint  // Binds the template to a character
+	Model       string // Binds the template to a chat model
+	Draft       bool   // Save without making it the active version
+}
+
+// PromptPreviewRequest selects a template and the context to render it in.
+// Body renders unsaved text; otherwise Name and Version pick a saved version
+// (the active one when Version is 0), and with neither the template that
+// would apply to the character is used. Without a character the sample
+// persona is used.
+type PromptPreviewRequest struct {
+	Body        string
+	Name        string
+	Version     int
+	CharacterID uint
+	UserID      uint
+	SessionID   string
+}
+
+// PromptPreview is a rendered system prompt
+type PromptPreview struct {
+	Template      *models.PromptTemplate `json:"template,omitempty"` // Nil for unsaved text and the built-in prompt
+	PromptVersion string                 `json:"prompt_version"`     // Recorded on replies generated from it
+	Prompt        string                 `json:"prompt"`
+}
+
+// PromptTemplateService stores versioned prompt templates and renders the
+// one bound to a character into the system prompt of its replies
+type PromptTemplateService struct {
+	db            *gorm.DB
+	characters    *CharacterService
+	model         string
+	promptVersion string
+	compiled      sync.Map // Template ID -> *template.Template; versions never change
+}
+
+// NewPromptTemplateService creates the prompt template service. model is the
+// chat model replies come from and selects model-bound templates;
+// promptVersion is recorded for replies that use the built-in prompt.
+func NewPromptTemplateService(db *gorm.DB, characters *CharacterService, model string, promptVersion string) *PromptTemplateService {
+	return &PromptTemplateService{
+		db:            db,
+		characters:    characters,
+		model:         strings.TrimSpace(model),
+		promptVersion: promptVersion,
+	}
+}
+
+// CreateTemplate saves a new version of a template, numbered after the
+// latest version of the same name. Unless it is a draft it becomes the
+// active version.
+func (s *PromptTemplateService) CreateTemplate(input PromptTemplateInput, createdBy *uint) (*models.PromptTemplate, error) {
+	name := strings.TrimSpace(input.Name)
+	if !categorySlugPattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: name must be lowercase letters, digits and hyphens", ErrInvalidPromptTemplate)
+	}
+	if err := prompt.Check(name, input.Body); err != nil {
+		return nil, err
+	}
+	if input.CharacterID != nil {
+		if _, err := s.characters.GetCharacter(*input.CharacterID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrCharacterNotFound
+			}
+			return nil, fmt.Errorf("error retrieving character: %w", err)
+		}
+	}
+
+	tmpl := &models.PromptTemplate{
+		Name:        name,
+		Description: strings.TrimSpace(input.Description),
+		Body:        input.Body,
+		CharacterID: input.CharacterID,
+		Model:       strings.TrimSpace(input.Model),
+		Active:      !input.Draft,
+		CreatedBy:   createdBy,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.PromptTemplate{}).Where("name = ?", name).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return fmt.Errorf("error finding latest prompt template version: %w", err)
+		}
+		tmpl.Version = latest + 1
+
+		if tmpl.Active {
+			if err := deactivatePromptTemplates(tx, name); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(tmpl).Error; err != nil {
+			return fmt.Errorf("error saving prompt template: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// ListTemplates returns every template version, optionally of one name,
+// ordered by name and newest version first
+func (s *PromptTemplateService) ListTemplates(name string) ([]models.PromptTemplate, error) {
+	query := s.db.Order("name ASC, version DESC")
+	if name != "" {
+		query = query.Where("name = ?", name)
+	}
+
+	var templates []models.PromptTemplate
+	if err := query.Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("error listing prompt templates: %w", err)
+	}
+	if templates == nil {
+		templates = []models.PromptTemplate{}
+	}
+	return templates, nil
+}
+
+// GetTemplate returns one version of a template, or its active version when version is 0
+func (s *PromptTemplateService) GetTemplate(name string, version int) (*models.PromptTemplate, error) {
+	return findPromptTemplate(s.db, name, version)
+}
+
+// findPromptTemplate loads one version of a template, or its active version when version is 0
+func findPromptTemplate(db *gorm.DB, name string, version int) (*models.PromptTemplate, error) {
+	query := db.Where("name = ?", name)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	} else {
+		query = query.Where("active = ?", true)
+	}
+
+	var tmpl models.PromptTemplate
+	if err := query.First(&tmpl).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromptTemplateNotFound
+		}
+		return nil, fmt.Errorf("error retrieving prompt template: %w", err)
+	}
+	return &tmpl, nil
+}
+
+// ActivateTemplate makes a version the active one of its name, which is
+// also how a template is rolled back
+func (s *PromptTemplateService) ActivateTemplate(name string, version int) (*models.PromptTemplate, error) {
+	var tmpl *models.PromptTemplate
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if tmpl, err = findPromptTemplate(tx, name, version); err != nil {
+			return err
+		}
+		if err := deactivatePromptTemplates(tx, name); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PromptTemplate{}).Where("id = ?", tmpl.ID).Update("active", true).Error; err != nil {
+			return fmt.Errorf("error activating prompt template: %w", err)
+		}
+		tmpl.Active = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// DeactivateTemplate stops a template from applying to replies; its
+// versions are kept
+func (s *PromptTemplateService) DeactivateTemplate(name string) error {
+	if _, err := s.GetTemplate(name, 0); err != nil {
+		return err
+	}
+	return deactivatePromptTemplates(s.db, name)
+}
+
+// deactivatePromptTemplates clears the active version of a name
+func deactivatePromptTemplates(tx *gorm.DB, name string) error {
+	if err := tx.Model(&models.PromptTemplate{}).Where("name = ? AND active = ?", name, true).
+		Update("active", false).Error; err != nil {
+		return fmt.Errorf("error deactivating prompt templates: %w", err)
+	}
+	return nil
+}
+
+// Resolve returns the active template that applies to a character, or nil
+// when the built-in prompt applies. The most specific binding wins: the
+// character and model, the character, the model, then a global template.
+func (s *PromptTemplateService) Resolve(characterID uint) (*models.PromptTemplate, error) {
+	var candidates []models.PromptTemplate
+	if err := s.db.Where("active = ?", true).
+		Where("character_id = ? OR character_id IS NULL", characterID).
+		Where("model = ? OR model = ''", s.model).
+		Order("created_at DESC, id DESC").
+		Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("error resolving prompt template: %w", err)
+	}
+
+	var best *models.PromptTemplate
+	bestRank := -1
+	for i := range candidates {
+		rank := 0
+		if candidates[i].CharacterID != nil {
+			rank += 2
+		}
+		if candidates[i].Model != "" {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = &candidates[i], rank
+		}
+	}
+	return best, nil
+}
+
+// Apply renders the template bound to a character into its system prompt
+// for a reply in the session, recording the template version on the
+// character. Errors are logged and leave the built-in prompt in place, so
+// a broken template never stops a reply.
+func (s *PromptTemplateService) Apply(ctx context.Context, character *ws.Character, sessionID string) {
+	tmpl, err := s.Resolve(character.ID)
+	if err != nil {
+		log.Printf("Error resolving prompt template for character %d: %v", character.ID, err)
+		return
+	}
+	if tmpl == nil {
+		return
+	}
+
+	data, err := s.promptData(ctx, character, 0, sessionID)
+	if err != nil {
+		log.Printf("Error loading prompt data for session %s: %v", sessionID, err)
+		return
+	}
+	rendered, err := s.render(tmpl, data)
+	if err != nil {
+		log.Printf("Error rendering prompt template %s: %v", tmpl.Label(), err)
+		return
+	}
+
+	character.SystemPrompt = rendered
+	character.PromptVersion = tmpl.Label()
+}
+
+// Preview renders a template the way a reply would see it
+func (s *PromptTemplateService) Preview(ctx context.Context, req PromptPreviewRequest) (*PromptPreview, error) {
+	data := prompt.SampleData()
+	if req.CharacterID != 0 {
+		character, err := s.characters.GetCharacter(req.CharacterID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrCharacterNotFound
+			}
+			return nil, fmt.Errorf("error retrieving character: %w", err)
+		}
+		if data, err = s.promptData(ctx, WebSocketCharacter(character), req.UserID, req.SessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.TrimSpace(req.Body) != "" {
+		t, err := prompt.Parse("preview", req.Body)
+		if err != nil {
+			return nil, err
+		}
+		rendered, err := prompt.Render(t, data)
+		if err != nil {
+			return nil, err
+		}
+		return &PromptPreview{PromptVersion: "preview", Prompt: rendered}, nil
+	}
+
+	var tmpl *models.PromptTemplate
+	var err error
+	if req.Name != "" {
+		tmpl, err = s.GetTemplate(req.Name, req.Version)
+	} else if req.CharacterID != 0 {
+		tmpl, err = s.Resolve(req.CharacterID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return &PromptPreview{PromptVersion: s.promptVersion, Prompt: data.Default()}, nil
+	}
+
+	rendered, err := s.render(tmpl, data)
+	if err != nil {
+		return nil, err
+	}
+	return &PromptPreview{Template: tmpl, PromptVersion: tmpl.Label(), Prompt: rendered}, nil
+}
+
+// render executes a saved template, compiling it once
+func (s *PromptTemplateService) render(tmpl *models.PromptTemplate, data prompt.Data) (string, error) {
+	cached, ok := s.compiled.Load(tmpl.ID)
+	if !ok {
+		t, err := prompt.Parse(tmpl.Label(), tmpl.Body)
+		if err != nil {
+			return "", err
+		}
+		cached, _ = s.compiled.LoadOrStore(tmpl.ID, t)
+	}
+	return prompt.Render(cached.(*template.Template), data)
+}
+
+// promptData gathers what a template can refer to: the character, the
+// user's name and preferences, and the conversation's title and summary.
+// The session's owner is the user when userID is 0.
+func (s *PromptTemplateService) promptData(ctx context.Context, character *ws.Character, userID uint, sessionID string) (prompt.Data, error) {
+	data := prompt.Data{Character: character}
+	db := s.db.WithContext(ctx)
+
+	if sessionID != "" {
+		var conversations []models.Conversation
+		if err := db.Where("session_id = ?", sessionID).Limit(1).Find(&conversations).Error; err != nil {
+			return data, fmt.Errorf("error retrieving conversation: %w", err)
+		}
+		if len(conversations) > 0 {
+			data.Memory = prompt.Memory{Title: conversations[0].Title, Summary: conversations[0].Summary}
+			if userID == 0 && conversations[0].UserID != nil {
+				userID = *conversations[0].UserID
+			}
+		}
+	}
+
+	if userID != 0 {
+		var users []models.User
+		if err := db.Select("id", "name").Where("id = ?", userID).Limit(1).Find(&users).Error; err != nil {
+			return data, fmt.Errorf("error retrieving user: %w", err)
+		}
+		if len(users) > 0 {
+			data.User.Name = users[0].Name
+		}
+
+		var preferences []models.UserPreference
+		if err := db.Where("user_id = ?", userID).Order("updated_at DESC").Limit(1).Find(&preferences).Error; err != nil {
+			return data, fmt.Errorf("error retrieving user preferences: %w", err)
+		}
+		if len(preferences) > 0 {
+			data.User.Preferences = prompt.Preferences{ChatStyle: preferences[0].ChatStyle, Voice: preferences[0].TTSVoice}
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/audio"
+)
+
+const (
+	// Recordings are mixed down to mono speech-quality PCM
+	recordingSampleRate = 24000
+	recordingChannels   = 1
+
+	// RecordingTargetLoudness is the RMS level used when normalization is requested
+	RecordingTargetLoudness = -20.0
+
+	// MaxRecordingGap bounds the silence inserted between segments
+	MaxRecordingGap = 10 * time.Second
+)
+
+var (
+	// ErrRecordingEmpty is returned when a session has no decodable audio
+	ErrRecordingEmpty = errors.New("session has no recordable audio")
+
+	// ErrRecordingForbidden is returned when the session's audio belongs to another user
+	ErrRecordingForbidden = errors.New("session recording belongs to another user")
+)
+
+// RecordingOptions controls how session audio is stitched together
+type RecordingOptions struct {
+	Gap       time.Duration // Silence inserted between consecutive segments
+	Normalize bool          // Apply a single loudness gain to the whole recording
+}
+
+// TranscriptCue is a transcript line aligned to the stitched recording
+type TranscriptCue struct {
+	MessageID string    `json:"message_id"`
+	Sender    string    `json:"sender"`
+	Text      string    `json:"text"`
+	Start     float64   `json:"start"` // Seconds from the start of the recording
+	End       float64   `json:"end"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Recording is a session's audio planned as a single timeline. The audio is
+// decoded again segment by segment when it is written, so a recording never
+// holds the whole session in memory.
+type Recording struct {
+	SessionID string          `json:"session_id"`
+	Duration  float64         `json:"duration"`
+	Segments  int             `json:"segments"`
+	Skipped   int             `json:"skipped"` // Chunks that could not be decoded
+	Cues      []TranscriptCue `json:"cues"`
+	parts     []recordingPart
+	gap       int // Samples of silence between segments
+	samples   int
+	gain      float64
+}
+
+// recordingSegment is one stored chunk placed on the session timeline
+type recordingSegment struct {
+	at      time.Time
+	chunk   *models.AudioChunk
+	message *models.Message
+}
+
+// recordingPart is a decodable chunk of a recording and its length
+type recordingPart struct {
+	chunk   *models.AudioChunk
+	samples int
+}
+
+// RecordingService builds whole-session recordings from stored audio chunks
+type RecordingService struct {
+	db           *gorm.DB
+	transcoder   *audio.Transcoder
+	audioService *AudioService // Reads audio kept in the blob store
+}
+
+// NewRecordingService creates a new recording service
+func NewRecordingService(db *gorm.DB, transcoder *audio.Transcoder) *RecordingService {
+	return &RecordingService{
+		db:         db,
+		transcoder: transcoder,
+	}
+}
+
+// SetAudioService lets the service read audio kept in the blob store
+func (s *RecordingService) SetAudioService(audioService *AudioService) {
+	s.audioService = audioService
+}
+
+// BuildRecording places the user's and character's audio for a session in
+// timeline order and aligns the transcript to it. Each chunk is decoded once
+// to measure it and dropped again.
+func (s *RecordingService) BuildRecording(ctx context.Context, sessionID string, userID string, opts RecordingOptions) (*Recording, error) {
+	segments, err := s.loadSegments(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Gap < 0 {
+		opts.Gap = 0
+	}
+	if opts.Gap > MaxRecordingGap {
+		opts.Gap = MaxRecordingGap
+	}
+
+	recording := &Recording{
+		SessionID: sessionID,
+		Cues:      []TranscriptCue{},
+		gap:       len(audio.Silence(recordingSampleRate, recordingChannels, opts.Gap).Samples),
+		gain:      1,
+	}
+	var level audio.Level
+
+	for _, segment := range segments {
+		pcm, err := s.decode(ctx, segment.chunk)
+		segment.chunk.AudioData = nil
+		if err != nil {
+			log.Printf("Skipping audio chunk %d in recording for session %s: %v", segment.chunk.ID, sessionID, err)
+			recording.Skipped++
+			continue
+		}
+
+		if len(recording.parts) > 0 && recording.gap > 0 {
+			recording.samples += recording.gap
+			level.AddSilence(recording.gap)
+		}
+
+		start := recordingDuration(recording.samples)
+		recording.parts = append(recording.parts, recordingPart{chunk: segment.chunk, samples: len(pcm.Samples)})
+		recording.samples += len(pcm.Samples)
+		recording.Segments++
+		if opts.Normalize {
+			level.Add(pcm)
+		}
+
+		if segment.message != nil && segment.message.Content != "" {
+			recording.Cues = append(recording.Cues, TranscriptCue{
+				MessageID: segment.message.ExternalID,
+				Sender:    segment.message.Sender,
+				Text:      segment.message.Content,
+				Start:     start.Seconds(),
+				End:       recordingDuration(recording.samples).Seconds(),
+				Timestamp: segment.message.Timestamp,
+			})
+		}
+	}
+
+	if recording.Segments == 0 {
+		return nil, ErrRecordingEmpty
+	}
+
+	if opts.Normalize {
+		recording.gain = level.Gain(RecordingTargetLoudness)
+	}
+	recording.Duration = recordingDuration(recording.samples).Seconds()
+
+	return recording, nil
+}
+
+// ContentType returns the content type of a recording written as "wav" or
+// "ogg", or ErrTranscoderUnavailable when the format cannot be written
+func (s *RecordingService) ContentType(format string) (string, error) {
+	switch format {
+	case "", "wav":
+		return "audio/wav", nil
+	case "ogg":
+		if !s.transcoder.Available() {
+			return "", audio.ErrTranscoderUnavailable
+		}
+		return "audio/ogg", nil
+	default:
+		return "", fmt.Errorf("unsupported recording format: %s", format)
+	}
+}
+
+// WriteRecording streams the recording to w as "wav" or "ogg", decoding one
+// segment at a time
+func (s *RecordingService) WriteRecording(ctx context.Context, w io.Writer, recording *Recording, format string) error {
+	switch format {
+	case "", "wav":
+		return s.writeWAV(ctx, w, recording)
+	case "ogg":
+		return s.transcoder.EncodeOggStream(ctx, w, func(wav io.Writer) error {
+			return s.writeWAV(ctx, wav, recording)
+		})
+	default:
+		return fmt.Errorf("unsupported recording format: %s", format)
+	}
+}
+
+// writeWAV writes the recording as a WAV file. A chunk that no longer decodes
+// to the length it was planned with is cut or padded with silence, so the
+// header stays correct.
+func (s *RecordingService) writeWAV(ctx context.Context, w io.Writer, recording *Recording) error {
+	if err := audio.WriteWAVHeader(w, recordingSampleRate, recordingChannels, recording.samples); err != nil {
+		return err
+	}
+
+	gap := make([]int16, recording.gap)
+	for i, part := range recording.parts {
+		if i > 0 && len(gap) > 0 {
+			if err := audio.WriteSamples(w, gap); err != nil {
+				return err
+			}
+		}
+
+		pcm, err := s.decode(ctx, part.chunk)
+		part.chunk.AudioData = nil
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Writing silence for audio chunk %d in recording for session %s: %v", part.chunk.ID, recording.SessionID, err)
+			pcm = &audio.PCM{SampleRate: recordingSampleRate, Channels: recordingChannels}
+		}
+		samples := pcm.Samples
+		if len(samples) > part.samples {
+			samples = samples[:part.samples]
+		} else if len(samples) < part.samples {
+			samples = append(samples, make([]int16, part.samples-len(samples))...)
+		}
+		pcm.Samples = samples
+		audio.Amplify(pcm, recording.gain)
+
+		if err := audio.WriteSamples(w, pcm.Samples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordingDuration returns the playback length of a number of recording samples
+func recordingDuration(samples int) time.Duration {
+	return time.Duration(samples/recordingChannels) * time.Second / time.Duration(recordingSampleRate)
+}
+
+// loadSegments places the owner's unexpired chunks of the session on a
+// timeline, without their audio. Chunks linked to a message use the message
+// timestamp; only the newest chunk per message is kept so re-synthesized
+// replies are not played twice.
+func (s *RecordingService) loadSegments(sessionID string, userID string) ([]recordingSegment, error) {
+	// Anonymous sessions and sessions without a conversation row belong to no
+	// one until they are claimed
+	var conversation models.Conversation
+	if err := s.db.Where("session_id = ?", sessionID).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordingForbidden
+		}
+		return nil, fmt.Errorf("error retrieving conversation: %w", err)
+	}
+	if conversation.UserID == nil || strconv.FormatUint(uint64(*conversation.UserID), 10) != userID {
+		return nil, ErrRecordingForbidden
+	}
+
+	// Only the owner's audio is played; anything else stored under the
+	// session was not recorded by them
+	var chunks []*models.AudioChunk
+	if err := s.db.Omit("audio_data").
+		Where("session_id = ? AND user_id = ? AND expires_at > ?", sessionID, userID, time.Now()).
+		Order("created_at ASC").
+		Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving session audio: %w", err)
+	}
+	if len(chunks) == 0 {
+		return nil, ErrRecordingEmpty
+	}
+
+	var messages []models.Message
+	if err := s.db.Where("session_id = ?", sessionID).Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving session messages: %w", err)
+	}
+	byExternalID := make(map[string]*models.Message, len(messages))
+	for i := range messages {
+		byExternalID[messages[i].ExternalID] = &messages[i]
+	}
+
+	var links []models.AudioMessageLink
+	if err := s.db.Where("session_id = ?", sessionID).Order("id ASC").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("error retrieving session audio links: %w", err)
+	}
+	linked := make(map[uint][]string)
+	for _, link := range links {
+		linked[link.ChunkID] = append(linked[link.ChunkID], link.MessageID)
+	}
+
+	latest := make(map[string]*models.AudioChunk)
+	for _, chunk := range chunks {
+		for _, messageID := range linked[chunk.ID] {
+			latest[messageID] = chunk
+		}
+	}
+
+	segments := make([]recordingSegment, 0, len(chunks))
+	for _, chunk := range chunks {
+		messageIDs := linked[chunk.ID]
+		if len(messageIDs) == 0 {
+			segments = append(segments, recordingSegment{at: chunk.CreatedAt, chunk: chunk})
+			continue
+		}
+
+		// A deduplicated chunk plays once for every message that sent it
+		for _, messageID := range messageIDs {
+			if latest[messageID] != chunk {
+				continue
+			}
+			segment := recordingSegment{at: chunk.CreatedAt, chunk: chunk}
+			if message, ok := byExternalID[messageID]; ok {
+				segment.message = message
+				segment.at = message.Timestamp
+			}
+			segments = append(segments, segment)
+		}
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].at.Before(segments[j].at)
+	})
+
+	return segments, nil
+}
+
+// decode loads a chunk's audio and converts it to the recording's PCM format
+func (s *RecordingService) decode(ctx context.Context, chunk *models.AudioChunk) (*audio.PCM, error) {
+	if chunk.BlobKey == "" && len(chunk.AudioData) == 0 {
+		var stored models.AudioChunk
+		if err := s.db.WithContext(ctx).Select("audio_data").Where("id = ?", chunk.ID).First(&stored).Error; err != nil {
+			return nil, fmt.Errorf("error retrieving audio: %w", err)
+		}
+		chunk.AudioData = stored.AudioData
+	}
+	if s.audioService != nil {
+		if err := s.audioService.LoadAudio(ctx, chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	pcm, err := audio.Decode(ctx, s.transcoder, chunk.AudioData, recordingSampleRate, recordingChannels)
+	if err != nil {
+		return nil, err
+	}
+	return audio.Convert(pcm, recordingSampleRate, recordingChannels), nil
+}
+
+// FormatWebVTT renders transcript cues as a WebVTT document
+func FormatWebVTT(cues []TranscriptCue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+
+	for i, cue := range cues {
+		fmt.Fprintf(&b, "\n%d\n%s --> %s\n", i+1, vttTimestamp(cue.Start), vttTimestamp(cue.End))
+		fmt.Fprintf(&b, "<v %s>%s\n", cue.Sender, vttText(cue.Text))
+	}
+
+	return b.String()
+}
+
+// vttText drops blank lines, which would terminate the cue early
+func vttText(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			kept = append(kept, strings.ReplaceAll(line, "-->", "->"))
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func vttTimestamp(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/fixtures"
+)
+
+// errSeedSkipped marks a record that was deliberately not loaded
+var errSeedSkipped = errors.New("skipped")
+
+// SeedCounts counts the records of one kind a seeding run loaded
+type SeedCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// SeedResult reports what a seeding run did
+type SeedResult struct {
+	Set        string     `json:"set"`
+	Categories SeedCounts `json:"categories"`
+	Voices     SeedCounts `json:"voices"`
+	Users      SeedCounts `json:"users"`
+	Characters SeedCounts `json:"characters"`
+	Skipped    []string   `json:"skipped,omitempty"` // Records that were not loaded, with the reason
+}
+
+// SeedService loads fixture sets into the database. Loading is idempotent:
+// records are matched by their fixture key and only written again when their
+// revision goes up, so seeding on every start is safe.
+type SeedService struct {
+	db *gorm.DB
+}
+
+// NewSeedService creates a new seed service
+func NewSeedService(db *gorm.DB) *SeedService {
+	return &SeedService{db: db}
+}
+
+// Migrate creates the tables and indexes seeding writes to, so fixtures can
+// be loaded before the server first starts
+func (s *SeedService) Migrate() error {
+	if err := s.db.AutoMigrate(&models.User{}, &models.CharacterCategory{}, &models.Character{}, &models.CharacterVersion{}, &models.Voice{}, &models.SeedRecord{}); err != nil {
+		return fmt.Errorf("failed to migrate seed tables: %w", err)
+	}
+	if err := s.db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_characters_slug ON characters(slug) WHERE slug <> ''").Error; err != nil {
+		return fmt.Errorf("failed to create character slug index: %w", err)
+	}
+	return nil
+}
+
+// SeedSet reads a fixture set and loads it
+func (s *SeedService) SeedSet(fsys fs.FS, name string) (*SeedResult, error) {
+	set, err := fixtures.Load(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.Seed(set)
+}
+
+// Seed loads a fixture set: categories, voices, users, then characters.
+// Each record is written in its own transaction; the first failure stops
+// the run and is returned with the counts so far.
+func (s *SeedService) Seed(set *fixtures.Set) (*SeedResult, error) {
+	result := &SeedResult{Set: set.Name}
+
+	for _, c := range set.Categories {
+		if err := s.apply(set.Name, models.SeedKindCategory, c.Slug, c.Revision, &result.Categories, &result.Skipped, func(tx *gorm.DB) (uint, bool, error) {
+			return seedCategory(tx, c)
+		}); err != nil {
+			return result, err
+		}
+	}
+
+	for _, v := range set.Voices {
+		if err := s.apply(set.Name, models.SeedKindVoice, v.Slug, v.Revision, &result.Voices, &result.Skipped, func(tx *gorm.DB) (uint, bool, error) {
+			return seedVoice(tx, v)
+		}); err != nil {
+			return result, err
+		}
+	}
+
+	for _, u := range set.Users {
+		if err := s.apply(set.Name, models.SeedKindUser, u.Email, u.Revision, &result.Users, &result.Skipped, func(tx *gorm.DB) (uint, bool, error) {
+			return seedUser(tx, u)
+		}); err != nil {
+			return result, err
+		}
+	}
+
+	for _, c := range set.Characters {
+		voice, _ := set.Voice(c.Voice)
+		if err := s.apply(set.Name, models.SeedKindCharacter, c.Slug, c.Revision, &result.Characters, &result.Skipped, func(tx *gorm.DB) (uint, bool, error) {
+			return seedCharacter(tx, c, voice)
+		}); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// apply loads one record unless its revision was already loaded, and
+// remembers the revision
+func (s *SeedService) apply(set, kind, key string, revision int, counts *SeedCounts, skipped *[]string, load func(tx *gorm.DB) (uint, bool, error)) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var records []models.SeedRecord
+		if err := tx.Where("kind = ? AND key = ?", kind, key).Limit(1).Find(&records).Error; err != nil {
+			return fmt.Errorf("error reading seed record: %w", err)
+		}
+		if len(records) > 0 && records[0].Revision >= revision {
+			counts.Unchanged++
+			return nil
+		}
+
+		id, created, err := load(tx)
+		if err != nil {
+			return err
+		}
+
+		record := models.SeedRecord{Kind: kind, Key: key}
+		if len(records) > 0 {
+			record = records[0]
+		}
+		record.Revision = revision
+		record.FixtureSet = set
+		record.TargetID = id
+		record.AppliedAt = time.Now()
+		if err := tx.Save(&record).Error; err != nil {
+			return fmt.Errorf("error saving seed record: %w", err)
+		}
+
+		if created {
+			counts.Created++
+		} else {
+			counts.Updated++
+		}
+		return nil
+	})
+	if errors.Is(err, errSeedSkipped) {
+		*skipped = append(*skipped, fmt.Sprintf("%s %s: %v", kind, key, err))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", kind, key, err)
+	}
+	return nil
+}
+
+// seedCategory creates or updates a category by slug
+func seedCategory(tx *gorm.DB, fixture fixtures.Category) (uint, bool, error) {
+	category, err := findCategory(tx, fixture.Slug)
+	created := errors.Is(err, ErrCategoryNotFound)
+	if err != nil && !created {
+		return 0, false, err
+	}
+	if created {
+		category = &models.CharacterCategory{Slug: fixture.Slug}
+	}
+
+	category.Name = fixture.Name
+	category.Description = fixture.Description
+	category.SortOrder = fixture.SortOrder
+	if err := tx.Save(category).Error; err != nil {
+		return 0, false, fmt.Errorf("error saving category: %w", err)
+	}
+	return category.ID, created, nil
+}
+
+// seedVoice creates or updates a voice by slug
+func seedVoice(tx *gorm.DB, fixture fixtures.Voice) (uint, bool, error) {
+	var voices []models.Voice
+	if err := tx.Where("slug = ?", fixture.Slug).Limit(1).Find(&voices).Error; err != nil {
+		return 0, false, fmt.Errorf("error reading voice: %w", err)
+	}
+	voice := models.Voice{Slug: fixture.Slug}
+	if len(voices) > 0 {
+		voice = voices[0]
+	}
+
+	voice.Name = fixture.Name
+	voice.Provider = fixture.Provider
+	voice.ProviderVoiceID = fixture.ProviderVoiceID
+	voice.Gender = fixture.Gender
+	voice.Style = fixture.Style
+	voice.Language = fixture.Language
+	voice.Description = fixture.Description
+	if err := tx.Save(&voice).Error; err != nil {
+		return 0, false, fmt.Errorf("error saving voice: %w", err)
+	}
+	return voice.ID, len(voices) == 0, nil
+}
+
+// seedUser creates or updates a demo user by email. Accounts that exist
+// but were not created by seeding are left alone.
+func seedUser(tx *gorm.DB, fixture fixtures.User) (uint, bool, error) {
+	password := fixture.Password
+	if fixture.PasswordEnv != "" {
+		if password = os.Getenv(fixture.PasswordEnv); password == "" {
+			return 0, false, fmt.Errorf("%w: %s is not set", errSeedSkipped, fixture.PasswordEnv)
+		}
+	}
+
+	var users []models.User
+	if err := tx.Where("email = ?", fixture.Email).Limit(1).Find(&users).Error; err != nil {
+		return 0, false, fmt.Errorf("error reading user: %w", err)
+	}
+	if len(users) == 0 {
+		user := models.User{Name: fixture.Name, Email: fixture.Email, Password: password, Role: fixture.Role}
+		if err := tx.Create(&user).Error; err != nil {
+			return 0, false, fmt.Errorf("error creating user: %w", err)
+		}
+		return user.ID, true, nil
+	}
+
+	var seeded int64
+	if err := tx.Model(&models.SeedRecord{}).Where("kind = ? AND target_id = ?", models.SeedKindUser, users[0].ID).
+		Count(&seeded).Error; err != nil {
+		return 0, false, fmt.Errorf("error reading seed record: %w", err)
+	}
+	if seeded == 0 {
+		return 0, false, fmt.Errorf("%w: an account that was not seeded has this email", errSeedSkipped)
+	}
+
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return 0, false, fmt.Errorf("error hashing password: %w", err)
+	}
+	if err := tx.Model(&users[0]).Updates(map[string]interface{}{
+		"name":     fixture.Name,
+		"role":     fixture.Role,
+		"password": hash,
+	}).Error; err != nil {
+		return 0, false, fmt.Errorf("error updating user: %w", err)
+	}
+	return users[0].ID, false, nil
+}
+
+// seedCharacter creates or updates a character by slug, writing a "seed"
+// version. A system character with the same name and no slug, created
+// before fixtures existed, is adopted. Deleted characters stay deleted.
+func seedCharacter(tx *gorm.DB, fixture fixtures.Character, voice fixtures.Voice) (uint, bool, error) {
+	var characters []models.Character
+	if err := tx.Unscoped().Where("slug = ?", fixture.Slug).Limit(1).Find(&characters).Error; err != nil {
+		return 0, false, fmt.Errorf("error reading character: %w", err)
+	}
+	if len(characters) == 0 {
+		if err := tx.Where("slug = '' AND owner_id IS NULL AND name = ?", fixture.Name).
+			Order("id ASC").Limit(1).Find(&characters).Error; err != nil {
+			return 0, false, fmt.Errorf("error reading character: %w", err)
+		}
+	}
+	if len(characters) > 0 && characters[0].DeletedAt.Valid {
+		return 0, false, fmt.Errorf("%w: the character was deleted", errSeedSkipped)
+	}
+
+	var ownerID *uint
+	if fixture.Owner != "" {
+		var owners []uint
+		if err := tx.Model(&models.User{}).Where("email = ?", fixture.Owner).Limit(1).Pluck("id", &owners).Error; err != nil {
+			return 0, false, fmt.Errorf("error reading owner: %w", err)
+		}
+		if len(owners) == 0 {
+			return 0, false, fmt.Errorf("%w: owner %s was not seeded", errSeedSkipped, fixture.Owner)
+		}
+		ownerID = &owners[0]
+	}
+
+	fields := models.CharacterFields{
+		Name:               fixture.Name,
+		Description:        strings.TrimSpace(fixture.Description),
+		Personality:        strings.TrimSpace(fixture.Personality),
+		Background:         strings.TrimSpace(fixture.Background),
+		Scenario:           strings.TrimSpace(fixture.Scenario),
+		Category:           fixture.Category,
+		Traits:             fixture.Traits,
+		Goals:              fixture.Goals,
+		Fears:              fixture.Fears,
+		Relationships:      fixture.Relationships,
+		VoiceType:          voice.Slug,
+		VoiceGender:        voice.Gender,
+		VoiceStyle:         voice.Style,
+		Greeting:           strings.TrimSpace(fixture.Greeting),
+		AlternateGreetings: fixture.AlternateGreetings,
+		ExampleDialogue:    strings.TrimSpace(fixture.ExampleDialogue),
+	}
+	if err := validateCharacterFields(fields); err != nil {
+		return 0, false, err
+	}
+	if err := checkCategory(tx, fields.Category); err != nil {
+		return 0, false, err
+	}
+
+	created := len(characters) == 0
+	character := &models.Character{Version: 1}
+	if !created {
+		character = &characters[0]
+		fields.AvatarURL = character.AvatarURL
+		character.Version++
+	}
+	character.SetFields(fields)
+	character.Slug = fixture.Slug
+	character.OwnerID = ownerID
+	character.Visibility = fixture.Visibility
+	character.IsCustom = false
+
+	if err := tx.Save(character).Error; err != nil {
+		return 0, false, fmt.Errorf("error saving character: %w", err)
+	}
+	if err := createCharacterVersion(tx, character, models.CharacterChangeSeed, nil, nil); err != nil {
+		return 0, false, err
+	}
+	return character.ID, created, nil
+}
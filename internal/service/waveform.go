@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ai-agent-character-demo/backend/internal/models"
+	"ai-agent-character-demo/backend/pkg/audio"
+	"ai-agent-character-demo/backend/pkg/cache"
+)
+
+const (
+	// DefaultPeakBuckets is the waveform resolution used when none is requested
+	DefaultPeakBuckets = 800
+
+	// MaxPeakBuckets bounds the waveform resolution a client may request
+	MaxPeakBuckets = 4000
+
+	// Peaks are decoded at a fixed rate so results don't depend on the source format
+	peaksSampleRate = 16000
+)
+
+// WaveformService computes and caches waveform peaks for stored audio
+type WaveformService struct {
+	transcoder   *audio.Transcoder
+	cache        *cache.Cache
+	audioService *AudioService // Reads audio kept in the blob store
+}
+
+// NewWaveformService creates a new waveform service
+func NewWaveformService(transcoder *audio.Transcoder) *WaveformService {
+	return &WaveformService{
+		transcoder: transcoder,
+		cache:      cache.NewCache(),
+	}
+}
+
+// SetAudioService lets the service read audio kept in the blob store
+func (s *WaveformService) SetAudioService(audioService *AudioService) {
+	s.audioService = audioService
+}
+
+// GetPeaks returns min/max peaks for a chunk, computing them on first request.
+// Chunks are immutable so cached peaks stay valid until the chunk expires.
+func (s *WaveformService) GetPeaks(ctx context.Context, chunk *models.AudioChunk, buckets int) (*audio.Peaks, error) {
+	if buckets <= 0 {
+		buckets = DefaultPeakBuckets
+	}
+	if buckets > MaxPeakBuckets {
+		buckets = MaxPeakBuckets
+	}
+
+	key := fmt.Sprintf("peaks:%d:%d", chunk.ID, buckets)
+	if cached, ok := s.cache.Get(key); ok {
+		return cached.(*audio.Peaks), nil
+	}
+
+	if s.audioService != nil {
+		if err := s.audioService.LoadAudio(ctx, chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	pcm, err := audio.Decode(ctx, s.transcoder, chunk.AudioData, peaksSampleRate, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+
+	peaks := audio.ComputePeaks(pcm, buckets)
+	if ttl := time.Until(chunk.ExpiresAt); ttl > 0 {
+		s.cache.SetWithExpiration(key, peaks, ttl)
+	}
+
+	return peaks, nil
+}
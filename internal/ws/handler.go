@@ -6,11 +6,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"ai-agent-character-demo/backend/pkg/fixtures"
+	"ai-agent-character-demo/backend/pkg/jwt"
+	"ai-agent-character-demo/backend/pkg/prompt"
+	"ai-agent-character-demo/backend/pkg/scene"
 	ws "ai-agent-character-demo/backend/pkg/ws"
 
 	"github.com/gin-gonic/gin"
@@ -29,6 +35,12 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 512 * 1024 // 512KB
+
+	// Number of messages sent in chat_history on connect
+	historyPageSize = 50
+
+	// Number of recent messages sent to the AI alongside a resume summary
+	resumeHistorySize = 20
 )
 
 var upgrader = websocket.Upgrader{
@@ -50,15 +62,14 @@ type Client struct {
 	UserID     string // Optional for authentication
 	messagesMu sync.Mutex
 	messages   []ws.ChatMessage // Store conversation history
+	previously string           // Summary of earlier turns when resuming an old session
 	SessionID  string           // Optional session ID for persistent conversations
 	closed     bool             // Add closed flag
 	mu         sync.Mutex       // Add mutex for closed flag
 }
 
-type Message struct {
-	Type    string      `json:"type"`
-	Content interface{} `json:"content"`
-}
+// Message is the protocol envelope shared with the conversation service
+type Message = ws.Envelope
 
 // CharacterService defines the interface for character operations
 type CharacterService interface {
@@ -76,24 +87,63 @@ type AIService interface {
 type MessageService interface {
 	SaveMessage(characterID uint, sessionID string, message *ws.ChatMessage) error
 	GetSessionMessages(characterID uint, sessionID string) ([]ws.ChatMessage, error)
+	GetHistoryPage(characterID uint, sessionID string, before string, limit int) (*ws.HistoryPage, error)
 }
 
 // AudioService interface for audio storage
 type AudioService interface {
 	StoreAudioChunk(userID string, sessionID string, charID uint, audioData []byte, format string, duration float64, sampleRate int, channels int, metadata string, ttl time.Duration) (string, error)
+	StoreCharacterAudio(userID string, sessionID string, charID uint, messageID string, audioData []byte, format string, voiceType string, ttl time.Duration) (string, error)
+	LinkAudioChunkToMessage(chunkID string, messageID string) error
+	GetTranscript(chunkID string) (string, bool)
+	SaveTranscript(chunkID string, transcript string) error
+}
+
+// ConversationService resolves and authorizes the conversation behind a session
+type ConversationService interface {
+	AuthorizeSession(sessionID string, characterID uint, userID *uint) (bool, error)
+	ResumeSummary(sessionID string) (string, error)
+}
+
+// SceneService describes the scene behind a session: its speaker routing and
+// cast in speaking order, or an empty routing for one-on-one sessions. A
+// ConversationService that implements it enables multi-character scenes.
+type SceneService interface {
+	SceneCast(sessionID string) (string, []uint, error)
+}
+
+// CharacterResolver looks up seeded characters by their fixture slug
+type CharacterResolver interface {
+	CharacterIDBySlug(slug string) (uint, error)
+}
+
+// KnowledgeService adds the character knowledge relevant to a user turn to
+// the history sent to the AI
+type KnowledgeService interface {
+	Augment(ctx context.Context, characterID uint, query string, history []ws.ChatMessage) ([]ws.ChatMessage, []ws.Citation)
+}
+
+// PromptService renders the prompt template bound to a character into the
+// system prompt of a reply
+type PromptService interface {
+	Apply(ctx context.Context, character *ws.Character, sessionID string)
 }
 
 type Hub struct {
-	clients          map[*Client]bool
-	broadcast        chan []byte
-	register         chan *Client
-	unregister       chan *Client
-	characterService CharacterService
-	aiService        AIService
-	messageService   MessageService
-	audioService     interface{}
-	mu               sync.Mutex
-	undelivered      map[string][]Message // Buffer for undelivered messages
+	clients             map[*Client]bool
+	broadcast           chan []byte
+	register            chan *Client
+	unregister          chan *Client
+	characterService    CharacterService
+	aiService           AIService
+	messageService      MessageService
+	audioService        interface{}
+	conversationService ConversationService
+	knowledgeService    KnowledgeService
+	promptService       PromptService
+	jwtService          *jwt.Service
+	mu                  sync.Mutex
+	undelivered         map[string][]Message // Buffer for undelivered messages
 }
 
 func NewHub(characterService CharacterService, aiService AIService, messageService MessageService) *Hub {
@@ -115,6 +165,27 @@ func (h *Hub) SetAudioService(audioService interface{}) {
 	h.audioService = audioService
 }
 
+// SetConversationService sets the service that authorizes session access
+func (h *Hub) SetConversationService(conversationService ConversationService) {
+	h.conversationService = conversationService
+}
+
+// SetKnowledgeService enables retrieval of character knowledge into replies
+func (h *Hub) SetKnowledgeService(knowledgeService KnowledgeService) {
+	h.knowledgeService = knowledgeService
+}
+
+// SetPromptService enables prompt templates for replies
+func (h *Hub) SetPromptService(promptService PromptService) {
+	h.promptService = promptService
+}
+
+// SetJWTService enables token authentication for WebSocket connections.
+// Connections without a token remain anonymous.
+func (h *Hub) SetJWTService(jwtService *jwt.Service) {
+	h.jwtService = jwtService
+}
+
 // GetActiveConnections returns the number of active WebSocket connections
 func (h *Hub) GetActiveConnections() []string {
 	h.mu.Lock()
@@ -224,42 +295,54 @@ func (c *Client) handleMessage(message Message) {
 	}()
 
 	switch message.Type {
-	case "chat":
+	case ws.TypeChat:
 		c.handleChatMessage(message)
 	case "audio":
 		c.handleAudioMessage(message)
-	case "ping":
+	case ws.TypePing:
 		// Handle ping messages
-		c.sendMessage("pong", nil)
+		c.sendMessage(ws.TypePong, nil)
 	case "start_stream":
 		c.handleStartStreamMessage(message)
 	case "stream_config":
 		c.handleStreamConfigMessage(message)
+	case ws.TypeLoadMore:
+		c.handleLoadMoreMessage(message)
 	default:
 		log.Printf("Unknown message type: %s", message.Type)
 		c.sendErrorMessage(fmt.Sprintf("Unknown message type: %s", message.Type))
 	}
 }
 
-// Add detailed logging for WebSocket message handling
-func (c *Client) handleChatMessage(message Message) {
-	log.Printf("Received chat message: %+v", message)
+// handleLoadMoreMessage sends the page of history before the given cursor
+func (c *Client) handleLoadMoreMessage(message Message) {
+	if c.SessionID == "" {
+		c.sendErrorMessage("load_more requires a session")
+		return
+	}
 
-	var chatContent struct {
-		ID        string `json:"id"`
-		Sender    string `json:"sender"`
-		Content   string `json:"content"`
-		Timestamp int64  `json:"timestamp"`
+	var request ws.LoadMoreRequest
+	if err := ws.DecodeContent(message.Content, &request); err != nil || request.Before == "" {
+		c.sendErrorMessage("load_more requires a before cursor")
+		return
 	}
 
-	contentBytes, err := json.Marshal(message.Content)
+	page, err := c.Hub.messageService.GetHistoryPage(c.CharID, c.SessionID, request.Before, request.Limit)
 	if err != nil {
-		log.Printf("Error marshaling content: %v", err)
-		c.sendErrorMessage("Error processing message content")
+		log.Printf("Error loading history page for session %s: %v", c.SessionID, err)
+		c.sendErrorMessage(fmt.Sprintf("Failed to load history: %v", err))
 		return
 	}
 
-	if err := json.Unmarshal(contentBytes, &chatContent); err != nil {
+	c.sendMessage(ws.TypeHistoryPage, page)
+}
+
+// Add detailed logging for WebSocket message handling
+func (c *Client) handleChatMessage(message Message) {
+	log.Printf("Received chat message: %+v", message)
+
+	var chatContent ws.ChatRequest
+	if err := ws.DecodeContent(message.Content, &chatContent); err != nil {
 		log.Printf("Error unmarshaling chat content: %v", err)
 		c.sendErrorMessage("Invalid chat message format")
 		return
@@ -294,7 +377,6 @@ func (c *Client) handleChatMessage(message Message) {
 
 	c.messagesMu.Lock()
 	c.messages = append(c.messages, userMessage)
-	messages := c.messages
 	c.messagesMu.Unlock()
 
 	if c.SessionID != "" {
@@ -303,24 +385,24 @@ func (c *Client) handleChatMessage(message Message) {
 			log.Printf("Error saving message to database: %v", err)
 		}
 	}
+	messages := c.syncHistory()
 
-	c.sendMessage("ack", map[string]string{
-		"messageId": userMessage.ID,
-		"status":    "received",
-	})
+	c.sendMessage(ws.TypeAck, ws.Ack{MessageID: userMessage.ID, Status: "received"})
 
 	log.Printf("Acknowledged user message: %s", userMessage.ID)
 
 	go func() {
-		// Get character first
-		character, err := c.Hub.characterService.GetCharacter(c.CharID, c.UserID)
+		// Pick the character who answers first
+		character, history, err := c.speaker(chatContent.Content, c.aiContext(messages))
 		if err != nil {
 			log.Printf("Error fetching character: %v", err)
 			c.sendErrorMessage("Failed to fetch character information")
 			return
 		}
 
-		aiResponse, err := c.Hub.aiService.GenerateResponse(character, chatContent.Content, messages)
+		c.withPrompt(character)
+		history, citations := c.withKnowledge(character.ID, chatContent.Content, history)
+		aiResponse, err := c.Hub.aiService.GenerateResponse(character, chatContent.Content, history)
 		if err != nil {
 			log.Printf("Error generating AI response: %v", err)
 			c.sendErrorMessage("Failed to generate response from the AI character")
@@ -330,22 +412,31 @@ func (c *Client) handleChatMessage(message Message) {
 		log.Printf("Generated AI response: %s", aiResponse)
 
 		characterMessage := ws.ChatMessage{
-			ID:        fmt.Sprintf("resp-%d", time.Now().UnixNano()),
-			Sender:    "character",
-			Content:   aiResponse,
-			Timestamp: time.Now(),
+			ID:            fmt.Sprintf("resp-%d", time.Now().UnixNano()),
+			Sender:        "character",
+			CharacterID:   character.ID,
+			Content:       aiResponse,
+			Timestamp:     time.Now(),
+			Citations:     citations,
+			PromptVersion: character.PromptVersion,
 		}
 
 		c.messagesMu.Lock()
 		c.messages = append(c.messages, characterMessage)
 		c.messagesMu.Unlock()
 
-		sent := c.sendMessageWithAck("chat", characterMessage)
+		if c.SessionID != "" {
+			if err := c.Hub.messageService.SaveMessage(character.ID, c.SessionID, &characterMessage); err != nil {
+				log.Printf("Error saving character message to database: %v", err)
+			}
+		}
+
+		sent := c.sendMessageWithAck(ws.TypeChat, characterMessage)
 		if !sent {
 			// Buffer the message for later delivery
 			bufferKey := c.SessionID + ":" + c.ID
 			c.Hub.mu.Lock()
-			c.Hub.undelivered[bufferKey] = append(c.Hub.undelivered[bufferKey], Message{Type: "chat", Content: characterMessage})
+			c.Hub.undelivered[bufferKey] = append(c.Hub.undelivered[bufferKey], Message{Type: ws.TypeChat, Content: characterMessage})
 			c.Hub.mu.Unlock()
 			log.Printf("[BUFFER] Buffered undelivered message for %s", bufferKey)
 		}
@@ -416,10 +507,7 @@ func (c *Client) handleAudioMessage(message Message) {
 	}
 
 	// Acknowledge receipt of audio chunk
-	c.sendMessage("ack", map[string]string{
-		"messageId": chunkID,
-		"status":    "received",
-	})
+	c.sendMessage(ws.TypeAck, ws.Ack{MessageID: chunkID, Status: "received"})
 
 	// Store audio chunk for ML processing (if audio service is available)
 	var storedChunkId string
@@ -460,45 +548,32 @@ func (c *Client) handleAudioMessage(message Message) {
 		log.Printf("Warning: Hub.audioService is nil, audio chunk will not be stored")
 	}
 
-	// Process speech to text with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Use a channel to handle the STT response with timeout
-	type sttResult struct {
-		transcript string
-		aiResponse string
-		err        error
-	}
-	resultChan := make(chan sttResult, 1)
-
-	// IMPORTANT: Use the client's session ID for STT processing, not a random one
-	log.Printf("Using session ID %s for STT processing", c.SessionID)
-
-	go func() {
-		transcript, aiResponse, sttErr := c.Hub.aiService.SpeechToText(ctx, c.SessionID, audioData)
-		resultChan <- sttResult{transcript: transcript, aiResponse: aiResponse, err: sttErr}
-	}()
-
-	// Wait for response or timeout
+	// Identical audio that was already transcribed skips the STT call
 	var transcript string
 	var aiResponse string
-	select {
-	case <-ctx.Done():
-		log.Printf("Speech-to-text processing timed out for client %s", c.ID)
-		c.sendErrorMessage("Speech processing timed out")
-		return
-	case result := <-resultChan:
-		if result.err != nil {
-			log.Printf("Error converting speech to text: %v", result.err)
-			c.sendErrorMessage("Failed to process speech")
+	if storedChunkId != "" {
+		if audioService, ok := c.Hub.audioService.(AudioService); ok {
+			if cached, found := audioService.GetTranscript(storedChunkId); found {
+				log.Printf("Reusing cached transcript for audio chunk %s", storedChunkId)
+				transcript = cached
+			}
+		}
+	}
+
+	if transcript == "" {
+		var ok bool
+		transcript, aiResponse, ok = c.transcribe(audioData)
+		if !ok {
 			return
 		}
-		transcript = result.transcript
-		aiResponse = result.aiResponse
-		log.Printf("Got transcript: '%s' and AI response: '%s'",
-			transcript,
-			aiResponse[:min(50, len(aiResponse))])
+
+		if transcript != "" && storedChunkId != "" {
+			if audioService, ok := c.Hub.audioService.(AudioService); ok {
+				if saveErr := audioService.SaveTranscript(storedChunkId, transcript); saveErr != nil {
+					log.Printf("Error caching transcript for audio chunk %s: %v", storedChunkId, saveErr)
+				}
+			}
+		}
 	}
 
 	// If transcript is empty, notify the user but don't proceed further
@@ -538,25 +613,35 @@ func (c *Client) handleAudioMessage(message Message) {
 			// Continue processing even if save fails
 		}
 	}
+	history := c.syncHistory()
+
+	// Link the stored recording to the transcribed message so it can be replayed
+	if storedChunkId != "" {
+		if audioService, ok := c.Hub.audioService.(AudioService); ok {
+			if linkErr := audioService.LinkAudioChunkToMessage(storedChunkId, userMessage.ID); linkErr != nil {
+				log.Printf("Error linking audio chunk %s to message %s: %v", storedChunkId, userMessage.ID, linkErr)
+			}
+		}
+	}
 
 	// Acknowledge receipt of message
-	c.sendMessage("ack", map[string]string{
-		"messageId": userMessage.ID,
-		"status":    "received",
-	})
+	c.sendMessage(ws.TypeAck, ws.Ack{MessageID: userMessage.ID, Status: "received"})
 
 	// Notify client that character is typing
-	c.sendMessage("typing", map[string]interface{}{
-		"is_typing": true,
-	})
+	c.sendMessage(ws.TypeTyping, ws.Typing{IsTyping: true})
 
 	// Here's the key change: If we have an AI response from LLM_Layer, use that
 	// Otherwise fallback to generating a response with the internal AI service
 	var characterResponse string
+	var citations []ws.Citation
+	var promptVersion string
 	var audioResponse []byte
+	voiceType := "default"
+	speakerID := c.CharID
 
-	// Check if we have a valid AI response from our LLM_Layer
-	if aiResponse != "" {
+	// Check if we have a valid AI response from our LLM_Layer. It answers as
+	// the session's character, so scenes route the turn themselves.
+	if aiResponse != "" && !c.inScene() {
 		log.Printf("Using AI response from LLM_Layer for client %s", c.ID)
 		characterResponse = aiResponse
 
@@ -565,19 +650,20 @@ func (c *Client) handleAudioMessage(message Message) {
 		defer audioCancel()
 
 		var ttsErr error
-		audioResponse, ttsErr = c.Hub.aiService.TextToSpeech(audioCtx, characterResponse, "default")
+		audioResponse, ttsErr = c.Hub.aiService.TextToSpeech(audioCtx, characterResponse, voiceType)
 		if ttsErr != nil {
 			log.Printf("Error generating speech for LLM response: %v", ttsErr)
 			// Continue without audio
 		}
 	} else {
-		// Fetch character data
-		character, charErr := c.Hub.characterService.GetCharacter(c.CharID, c.UserID)
+		// Pick the character who answers
+		character, aiHistory, charErr := c.speaker(transcript, c.aiContext(history))
 		if charErr != nil {
 			log.Printf("Error fetching character: %v", charErr)
 			c.sendErrorMessage("Failed to fetch character information")
 			return
 		}
+		speakerID = character.ID
 
 		// Generate AI response with timeout
 		aiCtx, aiCancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -590,8 +676,12 @@ func (c *Client) handleAudioMessage(message Message) {
 		}
 		aiResultChan := make(chan responseResult, 1)
 
+		c.withPrompt(character)
+		promptVersion = character.PromptVersion
+
 		go func() {
-			resp, respErr := c.Hub.aiService.GenerateResponse(character, transcript, c.messages)
+			aiHistory, citations = c.withKnowledge(character.ID, transcript, aiHistory)
+			resp, respErr := c.Hub.aiService.GenerateResponse(character, transcript, aiHistory)
 			aiResultChan <- responseResult{response: resp, err: respErr}
 		}()
 
@@ -614,8 +704,10 @@ func (c *Client) handleAudioMessage(message Message) {
 		audioCtx, audioCancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer audioCancel()
 
+		voiceType = character.VoiceType
+
 		var ttsErr error
-		audioResponse, ttsErr = c.Hub.aiService.TextToSpeech(audioCtx, characterResponse, character.VoiceType)
+		audioResponse, ttsErr = c.Hub.aiService.TextToSpeech(audioCtx, characterResponse, voiceType)
 		if ttsErr != nil {
 			log.Printf("Error generating speech: %v", ttsErr)
 			// Continue without audio
@@ -624,10 +716,18 @@ func (c *Client) handleAudioMessage(message Message) {
 
 	// Create the character's response message
 	characterMessage := ws.ChatMessage{
-		ID:        fmt.Sprintf("resp-%d", time.Now().UnixNano()),
-		Sender:    "character",
-		Content:   characterResponse,
-		Timestamp: time.Now(),
+		ID:            fmt.Sprintf("resp-%d", time.Now().UnixNano()),
+		Sender:        "character",
+		CharacterID:   speakerID,
+		Content:       characterResponse,
+		Timestamp:     time.Now(),
+		Citations:     citations,
+		PromptVersion: promptVersion,
+	}
+
+	// Persist the synthesized voice so the reply can be replayed from history
+	if audioResponse != nil {
+		c.storeCharacterAudio(&characterMessage, audioResponse, voiceType)
 	}
 
 	// Store the character message in conversation history
@@ -637,7 +737,7 @@ func (c *Client) handleAudioMessage(message Message) {
 
 	// Save the character's response to persistent storage
 	if c.SessionID != "" {
-		saveErr := c.Hub.messageService.SaveMessage(c.CharID, c.SessionID, &characterMessage)
+		saveErr := c.Hub.messageService.SaveMessage(speakerID, c.SessionID, &characterMessage)
 		if saveErr != nil {
 			log.Printf("Error saving character message to database: %v", saveErr)
 			// Continue even if save fails
@@ -645,7 +745,7 @@ func (c *Client) handleAudioMessage(message Message) {
 	}
 
 	// Send the character's response
-	c.sendMessage("chat", characterMessage)
+	c.sendMessage(ws.TypeChat, characterMessage)
 
 	// Send audio if we have it
 	if audioResponse != nil {
@@ -653,10 +753,262 @@ func (c *Client) handleAudioMessage(message Message) {
 		c.sendMessage("audio", map[string]interface{}{
 			"data":      audioResponse,
 			"messageId": characterMessage.ID,
+			"audio_url": characterMessage.AudioURL,
 		})
 	}
 }
 
+// aiContext trims the history to recent turns and prepends the conversation
+// summary when the session was resumed after a break
+func (c *Client) aiContext(history []ws.ChatMessage) []ws.ChatMessage {
+	if c.previously == "" {
+		return history
+	}
+	if len(history) > resumeHistorySize {
+		history = history[len(history)-resumeHistorySize:]
+	}
+
+	withSummary := make([]ws.ChatMessage, 0, len(history)+1)
+	withSummary = append(withSummary, ws.ChatMessage{
+		Sender:  ws.SenderSystem,
+		Content: "Previously in this conversation: " + c.previously,
+	})
+	return append(withSummary, history...)
+}
+
+// greet opens a new conversation with the character's greeting, or one of
+// its alternates, spoken in the character's voice when speech is available.
+// Scenes are opened by the first character of the cast.
+func (c *Client) greet() {
+	var character *ws.Character
+	if _, cast := c.sceneCast(); len(cast) > 0 {
+		character = cast[0]
+	} else {
+		var err error
+		if character, err = c.Hub.characterService.GetCharacter(c.CharID, c.UserID); err != nil {
+			log.Printf("Error fetching character for greeting: %v", err)
+			return
+		}
+	}
+	greeting := prompt.Greeting(character, "", rand.Intn)
+	if greeting == "" {
+		return
+	}
+
+	greetingMessage := ws.ChatMessage{
+		ID:          fmt.Sprintf("resp-%d", time.Now().UnixNano()),
+		Sender:      "character",
+		CharacterID: character.ID,
+		Content:     greeting,
+		Timestamp:   time.Now(),
+	}
+
+	// Store the greeting before speaking it so it stays the first message
+	c.messagesMu.Lock()
+	c.messages = append(c.messages, greetingMessage)
+	c.messagesMu.Unlock()
+	if c.SessionID != "" {
+		if err := c.Hub.messageService.SaveMessage(character.ID, c.SessionID, &greetingMessage); err != nil {
+			log.Printf("Error saving greeting to database: %v", err)
+		}
+	}
+
+	audioCtx, audioCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer audioCancel()
+	audioResponse, err := c.Hub.aiService.TextToSpeech(audioCtx, greeting, character.VoiceType)
+	if err != nil {
+		log.Printf("Error generating speech for greeting: %v", err)
+		audioResponse = nil
+	}
+	if audioResponse != nil {
+		c.storeCharacterAudio(&greetingMessage, audioResponse, character.VoiceType)
+	}
+
+	c.sendMessage(ws.TypeChat, greetingMessage)
+	if audioResponse != nil {
+		c.sendMessage("audio", map[string]interface{}{
+			"data":      audioResponse,
+			"messageId": greetingMessage.ID,
+			"audio_url": greetingMessage.AudioURL,
+		})
+	}
+}
+
+// withKnowledge adds the speaking character's knowledge passages relevant to
+// the user turn, returning the citations to attach to the reply
+func (c *Client) withKnowledge(characterID uint, query string, history []ws.ChatMessage) ([]ws.ChatMessage, []ws.Citation) {
+	if c.Hub.knowledgeService == nil {
+		return history, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return c.Hub.knowledgeService.Augment(ctx, characterID, query, history)
+}
+
+// speaker picks the character who answers a user turn and the history it
+// answers from. One-on-one sessions are answered by the session's character.
+// Scenes route the turn to one of their cast, which sees the others' lines
+// labelled and its relationships to them.
+func (c *Client) speaker(text string, history []ws.ChatMessage) (*ws.Character, []ws.ChatMessage, error) {
+	routing, cast := c.sceneCast()
+	if len(cast) < scene.MinCast {
+		character, err := c.Hub.characterService.GetCharacter(c.CharID, c.UserID)
+		return character, history, err
+	}
+
+	character := scene.Route(routing, cast, history, text, func() (string, error) {
+		recent := history
+		if len(recent) > resumeHistorySize {
+			recent = recent[len(recent)-resumeHistorySize:]
+		}
+		return c.Hub.aiService.GenerateResponse(scene.Director(cast), text, scene.Label(cast, recent, 0))
+	})
+	log.Printf("Scene session %s routed turn to character %d (%s)", c.SessionID, character.ID, routing)
+	return character, scene.Transcript(character, cast, history), nil
+}
+
+// sceneCast loads the routing and characters of the session's scene. The
+// cast is empty when the session is not a scene. Characters that can no
+// longer be loaded leave the cast.
+func (c *Client) sceneCast() (string, []*ws.Character) {
+	scenes, ok := c.Hub.conversationService.(SceneService)
+	if !ok || c.SessionID == "" {
+		return "", nil
+	}
+	routing, ids, err := scenes.SceneCast(c.SessionID)
+	if err != nil {
+		log.Printf("Error loading scene for session %s: %v", c.SessionID, err)
+		return "", nil
+	}
+
+	var cast []*ws.Character
+	for _, id := range ids {
+		character, err := c.Hub.characterService.GetCharacter(id, c.UserID)
+		if err != nil {
+			log.Printf("Leaving character %d out of scene %s: %v", id, c.SessionID, err)
+			continue
+		}
+		cast = append(cast, character)
+	}
+	return routing, cast
+}
+
+// inScene reports whether the session is a scene
+func (c *Client) inScene() bool {
+	scenes, ok := c.Hub.conversationService.(SceneService)
+	if !ok || c.SessionID == "" {
+		return false
+	}
+	routing, _, err := scenes.SceneCast(c.SessionID)
+	return err == nil && routing != ""
+}
+
+// withPrompt renders the character's prompt template for this session
+func (c *Client) withPrompt(character *ws.Character) {
+	if c.Hub.promptService == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c.Hub.promptService.Apply(ctx, character, c.SessionID)
+}
+
+// syncHistory reloads the session's active branch from storage so replies
+// follow edits and regenerations made through the HTTP API. The in-memory
+// history is kept when the session cannot be loaded.
+func (c *Client) syncHistory() []ws.ChatMessage {
+	if c.SessionID != "" {
+		history, err := c.Hub.messageService.GetSessionMessages(c.CharID, c.SessionID)
+		if err != nil {
+			log.Printf("Error reloading history for session %s: %v", c.SessionID, err)
+		} else if len(history) > 0 {
+			c.messagesMu.Lock()
+			c.messages = history
+			c.messagesMu.Unlock()
+		}
+	}
+
+	c.messagesMu.Lock()
+	defer c.messagesMu.Unlock()
+	return c.messages
+}
+
+// transcribe runs speech-to-text for the client's session. It reports the
+// error to the client and returns ok=false on failure.
+func (c *Client) transcribe(audioData []byte) (transcript string, aiResponse string, ok bool) {
+	// Process speech to text with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Use a channel to handle the STT response with timeout
+	type sttResult struct {
+		transcript string
+		aiResponse string
+		err        error
+	}
+	resultChan := make(chan sttResult, 1)
+
+	// IMPORTANT: Use the client's session ID for STT processing, not a random one
+	log.Printf("Using session ID %s for STT processing", c.SessionID)
+
+	go func() {
+		transcript, aiResponse, sttErr := c.Hub.aiService.SpeechToText(ctx, c.SessionID, audioData)
+		resultChan <- sttResult{transcript: transcript, aiResponse: aiResponse, err: sttErr}
+	}()
+
+	// Wait for response or timeout
+	select {
+	case <-ctx.Done():
+		log.Printf("Speech-to-text processing timed out for client %s", c.ID)
+		c.sendErrorMessage("Speech processing timed out")
+		return "", "", false
+	case result := <-resultChan:
+		if result.err != nil {
+			log.Printf("Error converting speech to text: %v", result.err)
+			c.sendErrorMessage("Failed to process speech")
+			return "", "", false
+		}
+		log.Printf("Got transcript: '%s' and AI response: '%s'",
+			result.transcript,
+			result.aiResponse[:min(50, len(result.aiResponse))])
+		return result.transcript, result.aiResponse, true
+	}
+}
+
+// storeCharacterAudio saves TTS output for a character message and sets its replay URL
+func (c *Client) storeCharacterAudio(message *ws.ChatMessage, audioData []byte, voiceType string) {
+	if c.SessionID == "" || c.Hub.audioService == nil {
+		return
+	}
+	audioService, ok := c.Hub.audioService.(AudioService)
+	if !ok {
+		log.Printf("Error: audioService could not be cast to AudioService interface")
+		return
+	}
+
+	characterID := message.CharacterID
+	if characterID == 0 {
+		characterID = c.CharID
+	}
+	chunkID, err := audioService.StoreCharacterAudio(
+		c.UserID,
+		c.SessionID,
+		characterID,
+		message.ID,
+		audioData,
+		"mp3",
+		voiceType,
+		0, // Use the service's default TTL
+	)
+	if err != nil {
+		log.Printf("Error storing character audio for message %s: %v", message.ID, err)
+		return
+	}
+
+	message.AudioURL = ws.MessageAudioURL(message.ID)
+	log.Printf("Stored character audio %s for message %s", chunkID, message.ID)
+}
+
 func (c *Client) handleStartStreamMessage(message Message) {
 	log.Printf("Handling start_stream message from client %s", c.ID)
 
@@ -791,7 +1143,7 @@ func (c *Client) sendMessage(messageType string, content interface{}) {
 	}
 
 	// Only log non-ping/pong messages to reduce log noise
-	if messageType != "ping" && messageType != "pong" {
+	if messageType != ws.TypePing && messageType != ws.TypePong {
 		logPreview := string(messageJSON)
 		if len(logPreview) > 100 {
 			logPreview = logPreview[:100] + "..."
@@ -828,9 +1180,7 @@ func min(a, b int) int {
 }
 
 func (c *Client) sendErrorMessage(errorText string) {
-	c.sendMessage("error", map[string]string{
-		"message": errorText,
-	})
+	c.sendMessage(ws.TypeError, ws.Error{Message: errorText})
 }
 
 // WritePump pumps messages from the hub to the websocket connection.
@@ -867,6 +1217,32 @@ func (c *Client) WritePump() {
 	}
 }
 
+// resolveCharacterID turns the characterId query parameter into an ID. It
+// accepts a numeric ID or a seeded character's slug; anything else falls
+// back to the default seeded character, or to ID 1 when slugs cannot be
+// resolved.
+func (h *Hub) resolveCharacterID(ref string) uint {
+	if id, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		return uint(id)
+	}
+
+	resolver, ok := h.characterService.(CharacterResolver)
+	if !ok {
+		log.Printf("Non-numeric character ID '%s', using fallback character 1", ref)
+		return 1
+	}
+	if id, err := resolver.CharacterIDBySlug(ref); err == nil {
+		return id
+	}
+	id, err := resolver.CharacterIDBySlug(fixtures.DefaultCharacterSlug)
+	if err != nil {
+		log.Printf("Default character '%s' is not seeded (%v), using fallback character 1", fixtures.DefaultCharacterSlug, err)
+		return 1
+	}
+	log.Printf("Unknown character '%s', using default character '%s'", ref, fixtures.DefaultCharacterSlug)
+	return id
+}
+
 // Improve logging for WebSocket connections
 func ServeWs(hub *Hub, c *gin.Context) {
 	// Validate input parameters
@@ -891,13 +1267,33 @@ func ServeWs(hub *Hub, c *gin.Context) {
 		sessionID = fmt.Sprintf("session-%s-%s-%d", charID, clientID, time.Now().Unix())
 	}
 
-	// Try to parse character ID as uint, fallback to 1 (Elon Musk) if not numeric
-	var charIDUint uint64
-	var err error
-	charIDUint, err = strconv.ParseUint(charID, 10, 64)
-	if err != nil {
-		log.Printf("Non-numeric character ID '%s', using fallback character 1 (Elon Musk)", charID)
-		charIDUint = 1
+	charIDUint := uint64(hub.resolveCharacterID(charID))
+
+	// Authenticate before upgrading so rejected clients get a plain HTTP status
+	var userID *uint
+	if hub.jwtService != nil {
+		if token := wsToken(c); token != "" {
+			claims, err := hub.jwtService.ValidateToken(token)
+			if err != nil {
+				log.Printf("Rejecting WebSocket connection with invalid token: %v", err)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				return
+			}
+			userID = &claims.UserID
+		}
+	}
+
+	if hub.conversationService != nil {
+		allowed, err := hub.conversationService.AuthorizeSession(sessionID, uint(charIDUint), userID)
+		if err != nil {
+			log.Printf("Error resolving conversation for session %s: %v", sessionID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open conversation"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this conversation"})
+			return
+		}
 	}
 
 	// Upgrade the connection
@@ -923,8 +1319,22 @@ func ServeWs(hub *Hub, c *gin.Context) {
 		SessionID: sessionID,
 		messages:  []ws.ChatMessage{},
 	}
+	if userID != nil {
+		client.UserID = strconv.FormatUint(uint64(*userID), 10)
+	}
+
+	// Sessions resumed after a break carry their summary into the AI context
+	if sessionID != "" && hub.conversationService != nil {
+		summary, err := hub.conversationService.ResumeSummary(sessionID)
+		if err != nil {
+			log.Printf("Error loading summary for session %s: %v", sessionID, err)
+		}
+		client.previously = summary
+	}
 
-	// Load previous messages for this session if it exists
+	// Load previous messages for this session if it exists; a new
+	// conversation opens with the character's greeting
+	greet := false
 	if sessionID != "" {
 		previousMessages, err := hub.messageService.GetSessionMessages(client.CharID, sessionID)
 		if err != nil {
@@ -933,18 +1343,23 @@ func ServeWs(hub *Hub, c *gin.Context) {
 			client.messages = previousMessages
 			log.Printf("Loaded %d previous messages for session %s", len(previousMessages), sessionID)
 
-			// Send the chat history to the client
-			client.sendMessage("chat_history", map[string]interface{}{
-				"messages": previousMessages,
-			})
+			// Send only the latest page; older messages are fetched with load_more
+			history, err := hub.messageService.GetHistoryPage(client.CharID, sessionID, "", historyPageSize)
+			if err != nil {
+				log.Printf("Error loading history page: %v", err)
+				history = &ws.HistoryPage{Messages: previousMessages}
+			}
+			client.sendMessage(ws.TypeChatHistory, history)
 			log.Printf("[DEBUG] Sent chat history to client %s, session %s. About to start ReadPump/WritePump.", client.ID, client.SessionID)
+		} else {
+			greet = true
 		}
 	}
 
 	client.Hub.register <- client
 
 	// After registering client and before starting ReadPump/WritePump:
-	connectedMsg := map[string]interface{}{"type": "connected"}
+	connectedMsg := map[string]interface{}{"type": ws.TypeConnected}
 	msgBytes, _ := json.Marshal(connectedMsg)
 	select {
 	case client.Send <- msgBytes:
@@ -964,6 +1379,10 @@ func ServeWs(hub *Hub, c *gin.Context) {
 	}
 	hub.mu.Unlock()
 
+	if greet {
+		go client.greet()
+	}
+
 	// Start the client's message pumps with panic recovery
 	go func() {
 		defer func() {
@@ -1016,3 +1435,12 @@ func (c *Client) sendMessageWithAck(messageType string, content interface{}) boo
 		return false
 	}
 }
+
+// wsToken reads a bearer token from the query string or Authorization header.
+// Browsers cannot set headers on WebSocket requests, so the query is checked first.
+func wsToken(c *gin.Context) string {
+	if token := c.Query("token"); token != "" {
+		return token
+	}
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
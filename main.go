@@ -121,6 +121,8 @@ func main() {
 	messageService := service.NewMessageService(db)
 	messageHandler := api.NewMessageController(messageService, characterHandler, aiServiceAdapter, jwtService)
 	audioHandler := api.NewAudioController(audioService, jwtService)
+	audioHandler.SetMessageAudioService(service.NewMessageAudioService(db, audioService, characterService, aiServiceAdapter.TextToSpeech))
+	audioHandler.SetConversationService(service.NewConversationService(db))
 	userController := api.NewUserController(db)
 
 	// Set up /api legacy routes for frontend compatibility
@@ -188,6 +190,7 @@ func main() {
 		// Audio (protected)
 		apiLegacy.POST("/audio/upload", legacyJWT, audioHandler.UploadAudio)
 		apiLegacy.GET("/audio/session/:sessionId", legacyJWT, audioHandler.GetSessionAudio)
+		apiLegacy.GET("/audio/messages/:messageId", legacyJWT, audioHandler.GetMessageAudio)
 
 		// Forgot Password (stub)
 		apiLegacy.POST("/forgot-password", func(c *gin.Context) {
@@ -394,12 +397,27 @@ func setupDatabase() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// Inbound audio is unique per (session, content); merge duplicates before the index is created
+	if err := service.PrepareAudioSchema(db); err != nil {
+		return nil, fmt.Errorf("failed to prepare audio tables: %w", err)
+	}
+
 	// Run migrations
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Character{},
+		&models.CharacterVersion{},
+		&models.CharacterCategory{},
+		&models.Conversation{},
+		&models.ConversationParticipant{},
 		&models.AudioChunk{},
+		&models.AudioMessageLink{},
 		&models.Message{},
+		&models.KnowledgeDocument{},
+		&models.KnowledgeChunk{},
+		&models.PromptTemplate{},
+		&models.Voice{},
+		&models.SeedRecord{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
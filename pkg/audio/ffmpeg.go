@@ -0,0 +1,135 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// ErrTranscoderUnavailable is returned when a conversion needs ffmpeg but none is installed
+var ErrTranscoderUnavailable = errors.New("ffmpeg is not available")
+
+// Transcoder shells out to ffmpeg for formats that cannot be handled in Go
+type Transcoder struct {
+	path string
+}
+
+// NewTranscoder locates ffmpeg via FFMPEG_PATH or the PATH. The returned
+// transcoder is always usable; Available reports whether conversions will work.
+func NewTranscoder() *Transcoder {
+	path := os.Getenv("FFMPEG_PATH")
+	if path == "" {
+		path, _ = exec.LookPath("ffmpeg")
+	}
+	return &Transcoder{path: path}
+}
+
+// Available reports whether an ffmpeg binary was found
+func (t *Transcoder) Available() bool {
+	return t != nil && t.path != ""
+}
+
+// Decode converts encoded audio (webm, mp3, ogg, ...) to PCM in the given format
+func (t *Transcoder) Decode(ctx context.Context, data []byte, sampleRate, channels int) (*PCM, error) {
+	if !t.Available() {
+		return nil, ErrTranscoderUnavailable
+	}
+
+	out, err := t.run(ctx, data,
+		"-i", "pipe:0",
+		"-f", "s16le",
+		"-acodec", "pcm_s16le",
+		"-ar", strconv.Itoa(sampleRate),
+		"-ac", strconv.Itoa(channels),
+		"pipe:1",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]int16, len(out)/2)
+	if err := binary.Read(bytes.NewReader(out[:len(samples)*2]), binary.LittleEndian, samples); err != nil {
+		return nil, fmt.Errorf("failed to read decoded samples: %w", err)
+	}
+
+	return &PCM{SampleRate: sampleRate, Channels: channels, Samples: samples}, nil
+}
+
+// EncodeOgg encodes PCM as Ogg/Opus
+func (t *Transcoder) EncodeOgg(ctx context.Context, p *PCM) ([]byte, error) {
+	if !t.Available() {
+		return nil, ErrTranscoderUnavailable
+	}
+
+	return t.run(ctx, EncodeWAV(p),
+		"-f", "wav",
+		"-i", "pipe:0",
+		"-c:a", "libopus",
+		"-b:a", "48k",
+		"-f", "ogg",
+		"pipe:1",
+	)
+}
+
+// EncodeOggStream encodes the WAV stream produced by wav as Ogg/Opus into w
+// without holding either file in memory
+func (t *Transcoder) EncodeOggStream(ctx context.Context, w io.Writer, wav func(io.Writer) error) error {
+	if !t.Available() {
+		return ErrTranscoderUnavailable
+	}
+
+	cmd := exec.CommandContext(ctx, t.path, "-hide_banner", "-loglevel", "error",
+		"-f", "wav",
+		"-i", "pipe:0",
+		"-c:a", "libopus",
+		"-b:a", "48k",
+		"-f", "ogg",
+		"pipe:1",
+	)
+	input, output := io.Pipe()
+	cmd.Stdin = input
+	cmd.Stdout = w
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	writeErr := make(chan error, 1)
+	go func() {
+		err := wav(output)
+		output.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	err := cmd.Wait()
+	input.CloseWithError(io.ErrClosedPipe) // Unblocks the writer if ffmpeg stopped reading
+	if werr := <-writeErr; werr != nil && !errors.Is(werr, io.ErrClosedPipe) {
+		return werr
+	}
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+func (t *Transcoder) run(ctx context.Context, input []byte, args ...string) ([]byte, error) {
+	args = append([]string{"-hide_banner", "-loglevel", "error"}, args...)
+	cmd := exec.CommandContext(ctx, t.path, args...)
+	cmd.Stdin = bytes.NewReader(input)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
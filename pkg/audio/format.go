@@ -0,0 +1,39 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ContentType maps a stored format name to its MIME type
+func ContentType(format string) string {
+	switch strings.ToLower(format) {
+	case "mp3", "mpeg":
+		return "audio/mpeg"
+	case "wav", "wave":
+		return "audio/wav"
+	case "m4a", "mp4", "aac":
+		return "audio/mp4"
+	case "":
+		return "application/octet-stream"
+	default:
+		return "audio/" + strings.ToLower(format)
+	}
+}
+
+// Decode returns PCM for stored audio in its native format. Integer PCM WAV is
+// parsed in Go; everything else goes through the transcoder at the given rate.
+func Decode(ctx context.Context, t *Transcoder, data []byte, sampleRate, channels int) (*PCM, error) {
+	if IsWAV(data) {
+		pcm, err := DecodeWAV(data)
+		if err == nil {
+			return pcm, nil
+		}
+		if !errors.Is(err, ErrUnsupportedWAV) {
+			return nil, err
+		}
+	}
+
+	return t.Decode(ctx, data, sampleRate, channels)
+}
@@ -0,0 +1,179 @@
+// Package audio provides PCM helpers for stitching, normalizing and encoding
+// stored conversation audio.
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+// PCM holds interleaved signed 16-bit samples
+type PCM struct {
+	SampleRate int
+	Channels   int
+	Samples    []int16
+}
+
+// Duration returns the playback length of the buffer
+func (p *PCM) Duration() time.Duration {
+	if p == nil || p.SampleRate <= 0 || p.Channels <= 0 {
+		return 0
+	}
+	frames := len(p.Samples) / p.Channels
+	return time.Duration(frames) * time.Second / time.Duration(p.SampleRate)
+}
+
+// Silence returns a buffer of silence with the given format and length
+func Silence(sampleRate, channels int, d time.Duration) *PCM {
+	frames := int(int64(d) * int64(sampleRate) / int64(time.Second))
+	return &PCM{
+		SampleRate: sampleRate,
+		Channels:   channels,
+		Samples:    make([]int16, frames*channels),
+	}
+}
+
+// Convert resamples and remixes a buffer to the given format. Channels are
+// averaged down to mono or duplicated up, and samples are linearly interpolated.
+func Convert(p *PCM, sampleRate, channels int) *PCM {
+	if p.SampleRate == sampleRate && p.Channels == channels {
+		return p
+	}
+
+	// Remix to mono first so resampling only has to handle one channel
+	frames := len(p.Samples) / p.Channels
+	mono := make([]float64, frames)
+	for i := 0; i < frames; i++ {
+		var sum float64
+		for ch := 0; ch < p.Channels; ch++ {
+			sum += float64(p.Samples[i*p.Channels+ch])
+		}
+		mono[i] = sum / float64(p.Channels)
+	}
+
+	outFrames := frames
+	if p.SampleRate != sampleRate && frames > 0 {
+		outFrames = int(int64(frames) * int64(sampleRate) / int64(p.SampleRate))
+	}
+
+	out := &PCM{
+		SampleRate: sampleRate,
+		Channels:   channels,
+		Samples:    make([]int16, outFrames*channels),
+	}
+
+	ratio := float64(p.SampleRate) / float64(sampleRate)
+	for i := 0; i < outFrames; i++ {
+		pos := float64(i) * ratio
+		idx := int(pos)
+		frac := pos - float64(idx)
+
+		sample := mono[min(idx, frames-1)]
+		if idx+1 < frames {
+			sample += (mono[idx+1] - sample) * frac
+		}
+
+		for ch := 0; ch < channels; ch++ {
+			out.Samples[i*channels+ch] = clamp(sample)
+		}
+	}
+
+	return out
+}
+
+// Concat joins buffers that already share the same format
+func Concat(sampleRate, channels int, parts ...*PCM) *PCM {
+	total := 0
+	for _, part := range parts {
+		total += len(part.Samples)
+	}
+
+	out := &PCM{
+		SampleRate: sampleRate,
+		Channels:   channels,
+		Samples:    make([]int16, 0, total),
+	}
+	for _, part := range parts {
+		out.Samples = append(out.Samples, part.Samples...)
+	}
+	return out
+}
+
+// RMS returns the root mean square level of the buffer in dBFS
+func (p *PCM) RMS() float64 {
+	if len(p.Samples) == 0 {
+		return math.Inf(-1)
+	}
+
+	var sum float64
+	for _, s := range p.Samples {
+		v := float64(s) / 32768
+		sum += v * v
+	}
+	return 20 * math.Log10(math.Sqrt(sum/float64(len(p.Samples))))
+}
+
+// Normalize applies a single gain so the buffer's RMS level reaches targetDBFS.
+// The gain is capped so that the loudest sample does not clip.
+func Normalize(p *PCM, targetDBFS float64) {
+	var level Level
+	level.Add(p)
+	Amplify(p, level.Gain(targetDBFS))
+}
+
+// Level measures the loudness of audio that is processed in pieces
+type Level struct {
+	sum     float64
+	samples int
+	peak    float64
+}
+
+// Add measures a buffer
+func (l *Level) Add(p *PCM) {
+	for _, s := range p.Samples {
+		v := float64(s)
+		l.sum += (v / 32768) * (v / 32768)
+		l.peak = math.Max(l.peak, math.Abs(v))
+	}
+	l.samples += len(p.Samples)
+}
+
+// AddSilence counts n silent samples
+func (l *Level) AddSilence(n int) {
+	l.samples += n
+}
+
+// Gain returns the single gain that brings the measured audio to targetDBFS,
+// capped so that the loudest sample does not clip. Silence gets a gain of 1.
+func (l *Level) Gain(targetDBFS float64) float64 {
+	if l.sum == 0 {
+		return 1
+	}
+
+	current := 20 * math.Log10(math.Sqrt(l.sum/float64(l.samples)))
+	gain := math.Pow(10, (targetDBFS-current)/20)
+	if l.peak > 0 {
+		gain = math.Min(gain, 32767/l.peak)
+	}
+	return gain
+}
+
+// Amplify multiplies every sample by gain
+func Amplify(p *PCM, gain float64) {
+	if gain == 1 {
+		return
+	}
+	for i, s := range p.Samples {
+		p.Samples[i] = clamp(float64(s) * gain)
+	}
+}
+
+func clamp(v float64) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
@@ -0,0 +1,47 @@
+package audio
+
+import "math"
+
+// Peaks is waveform overview data in the audiowaveform JSON layout, so it can
+// be fed directly to peaks.js or wavesurfer.js
+type Peaks struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"` // Interleaved min/max pairs
+}
+
+// ComputePeaks downmixes the buffer to mono and reduces it to at most buckets
+// min/max pairs
+func ComputePeaks(p *PCM, buckets int) *Peaks {
+	mono := Convert(p, p.SampleRate, 1)
+
+	samplesPerPixel := 1
+	if buckets > 0 && len(mono.Samples) > buckets {
+		samplesPerPixel = int(math.Ceil(float64(len(mono.Samples)) / float64(buckets)))
+	}
+
+	peaks := &Peaks{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      p.SampleRate,
+		SamplesPerPixel: samplesPerPixel,
+		Bits:            8,
+	}
+
+	for start := 0; start < len(mono.Samples); start += samplesPerPixel {
+		end := min(start+samplesPerPixel, len(mono.Samples))
+		lo, hi := mono.Samples[start], mono.Samples[start]
+		for _, s := range mono.Samples[start+1 : end] {
+			lo = min(lo, s)
+			hi = max(hi, s)
+		}
+		peaks.Data = append(peaks.Data, int8(lo>>8), int8(hi>>8))
+		peaks.Length++
+	}
+
+	return peaks
+}
@@ -0,0 +1,134 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrUnsupportedWAV is returned for WAV files that are not 8/16-bit integer PCM
+var ErrUnsupportedWAV = errors.New("unsupported WAV encoding")
+
+// IsWAV reports whether data starts with a RIFF/WAVE header
+func IsWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// DecodeWAV parses an integer PCM WAV file
+func DecodeWAV(data []byte) (*PCM, error) {
+	if !IsWAV(data) {
+		return nil, errors.New("not a WAV file")
+	}
+
+	var (
+		format        uint16
+		channels      uint16
+		sampleRate    uint32
+		bitsPerSample uint16
+		haveFormat    bool
+	)
+
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := pos + 8
+		end := body + size
+		if end > len(data) {
+			// Streaming encoders often write a placeholder size for the data chunk
+			end = len(data)
+		}
+
+		switch id {
+		case "fmt ":
+			if end-body < 16 {
+				return nil, errors.New("WAV fmt chunk is too short")
+			}
+			format = binary.LittleEndian.Uint16(data[body:])
+			channels = binary.LittleEndian.Uint16(data[body+2:])
+			sampleRate = binary.LittleEndian.Uint32(data[body+4:])
+			bitsPerSample = binary.LittleEndian.Uint16(data[body+14:])
+			// WAVE_FORMAT_EXTENSIBLE carries the real format in the sub-format GUID
+			if format == 0xFFFE && end-body >= 26 {
+				format = binary.LittleEndian.Uint16(data[body+24:])
+			}
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return nil, errors.New("WAV data chunk precedes fmt chunk")
+			}
+			if format != 1 || (bitsPerSample != 8 && bitsPerSample != 16) || channels == 0 || sampleRate == 0 {
+				return nil, fmt.Errorf("%w: format %d, %d bits", ErrUnsupportedWAV, format, bitsPerSample)
+			}
+			return &PCM{
+				SampleRate: int(sampleRate),
+				Channels:   int(channels),
+				Samples:    decodeSamples(data[body:end], bitsPerSample),
+			}, nil
+		}
+
+		// Chunks are word aligned
+		pos = end + size%2
+	}
+
+	return nil, errors.New("WAV file has no data chunk")
+}
+
+func decodeSamples(raw []byte, bitsPerSample uint16) []int16 {
+	if bitsPerSample == 8 {
+		samples := make([]int16, len(raw))
+		for i, b := range raw {
+			samples[i] = (int16(b) - 128) << 8
+		}
+		return samples
+	}
+
+	samples := make([]int16, len(raw)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(raw[i*2:]))
+	}
+	return samples
+}
+
+// EncodeWAV writes the buffer as a 16-bit PCM WAV file
+func EncodeWAV(p *PCM) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 44+len(p.Samples)*2))
+	WriteWAVHeader(buf, p.SampleRate, p.Channels, len(p.Samples))
+	WriteSamples(buf, p.Samples)
+	return buf.Bytes()
+}
+
+// WriteWAVHeader starts a 16-bit PCM WAV file that will hold the given number
+// of samples, so the samples can be written as they are produced
+func WriteWAVHeader(w io.Writer, sampleRate, channels, samples int) error {
+	dataSize := samples * 2
+	blockAlign := channels * 2
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteSamples writes samples as little-endian 16-bit PCM
+func WriteSamples(w io.Writer, samples []int16) error {
+	return binary.Write(w, binary.LittleEndian, samples)
+}
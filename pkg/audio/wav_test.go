@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	original := &PCM{SampleRate: 16000, Channels: 2, Samples: []int16{0, 100, -100, 32767, -32768, 5}}
+
+	decoded, err := DecodeWAV(EncodeWAV(original))
+	require.NoError(t, err)
+	assert.Equal(t, original, decoded)
+}
+
+func TestConvertAndConcat(t *testing.T) {
+	stereo := &PCM{SampleRate: 48000, Channels: 2, Samples: make([]int16, 48000*2)}
+	mono := Convert(stereo, 24000, 1)
+
+	assert.Equal(t, 24000, len(mono.Samples))
+	assert.Equal(t, time.Second, mono.Duration())
+
+	joined := Concat(24000, 1, mono, Silence(24000, 1, 500*time.Millisecond))
+	assert.Equal(t, 1500*time.Millisecond, joined.Duration())
+}
+
+func TestNormalizeDoesNotClip(t *testing.T) {
+	p := &PCM{SampleRate: 8000, Channels: 1, Samples: []int16{1000, -1000, 20000, -20000}}
+	Normalize(p, 0)
+
+	assert.Equal(t, int16(32767), p.Samples[2])
+	assert.Equal(t, int16(-32767), p.Samples[3])
+}
+
+func TestStreamedWAVMatchesEncoded(t *testing.T) {
+	first := &PCM{SampleRate: 8000, Channels: 1, Samples: []int16{1000, -1000}}
+	second := &PCM{SampleRate: 8000, Channels: 1, Samples: []int16{20000, -20000, 5}}
+	whole := Concat(8000, 1, first, second)
+
+	var level Level
+	level.Add(first)
+	level.Add(second)
+	gain := level.Gain(-20)
+
+	var streamed bytes.Buffer
+	require.NoError(t, WriteWAVHeader(&streamed, 8000, 1, len(whole.Samples)))
+	for _, part := range []*PCM{first, second} {
+		Amplify(part, gain)
+		require.NoError(t, WriteSamples(&streamed, part.Samples))
+	}
+
+	Normalize(whole, -20)
+	assert.Equal(t, EncodeWAV(whole), streamed.Bytes())
+}
+
+func TestComputePeaks(t *testing.T) {
+	p := &PCM{SampleRate: 8000, Channels: 1, Samples: []int16{-256, 512, 0, 0, 32767, -32768}}
+	peaks := ComputePeaks(p, 3)
+
+	assert.Equal(t, 3, peaks.Length)
+	assert.Equal(t, 2, peaks.SamplesPerPixel)
+	assert.Equal(t, []int8{-1, 2, 0, 0, -128, 127}, peaks.Data)
+}
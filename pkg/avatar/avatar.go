@@ -0,0 +1,210 @@
+// Package avatar turns uploaded images into square avatar thumbnails.
+// Uploads are identified by magic bytes, decoded with the standard image
+// packages and re-encoded, which drops EXIF, ICC and other metadata.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// Sizes are the edge lengths, in pixels, of the thumbnails Process returns
+var Sizes = []int{512, 256, 128, 64}
+
+// DefaultSize is the thumbnail served when no size is asked for
+const DefaultSize = 256
+
+const (
+	// MinDimension is the smallest width or height accepted
+	MinDimension = 32
+
+	// MaxDimension is the largest width or height accepted
+	MaxDimension = 8192
+
+	// MaxPixels bounds width*height so small files cannot decode to huge images
+	MaxPixels = 40_000_000
+
+	jpegQuality = 85
+)
+
+var (
+	// ErrUnsupportedFormat is returned for anything but PNG, JPEG and GIF
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+
+	// ErrInvalidImage is returned for images that fail to decode or have bad dimensions
+	ErrInvalidImage = errors.New("invalid image")
+)
+
+// Image formats recognised by Format
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatGIF  = "gif"
+)
+
+// Thumbnail is one encoded avatar size
+type Thumbnail struct {
+	Size        int
+	ContentType string
+	Data        []byte
+}
+
+// Format identifies an image by its magic bytes
+func Format(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG, nil
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return FormatJPEG, nil
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF, nil
+	}
+	return "", fmt.Errorf("%w: expected PNG, JPEG or GIF", ErrUnsupportedFormat)
+}
+
+// Process validates an uploaded image and returns it cropped to a centred
+// square at each of Sizes. Images smaller than a size are not enlarged.
+// Opaque images are encoded as JPEG and images with transparency as PNG.
+func Process(data []byte) ([]Thumbnail, error) {
+	img, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	square := crop(img)
+	opaque := square.Opaque()
+	thumbnails := make([]Thumbnail, 0, len(Sizes))
+	for _, size := range Sizes {
+		edge := size
+		if b := square.Bounds(); b.Dx() < edge {
+			edge = b.Dx()
+		}
+		thumb, err := encode(resize(square, edge), opaque)
+		if err != nil {
+			return nil, err
+		}
+		thumb.Size = size
+		thumbnails = append(thumbnails, thumb)
+	}
+	return thumbnails, nil
+}
+
+// Decode checks an image's format and dimensions and decodes it, applying
+// the EXIF orientation of JPEG photos. Only the first frame of a GIF is kept.
+func Decode(data []byte) (image.Image, error) {
+	format, err := Format(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the header before decoding so oversized images are never allocated
+	config, configFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if configFormat != format {
+		return nil, fmt.Errorf("%w: content is %s but starts like %s", ErrInvalidImage, configFormat, format)
+	}
+	if config.Width < MinDimension || config.Height < MinDimension {
+		return nil, fmt.Errorf("%w: image must be at least %dx%d", ErrInvalidImage, MinDimension, MinDimension)
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension || config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%w: image is too large", ErrInvalidImage)
+	}
+
+	var img image.Image
+	switch format {
+	case FormatPNG:
+		img, err = png.Decode(bytes.NewReader(data))
+	case FormatJPEG:
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case FormatGIF:
+		img, err = gif.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	if format == FormatJPEG {
+		img = orient(img, jpegOrientation(data))
+	}
+	return img, nil
+}
+
+// crop copies the centred square of an image into an RGBA image
+func crop(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	edge := b.Dx()
+	if b.Dy() < edge {
+		edge = b.Dy()
+	}
+	origin := image.Pt(b.Min.X+(b.Dx()-edge)/2, b.Min.Y+(b.Dy()-edge)/2)
+
+	square := image.NewRGBA(image.Rect(0, 0, edge, edge))
+	draw.Draw(square, square.Bounds(), img, origin, draw.Src)
+	return square
+}
+
+// resize scales a square image down to edge pixels by averaging the source
+// pixels each destination pixel covers
+func resize(src *image.RGBA, edge int) *image.RGBA {
+	srcEdge := src.Bounds().Dx()
+	if edge == srcEdge {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, edge, edge))
+	for dy := 0; dy < edge; dy++ {
+		sy0, sy1 := span(dy, edge, srcEdge)
+		for dx := 0; dx < edge; dx++ {
+			sx0, sx1 := span(dx, edge, srcEdge)
+
+			var r, g, b, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := sx0; sx < sx1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					b += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+			dst.SetRGBA(dx, dy, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// span returns the source pixel range covered by destination pixel i
+func span(i, dstEdge, srcEdge int) (int, int) {
+	start := i * srcEdge / dstEdge
+	end := (i + 1) * srcEdge / dstEdge
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// encode writes a thumbnail as JPEG when opaque and PNG otherwise
+func encode(img *image.RGBA, opaque bool) (Thumbnail, error) {
+	var buf bytes.Buffer
+	if opaque {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Thumbnail{}, err
+		}
+		return Thumbnail{ContentType: "image/jpeg", Data: buf.Bytes()}, nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return Thumbnail{}, err
+	}
+	return Thumbnail{ContentType: "image/png", Data: buf.Bytes()}, nil
+}
@@ -0,0 +1,139 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func filled(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestProcessOpaqueImage(t *testing.T) {
+	thumbs, err := Process(encodeJPEG(t, filled(800, 600, color.RGBA{R: 200, A: 255})))
+	require.NoError(t, err)
+	require.Len(t, thumbs, len(Sizes))
+
+	for i, thumb := range thumbs {
+		assert.Equal(t, Sizes[i], thumb.Size)
+		assert.Equal(t, "image/jpeg", thumb.ContentType)
+
+		img, err := jpeg.Decode(bytes.NewReader(thumb.Data))
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, thumb.Size, thumb.Size), img.Bounds())
+	}
+}
+
+func TestProcessKeepsTransparencyAndDoesNotEnlarge(t *testing.T) {
+	thumbs, err := Process(encodePNG(t, filled(100, 80, color.RGBA{})))
+	require.NoError(t, err)
+
+	for _, thumb := range thumbs {
+		assert.Equal(t, "image/png", thumb.ContentType)
+		img, err := png.Decode(bytes.NewReader(thumb.Data))
+		require.NoError(t, err)
+
+		edge := thumb.Size
+		if edge > 80 {
+			edge = 80
+		}
+		assert.Equal(t, image.Rect(0, 0, edge, edge), img.Bounds(), thumb.Size)
+	}
+}
+
+func TestResizeAverages(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.RGBA{R: 255, A: 255})
+	src.Set(1, 0, color.RGBA{A: 255})
+	src.Set(0, 1, color.RGBA{A: 255})
+	src.Set(1, 1, color.RGBA{R: 255, A: 255})
+
+	dst := resize(src, 1)
+	assert.Equal(t, color.RGBA{R: 127, A: 255}, dst.RGBAAt(0, 0))
+}
+
+func TestDecodeRejectsBadImages(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"webp":      {[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), ErrUnsupportedFormat},
+		"text":      {[]byte("<svg></svg>"), ErrUnsupportedFormat},
+		"truncated": {[]byte("\x89PNG\r\n\x1a\nnot really"), ErrInvalidImage},
+		"tiny":      {encodePNG(t, filled(8, 8, color.White)), ErrInvalidImage},
+		"too tall":  {encodePNG(t, image.NewGray(image.Rect(0, 0, 40, MaxDimension+1))), ErrInvalidImage},
+	}
+	for name, tt := range tests {
+		_, err := Decode(tt.data)
+		assert.ErrorIs(t, err, tt.err, name)
+	}
+}
+
+// withOrientation inserts an EXIF APP1 segment carrying an orientation tag
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1) // One IFD entry
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // Value padding and next IFD offset
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xff, 0xe1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestDecodeAppliesOrientation(t *testing.T) {
+	data := withOrientation(encodeJPEG(t, filled(40, 64, color.Gray{Y: 128})), 6)
+	assert.Equal(t, 6, jpegOrientation(data))
+
+	img, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 64, 40), img.Bounds())
+}
+
+func TestOrientRotatesClockwise(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.RGBA{R: 255, A: 255})
+	src.Set(1, 0, color.RGBA{B: 255, A: 255})
+
+	// Orientation 6 is displayed turned 90° clockwise: left becomes top
+	rotated := orient(src, 6).(*image.RGBA)
+	assert.Equal(t, image.Rect(0, 0, 1, 2), rotated.Bounds())
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, rotated.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, rotated.RGBAAt(0, 1))
+}
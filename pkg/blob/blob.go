@@ -0,0 +1,212 @@
+// Package blob stores opaque binary objects by key. Stores are pluggable;
+// FileStore keeps objects on local disk and MemoryStore is for tests.
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned when no object has the key
+	ErrNotFound = errors.New("blob not found")
+
+	// ErrInvalidKey is returned for empty, absolute or escaping keys
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store keeps objects under slash-separated keys such as "avatars/ab12/256"
+type Store interface {
+	// Put writes an object, replacing any existing one
+	Put(ctx context.Context, key string, data []byte) error
+
+	// PutReader writes an object read from r, replacing any existing one
+	PutReader(ctx context.Context, key string, r io.Reader) error
+
+	// Get reads an object
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Open returns a seekable reader over an object, which the caller closes
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+
+	// Delete removes an object; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidateKey checks that a key is a clean relative path
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+// FileStore keeps objects as files below a directory
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a store rooted at dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put writes the object to a temporary file and renames it into place so
+// readers never see a partial object
+func (s *FileStore) Put(ctx context.Context, key string, data []byte) error {
+	return s.PutReader(ctx, key, bytes.NewReader(data))
+}
+
+// PutReader streams r into a temporary file and renames it into place, so
+// objects of any size are written without holding them in memory
+func (s *FileStore) PutReader(_ context.Context, key string, r io.Reader) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	target := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".blob-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to store blob: %w", err)
+	}
+	return nil
+}
+
+// Get reads the object's file
+func (s *FileStore) Get(_ context.Context, key string) ([]byte, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Open opens the object's file
+func (s *FileStore) Open(_ context.Context, key string) (io.ReadSeekCloser, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Delete removes the object's file
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// MemoryStore keeps objects in memory
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string][]byte)}
+}
+
+// Put stores a copy of the data
+func (s *MemoryStore) Put(_ context.Context, key string, data []byte) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+// PutReader stores everything read from r
+func (s *MemoryStore) PutReader(ctx context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	return s.Put(ctx, key, data)
+}
+
+// Get returns a copy of the data
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Open returns a reader over a copy of the data
+func (s *MemoryStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	data, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+// nopCloser adds a no-op Close to an in-memory reader
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+// Delete removes the object
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
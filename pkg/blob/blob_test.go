@@ -0,0 +1,57 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	_, err := store.Get(ctx, "avatars/a/256")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Put(ctx, "avatars/a/256", []byte("one")))
+	require.NoError(t, store.Put(ctx, "avatars/a/256", []byte("two")))
+	data, err := store.Get(ctx, "avatars/a/256")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("two"), data)
+
+	require.NoError(t, store.Delete(ctx, "avatars/a/256"))
+	require.NoError(t, store.Delete(ctx, "avatars/a/256"))
+	_, err = store.Get(ctx, "avatars/a/256")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.PutReader(ctx, "audio/b", strings.NewReader("streamed bytes")))
+	reader, err := store.Open(ctx, "audio/b")
+	require.NoError(t, err)
+	_, err = reader.Seek(9, io.SeekStart)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "bytes", string(rest))
+
+	_, err = store.Open(ctx, "audio/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b", ".."} {
+		assert.ErrorIs(t, store.Put(ctx, key, nil), ErrInvalidKey, key)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	testStore(t, store)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
@@ -0,0 +1,180 @@
+// Package charactercard reads and writes community character cards: the
+// Character Card V2 JSON spec, the older flat V1 layout, and PNG images that
+// carry a card in a "chara" tEXt chunk.
+package charactercard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SpecV2 and SpecVersionV2 identify Character Card V2 documents
+const (
+	SpecV2        = "chara_card_v2"
+	SpecVersionV2 = "2.0"
+)
+
+var (
+	// ErrInvalidCard is returned for documents that are not character cards
+	ErrInvalidCard = errors.New("invalid character card")
+
+	// ErrUnsupportedSpec is returned for card specs other than V1 and V2
+	ErrUnsupportedSpec = errors.New("unsupported character card spec")
+)
+
+// Card is a Character Card V2 document
+type Card struct {
+	Spec        string `json:"spec"`
+	SpecVersion string `json:"spec_version"`
+	Data        Data   `json:"data"`
+}
+
+// Data holds a card's fields. Members other than the mapped ones, such as
+// extensions, creator_notes or character_book, are kept verbatim in Extra so
+// a card can be written back without loss.
+type Data struct {
+	Name               string
+	Description        string
+	Personality        string
+	Scenario           string
+	FirstMes           string
+	AlternateGreetings []string
+	MesExample         string
+	Tags               []string
+	Extra              map[string]json.RawMessage
+}
+
+// dataFields are the members of Data that are not kept in Extra
+var dataFields = []string{"name", "description", "personality", "scenario", "first_mes", "alternate_greetings", "mes_example", "tags"}
+
+// UnmarshalJSON reads the mapped members and keeps every other one in Extra
+func (d *Data) UnmarshalJSON(b []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return err
+	}
+
+	targets := map[string]interface{}{
+		"name":                &d.Name,
+		"description":         &d.Description,
+		"personality":         &d.Personality,
+		"scenario":            &d.Scenario,
+		"first_mes":           &d.FirstMes,
+		"alternate_greetings": &d.AlternateGreetings,
+		"mes_example":         &d.MesExample,
+		"tags":                &d.Tags,
+	}
+	for _, key := range dataFields {
+		raw, ok := members[key]
+		if !ok {
+			continue
+		}
+		delete(members, key)
+		if bytes.Equal(raw, []byte("null")) {
+			continue
+		}
+		if err := json.Unmarshal(raw, targets[key]); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidCard, key, err)
+		}
+	}
+
+	d.Extra = nil
+	if len(members) > 0 {
+		d.Extra = members
+	}
+	return nil
+}
+
+// MarshalJSON writes the mapped members over Extra. V2 requires every string
+// field and the tags, extensions and alternate_greetings members, so missing
+// ones are written empty.
+func (d Data) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(d.Extra)+len(dataFields))
+	for key, raw := range d.Extra {
+		members[key] = raw
+	}
+	for _, key := range []string{"creator_notes", "system_prompt", "post_history_instructions", "creator", "character_version"} {
+		if _, ok := members[key]; !ok {
+			members[key] = ""
+		}
+	}
+	if _, ok := members["extensions"]; !ok {
+		members["extensions"] = map[string]interface{}{}
+	}
+
+	tags := d.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	greetings := d.AlternateGreetings
+	if greetings == nil {
+		greetings = []string{}
+	}
+	members["name"] = d.Name
+	members["description"] = d.Description
+	members["personality"] = d.Personality
+	members["scenario"] = d.Scenario
+	members["first_mes"] = d.FirstMes
+	members["mes_example"] = d.MesExample
+	members["tags"] = tags
+	members["alternate_greetings"] = greetings
+	return json.Marshal(members)
+}
+
+// New returns a V2 card for the data
+func New(data Data) *Card {
+	return &Card{Spec: SpecV2, SpecVersion: SpecVersionV2, Data: data}
+}
+
+// Parse reads a card from JSON or from a PNG image carrying one. V1 cards
+// are upgraded to V2.
+func Parse(b []byte) (*Card, error) {
+	if IsPNG(b) {
+		text, err := ExtractPNG(b)
+		if err != nil {
+			return nil, err
+		}
+		b = text
+	}
+
+	var head struct {
+		Spec string          `json:"spec"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &head); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCard, err)
+	}
+
+	var card Card
+	switch head.Spec {
+	case SpecV2:
+		if len(head.Data) == 0 {
+			return nil, fmt.Errorf("%w: missing data", ErrInvalidCard)
+		}
+		if err := json.Unmarshal(b, &card); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCard, err)
+		}
+	case "":
+		// V1 cards are the data object itself
+		var data Data
+		if err := json.Unmarshal(b, &data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCard, err)
+		}
+		card = *New(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSpec, head.Spec)
+	}
+
+	if card.Data.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidCard)
+	}
+	card.SpecVersion = SpecVersionV2
+	return &card, nil
+}
+
+// JSON encodes the card
+func (c *Card) JSON() ([]byte, error) {
+	return json.Marshal(c)
+}
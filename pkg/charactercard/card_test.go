@@ -0,0 +1,115 @@
+package charactercard
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const v2Card = `{
+	"spec": "chara_card_v2",
+	"spec_version": "2.0",
+	"data": {
+		"name": "Ada",
+		"description": "A mathematician",
+		"personality": "curious",
+		"scenario": "Victorian London",
+		"first_mes": "Hello there.",
+		"mes_example": "<START>\n{{char}}: Numbers!",
+		"creator_notes": "notes",
+		"system_prompt": "",
+		"post_history_instructions": "",
+		"alternate_greetings": ["Good day."],
+		"character_book": {"entries": [{"keys": ["engine"], "content": "The analytical engine"}]},
+		"tags": ["math", "history"],
+		"creator": "someone",
+		"character_version": "3",
+		"extensions": {"depth_prompt": {"depth": 4, "prompt": "stay in character"}}
+	}
+}`
+
+func TestParseV2(t *testing.T) {
+	card, err := Parse([]byte(v2Card))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Ada", card.Data.Name)
+	assert.Equal(t, "Victorian London", card.Data.Scenario)
+	assert.Equal(t, "Hello there.", card.Data.FirstMes)
+	assert.Equal(t, []string{"math", "history"}, card.Data.Tags)
+	assert.Equal(t, []string{"Good day."}, card.Data.AlternateGreetings)
+	assert.Contains(t, card.Data.Extra, "extensions")
+	assert.Contains(t, card.Data.Extra, "character_book")
+	assert.NotContains(t, card.Data.Extra, "name")
+	assert.NotContains(t, card.Data.Extra, "alternate_greetings")
+}
+
+func TestRoundTripIsLossless(t *testing.T) {
+	card, err := Parse([]byte(v2Card))
+	require.NoError(t, err)
+
+	out, err := card.JSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, v2Card, string(out))
+}
+
+func TestParseV1(t *testing.T) {
+	card, err := Parse([]byte(`{"name":"Bob","description":"d","first_mes":"Hi","mes_example":"","personality":"p","scenario":"s"}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, SpecV2, card.Spec)
+	assert.Equal(t, "Bob", card.Data.Name)
+	assert.Equal(t, "Hi", card.Data.FirstMes)
+}
+
+func TestParseRejectsInvalidCards(t *testing.T) {
+	_, err := Parse([]byte(`not json`))
+	assert.ErrorIs(t, err, ErrInvalidCard)
+
+	_, err = Parse([]byte(`{"spec":"chara_card_v2","data":{"description":"no name"}}`))
+	assert.ErrorIs(t, err, ErrInvalidCard)
+
+	_, err = Parse([]byte(`{"spec":"something_else","data":{"name":"x"}}`))
+	assert.ErrorIs(t, err, ErrUnsupportedSpec)
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+	return buf.Bytes()
+}
+
+func TestPNGRoundTrip(t *testing.T) {
+	img := testPNG(t)
+
+	embedded, err := EmbedPNG(img, []byte(v2Card))
+	require.NoError(t, err)
+
+	// The image still decodes after the chunk is added
+	_, err = png.Decode(bytes.NewReader(embedded))
+	require.NoError(t, err)
+
+	card, err := Parse(embedded)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", card.Data.Name)
+
+	// Embedding again replaces the card rather than adding a second one
+	replaced, err := EmbedPNG(embedded, []byte(`{"name":"Bob"}`))
+	require.NoError(t, err)
+	card, err = Parse(replaced)
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", card.Data.Name)
+	assert.Equal(t, 1, bytes.Count(replaced, []byte("tEXtchara")))
+}
+
+func TestExtractPNGWithoutCard(t *testing.T) {
+	_, err := ExtractPNG(testPNG(t))
+	assert.ErrorIs(t, err, ErrInvalidCard)
+
+	_, err = ExtractPNG([]byte("GIF89a"))
+	assert.ErrorIs(t, err, ErrInvalidCard)
+}
@@ -0,0 +1,119 @@
+package charactercard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// pngKeyword is the tEXt keyword community tools store cards under
+const pngKeyword = "chara"
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// IsPNG reports whether b starts with the PNG signature
+func IsPNG(b []byte) bool {
+	return bytes.HasPrefix(b, pngSignature)
+}
+
+// pngChunk is one chunk of a PNG stream; raw includes length, type and CRC
+type pngChunk struct {
+	kind string
+	data []byte
+	raw  []byte
+}
+
+// readChunks splits a PNG image into its chunks
+func readChunks(b []byte) ([]pngChunk, error) {
+	if !IsPNG(b) {
+		return nil, fmt.Errorf("%w: not a PNG image", ErrInvalidCard)
+	}
+
+	var chunks []pngChunk
+	for pos := len(pngSignature); pos < len(b); {
+		if len(b)-pos < 12 {
+			return nil, fmt.Errorf("%w: truncated PNG chunk", ErrInvalidCard)
+		}
+		length := int(binary.BigEndian.Uint32(b[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(b) || end < pos {
+			return nil, fmt.Errorf("%w: truncated PNG chunk", ErrInvalidCard)
+		}
+		chunk := pngChunk{
+			kind: string(b[pos+4 : pos+8]),
+			data: b[pos+8 : pos+8+length],
+			raw:  b[pos:end],
+		}
+		chunks = append(chunks, chunk)
+		pos = end
+		if chunk.kind == "IEND" {
+			break
+		}
+	}
+	return chunks, nil
+}
+
+// isCardChunk reports whether a chunk is a tEXt chunk holding a card
+func isCardChunk(c pngChunk) bool {
+	return c.kind == "tEXt" && bytes.HasPrefix(c.data, []byte(pngKeyword+"\x00"))
+}
+
+// ExtractPNG returns the card JSON stored in a PNG image
+func ExtractPNG(b []byte) ([]byte, error) {
+	chunks, err := readChunks(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range chunks {
+		if !isCardChunk(c) {
+			continue
+		}
+		text := c.data[len(pngKeyword)+1:]
+		decoded, err := base64.StdEncoding.DecodeString(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("%w: card chunk is not base64: %v", ErrInvalidCard, err)
+		}
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("%w: PNG image has no character card", ErrInvalidCard)
+}
+
+// EmbedPNG returns a copy of a PNG image carrying the card JSON, replacing
+// any card it already had
+func EmbedPNG(image []byte, card []byte) ([]byte, error) {
+	chunks, err := readChunks(image)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.Write(pngSignature)
+	for _, c := range chunks {
+		if isCardChunk(c) {
+			continue
+		}
+		if c.kind == "IEND" {
+			writeChunk(&out, "tEXt", append([]byte(pngKeyword+"\x00"), base64.StdEncoding.EncodeToString(card)...))
+		}
+		out.Write(c.raw)
+	}
+	return out.Bytes(), nil
+}
+
+// writeChunk appends a chunk with its length and CRC
+func writeChunk(w *bytes.Buffer, kind string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], kind)
+	w.Write(header[:])
+	w.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
@@ -3,7 +3,10 @@ package di
 import (
 	"ai-agent-character-demo/backend/ai"
 	"ai-agent-character-demo/backend/internal/service"
+	"ai-agent-character-demo/backend/pkg/audio"
+	"ai-agent-character-demo/backend/pkg/blob"
 	"ai-agent-character-demo/backend/pkg/jwt"
+	"ai-agent-character-demo/backend/pkg/knowledge"
 	"ai-agent-character-demo/backend/pkg/logger" // Aliased to avoid conflicts
 	"ai-agent-character-demo/backend/pkg/ws"
 	"context"
@@ -21,22 +24,46 @@ type Container struct {
 	UserService             *service.UserService
 	CharacterService        *service.CharacterService
 	MessageService          *service.MessageService
+	ConversationService     *service.ConversationService
+	SummaryService          *service.ConversationSummaryService
+	ShareService            *service.ShareService
+	FeedbackService         *service.FeedbackService
 	AudioService            *service.AudioService
+	MessageAudioService     *service.MessageAudioService
+	RecordingService        *service.RecordingService
+	WaveformService         *service.WaveformService
+	AudioUploadService      *service.AudioUploadService
+	AudioIntegrityService   *service.AudioIntegrityService
+	AvatarStore             blob.Store
+	KnowledgeService        *service.KnowledgeService
+	PromptTemplateService   *service.PromptTemplateService
 	AIBridge                *ai.AIBridge
 	AI_Layer2Client         *ai.AI_Layer2Client
 	AdapterService          *service.AdapterService
 	CharacterServiceAdapter *service.CharacterServiceAdapter
 	MessageServiceAdapter   *service.MessageServiceAdapter
+	ConversationAdapter     *service.ConversationServiceAdapter
 	AIServiceAdapter        *service.AIServiceAdapter
 }
 
 // Config holds the configuration for the container
 type Config struct {
-	DBConfig           *gorm.Config
-	LoggerConfig       logger.Config
-	JWTSecret          string
-	JWTExpiryHours     int
-	AudioServiceConfig service.AudioServiceConfig
+	DBConfig             *gorm.Config
+	LoggerConfig         logger.Config
+	JWTSecret            string
+	JWTExpiryHours       int
+	AudioServiceConfig   service.AudioServiceConfig
+	AudioUploadConfig    service.AudioUploadConfig
+	PromptVersion        string // Recorded on character replies for feedback analytics
+	ChatModel            string // Chat model replies come from; selects model-bound prompt templates
+	SummaryConfig        service.ConversationSummaryConfig
+	MaxCharactersPerUser int    // Characters a non-admin user may own; 0 means unlimited
+	AvatarStoreDir       string // Directory of the file-backed avatar store
+	AudioStoreDir        string // Directory of the file-backed store for uploaded audio
+	AvatarBaseURL        string // Prefix of generated avatar URLs; empty for relative URLs
+	KnowledgeConfig      service.KnowledgeConfig
+	Embedder             knowledge.Embedder // Embeds knowledge passages; nil uses the built-in hashing embedder
+	KnowledgePGVector    bool               // Rank knowledge passages with the pgvector extension
 }
 
 // DefaultConfig returns a default configuration
@@ -50,6 +77,13 @@ func DefaultConfig() *Config {
 			MaxChunksPerSession: 5000,
 			DefaultTTL:          24 * 60 * 60 * 1000000000, // 24 hours in nanoseconds
 		},
+		AudioUploadConfig:    service.DefaultAudioUploadConfig(),
+		PromptVersion:        "v1",
+		SummaryConfig:        service.DefaultConversationSummaryConfig(),
+		MaxCharactersPerUser: 50,
+		AvatarStoreDir:       "data/avatars",
+		AudioStoreDir:        "data/audio",
+		KnowledgeConfig:      service.DefaultKnowledgeConfig(),
 	}
 }
 
@@ -68,8 +102,43 @@ func New(db *gorm.DB, config *Config) (*Container, error) {
 	// Initialize core services
 	userService := service.NewUserService(db, jwtService)
 	characterService := service.NewCharacterService(db)
+	characterService.SetMaxCharactersPerUser(config.MaxCharactersPerUser)
+	avatarStore, err := blob.NewFileStore(config.AvatarStoreDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create avatar store: %w", err)
+	}
+	characterService.SetAvatarStore(avatarStore, config.AvatarBaseURL)
+	embedder := config.Embedder
+	if embedder == nil {
+		embedder = knowledge.NewHashEmbedder(0)
+	}
+	knowledgeService := service.NewKnowledgeService(db, characterService, embedder, config.KnowledgeConfig)
+	knowledgeService.SetPGVector(config.KnowledgePGVector)
 	messageService := service.NewMessageService(db)
+	messageService.SetPromptVersion(config.PromptVersion)
+	promptTemplateService := service.NewPromptTemplateService(db, characterService, config.ChatModel, config.PromptVersion)
+	conversationService := service.NewConversationService(db)
+	conversationService.SetCharacterService(characterService)
+	shareService := service.NewShareService(db, messageService)
+	feedbackService := service.NewFeedbackService(db)
 	audioService := service.NewAudioServiceWithConfig(db, config.AudioServiceConfig)
+	audioStore, err := blob.NewFileStore(config.AudioStoreDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio store: %w", err)
+	}
+	audioService.SetBlobStore(audioStore)
+	transcoder := audio.NewTranscoder()
+	recordingService := service.NewRecordingService(db, transcoder)
+	recordingService.SetAudioService(audioService)
+	waveformService := service.NewWaveformService(transcoder)
+	waveformService.SetAudioService(audioService)
+
+	audioUploadService, err := service.NewAudioUploadService(db, audioService, config.AudioUploadConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio upload service: %w", err)
+	}
+	audioUploadService.SetConversationService(conversationService)
+	audioIntegrityService := service.NewAudioIntegrityService(audioService, 6*time.Hour)
 
 	// Initialize AI Bridge
 	aiBridge, err := ai.NewAIBridge()
@@ -92,38 +161,14 @@ func New(db *gorm.DB, config *Config) (*Container, error) {
 	// Initialize service adapters
 	characterServiceAdapter := service.NewCharacterServiceAdapter(characterService)
 	messageServiceAdapter := service.NewMessageServiceAdapter(messageService)
+	conversationAdapter := service.NewConversationServiceAdapter(conversationService)
 	// Create AIServiceAdapter with function adapters (using AI_Layer2Client)
 	aiServiceAdapter := service.NewAIServiceAdapter(
 		// GenerateResponse adapter (calls LLM1 then LLM2)
 		func(character *ws.Character, userMessage string, history []ws.ChatMessage) (string, error) {
 			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 			defer cancel()
-			userID := ""
-			// Build character details map for LLM1
-			characterDetails := map[string]interface{}{
-				"id":          character.ID,
-				"name":        character.Name,
-				"description": character.Description,
-				"personality": character.Personality,
-				"voice_type":  character.VoiceType,
-			}
-			// TODO: Pass session ID if available
-			contextResp, err := aiLayer2Client.GenerateContext(ctx, ai.ContextRequest{
-				UserInput:        userMessage,
-				CharacterDetails: characterDetails,
-				SessionID:        "", // Pass session ID if you have it
-			})
-			if err != nil {
-				return "", fmt.Errorf("context gen failed: %w", err)
-			}
-			resp, err := aiLayer2Client.GenerateResponse(ctx, ai.ResponseRequest{
-				CharacterID: character.ID,
-				UserID:      userID,
-				Context:     contextResp.Context,
-				Message:     userMessage,
-				History:     history,
-			})
-			return resp, err
+			return aiLayer2Client.Reply(ctx, character, userMessage, history)
 		},
 		// TextToSpeech adapter
 		func(ctx context.Context, text string, voiceType string) ([]byte, error) {
@@ -136,6 +181,20 @@ func New(db *gorm.DB, config *Config) (*Container, error) {
 		},
 	)
 
+	// Titles and summaries come from the same chat provider as replies
+	summaryService := service.NewConversationSummaryService(db, messageService,
+		func(ctx context.Context, instructions string, transcript string) (string, error) {
+			return aiLayer2Client.GenerateResponse(ctx, ai.ResponseRequest{
+				Context: instructions,
+				Message: transcript,
+			})
+		},
+		config.SummaryConfig,
+	)
+
+	// Message audio replay re-synthesizes expired replies through the same TTS path
+	messageAudioService := service.NewMessageAudioService(db, audioService, characterService, aiServiceAdapter.TextToSpeech)
+
 	return &Container{
 		DB:                      db,
 		Logger:                  log,
@@ -143,12 +202,25 @@ func New(db *gorm.DB, config *Config) (*Container, error) {
 		UserService:             userService,
 		CharacterService:        characterService,
 		MessageService:          messageService,
+		ConversationService:     conversationService,
+		SummaryService:          summaryService,
+		ShareService:            shareService,
+		FeedbackService:         feedbackService,
 		AudioService:            audioService,
+		MessageAudioService:     messageAudioService,
+		RecordingService:        recordingService,
+		WaveformService:         waveformService,
+		AudioUploadService:      audioUploadService,
+		AudioIntegrityService:   audioIntegrityService,
+		AvatarStore:             avatarStore,
+		KnowledgeService:        knowledgeService,
+		PromptTemplateService:   promptTemplateService,
 		AIBridge:                aiBridge,
 		AI_Layer2Client:         aiLayer2Client,
 		AdapterService:          adapterService,
 		CharacterServiceAdapter: characterServiceAdapter,
 		MessageServiceAdapter:   messageServiceAdapter,
+		ConversationAdapter:     conversationAdapter,
 		AIServiceAdapter:        aiServiceAdapter,
 	}, nil
 }
@@ -0,0 +1,359 @@
+// Package fixtures reads the seed data loaded into a fresh database: the
+// category taxonomy, voices, demo users and characters. Fixtures are YAML or
+// JSON files grouped into sets, one per environment. Every set also loads the
+// files under common/, and a set's records replace common records with the
+// same key.
+package fixtures
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FormatVersion is the fixture file format this package reads
+const FormatVersion = 1
+
+// CommonSet holds the fixtures every set loads
+const CommonSet = "common"
+
+// DefaultCharacterSlug is the character WebSocket sessions fall back to
+const DefaultCharacterSlug = "elon-musk"
+
+var (
+	// ErrUnknownSet is returned when a fixture set has no directory
+	ErrUnknownSet = errors.New("unknown fixture set")
+
+	// ErrInvalidFixture is returned for malformed fixture files and records
+	ErrInvalidFixture = errors.New("invalid fixture")
+)
+
+//go:embed data
+var embedded embed.FS
+
+// Embedded returns the fixture sets built into the binary
+func Embedded() fs.FS {
+	sub, err := fs.Sub(embedded, "data")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// Source returns the fixture sets under dir, laid out like the embedded
+// ones, or the embedded sets when dir is empty
+func Source(dir string) fs.FS {
+	if dir == "" {
+		return Embedded()
+	}
+	return os.DirFS(dir)
+}
+
+// slugPattern is the form of fixture keys
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// File is one fixture file
+type File struct {
+	Version    int         `yaml:"version"`
+	Categories []Category  `yaml:"categories"`
+	Voices     []Voice     `yaml:"voices"`
+	Users      []User      `yaml:"users"`
+	Characters []Character `yaml:"characters"`
+}
+
+// Category is a category of the character taxonomy
+type Category struct {
+	Slug        string `yaml:"slug"`
+	Revision    int    `yaml:"revision"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	SortOrder   int    `yaml:"sort_order"`
+}
+
+// Voice is a text-to-speech voice characters speak with
+type Voice struct {
+	Slug            string `yaml:"slug"`
+	Revision        int    `yaml:"revision"`
+	Name            string `yaml:"name"`
+	Provider        string `yaml:"provider"`
+	ProviderVoiceID string `yaml:"provider_voice_id"`
+	Gender          string `yaml:"gender"`
+	Style           string `yaml:"style"`
+	Language        string `yaml:"language"`
+	Description     string `yaml:"description"`
+}
+
+// User is a demo account, keyed by email. The password comes from the
+// fixture or, for shared environments, from the environment variable named
+// by PasswordEnv.
+type User struct {
+	Email       string `yaml:"email"`
+	Revision    int    `yaml:"revision"`
+	Name        string `yaml:"name"`
+	Role        string `yaml:"role"`
+	Password    string `yaml:"password"`
+	PasswordEnv string `yaml:"password_env"`
+}
+
+// Character is a character persona. Voice is the slug of a voice; Owner is
+// the email of a fixture user, and characters without one are system
+// characters.
+type Character struct {
+	Slug               string   `yaml:"slug"`
+	Revision           int      `yaml:"revision"`
+	Name               string   `yaml:"name"`
+	Description        string   `yaml:"description"`
+	Personality        string   `yaml:"personality"`
+	Background         string   `yaml:"background"`
+	Scenario           string   `yaml:"scenario"`
+	Category           string   `yaml:"category"`
+	Traits             []string `yaml:"traits"`
+	Goals              []string `yaml:"goals"`
+	Fears              []string `yaml:"fears"`
+	Relationships      []string `yaml:"relationships"`
+	Voice              string   `yaml:"voice"`
+	Greeting           string   `yaml:"greeting"`
+	AlternateGreetings []string `yaml:"alternate_greetings"`
+	ExampleDialogue    string   `yaml:"example_dialogue"`
+	Owner              string   `yaml:"owner"`
+	Visibility         string   `yaml:"visibility"`
+}
+
+// Set is the merged content of a fixture set, in the order records are loaded
+type Set struct {
+	Name       string
+	Categories []Category
+	Voices     []Voice
+	Users      []User
+	Characters []Character
+}
+
+// Voice returns the set's voice with a slug
+func (s *Set) Voice(slug string) (Voice, bool) {
+	for _, v := range s.Voices {
+		if v.Slug == slug {
+			return v, true
+		}
+	}
+	return Voice{}, false
+}
+
+// Sets lists the fixture sets in fsys, without common
+func Sets(fsys fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var sets []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != CommonSet {
+			sets = append(sets, entry.Name())
+		}
+	}
+	return sets, nil
+}
+
+// Load reads a fixture set: the common files, then the set's own, each
+// directory in file name order
+func Load(fsys fs.FS, name string) (*Set, error) {
+	if !slugPattern.MatchString(name) || name == CommonSet {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSet, name)
+	}
+	if info, err := fs.Stat(fsys, name); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSet, name)
+	}
+
+	set := &Set{Name: name}
+	for _, dir := range []string{CommonSet, name} {
+		files, err := fixtureFiles(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := Parse(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			set.merge(parsed)
+		}
+	}
+
+	if err := set.Validate(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// fixtureFiles lists the YAML and JSON files of a directory, which may be missing
+func fixtureFiles(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		switch strings.ToLower(path.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, path.Join(dir, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Parse decodes a fixture file. JSON is read as YAML, which it is a subset
+// of. Unknown fields are rejected so typos do not pass silently.
+func Parse(data []byte) (*File, error) {
+	var file File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFixture, err)
+	}
+	if file.Version != FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d, expected %d", ErrInvalidFixture, file.Version, FormatVersion)
+	}
+	return &file, nil
+}
+
+// merge adds a file's records, replacing records with the same key
+func (s *Set) merge(file *File) {
+	for _, c := range file.Categories {
+		s.Categories = upsert(s.Categories, c, func(x Category) string { return x.Slug })
+	}
+	for _, v := range file.Voices {
+		s.Voices = upsert(s.Voices, v, func(x Voice) string { return x.Slug })
+	}
+	for _, u := range file.Users {
+		u.Email = strings.ToLower(strings.TrimSpace(u.Email))
+		s.Users = upsert(s.Users, u, func(x User) string { return x.Email })
+	}
+	for _, c := range file.Characters {
+		s.Characters = upsert(s.Characters, c, func(x Character) string { return x.Slug })
+	}
+}
+
+// upsert replaces the record with item's key, or appends item
+func upsert[T any](records []T, item T, key func(T) string) []T {
+	for i := range records {
+		if key(records[i]) == key(item) {
+			records[i] = item
+			return records
+		}
+	}
+	return append(records, item)
+}
+
+// Validate checks keys, required fields and references between records,
+// and defaults revisions to 1
+func (s *Set) Validate() error {
+	categories := map[string]bool{}
+	for i := range s.Categories {
+		c := &s.Categories[i]
+		if err := checkRecord("category", c.Slug, &c.Revision); err != nil {
+			return err
+		}
+		if strings.TrimSpace(c.Name) == "" {
+			return fmt.Errorf("%w: category %q has no name", ErrInvalidFixture, c.Slug)
+		}
+		categories[c.Slug] = true
+	}
+
+	for i := range s.Voices {
+		v := &s.Voices[i]
+		if err := checkRecord("voice", v.Slug, &v.Revision); err != nil {
+			return err
+		}
+		if strings.TrimSpace(v.Name) == "" {
+			return fmt.Errorf("%w: voice %q has no name", ErrInvalidFixture, v.Slug)
+		}
+	}
+
+	users := map[string]bool{}
+	for i := range s.Users {
+		u := &s.Users[i]
+		if !strings.Contains(u.Email, "@") {
+			return fmt.Errorf("%w: user %q needs an email", ErrInvalidFixture, u.Email)
+		}
+		if err := checkRevision("user", u.Email, &u.Revision); err != nil {
+			return err
+		}
+		switch u.Role {
+		case "":
+			u.Role = "user"
+		case "user", "admin", "guest":
+		default:
+			return fmt.Errorf("%w: user %q has unknown role %q", ErrInvalidFixture, u.Email, u.Role)
+		}
+		if u.Password == "" && u.PasswordEnv == "" {
+			return fmt.Errorf("%w: user %q needs a password or password_env", ErrInvalidFixture, u.Email)
+		}
+		users[u.Email] = true
+	}
+
+	for i := range s.Characters {
+		c := &s.Characters[i]
+		if err := checkRecord("character", c.Slug, &c.Revision); err != nil {
+			return err
+		}
+		switch {
+		case strings.TrimSpace(c.Name) == "", strings.TrimSpace(c.Description) == "", strings.TrimSpace(c.Personality) == "":
+			return fmt.Errorf("%w: character %q needs a name, description and personality", ErrInvalidFixture, c.Slug)
+		case c.Category != "" && !categories[c.Category]:
+			return fmt.Errorf("%w: character %q has unknown category %q", ErrInvalidFixture, c.Slug, c.Category)
+		case c.Owner != "" && !users[strings.ToLower(c.Owner)]:
+			return fmt.Errorf("%w: character %q has unknown owner %q", ErrInvalidFixture, c.Slug, c.Owner)
+		}
+		if _, ok := s.Voice(c.Voice); !ok {
+			return fmt.Errorf("%w: character %q has unknown voice %q", ErrInvalidFixture, c.Slug, c.Voice)
+		}
+		switch c.Visibility {
+		case "":
+			c.Visibility = "public"
+		case "private", "unlisted", "public":
+		default:
+			return fmt.Errorf("%w: character %q has unknown visibility %q", ErrInvalidFixture, c.Slug, c.Visibility)
+		}
+		c.Owner = strings.ToLower(c.Owner)
+	}
+	return nil
+}
+
+// checkRecord checks a slug-keyed record
+func checkRecord(kind, slug string, revision *int) error {
+	if !slugPattern.MatchString(slug) {
+		return fmt.Errorf("%w: %s slug %q must be lowercase letters, digits and hyphens", ErrInvalidFixture, kind, slug)
+	}
+	return checkRevision(kind, slug, revision)
+}
+
+// checkRevision defaults a record's revision to 1
+func checkRevision(kind, key string, revision *int) error {
+	if *revision < 0 {
+		return fmt.Errorf("%w: %s %q has a negative revision", ErrInvalidFixture, kind, key)
+	}
+	if *revision == 0 {
+		*revision = 1
+	}
+	return nil
+}
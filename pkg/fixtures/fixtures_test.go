@@ -0,0 +1,74 @@
+package fixtures
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedSetsLoad(t *testing.T) {
+	sets, err := Sets(Embedded())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"dev", "staging", "demo"}, sets)
+
+	for _, name := range sets {
+		set, err := Load(Embedded(), name)
+		require.NoError(t, err, name)
+
+		var slugs []string
+		for _, c := range set.Characters {
+			slugs = append(slugs, c.Slug)
+		}
+		assert.Contains(t, slugs, DefaultCharacterSlug, name)
+		assert.NotEmpty(t, set.Users, name)
+	}
+}
+
+func TestLoadMergesSetOverCommon(t *testing.T) {
+	fsys := fstest.MapFS{
+		"common/a.yaml": {Data: []byte(`version: 1
+voices:
+  - {slug: natural, name: Rachel}
+characters:
+  - {slug: ada, name: Ada, description: Mathematician, personality: Curious, voice: natural}
+`)},
+		"dev/b.json": {Data: []byte(`{"version": 1, "characters": [
+  {"slug": "ada", "revision": 2, "name": "Ada Lovelace", "description": "Mathematician", "personality": "Curious", "voice": "natural"}
+]}`)},
+	}
+
+	set, err := Load(fsys, "dev")
+	require.NoError(t, err)
+	require.Len(t, set.Characters, 1)
+	assert.Equal(t, "Ada Lovelace", set.Characters[0].Name)
+	assert.Equal(t, 2, set.Characters[0].Revision)
+	assert.Equal(t, "public", set.Characters[0].Visibility)
+	assert.Equal(t, 1, set.Voices[0].Revision)
+}
+
+func TestLoadRejectsBadFixtures(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":    "version: 1\nvoices:\n  - {slug: natural, name: Rachel, colour: red}\n",
+		"wrong version":    "version: 2\n",
+		"bad slug":         "version: 1\nvoices:\n  - {slug: Natural Voice, name: Rachel}\n",
+		"unknown voice":    "version: 1\ncharacters:\n  - {slug: ada, name: Ada, description: D, personality: P, voice: missing}\n",
+		"unknown owner":    "version: 1\nvoices:\n  - {slug: natural, name: Rachel}\ncharacters:\n  - {slug: ada, name: Ada, description: D, personality: P, voice: natural, owner: nobody@example.com}\n",
+		"no password":      "version: 1\nusers:\n  - {email: demo@example.com, name: Demo}\n",
+		"unknown role":     "version: 1\nusers:\n  - {email: demo@example.com, password: x, role: root}\n",
+		"unknown category": "version: 1\nvoices:\n  - {slug: natural, name: Rachel}\ncharacters:\n  - {slug: ada, name: Ada, description: D, personality: P, voice: natural, category: nope}\n",
+	}
+	for name, data := range cases {
+		_, err := Load(fstest.MapFS{"dev/a.yaml": {Data: []byte(data)}}, "dev")
+		assert.ErrorIs(t, err, ErrInvalidFixture, name)
+	}
+}
+
+func TestLoadUnknownSet(t *testing.T) {
+	fsys := fstest.MapFS{"common/a.yaml": {Data: []byte("version: 1\n")}}
+	_, err := Load(fsys, "prod")
+	assert.ErrorIs(t, err, ErrUnknownSet)
+	_, err = Load(fsys, CommonSet)
+	assert.ErrorIs(t, err, ErrUnknownSet)
+}
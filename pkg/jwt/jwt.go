@@ -221,6 +221,7 @@ func GetRolePermissions(role Role) []Permission {
 		return []Permission{
 			PermReadCharacter,
 			PermWriteCharacter,
+			PermDeleteCharacter, // Limited to their own characters by the character service
 			PermAccessAudio,
 		}
 	case RoleGuest:
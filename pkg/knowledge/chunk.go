@@ -0,0 +1,123 @@
+// Package knowledge turns documents into searchable passages: it extracts
+// text, splits it into overlapping chunks and embeds them as vectors.
+package knowledge
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultChunkSize is the target chunk length in characters
+	DefaultChunkSize = 800
+
+	// DefaultChunkOverlap is how much of the previous chunk a chunk repeats
+	DefaultChunkOverlap = 120
+)
+
+var (
+	paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+	sentenceEnd    = regexp.MustCompile(`([.!?])\s+`)
+	whitespace     = regexp.MustCompile(`\s+`)
+)
+
+// Chunk splits text into chunks of about size characters, breaking at
+// paragraphs, then sentences, then words. Each chunk after the first starts
+// with up to overlap characters from the end of the previous one so passages
+// that straddle a break are still found.
+func Chunk(text string, size, overlap int) []string {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var pieces []string
+	for _, paragraph := range paragraphBreak.Split(text, -1) {
+		paragraph = strings.TrimSpace(whitespace.ReplaceAllString(paragraph, " "))
+		if paragraph == "" {
+			continue
+		}
+		pieces = append(pieces, split(paragraph, size)...)
+	}
+
+	var chunks []string
+	var current strings.Builder
+	fresh := false // current holds more than the overlap carried over
+	for _, piece := range pieces {
+		if fresh && runes(current.String())+1+runes(piece) > size {
+			chunk := current.String()
+			chunks = append(chunks, chunk)
+			current.Reset()
+			current.WriteString(tailWords(chunk, overlap))
+		}
+		if current.Len() > 0 {
+			current.WriteString(" ")
+		}
+		current.WriteString(piece)
+		fresh = true
+	}
+	if fresh {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// split breaks a paragraph longer than size at sentence ends, and sentences
+// longer than size at word boundaries
+func split(paragraph string, size int) []string {
+	if runes(paragraph) <= size {
+		return []string{paragraph}
+	}
+
+	var pieces []string
+	for _, sentence := range strings.Split(sentenceEnd.ReplaceAllString(paragraph, "$1\n"), "\n") {
+		if runes(sentence) <= size {
+			pieces = append(pieces, sentence)
+			continue
+		}
+		var line strings.Builder
+		for _, word := range strings.Fields(sentence) {
+			if line.Len() > 0 && runes(line.String())+1+runes(word) > size {
+				pieces = append(pieces, line.String())
+				line.Reset()
+			}
+			if line.Len() > 0 {
+				line.WriteString(" ")
+			}
+			line.WriteString(word)
+		}
+		if line.Len() > 0 {
+			pieces = append(pieces, line.String())
+		}
+	}
+	return pieces
+}
+
+// tailWords returns the last whole words of s that fit in n characters
+func tailWords(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	words := strings.Fields(s)
+	length := 0
+	start := len(words)
+	for start > 0 {
+		next := runes(words[start-1])
+		if length > 0 {
+			next++
+		}
+		if length+next > n {
+			break
+		}
+		length += next
+		start--
+	}
+	return strings.Join(words[start:], " ")
+}
+
+func runes(s string) int {
+	return utf8.RuneCountInString(s)
+}
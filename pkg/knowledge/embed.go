@@ -0,0 +1,315 @@
+package knowledge
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// related the texts are. Vectors from different embedders are not comparable,
+// so stored chunks record the Name of the embedder that produced them.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Dimensions() int
+	Name() string
+}
+
+// HashEmbedder is a dependency-free embedder that hashes word and bigram
+// counts into a fixed number of buckets. It only captures lexical overlap,
+// but needs no external service and is deterministic.
+type HashEmbedder struct {
+	dims int
+}
+
+// NewHashEmbedder creates a hashing embedder with the given dimensions,
+// defaulting to 256
+func NewHashEmbedder(dims int) *HashEmbedder {
+	if dims <= 0 {
+		dims = 256
+	}
+	return &HashEmbedder{dims: dims}
+}
+
+// Name identifies the embedder and its dimensions
+func (e *HashEmbedder) Name() string {
+	return "hash-" + strconv.Itoa(e.dims)
+}
+
+// Dimensions returns the vector length
+func (e *HashEmbedder) Dimensions() int {
+	return e.dims
+}
+
+// Embed hashes each text into a normalized vector
+func (e *HashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	counts := make(map[uint32]float64)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	add := func(term string) {
+		h := fnv.New32a()
+		h.Write([]byte(term))
+		counts[h.Sum32()%uint32(e.dims)]++
+	}
+	for i, word := range words {
+		add(word)
+		if i > 0 {
+			add(words[i-1] + " " + word)
+		}
+	}
+
+	vector := make([]float32, e.dims)
+	for bucket, count := range counts {
+		vector[bucket] = float32(1 + math.Log(count))
+	}
+	normalize(vector)
+	return vector
+}
+
+// OpenAI embedding defaults
+const (
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+	openAIEmbeddingURL          = "https://api.openai.com/v1/embeddings"
+	openAIEmbeddingBatch        = 100
+)
+
+// OpenAIEmbedder embeds texts with the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	apiKey     string
+	model      string
+	dims       int
+	httpClient *http.Client
+}
+
+// NewOpenAIEmbedder creates an embedder for the given API key and model,
+// defaulting to text-embedding-3-small
+func NewOpenAIEmbedder(apiKey, model string) (*OpenAIEmbedder, error) {
+	if apiKey == "" {
+		return nil, errors.New("OpenAI API key is required")
+	}
+	if model == "" {
+		model = DefaultOpenAIEmbeddingModel
+	}
+	dims := 1536
+	if model == "text-embedding-3-large" {
+		dims = 3072
+	}
+	return &OpenAIEmbedder{
+		apiKey:     apiKey,
+		model:      model,
+		dims:       dims,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Name identifies the embedding model
+func (e *OpenAIEmbedder) Name() string {
+	return "openai/" + e.model
+}
+
+// Dimensions returns the vector length of the model
+func (e *OpenAIEmbedder) Dimensions() int {
+	return e.dims
+}
+
+// Embed sends texts to the API in batches
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += openAIEmbeddingBatch {
+		end := start + openAIEmbeddingBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (e *OpenAIEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"model": e.model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", openAIEmbeddingURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed with status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %v", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// Vector is an embedding stored in a Postgres real[] column
+type Vector []float32
+
+// Value encodes the vector as an array literal
+func (v Vector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return "{" + v.join() + "}", nil
+}
+
+// Literal encodes the vector in pgvector's input format
+func (v Vector) Literal() string {
+	return "[" + v.join() + "]"
+}
+
+func (v Vector) join() string {
+	parts := make([]string, len(v))
+	for i, f := range v {
+		parts[i] = strconv.FormatFloat(float64(f), 'g', -1, 32)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Scan decodes an array or pgvector literal
+func (v *Vector) Scan(src interface{}) error {
+	var s string
+	switch value := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case string:
+		s = value
+	case []byte:
+		s = string(value)
+	default:
+		return fmt.Errorf("cannot scan %T into Vector", src)
+	}
+
+	s = strings.Trim(strings.TrimSpace(s), "{}[]")
+	if s == "" {
+		*v = Vector{}
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	vector := make(Vector, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return fmt.Errorf("invalid vector element %q: %w", part, err)
+		}
+		vector[i] = float32(f)
+	}
+	*v = vector
+	return nil
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 when their
+// lengths differ or either is zero
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// Match is a candidate vector's position and similarity to a query
+type Match struct {
+	Index int
+	Score float64
+}
+
+// TopK ranks candidates by cosine similarity to query and returns the k
+// best, highest first
+func TopK(query []float32, candidates [][]float32, k int) []Match {
+	matches := make([]Match, 0, len(candidates))
+	for i, candidate := range candidates {
+		matches = append(matches, Match{Index: i, Score: Cosine(query, candidate)})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if k >= 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
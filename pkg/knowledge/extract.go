@@ -0,0 +1,100 @@
+package knowledge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document formats
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatPDF      = "pdf"
+)
+
+var (
+	// ErrUnsupportedDocument is returned for documents that are not text, Markdown or PDF
+	ErrUnsupportedDocument = errors.New("unsupported document format")
+
+	// ErrNoText is returned when a document has no extractable text
+	ErrNoText = errors.New("document has no text")
+
+	// ErrTooMuchText is returned when a document has more text than allowed
+	ErrTooMuchText = errors.New("document has too much text")
+)
+
+// DetectFormat identifies a document from its content, file name and
+// declared content type
+func DetectFormat(filename, contentType string, data []byte) (string, error) {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return FormatPDF, nil
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("%w: expected UTF-8 text, Markdown or PDF", ErrUnsupportedDocument)
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".md" || ext == ".markdown" || strings.HasPrefix(contentType, "text/markdown") {
+		return FormatMarkdown, nil
+	}
+	return FormatText, nil
+}
+
+// ExtractText returns the plain text of a document. Documents with more
+// than maxChars characters of text (0 for no limit) return ErrTooMuchText;
+// PDFs stop being read as soon as they pass it.
+func ExtractText(filename, contentType string, data []byte, maxChars int) (string, string, error) {
+	format, err := DetectFormat(filename, contentType, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	var text string
+	switch format {
+	case FormatPDF:
+		if text, err = extractPDF(data, maxChars); err != nil {
+			return "", "", err
+		}
+	case FormatMarkdown:
+		text = stripMarkdown(string(data))
+	default:
+		text = string(data)
+	}
+
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return "", "", ErrNoText
+	}
+	if maxChars > 0 && utf8.RuneCountInString(text) > maxChars {
+		return "", "", fmt.Errorf("%w: more than %d characters", ErrTooMuchText, maxChars)
+	}
+	return text, format, nil
+}
+
+var (
+	markdownFence    = regexp.MustCompile("(?m)^\\s*(```|~~~).*$")
+	markdownHeading  = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	markdownQuote    = regexp.MustCompile(`(?m)^\s{0,3}>\s?`)
+	markdownRule     = regexp.MustCompile(`(?m)^\s{0,3}([-*_]\s*){3,}$`)
+	markdownImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	markdownEmphasis = regexp.MustCompile(`(\*\*|__|\*|_|~~|` + "`" + `)([^\s*_~` + "`" + `][^*_~` + "`" + `]*?)(\*\*|__|\*|_|~~|` + "`" + `)`)
+	htmlTag          = regexp.MustCompile(`<[^>]+>`)
+)
+
+// stripMarkdown removes Markdown syntax, keeping the text it marks up
+func stripMarkdown(s string) string {
+	s = markdownFence.ReplaceAllString(s, "")
+	s = markdownHeading.ReplaceAllString(s, "")
+	s = markdownQuote.ReplaceAllString(s, "")
+	s = markdownRule.ReplaceAllString(s, "")
+	s = markdownImage.ReplaceAllString(s, "$1")
+	s = markdownLink.ReplaceAllString(s, "$1")
+	s = markdownEmphasis.ReplaceAllString(s, "$2")
+	return htmlTag.ReplaceAllString(s, "")
+}
@@ -0,0 +1,172 @@
+package knowledge
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkShortText(t *testing.T) {
+	assert.Equal(t, []string{"One paragraph. Another paragraph."}, Chunk("One paragraph.\n\nAnother paragraph.", 100, 10))
+	assert.Empty(t, Chunk("  \n\n ", 100, 10))
+}
+
+func TestChunkSizeAndOverlap(t *testing.T) {
+	var sentences []string
+	for i := 0; i < 40; i++ {
+		sentences = append(sentences, fmt.Sprintf("Sentence number %d talks about the lighthouse.", i))
+	}
+	chunks := Chunk(strings.Join(sentences, " "), 200, 50)
+
+	require.Greater(t, len(chunks), 5)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), 200+50)
+	}
+	for i := 1; i < len(chunks); i++ {
+		prev := strings.Fields(chunks[i-1])
+		assert.Contains(t, chunks[i][:60], prev[len(prev)-1], "chunk %d should repeat the end of the previous chunk", i)
+	}
+	assert.Contains(t, chunks[len(chunks)-1], "Sentence number 39")
+}
+
+func TestChunkSplitsLongWords(t *testing.T) {
+	chunks := Chunk(strings.Repeat("word ", 100), 30, 0)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), 30)
+	}
+	assert.Equal(t, 100, len(strings.Fields(strings.Join(chunks, " "))))
+}
+
+func TestExtractMarkdown(t *testing.T) {
+	md := "# The Keep\n\nThe **keep** has a [drawbridge](http://x) and `three` towers.\n\n> Beware the moat.\n\n---\n"
+	text, format, err := ExtractText("keep.md", "", []byte(md), 0)
+	require.NoError(t, err)
+	assert.Equal(t, FormatMarkdown, format)
+	assert.Contains(t, text, "The Keep")
+	assert.Contains(t, text, "The keep has a drawbridge and three towers.")
+	assert.Contains(t, text, "Beware the moat.")
+	assert.NotContains(t, text, "**")
+	assert.NotContains(t, text, "http://x")
+}
+
+func TestExtractRejectsBinary(t *testing.T) {
+	_, _, err := ExtractText("a.bin", "", []byte{0x00, 0xff, 0x10}, 0)
+	assert.ErrorIs(t, err, ErrUnsupportedDocument)
+
+	_, _, err = ExtractText("empty.txt", "", []byte("  \n"), 0)
+	assert.ErrorIs(t, err, ErrNoText)
+}
+
+func buildPDF(content []byte, flate bool) []byte {
+	dict := fmt.Sprintf("<< /Length %d >>", len(content))
+	if flate {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(content)
+		w.Close()
+		content = buf.Bytes()
+		dict = fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", len(content))
+	}
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	pdf.WriteString("4 0 obj\n" + dict + "\nstream\n")
+	pdf.Write(content)
+	pdf.WriteString("\nendstream\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	content := []byte("BT /F1 12 Tf 72 720 Td (The lighthouse keeper \\(retired\\)) Tj 0 -14 Td " +
+		"[(kept a ) -300 (journal)] TJ T* <4F6E65> Tj ET")
+
+	for _, flate := range []bool{false, true} {
+		text, format, err := ExtractText("notes.pdf", "application/pdf", buildPDF(content, flate), 0)
+		require.NoError(t, err)
+		assert.Equal(t, FormatPDF, format)
+		assert.Equal(t, "The lighthouse keeper (retired)\nkept a journal\nOne", text)
+	}
+}
+
+func TestExtractPDFWithoutText(t *testing.T) {
+	_, _, err := ExtractText("scan.pdf", "", buildPDF([]byte("q 100 0 0 100 0 0 cm /Im1 Do Q"), true), 0)
+	assert.ErrorIs(t, err, ErrNoText)
+}
+
+func TestExtractTextStopsAtBudget(t *testing.T) {
+	content := []byte("BT " + strings.Repeat("(nineteen characters) Tj ", 1000) + "ET")
+
+	_, _, err := ExtractText("long.pdf", "", buildPDF(content, true), 500)
+	assert.ErrorIs(t, err, ErrTooMuchText)
+
+	text, _, err := ExtractText("long.pdf", "", buildPDF(content, true), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 19000, len(text))
+
+	_, _, err = ExtractText("long.txt", "", []byte(strings.Repeat("a", 501)), 500)
+	assert.ErrorIs(t, err, ErrTooMuchText)
+}
+
+func TestExtractPDFCapsTotalInflatedSize(t *testing.T) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(bytes.Repeat([]byte(" "), maxPDFStreamSize))
+	w.Close()
+	stream := buf.Bytes()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	for i := 0; i <= maxPDFInflatedSize/maxPDFStreamSize; i++ {
+		fmt.Fprintf(&pdf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", i+1, len(stream))
+		pdf.Write(stream)
+		pdf.WriteString("\nendstream\nendobj\n")
+	}
+
+	_, _, err := ExtractText("bomb.pdf", "", pdf.Bytes(), 0)
+	assert.ErrorIs(t, err, ErrTooMuchText)
+}
+
+func TestHashEmbedderRanksRelatedText(t *testing.T) {
+	e := NewHashEmbedder(0)
+	assert.Equal(t, 256, e.Dimensions())
+	assert.Equal(t, "hash-256", e.Name())
+
+	docs := []string{
+		"The dragon sleeps beneath the northern mountain guarding gold.",
+		"Bread is baked every morning in the village bakery.",
+		"Ships leave the harbor when the tide is high.",
+	}
+	vectors, err := e.Embed(context.Background(), append(docs, "where does the dragon sleep"))
+	require.NoError(t, err)
+	require.Len(t, vectors, 4)
+
+	matches := TopK(vectors[3], vectors[:3], 2)
+	require.Len(t, matches, 2)
+	assert.Equal(t, 0, matches[0].Index)
+	assert.Greater(t, matches[0].Score, matches[1].Score)
+	assert.InDelta(t, 1.0, Cosine(vectors[0], vectors[0]), 1e-6)
+}
+
+func TestCosineMismatchedLengths(t *testing.T) {
+	assert.Zero(t, Cosine([]float32{1, 0}, []float32{1, 0, 0}))
+	assert.Zero(t, Cosine([]float32{0, 0}, []float32{1, 0}))
+}
+
+func TestVectorRoundTrip(t *testing.T) {
+	v := Vector{0.5, -1.25, 3}
+	value, err := v.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "{0.5,-1.25,3}", value)
+	assert.Equal(t, "[0.5,-1.25,3]", v.Literal())
+
+	var scanned Vector
+	require.NoError(t, scanned.Scan([]byte("{0.5,-1.25,3}")))
+	assert.Equal(t, v, scanned)
+	require.NoError(t, scanned.Scan(nil))
+	assert.Nil(t, scanned)
+}
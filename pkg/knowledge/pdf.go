@@ -0,0 +1,423 @@
+package knowledge
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// maxPDFStreamSize bounds each decompressed stream so small files cannot
+	// inflate without limit
+	maxPDFStreamSize = 32 << 20
+
+	// maxPDFInflatedSize bounds the decompressed size of all streams together
+	maxPDFInflatedSize = 128 << 20
+)
+
+// extractPDF pulls the text shown by a PDF's content streams. It handles
+// uncompressed and Flate streams with simple single-byte or UTF-16 strings,
+// which covers most text exported by word processors. Scanned pages and
+// fonts with custom CID encodings have no recoverable text here. Extraction
+// stops with ErrTooMuchText once more than maxChars characters (0 for no
+// limit) have been read.
+func extractPDF(data []byte, maxChars int) (string, error) {
+	var text strings.Builder
+	chars, inflatedSize := 0, 0
+	pos := 0
+	for {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			break
+		}
+		start := pos + i + len("stream")
+		pos = start
+
+		// "endstream" also contains "stream"
+		if i >= 3 && bytes.HasSuffix(data[:start-len("stream")], []byte("end")) {
+			continue
+		}
+		if start < len(data) && data[start] == '\r' {
+			start++
+		}
+		if start < len(data) && data[start] == '\n' {
+			start++
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := data[start : start+end]
+		pos = start + end + len("endstream")
+
+		dict := streamDictionary(data[:start])
+		if skipStream(dict) {
+			continue
+		}
+		content := raw
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			limit := maxPDFInflatedSize - inflatedSize
+			if limit > maxPDFStreamSize {
+				limit = maxPDFStreamSize
+			}
+			inflated, err := inflate(raw, limit+1)
+			if err != nil {
+				continue
+			}
+			inflatedSize += len(inflated)
+			if inflatedSize > maxPDFInflatedSize {
+				return "", fmt.Errorf("%w: PDF streams inflate to more than %d bytes", ErrTooMuchText, maxPDFInflatedSize)
+			}
+			content = inflated
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue // Other filters are used for images and fonts
+		}
+		if !bytes.Contains(content, []byte("BT")) {
+			continue
+		}
+
+		budget := 0
+		if maxChars > 0 {
+			budget = maxChars - chars
+		}
+		page, n, ok := contentText(content, budget)
+		if !ok {
+			return "", fmt.Errorf("%w: more than %d characters", ErrTooMuchText, maxChars)
+		}
+		text.WriteString(page)
+		text.WriteString("\n\n")
+		chars += n + 2
+	}
+
+	result := strings.TrimSpace(text.String())
+	if result == "" {
+		return "", fmt.Errorf("%w: PDF has no extractable text", ErrNoText)
+	}
+	if !mostlyPrintable(result) {
+		return "", fmt.Errorf("%w: PDF text uses an unsupported font encoding", ErrNoText)
+	}
+	return result, nil
+}
+
+// streamDictionary returns the dictionary of the object a stream belongs to
+func streamDictionary(before []byte) []byte {
+	if i := bytes.LastIndex(before, []byte("obj")); i >= 0 {
+		return before[i:]
+	}
+	return nil
+}
+
+// skipStream reports whether a stream cannot hold page text
+func skipStream(dict []byte) bool {
+	for _, marker := range []string{"/Image", "/XRef", "/ObjStm", "/FontFile", "/Length1", "/Metadata", "/ICCBased", "/N 3", "/N 4"} {
+		if bytes.Contains(dict, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// inflate decompresses at most limit bytes of a Flate stream, tolerating
+// truncated data
+func inflate(raw []byte, limit int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)))
+	if err != nil && len(data) == 0 {
+		return nil, err
+	}
+	return data, nil
+}
+
+// contentText interprets the text operators of a content stream and returns
+// the text with its length in characters. It stops and reports false once
+// the text is longer than budget (0 for no limit).
+func contentText(content []byte, budget int) (string, int, bool) {
+	var out strings.Builder
+	var operands []interface{}
+	lastY := 0.0
+	chars := 0
+
+	write := func(s string) {
+		out.WriteString(s)
+		chars += utf8.RuneCountInString(s)
+	}
+	newline := func() {
+		s := out.String()
+		if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+			write("\n")
+		}
+	}
+	space := func() {
+		s := out.String()
+		if len(s) > 0 && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+			write(" ")
+		}
+	}
+	number := func(i int) float64 {
+		if i < 0 || i >= len(operands) {
+			return 0
+		}
+		f, _ := operands[i].(float64)
+		return f
+	}
+
+	t := &pdfTokenizer{data: content}
+	for {
+		token, ok := t.next()
+		if !ok {
+			break
+		}
+		op, isOp := token.(pdfOperator)
+		if !isOp {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch op {
+		case "ET", "T*":
+			newline()
+		case "Td", "TD":
+			if number(1) != 0 {
+				newline()
+			} else {
+				space()
+			}
+		case "Tm":
+			if y := number(5); y != lastY {
+				lastY = y
+				newline()
+			} else {
+				space()
+			}
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					write(decodePDFString(s))
+				}
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					write(decodePDFString(s))
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				if items, ok := operands[len(operands)-1].([]interface{}); ok {
+					for _, item := range items {
+						switch v := item.(type) {
+						case pdfString:
+							write(decodePDFString(v))
+						case float64:
+							// Large negative adjustments separate words
+							if v < -250 {
+								space()
+							}
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+		if budget > 0 && chars > budget {
+			return "", chars, false
+		}
+	}
+	return out.String(), chars, true
+}
+
+type (
+	pdfOperator string
+	pdfString   []byte
+	pdfName     string
+)
+
+// pdfTokenizer splits a content stream into operands and operators
+type pdfTokenizer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+// next returns the next token: float64, pdfString, pdfName, []interface{}
+// or pdfOperator
+func (t *pdfTokenizer) next() (interface{}, bool) {
+	for t.pos < len(t.data) {
+		c := t.data[t.pos]
+		switch {
+		case isPDFSpace(c):
+			t.pos++
+		case c == '%':
+			for t.pos < len(t.data) && t.data[t.pos] != '\n' && t.data[t.pos] != '\r' {
+				t.pos++
+			}
+		case c == '(':
+			t.pos++
+			return t.literal(), true
+		case c == '<' && t.pos+1 < len(t.data) && t.data[t.pos+1] == '<':
+			t.pos += 2
+			return pdfOperator("<<"), true
+		case c == '>' && t.pos+1 < len(t.data) && t.data[t.pos+1] == '>':
+			t.pos += 2
+			return pdfOperator(">>"), true
+		case c == '<':
+			t.pos++
+			return t.hexString(), true
+		case c == '[':
+			t.pos++
+			var items []interface{}
+			for {
+				item, ok := t.next()
+				if !ok || item == pdfOperator("]") {
+					return items, true
+				}
+				items = append(items, item)
+			}
+		case c == ']':
+			t.pos++
+			return pdfOperator("]"), true
+		case c == '/':
+			t.pos++
+			return pdfName(t.word()), true
+		case isPDFDelimiter(c):
+			t.pos++
+		default:
+			w := t.word()
+			if f, err := strconv.ParseFloat(w, 64); err == nil {
+				return f, true
+			}
+			return pdfOperator(w), true
+		}
+	}
+	return nil, false
+}
+
+// word reads a regular token
+func (t *pdfTokenizer) word() string {
+	start := t.pos
+	for t.pos < len(t.data) && !isPDFSpace(t.data[t.pos]) && !isPDFDelimiter(t.data[t.pos]) {
+		t.pos++
+	}
+	return string(t.data[start:t.pos])
+}
+
+// literal reads a (string) after its opening parenthesis
+func (t *pdfTokenizer) literal() pdfString {
+	var s []byte
+	depth := 1
+	for t.pos < len(t.data) {
+		c := t.data[t.pos]
+		t.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s
+			}
+		case '\\':
+			if t.pos >= len(t.data) {
+				return s
+			}
+			e := t.data[t.pos]
+			t.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// Line continuation
+				if e == '\r' && t.pos < len(t.data) && t.data[t.pos] == '\n' {
+					t.pos++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for n := 0; n < 2 && t.pos < len(t.data) && t.data[t.pos] >= '0' && t.data[t.pos] <= '7'; n++ {
+						v = v*8 + int(t.data[t.pos]-'0')
+						t.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return s
+}
+
+// hexString reads a <hex string> after its opening bracket
+func (t *pdfTokenizer) hexString() pdfString {
+	var digits []byte
+	for t.pos < len(t.data) && t.data[t.pos] != '>' {
+		if c := t.data[t.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		t.pos++
+	}
+	t.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s, _ := hex.DecodeString(string(digits))
+	return s
+}
+
+// decodePDFString converts a UTF-16 string with a byte order mark, or a
+// single-byte string read as Latin-1
+func decodePDFString(s pdfString) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// mostlyPrintable reports whether text looks like words rather than glyph IDs
+func mostlyPrintable(s string) bool {
+	total, printable := 0, 0
+	for _, r := range s {
+		total++
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			printable++
+		}
+	}
+	return total > 0 && printable*100/total >= 90
+}
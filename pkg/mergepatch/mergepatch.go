@@ -0,0 +1,52 @@
+// Package mergepatch applies JSON merge patches (RFC 7386)
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidPatch is returned when a patch is not a JSON object
+var ErrInvalidPatch = errors.New("merge patch must be a JSON object")
+
+// Apply merges patch into doc and returns the result. Members set to null in
+// the patch are removed, objects are merged recursively and every other value
+// replaces the target's.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var patchValue interface{}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, err
+	}
+	if _, ok := patchValue.(map[string]interface{}); !ok {
+		return nil, ErrInvalidPatch
+	}
+
+	var target interface{}
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &target); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(merge(target, patchValue))
+}
+
+func merge(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = merge(targetObject[key], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,94 @@
+// Package pagination provides opaque keyset cursors for list endpoints.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultLimit is the page size used when a request does not set one
+	DefaultLimit = 20
+
+	// MaxLimit bounds the page size a request may ask for
+	MaxLimit = 100
+)
+
+// ErrInvalidCursor is returned for cursors that cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a list ordered by (time, id), or by (score, id)
+// for lists sorted by a number. The ID breaks ties between rows that share a
+// timestamp or score.
+type Cursor struct {
+	Time  time.Time `json:"t"`
+	Score float64   `json:"s,omitempty"`
+	ID    uint      `json:"id"`
+}
+
+// Encode returns the cursor as an opaque URL-safe string
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a cursor produced by Encode. An empty string yields nil.
+func Decode(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// ClampLimit applies the default and maximum page size
+func ClampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+// ErrConflictingCursors is returned when a request sets both before and after
+var ErrConflictingCursors = errors.New("only one of before and after may be set")
+
+// Page selects a window of a list ordered oldest first. With neither cursor
+// set it selects the newest items.
+type Page struct {
+	Before *Cursor // Items strictly older than this cursor
+	After  *Cursor // Items strictly newer than this cursor
+	Limit  int
+}
+
+// ParsePage decodes before/after cursors and clamps the limit
+func ParsePage(before, after string, limit int) (Page, error) {
+	if before != "" && after != "" {
+		return Page{}, ErrConflictingCursors
+	}
+
+	b, err := Decode(before)
+	if err != nil {
+		return Page{}, err
+	}
+	a, err := Decode(after)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return Page{Before: b, After: a, Limit: ClampLimit(limit)}, nil
+}
@@ -0,0 +1,56 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{Time: time.Date(2024, 5, 1, 12, 30, 0, 123, time.UTC), ID: 42}
+
+	decoded, err := Decode(c.Encode())
+	require.NoError(t, err)
+	assert.True(t, c.Time.Equal(decoded.Time))
+	assert.Equal(t, c.ID, decoded.ID)
+}
+
+func TestScoreCursorRoundTrip(t *testing.T) {
+	c := Cursor{Score: 0.8333333333333334, ID: 9}
+
+	decoded, err := Decode(c.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, c.Score, decoded.Score)
+	assert.Equal(t, c.ID, decoded.ID)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, s := range []string{"!!", "bm90IGpzb24", Cursor{}.Encode()} {
+		_, err := Decode(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+
+	c, err := Decode("")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+}
+
+func TestParsePage(t *testing.T) {
+	cursor := Cursor{Time: time.Now(), ID: 7}.Encode()
+
+	page, err := ParsePage(cursor, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), page.Before.ID)
+	assert.Nil(t, page.After)
+	assert.Equal(t, DefaultLimit, page.Limit)
+
+	page, err = ParsePage("", cursor, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), page.After.ID)
+	assert.Equal(t, MaxLimit, page.Limit)
+
+	_, err = ParsePage(cursor, cursor, 10)
+	assert.ErrorIs(t, err, ErrConflictingCursors)
+}
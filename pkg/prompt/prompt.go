@@ -0,0 +1,123 @@
+// Package prompt turns a character's persona into the instructions the chat
+// model plays it from.
+package prompt
+
+import (
+	"regexp"
+	"strings"
+
+	"ai-agent-character-demo/backend/pkg/ws"
+)
+
+// DefaultUserName stands in for {{user}} when the user's name is unknown
+const DefaultUserName = "User"
+
+var (
+	charMacro    = regexp.MustCompile(`(?i)\{\{char\}\}|<bot>`)
+	userMacro    = regexp.MustCompile(`(?i)\{\{user\}\}|<user>`)
+	startMarker  = regexp.MustCompile(`(?im)^\s*<start>\s*$`)
+	blankLines   = regexp.MustCompile(`\n{3,}`)
+	trailingStop = regexp.MustCompile(`[.!?]$`)
+)
+
+// SystemPrompt describes the character from every persona field it has.
+// Empty fields are left out, except personality, which falls back to the
+// description.
+func SystemPrompt(c *ws.Character) string {
+	var b strings.Builder
+	b.WriteString("You are " + c.Name + ".")
+	if description := strings.TrimSpace(c.Description); description != "" {
+		b.WriteString(" " + sentence(description))
+	}
+
+	var persona []string
+	add := func(label, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			persona = append(persona, label+": "+value)
+		}
+	}
+	// Many character cards leave personality empty and describe it in the description
+	personality := c.Personality
+	if strings.TrimSpace(personality) == "" {
+		personality = c.Description
+	}
+	add("Personality", personality)
+	add("Traits", join(c.Traits, ", "))
+	add("Background", c.Background)
+	add("Goals", join(c.Goals, "; "))
+	add("Fears", join(c.Fears, "; "))
+	add("Relationships", join(c.Relationships, "; "))
+	if len(persona) > 0 {
+		b.WriteString("\n\n" + strings.Join(persona, "\n"))
+	}
+
+	if scenario := strings.TrimSpace(c.Scenario); scenario != "" {
+		b.WriteString("\n\nScenario: " + Expand(scenario, c.Name, DefaultUserName))
+	}
+
+	if examples := ExampleDialogue(c.ExampleDialogue, c.Name, DefaultUserName); examples != "" {
+		b.WriteString("\n\nExample dialogue, showing how you speak. It is not part of this conversation:\n" + examples)
+	}
+
+	b.WriteString("\n\nStay in character as " + c.Name + " and respond concisely and engagingly.")
+	return b.String()
+}
+
+// Greeting returns the message that opens a conversation: the greeting or one
+// of the alternates, chosen by pick (which returns a number in [0, n)).
+// It returns an empty string when the character has no greeting.
+func Greeting(c *ws.Character, userName string, pick func(n int) int) string {
+	var greetings []string
+	for _, greeting := range append([]string{c.Greeting}, c.AlternateGreetings...) {
+		if strings.TrimSpace(greeting) != "" {
+			greetings = append(greetings, greeting)
+		}
+	}
+	if len(greetings) == 0 {
+		return ""
+	}
+
+	greeting := greetings[0]
+	if len(greetings) > 1 && pick != nil {
+		greeting = greetings[pick(len(greetings))]
+	}
+	return strings.TrimSpace(Expand(greeting, c.Name, userName))
+}
+
+// ExampleDialogue formats example exchanges written in the character card
+// convention: blocks separated by <START> lines with {{char}} and {{user}}
+// placeholders
+func ExampleDialogue(examples, charName, userName string) string {
+	examples = startMarker.ReplaceAllString(examples, "")
+	examples = blankLines.ReplaceAllString(strings.ReplaceAll(examples, "\r\n", "\n"), "\n\n")
+	return strings.TrimSpace(Expand(examples, charName, userName))
+}
+
+// Expand replaces the {{char}} and {{user}} placeholders (and the older
+// <BOT> and <USER>) with names
+func Expand(text, charName, userName string) string {
+	if userName == "" {
+		userName = DefaultUserName
+	}
+	text = charMacro.ReplaceAllLiteralString(text, charName)
+	return userMacro.ReplaceAllLiteralString(text, userName)
+}
+
+// join lists the non-empty items
+func join(items []string, sep string) string {
+	var kept []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			kept = append(kept, item)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// sentence ends text with a full stop unless it already ends a sentence
+func sentence(text string) string {
+	if trailingStop.MatchString(text) {
+		return text
+	}
+	return text + "."
+}
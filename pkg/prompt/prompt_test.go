@@ -0,0 +1,69 @@
+package prompt
+
+import (
+	"testing"
+
+	"ai-agent-character-demo/backend/pkg/ws"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemPromptUsesEveryPersonaField(t *testing.T) {
+	c := &ws.Character{
+		Name:            "Ada",
+		Description:     "A mathematician",
+		Personality:     "Curious and precise",
+		Background:      "Daughter of a poet",
+		Scenario:        "{{user}} visits {{char}} in her study.",
+		Traits:          []string{"witty", " ", "patient"},
+		Goals:           []string{"finish the notes"},
+		Fears:           []string{"being forgotten"},
+		Relationships:   []string{"Charles: collaborator"},
+		ExampleDialogue: "<START>\n{{user}}: Hello\n{{char}}: Numbers!\n<START>\n{{user}}: Why?\n{{char}}: Because.",
+	}
+
+	got := SystemPrompt(c)
+	assert.Contains(t, got, "You are Ada. A mathematician.")
+	assert.Contains(t, got, "Personality: Curious and precise")
+	assert.Contains(t, got, "Traits: witty, patient")
+	assert.Contains(t, got, "Background: Daughter of a poet")
+	assert.Contains(t, got, "Goals: finish the notes")
+	assert.Contains(t, got, "Fears: being forgotten")
+	assert.Contains(t, got, "Relationships: Charles: collaborator")
+	assert.Contains(t, got, "Scenario: User visits Ada in her study.")
+	assert.Contains(t, got, "User: Hello\nAda: Numbers!\n\nUser: Why?\nAda: Because.")
+	assert.NotContains(t, got, "<START>")
+}
+
+func TestSystemPromptSkipsEmptyFields(t *testing.T) {
+	got := SystemPrompt(&ws.Character{Name: "Bob", Description: "A sailor!", Personality: "gruff"})
+	assert.Equal(t, "You are Bob. A sailor!\n\nPersonality: gruff\n\nStay in character as Bob and respond concisely and engagingly.", got)
+}
+
+func TestSystemPromptPersonalityFallsBackToDescription(t *testing.T) {
+	got := SystemPrompt(&ws.Character{Name: "Bob", Description: "A gruff sailor."})
+	assert.Contains(t, got, "Personality: A gruff sailor.")
+}
+
+func TestGreetingPicksAmongAlternates(t *testing.T) {
+	c := &ws.Character{
+		Name:               "Ada",
+		Greeting:           "Hello, {{user}}.",
+		AlternateGreetings: []string{"", "Ah, {{user}}! {{char}} at your service."},
+	}
+
+	assert.Equal(t, "Hello, Sam.", Greeting(c, "Sam", func(int) int { return 0 }))
+	assert.Equal(t, "Ah, Sam! Ada at your service.", Greeting(c, "Sam", func(n int) int {
+		assert.Equal(t, 2, n)
+		return 1
+	}))
+	assert.Equal(t, "Hello, User.", Greeting(c, "", nil))
+}
+
+func TestGreetingEmpty(t *testing.T) {
+	assert.Empty(t, Greeting(&ws.Character{Name: "Ada", AlternateGreetings: []string{"  "}}, "", nil))
+}
+
+func TestExpandOldMacros(t *testing.T) {
+	assert.Equal(t, "Ada greets Sam", Expand("<BOT> greets <user>", "Ada", "Sam"))
+}
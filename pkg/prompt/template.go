@@ -0,0 +1,148 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"ai-agent-character-demo/backend/pkg/ws"
+)
+
+// ErrTemplate is returned for templates that do not parse or render
+var ErrTemplate = errors.New("invalid prompt template")
+
+// Data is what a prompt template is rendered with
+type Data struct {
+	Character *ws.Character
+	User      User
+	Memory    Memory
+}
+
+// User is the person talking to the character
+type User struct {
+	Name        string
+	Preferences Preferences
+}
+
+// Preferences are the user's personalization settings
+type Preferences struct {
+	ChatStyle string // e.g. concise or detailed
+	Voice     string
+}
+
+// Memory is what the character remembers of the conversation so far
+type Memory struct {
+	Title   string
+	Summary string
+}
+
+// UserName returns the user's name, or DefaultUserName when it is unknown
+func (d Data) UserName() string {
+	if name := strings.TrimSpace(d.User.Name); name != "" {
+		return name
+	}
+	return DefaultUserName
+}
+
+// Default returns the built-in system prompt, so templates can extend it
+func (d Data) Default() string {
+	return SystemPrompt(d.Character)
+}
+
+// Expand replaces the {{char}} and {{user}} placeholders in text
+func (d Data) Expand(text string) string {
+	return Expand(text, d.Character.Name, d.UserName())
+}
+
+// ExampleDialogue returns the character's formatted example exchanges
+func (d Data) ExampleDialogue() string {
+	return ExampleDialogue(d.Character.ExampleDialogue, d.Character.Name, d.UserName())
+}
+
+// funcs are the helpers available to templates besides the Data methods
+var funcs = template.FuncMap{
+	"join":     join,
+	"sentence": sentence,
+	"trim":     strings.TrimSpace,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"default": func(fallback, value string) string {
+		if strings.TrimSpace(value) == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// Parse compiles a prompt template
+func Parse(name, text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%w: template is empty", ErrTemplate)
+	}
+	t, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTemplate, err)
+	}
+	return t, nil
+}
+
+// Render executes a template and tidies its whitespace
+func Render(t *template.Template, data Data) (string, error) {
+	if data.Character == nil {
+		data.Character = &ws.Character{}
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTemplate, err)
+	}
+	text := strings.ReplaceAll(b.String(), "\r\n", "\n")
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n")), nil
+}
+
+// Check parses a template and renders it with sample data, catching
+// references to fields that do not exist
+func Check(name, text string) error {
+	t, err := Parse(name, text)
+	if err != nil {
+		return err
+	}
+	_, err = Render(t, SampleData())
+	return err
+}
+
+// SampleData is a made-up character and user for previewing templates
+func SampleData() Data {
+	return Data{
+		Character: &ws.Character{
+			Name:            "Ada",
+			Description:     "A patient mathematics tutor",
+			Personality:     "warm, curious and precise",
+			Background:      "Taught at a village school for twenty years",
+			Scenario:        "{{user}} asks {{char}} for help with homework",
+			Traits:          []string{"patient", "encouraging"},
+			Goals:           []string{"help {{user}} understand, not just answer"},
+			Greeting:        "Hello {{user}}, what are we working on today?",
+			ExampleDialogue: "<START>\n{{user}}: Is this right?\n{{char}}: Almost. Look at the second step again.",
+			VoiceType:       "female",
+		},
+		User: User{
+			Name:        "Sam",
+			Preferences: Preferences{ChatStyle: "concise", Voice: "female"},
+		},
+		Memory: Memory{
+			Title:   "Fractions homework",
+			Summary: "Sam asked about adding fractions with different denominators.",
+		},
+	}
+}
+
+// For returns the prompt a reply is generated from: the character's rendered
+// template when one applied, otherwise the built-in SystemPrompt
+func For(c *ws.Character) string {
+	if strings.TrimSpace(c.SystemPrompt) != "" {
+		return c.SystemPrompt
+	}
+	return SystemPrompt(c)
+}
@@ -0,0 +1,53 @@
+package prompt
+
+import (
+	"testing"
+
+	"ai-agent-character-demo/backend/pkg/ws"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderUsesCharacterUserAndMemory(t *testing.T) {
+	tmpl, err := Parse("persona", `You are {{.Character.Name}}, {{lower .Character.Personality}}.
+Traits: {{join .Character.Traits ", "}}
+
+
+{{.Expand .Character.Scenario}}
+{{if .User.Preferences.ChatStyle}}Keep replies {{.User.Preferences.ChatStyle}}.{{end}}
+Earlier: {{default "nothing yet" .Memory.Summary}}`)
+	require.NoError(t, err)
+
+	got, err := Render(tmpl, Data{
+		Character: &ws.Character{Name: "Ada", Personality: "Curious", Traits: []string{"witty", "patient"}, Scenario: "{{user}} meets {{char}}."},
+		User:      User{Name: "Sam", Preferences: Preferences{ChatStyle: "concise"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "You are Ada, curious.\nTraits: witty, patient\n\nSam meets Ada.\nKeep replies concise.\nEarlier: nothing yet", got)
+}
+
+func TestRenderDefaultExtendsBuiltInPrompt(t *testing.T) {
+	tmpl, err := Parse("extended", "{{.Default}}\n\nAlways answer in French.")
+	require.NoError(t, err)
+
+	c := &ws.Character{Name: "Bob", Personality: "gruff"}
+	got, err := Render(tmpl, Data{Character: c})
+	require.NoError(t, err)
+	assert.Equal(t, SystemPrompt(c)+"\n\nAlways answer in French.", got)
+}
+
+func TestCheckRejectsBadTemplates(t *testing.T) {
+	assert.ErrorIs(t, Check("empty", "  "), ErrTemplate)
+	assert.ErrorIs(t, Check("syntax", "{{.Character.Name"), ErrTemplate)
+	assert.ErrorIs(t, Check("field", "{{.Character.Nickname}}"), ErrTemplate)
+	assert.NoError(t, Check("ok", "{{.Character.Name}} talks to {{.UserName}}. {{.ExampleDialogue}}"))
+}
+
+func TestForPrefersRenderedPrompt(t *testing.T) {
+	c := &ws.Character{Name: "Bob"}
+	assert.Equal(t, SystemPrompt(c), For(c))
+
+	c.SystemPrompt = "You are Bob, a template."
+	assert.Equal(t, "You are Bob, a template.", For(c))
+}
@@ -0,0 +1,32 @@
+// Package redact removes personally identifiable information from free text.
+package redact
+
+import (
+	"regexp"
+)
+
+// rule replaces every match of pattern with a placeholder
+type rule struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}
+
+// Rules run in order; card and SSN patterns come before phone numbers so
+// long digit runs get the more specific label.
+var rules = []rule{
+	{regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`(?i)\bhttps?://[^\s]+`), "[URL]"},
+	{regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`), "[CARD]"},
+	{regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), "[SSN]"},
+	{regexp.MustCompile(`(?:\+\d{1,3}[ .\-]?)?(?:\(\d{2,4}\)[ .\-]?|\b\d{2,4}[ .\-])\d{3,4}[ .\-]\d{3,4}\b`), "[PHONE]"},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), "[IP]"},
+}
+
+// String returns s with email addresses, URLs, card numbers, US social
+// security numbers, phone numbers and IPv4 addresses replaced by placeholders
+func String(s string) string {
+	for _, r := range rules {
+		s = r.pattern.ReplaceAllString(s, r.placeholder)
+	}
+	return s
+}
@@ -0,0 +1,29 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"email", "mail me at jane.doe+ai@example.co.uk please", "mail me at [EMAIL] please"},
+		{"url", "see https://example.com/a?b=c for more", "see [URL] for more"},
+		{"card", "card 4111 1111 1111 1111 expires soon", "card [CARD] expires soon"},
+		{"ssn", "my ssn is 123-45-6789", "my ssn is [SSN]"},
+		{"phone", "call (555) 123-4567 or +44 20 7946 0958", "call [PHONE] or [PHONE]"},
+		{"ip", "server at 192.168.1.20 is down", "server at [IP] is down"},
+		{"plain", "I was born in 1990 and have 3 cats", "I was born in 1990 and have 3 cats"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, String(tt.in))
+		})
+	}
+}
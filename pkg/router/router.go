@@ -68,6 +68,16 @@ func New(container *di.Container) *Router {
 	// Set audio service in the hub for automatic audio storage
 	hub.SetAudioService(container.AudioService)
 
+	// Authenticate connections and tie each session to an owned conversation
+	hub.SetJWTService(container.JWTService)
+	hub.SetConversationService(container.ConversationAdapter)
+
+	// Ground replies in the documents attached to each character
+	hub.SetKnowledgeService(container.KnowledgeService)
+
+	// Build system prompts from the templates bound to each character
+	hub.SetPromptService(container.PromptTemplateService)
+
 	// Start the hub
 	go hub.Run()
 
@@ -91,13 +101,28 @@ func (r *Router) SetupRoutes() {
 	// Initialize controllers with proper constructor signatures
 	authHandler := api.NewAuthHandler(r.Container.UserService, r.Container.JWTService, r.Logger)
 	characterHandler := api.NewCharacterHandler(r.Container.CharacterService)
+	characterHandler.SetKnowledgeService(r.Container.KnowledgeService)
 	audioController := api.NewAudioController(r.Container.AudioService, r.Container.JWTService)
+	audioController.SetMessageAudioService(r.Container.MessageAudioService)
+	audioController.SetWaveformService(r.Container.WaveformService)
+	audioController.SetUploadService(r.Container.AudioUploadService)
+	audioController.SetIntegrityService(r.Container.AudioIntegrityService)
+	audioController.SetConversationService(r.Container.ConversationService)
+	recordingHandler := api.NewRecordingHandler(r.Container.RecordingService)
+	conversationHandler := api.NewConversationHandler(r.Container.ConversationService)
+	feedbackHandler := api.NewFeedbackHandler(r.Container.FeedbackService)
+	shareHandler := api.NewShareHandler(r.Container.ShareService, r.Container.MessageAudioService)
 	messageController := api.NewMessageController(
 		r.Container.MessageService,
 		r.Container.CharacterService,
 		r.Container.AIServiceAdapter,
 		r.Container.JWTService,
 	)
+	messageController.SetConversationService(r.Container.ConversationService)
+	messageController.SetFeedbackService(r.Container.FeedbackService)
+	messageController.SetKnowledgeService(r.Container.KnowledgeService)
+	messageController.SetPromptService(r.Container.PromptTemplateService)
+	promptTemplateHandler := api.NewPromptTemplateHandler(r.Container.PromptTemplateService)
 
 	// API version 1 routes
 	v1 := r.Engine.Group("/api/v1")
@@ -115,6 +140,16 @@ func (r *Router) SetupRoutes() {
 			authRoutes.POST("/login", authHandler.Login)
 			authRoutes.GET("/me", jwtAuth, authHandler.Me)
 		}
+
+		// Shared conversations are readable by anyone holding the token
+		sharedRoutes := publicRoutes.Group("/shared")
+		{
+			sharedRoutes.GET("/:token", shareHandler.GetSharedConversation)
+			sharedRoutes.GET("/:token/audio/:messageId", shareHandler.GetSharedAudio)
+		}
+
+		// Avatars are public so they load in <img> tags
+		publicRoutes.GET("/avatars/:avatarId", characterHandler.GetAvatar)
 	}
 
 	// Protected routes (require authentication)
@@ -126,19 +161,76 @@ func (r *Router) SetupRoutes() {
 		adminRoutes.Use(middleware.RequireRole(jwt.RoleAdmin))
 		{
 			adminRoutes.PUT("/users/:id/role", authHandler.UpdateUserRole)
+			adminRoutes.PUT("/characters/:id/owner", characterHandler.TransferCharacterOwnership)
+			adminRoutes.POST("/categories", characterHandler.CreateCategory)
+			adminRoutes.PUT("/categories/:slug", characterHandler.UpdateCategory)
+			adminRoutes.DELETE("/categories/:slug", characterHandler.DeleteCategory)
+			adminRoutes.GET("/prompt-templates", promptTemplateHandler.ListPromptTemplates)
+			adminRoutes.POST("/prompt-templates", promptTemplateHandler.CreatePromptTemplate)
+			adminRoutes.POST("/prompt-templates/preview", promptTemplateHandler.PreviewPromptTemplate)
+			adminRoutes.GET("/prompt-templates/:name", promptTemplateHandler.GetPromptTemplate)
+			adminRoutes.DELETE("/prompt-templates/:name", promptTemplateHandler.DeactivatePromptTemplate)
+			adminRoutes.GET("/prompt-templates/:name/versions/:version", promptTemplateHandler.GetPromptTemplateVersion)
+			adminRoutes.POST("/prompt-templates/:name/versions/:version/activate", promptTemplateHandler.ActivatePromptTemplateVersion)
 		}
 
 		// Character routes - protected by auth
 		characterRoutes := protectedRoutes.Group("/characters")
 		{
 			characterRoutes.POST("", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.CreateCharacter)
+			characterRoutes.POST("/import", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.ImportCharacterCard)
 			characterRoutes.GET("", middleware.RequirePermission(jwt.PermReadCharacter), characterHandler.ListCharacters)
 			characterRoutes.GET("/:id", middleware.RequirePermission(jwt.PermReadCharacter), characterHandler.GetCharacter)
 			characterRoutes.GET("/all", middleware.RequirePermission(jwt.PermReadCharacter), characterHandler.ListAllCharacters)
+			characterRoutes.PUT("/:id", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.UpdateCharacter)
+			characterRoutes.PATCH("/:id", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.PatchCharacter)
+			characterRoutes.DELETE("/:id", middleware.RequirePermission(jwt.PermDeleteCharacter), characterHandler.DeleteCharacter)
+			characterRoutes.PUT("/:id/avatar", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.SetCharacterAvatar)
+			characterRoutes.DELETE("/:id/avatar", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.DeleteCharacterAvatar)
+			characterRoutes.PUT("/:id/visibility", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.SetCharacterVisibility)
+			characterRoutes.GET("/:id/export", middleware.RequirePermission(jwt.PermReadCharacter), characterHandler.ExportCharacterCard)
+			characterRoutes.POST("/:id/knowledge", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.AddKnowledgeDocument)
+			characterRoutes.GET("/:id/knowledge", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.ListKnowledgeDocuments)
+			characterRoutes.GET("/:id/knowledge/search", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.SearchKnowledge)
+			characterRoutes.DELETE("/:id/knowledge/:docId", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.DeleteKnowledgeDocument)
+			characterRoutes.GET("/:id/versions", middleware.RequirePermission(jwt.PermReadCharacter), characterHandler.ListCharacterVersions)
+			characterRoutes.GET("/:id/versions/:version", middleware.RequirePermission(jwt.PermReadCharacter), characterHandler.GetCharacterVersion)
+			characterRoutes.POST("/:id/versions/:version/rollback", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.RollbackCharacter)
+		}
 
-			// Comment out these routes until the methods are implemented
-			// characterRoutes.PUT("/:id", middleware.RequirePermission(jwt.PermWriteCharacter), characterHandler.UpdateCharacter)
-			// characterRoutes.DELETE("/:id", middleware.RequirePermission(jwt.PermDeleteCharacter), characterHandler.DeleteCharacter)
+		protectedRoutes.GET("/categories", middleware.RequirePermission(jwt.PermReadCharacter), characterHandler.ListCategories)
+
+		// Conversation routes
+		conversationRoutes := protectedRoutes.Group("/conversations")
+		{
+			conversationRoutes.GET("", conversationHandler.ListConversations)
+			conversationRoutes.POST("", conversationHandler.CreateConversation)
+			conversationRoutes.POST("/import", conversationHandler.ImportConversation)
+			conversationRoutes.POST("/claim", conversationHandler.ClaimConversation)
+			conversationRoutes.GET("/:id", conversationHandler.GetConversation)
+			conversationRoutes.PATCH("/:id", conversationHandler.UpdateConversation)
+			conversationRoutes.POST("/:id/participants", conversationHandler.InviteCharacter)
+			conversationRoutes.POST("/:id/archive", conversationHandler.ArchiveConversation)
+			conversationRoutes.POST("/:id/unarchive", conversationHandler.UnarchiveConversation)
+			conversationRoutes.DELETE("/:id", conversationHandler.DeleteConversation)
+			conversationRoutes.GET("/:id/export", conversationHandler.ExportConversation)
+			conversationRoutes.POST("/:id/shares", shareHandler.CreateShare)
+			conversationRoutes.GET("/:id/shares", shareHandler.ListShares)
+			conversationRoutes.DELETE("/:id/shares/:shareId", shareHandler.RevokeShare)
+		}
+
+		// Analytics routes
+		analyticsRoutes := protectedRoutes.Group("/analytics")
+		analyticsRoutes.Use(middleware.RequirePermission(jwt.PermAccessAnalytics))
+		{
+			analyticsRoutes.GET("/feedback", feedbackHandler.GetFeedbackAnalytics)
+		}
+
+		// Session export routes
+		sessionRoutes := protectedRoutes.Group("/sessions")
+		{
+			sessionRoutes.GET("/:id/recording", recordingHandler.GetRecording)
+			sessionRoutes.GET("/:id/recording/transcript", recordingHandler.GetRecordingTranscript)
 		}
 	}
 
@@ -192,9 +284,9 @@ func corsMiddleware() gin.HandlerFunc {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		}
 
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept, Accept-Encoding, X-CSRF-Token, Authorization, Origin, Upgrade, Connection, Cache-Control")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Upgrade, Connection")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept, Accept-Encoding, X-CSRF-Token, Authorization, Origin, Upgrade, Connection, Cache-Control, Range, Upload-Offset, Upload-Length, Upload-Checksum, Tus-Resumable")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Upgrade, Connection, Location, ETag, Content-Range, Accept-Ranges, Upload-Offset, Upload-Length, Tus-Resumable")
 		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
 
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,258 @@
+// Package scene runs conversations with several characters: it picks which
+// character answers each user turn and gives the speaker the context of the
+// others in the room.
+package scene
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"ai-agent-character-demo/backend/pkg/ws"
+)
+
+// Speaker routing modes
+const (
+	RoutingRoundRobin = "round_robin" // Characters take turns in cast order
+	RoutingMention    = "mention"     // The character the user names answers, else the next in turn
+	RoutingModel      = "model"       // The chat model picks who answers, unless the user names someone
+)
+
+// MinCast is the number of characters a scene needs
+const MinCast = 2
+
+// ErrInvalidRouting is returned for unknown speaker routing modes
+var ErrInvalidRouting = errors.New("speaker routing must be round_robin, mention or model")
+
+// ValidRouting reports whether routing is a known mode
+func ValidRouting(routing string) bool {
+	switch routing {
+	case RoutingRoundRobin, RoutingMention, RoutingModel:
+		return true
+	}
+	return false
+}
+
+// wordPattern splits names into words
+var wordPattern = regexp.MustCompile(`[\pL\pN]+`)
+
+// Next returns the character after lastSpeaker in cast order, or the first
+// when lastSpeaker is not in the cast
+func Next(cast []*ws.Character, lastSpeaker uint) *ws.Character {
+	if len(cast) == 0 {
+		return nil
+	}
+	for i, c := range cast {
+		if c.ID == lastSpeaker {
+			return cast[(i+1)%len(cast)]
+		}
+	}
+	return cast[0]
+}
+
+// LastSpeaker returns the character who spoke last in history, or 0
+func LastSpeaker(history []ws.ChatMessage) uint {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Sender == "character" && history[i].CharacterID != 0 {
+			return history[i].CharacterID
+		}
+	}
+	return 0
+}
+
+// Mentioned returns the character text names first. A character is named
+// by its full name, or by a word of its name no other cast member shares,
+// with or without a leading @.
+func Mentioned(cast []*ws.Character, text string) (*ws.Character, bool) {
+	terms := addressTerms(cast)
+
+	var found *ws.Character
+	first := -1
+	for _, c := range cast {
+		for _, term := range terms[c.ID] {
+			if at := indexWord(text, term); at >= 0 && (first < 0 || at < first) {
+				found, first = c, at
+			}
+		}
+	}
+	return found, found != nil
+}
+
+// addressTerms lists what each cast member can be called: the full name and
+// the words of it no other cast member shares
+func addressTerms(cast []*ws.Character) map[uint][]string {
+	shared := map[string]int{}
+	for _, c := range cast {
+		seen := map[string]bool{}
+		for _, w := range nameWords(c.Name) {
+			if !seen[w] {
+				seen[w] = true
+				shared[w]++
+			}
+		}
+	}
+
+	terms := make(map[uint][]string, len(cast))
+	for _, c := range cast {
+		terms[c.ID] = []string{c.Name}
+		for _, w := range nameWords(c.Name) {
+			if shared[w] == 1 {
+				terms[c.ID] = append(terms[c.ID], w)
+			}
+		}
+	}
+	return terms
+}
+
+// nameWords returns the lowercase words of a name long enough to address
+// someone by
+func nameWords(name string) []string {
+	var words []string
+	for _, w := range wordPattern.FindAllString(strings.ToLower(name), -1) {
+		if len([]rune(w)) >= 3 {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// indexWord finds term in text as a whole word, ignoring case
+func indexWord(text, term string) int {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return -1
+	}
+	pattern, err := regexp.Compile(`(?i)(^|[^\pL\pN])` + regexp.QuoteMeta(term) + `($|[^\pL\pN])`)
+	if err != nil {
+		return -1
+	}
+	loc := pattern.FindStringIndex(text)
+	if loc == nil {
+		return -1
+	}
+	return loc[0]
+}
+
+// Route picks the character who answers text. choose asks the chat model
+// for a name and is only called in model routing; when it fails or names
+// no one, the turn passes to the next character.
+func Route(routing string, cast []*ws.Character, history []ws.ChatMessage, text string, choose func() (string, error)) *ws.Character {
+	if len(cast) == 0 {
+		return nil
+	}
+	if routing != RoutingRoundRobin {
+		if c, ok := Mentioned(cast, text); ok {
+			return c
+		}
+	}
+	if routing == RoutingModel && choose != nil {
+		if answer, err := choose(); err == nil {
+			if c, ok := Mentioned(cast, answer); ok {
+				return c
+			}
+		}
+	}
+	return Next(cast, LastSpeaker(history))
+}
+
+// DirectorPrompt instructs the chat model to pick the next speaker
+func DirectorPrompt(cast []*ws.Character) string {
+	var b strings.Builder
+	b.WriteString("You direct a group conversation between the user and these characters:\n")
+	for _, c := range cast {
+		b.WriteString("- " + c.Name)
+		if description := strings.TrimSpace(c.Description); description != "" {
+			b.WriteString(": " + description)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\nGiven the conversation so far and the user's latest message, decide which character should answer next. Reply with that character's name only.")
+	return b.String()
+}
+
+// Director is the stand-in character the chat model plays to pick a speaker
+func Director(cast []*ws.Character) *ws.Character {
+	return &ws.Character{Name: "Director", SystemPrompt: DirectorPrompt(cast)}
+}
+
+// Relationships returns the speaker's relationship lines that concern
+// another cast member: those naming it the way Mentioned would
+func Relationships(speaker, other *ws.Character, cast []*ws.Character) []string {
+	terms := addressTerms(cast)[other.ID]
+	var lines []string
+	for _, relationship := range speaker.Relationships {
+		relationship = strings.TrimSpace(relationship)
+		for _, term := range terms {
+			if indexWord(relationship, term) >= 0 {
+				lines = append(lines, relationship)
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// Context describes the scene to the speaker: who else is present, how the
+// speaker relates to each of them, and how their lines appear in history
+func Context(speaker *ws.Character, cast []*ws.Character) string {
+	var others []*ws.Character
+	for _, c := range cast {
+		if c.ID != speaker.ID {
+			others = append(others, c)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("This is a group scene. Also present: ")
+	names := make([]string, len(others))
+	for i, c := range others {
+		names[i] = c.Name
+	}
+	b.WriteString(strings.Join(names, ", ") + ".")
+
+	for _, c := range others {
+		line := "\n- " + c.Name
+		if description := strings.TrimSpace(c.Description); description != "" {
+			line += ": " + description
+		}
+		if relationships := Relationships(speaker, c, cast); len(relationships) > 0 {
+			line += "\n  Your relationship: " + strings.Join(relationships, "; ")
+		}
+		b.WriteString(line)
+	}
+
+	b.WriteString("\n\nLines spoken by the other characters start with their name. Speak only as " + speaker.Name + ", never for the others.")
+	return b.String()
+}
+
+// Transcript prepares history for the speaker: the scene context leads, its
+// own lines stay replies and the other characters' lines are labelled
+func Transcript(speaker *ws.Character, cast []*ws.Character, history []ws.ChatMessage) []ws.ChatMessage {
+	transcript := make([]ws.ChatMessage, 0, len(history)+1)
+	transcript = append(transcript, ws.ChatMessage{Sender: ws.SenderSystem, Content: Context(speaker, cast)})
+	return append(transcript, Label(cast, history, speaker.ID)...)
+}
+
+// Label turns the lines of characters other than speakerID into input
+// prefixed with the character's name. A speakerID of 0 labels every line,
+// as the director sees them.
+func Label(cast []*ws.Character, history []ws.ChatMessage, speakerID uint) []ws.ChatMessage {
+	names := make(map[uint]string, len(cast))
+	for _, c := range cast {
+		names[c.ID] = c.Name
+	}
+
+	labelled := make([]ws.ChatMessage, len(history))
+	for i, msg := range history {
+		if msg.Sender == "character" && msg.CharacterID != speakerID {
+			name := names[msg.CharacterID]
+			if name == "" {
+				name = "Another character"
+			}
+			msg.Sender = "user"
+			msg.Content = name + ": " + msg.Content
+		}
+		labelled[i] = msg
+	}
+	return labelled
+}
@@ -0,0 +1,120 @@
+package scene
+
+import (
+	"errors"
+	"testing"
+
+	"ai-agent-character-demo/backend/pkg/ws"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCast() []*ws.Character {
+	return []*ws.Character{
+		{ID: 1, Name: "Ada Lovelace", Description: "A mathematician", Relationships: []string{"Charles Babbage: collaborator and friend", "Lord Byron: her father"}},
+		{ID: 2, Name: "Charles Babbage", Description: "An inventor", Relationships: []string{"Ada: the only one who understands the engine"}},
+		{ID: 3, Name: "Charles Dickens", Description: "A novelist"},
+	}
+}
+
+func TestNextTakesTurnsInCastOrder(t *testing.T) {
+	cast := testCast()
+	assert.Equal(t, uint(1), Next(cast, 0).ID)
+	assert.Equal(t, uint(2), Next(cast, 1).ID)
+	assert.Equal(t, uint(1), Next(cast, 3).ID)
+	assert.Equal(t, uint(1), Next(cast, 42).ID)
+	assert.Nil(t, Next(nil, 1))
+}
+
+func TestLastSpeakerSkipsUserAndSystemLines(t *testing.T) {
+	history := []ws.ChatMessage{
+		{Sender: "character", CharacterID: 2},
+		{Sender: "user", CharacterID: 1},
+		{Sender: ws.SenderSystem},
+	}
+	assert.Equal(t, uint(2), LastSpeaker(history))
+	assert.Equal(t, uint(0), LastSpeaker(nil))
+}
+
+func TestMentionedMatchesNamesAndUniqueWords(t *testing.T) {
+	cast := testCast()
+
+	c, ok := Mentioned(cast, "What do you think, @ada?")
+	require.True(t, ok)
+	assert.Equal(t, uint(1), c.ID)
+
+	c, ok = Mentioned(cast, "Mr. Dickens, and then Ada")
+	require.True(t, ok)
+	assert.Equal(t, uint(3), c.ID, "the first name in the text wins")
+
+	c, ok = Mentioned(cast, "charles babbage, tell us about the engine")
+	require.True(t, ok)
+	assert.Equal(t, uint(2), c.ID)
+
+	_, ok = Mentioned(cast, "Charles, what do you say?")
+	assert.False(t, ok, "a word two characters share names no one")
+
+	_, ok = Mentioned(cast, "Adams wrote about dickensian London")
+	assert.False(t, ok, "names match whole words only")
+}
+
+func TestRoute(t *testing.T) {
+	cast := testCast()
+	history := []ws.ChatMessage{{Sender: "character", CharacterID: 1}}
+	never := func() (string, error) {
+		t.Fatal("the model was asked")
+		return "", nil
+	}
+
+	assert.Equal(t, uint(2), Route(RoutingRoundRobin, cast, history, "Dickens?", never).ID)
+	assert.Equal(t, uint(3), Route(RoutingMention, cast, history, "Dickens?", never).ID)
+	assert.Equal(t, uint(2), Route(RoutingMention, cast, history, "Anyone?", never).ID)
+	assert.Equal(t, uint(3), Route(RoutingModel, cast, history, "Dickens?", never).ID)
+
+	chosen := func() (string, error) { return "Charles Dickens should answer.", nil }
+	assert.Equal(t, uint(3), Route(RoutingModel, cast, history, "Anyone?", chosen).ID)
+
+	failed := func() (string, error) { return "", errors.New("timeout") }
+	assert.Equal(t, uint(2), Route(RoutingModel, cast, history, "Anyone?", failed).ID)
+}
+
+func TestValidRouting(t *testing.T) {
+	assert.True(t, ValidRouting(RoutingModel))
+	assert.False(t, ValidRouting(""))
+	assert.False(t, ValidRouting("random"))
+}
+
+func TestContextIncludesRelationshipsToOthers(t *testing.T) {
+	cast := testCast()
+
+	got := Context(cast[0], cast)
+	assert.Contains(t, got, "Also present: Charles Babbage, Charles Dickens.")
+	assert.Contains(t, got, "- Charles Babbage: An inventor\n  Your relationship: Charles Babbage: collaborator and friend")
+	assert.Contains(t, got, "- Charles Dickens: A novelist\n")
+	assert.NotContains(t, got, "Lord Byron")
+	assert.Contains(t, got, "Speak only as Ada Lovelace")
+
+	assert.Contains(t, Context(cast[1], cast), "Your relationship: Ada: the only one who understands the engine")
+}
+
+func TestTranscriptLabelsOtherCharacters(t *testing.T) {
+	cast := testCast()
+	history := []ws.ChatMessage{
+		{Sender: "user", Content: "Hello all"},
+		{Sender: "character", CharacterID: 2, Content: "Good day."},
+		{Sender: "character", CharacterID: 1, Content: "Hello!"},
+	}
+
+	got := Transcript(cast[0], cast, history)
+	require.Len(t, got, 4)
+	assert.Equal(t, ws.SenderSystem, got[0].Sender)
+	assert.Equal(t, history[0], got[1])
+	assert.Equal(t, "user", got[2].Sender)
+	assert.Equal(t, "Charles Babbage: Good day.", got[2].Content)
+	assert.Equal(t, history[2], got[3])
+	assert.Equal(t, "character", history[1].Sender, "history is not modified")
+
+	directed := Label(cast, history, 0)
+	assert.Equal(t, "Ada Lovelace: Hello!", directed[2].Content)
+}
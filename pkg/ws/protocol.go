@@ -0,0 +1,75 @@
+package ws
+
+import "encoding/json"
+
+// Message types of the chat protocol. The monolith's /ws and the conversation
+// service's /ws/chat speak the same protocol, so clients can use either.
+const (
+	// Client to server
+	TypeChat     = "chat"      // ChatRequest; the server replies with TypeChat carrying a ChatMessage
+	TypePing     = "ping"      // No content
+	TypeLoadMore = "load_more" // LoadMoreRequest
+
+	// Server to client
+	TypeConnected   = "connected"    // Sent once the connection is registered
+	TypeChatHistory = "chat_history" // HistoryPage with the latest messages
+	TypeHistoryPage = "history_page" // HistoryPage in reply to load_more
+	TypeAck         = "ack"          // Ack for a received chat message
+	TypeTyping      = "typing"       // Typing while a reply is generated
+	TypeReplyDelta  = "reply_delta"  // ReplyDelta while a reply streams in; the stored reply follows as TypeChat
+	TypePong        = "pong"         // No content
+	TypeError       = "error"        // Error
+)
+
+// Envelope is the frame every protocol message travels in
+type Envelope struct {
+	Type    string      `json:"type"`
+	Content interface{} `json:"content"`
+}
+
+// ChatRequest is a chat message sent by the client
+type ChatRequest struct {
+	ID        string `json:"id"`     // Optional; generated by the server when empty
+	Sender    string `json:"sender"` // Must be "user"
+	Content   string `json:"content"`
+	Timestamp int64  `json:"timestamp"` // Client time in milliseconds, informational only
+}
+
+// LoadMoreRequest asks for the page of history before a cursor
+type LoadMoreRequest struct {
+	Before string `json:"before"`
+	Limit  int    `json:"limit"`
+}
+
+// Ack confirms that a chat message was received
+type Ack struct {
+	MessageID string `json:"messageId"`
+	Status    string `json:"status"`
+}
+
+// Typing reports whether the character is composing a reply
+type Typing struct {
+	IsTyping bool `json:"is_typing"`
+}
+
+// ReplyDelta is the next piece of a character's reply. ID is the ID the
+// stored reply will carry, so clients can replace the streamed text with it.
+type ReplyDelta struct {
+	ID          string `json:"id"`
+	CharacterID uint   `json:"character_id"`
+	Delta       string `json:"delta"`
+}
+
+// Error reports a problem with the last request
+type Error struct {
+	Message string `json:"message"`
+}
+
+// DecodeContent converts an envelope's generically decoded content into a typed payload
+func DecodeContent(content interface{}, v interface{}) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -1,22 +1,69 @@
 package ws
 
 import (
+	"net/url"
 	"time"
 )
 
 // Character represents a character in the WebSocket context
 type Character struct {
-	ID          uint   `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Personality string `json:"personality"`
-	VoiceType   string `json:"voiceType"`
+	ID                 uint     `json:"id"`
+	Name               string   `json:"name"`
+	Description        string   `json:"description"`
+	Personality        string   `json:"personality"`
+	Background         string   `json:"background,omitempty"`
+	Scenario           string   `json:"scenario,omitempty"`
+	Traits             []string `json:"traits,omitempty"`
+	Goals              []string `json:"goals,omitempty"`
+	Fears              []string `json:"fears,omitempty"`
+	Relationships      []string `json:"relationships,omitempty"`
+	Greeting           string   `json:"greeting,omitempty"`
+	AlternateGreetings []string `json:"alternateGreetings,omitempty"`
+	ExampleDialogue    string   `json:"exampleDialogue,omitempty"`
+	VoiceType          string   `json:"voiceType"`
+
+	// Prompt rendered from the character's template and the version it came
+	// from; empty when the built-in prompt applies
+	SystemPrompt  string `json:"-"`
+	PromptVersion string `json:"-"`
 }
 
+// SenderSystem marks context messages that are not part of the chat itself,
+// such as the summary of earlier turns
+const SenderSystem = "system"
+
 // ChatMessage represents a message in the chat history
 type ChatMessage struct {
-	ID        string    `json:"id"`
-	Sender    string    `json:"sender"` // "user" or "character"
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
+	ID          string     `json:"id"`
+	Sender      string     `json:"sender"`                 // "user", "character" or "system"
+	CharacterID uint       `json:"character_id,omitempty"` // Character that spoke a reply; scenes have several
+	Content     string     `json:"content"`
+	Timestamp   time.Time  `json:"timestamp"`
+	AudioURL    string     `json:"audio_url,omitempty"` // Replayable voice for this message, if any
+	Citations   []Citation `json:"citations,omitempty"` // Knowledge passages a reply drew on
+
+	PromptVersion string `json:"-"` // Prompt template that produced a reply; recorded, not sent
+}
+
+// Citation identifies a knowledge passage that was given to the model for a
+// reply. Index matches the [n] marker the passage had in the prompt.
+type Citation struct {
+	Index      int     `json:"index"`
+	DocumentID uint    `json:"document_id"`
+	Title      string  `json:"title"`
+	ChunkID    uint    `json:"chunk_id"`
+	Snippet    string  `json:"snippet"`
+	Score      float64 `json:"score"`
+}
+
+// MessageAudioURL returns the replay URL for a message's voice
+func MessageAudioURL(messageID string) string {
+	return "/api/v1/audio/messages/" + url.PathEscape(messageID)
+}
+
+// HistoryPage is a window of older chat history sent in reply to load_more
+type HistoryPage struct {
+	Messages   []ChatMessage `json:"messages"`
+	PrevCursor string        `json:"prev_cursor,omitempty"` // Pass as before to load the next older page
+	HasMore    bool          `json:"has_more"`
 }